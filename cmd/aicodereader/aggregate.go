@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/output"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
+
+// digestRunes bounds the excerpt of each section the executive summary is
+// written from when the reports are too long to send whole.
+const digestRunes = 300
+
+// newAggregateCmd creates the aggregate command, which merges the reports of
+// several runs into one.
+func newAggregateCmd() *cobra.Command {
+	var (
+		out       string
+		noSummary bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "aggregate report...",
+		Short: "Merge the reports of several runs into one indexed report with an executive summary",
+		Long: `Merge Markdown reports, such as those written by --report or --append-to for
+each package of a repository, into one report: an executive summary written by
+the model, an index of the reports and their sections, then each report under
+its file name.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return aggregateReports(cmd.Context(), cmd.OutOrStdout(), args, out, !noSummary)
+		},
+	}
+
+	cmd.Flags().StringVarP(&out, "output", "o", "", "file to write the merged report to (default stdout)")
+	cmd.Flags().BoolVar(&noSummary, "no-summary", false, "merge the reports without asking the model for an executive summary")
+	return cmd
+}
+
+// aggregateReports merges the reports at paths and writes the result to out,
+// or to w if out is "" or "-". With summarize, the model writes an executive
+// summary of the reports.
+func aggregateReports(ctx context.Context, w io.Writer, paths []string, out string, summarize bool) error {
+	parts := make([]output.Part, 0, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read report: %w", err)
+		}
+		name := strings.TrimSuffix(filepath.ToSlash(path), filepath.Ext(path))
+		parts = append(parts, output.ParsePart(name, string(content)))
+	}
+
+	var summary string
+	if summarize {
+		var err error
+		if summary, err = executiveSummary(ctx, parts); err != nil {
+			return err
+		}
+	}
+
+	report := output.Aggregate(summary, parts)
+	if out == "" || out == stdinPath {
+		_, err := io.WriteString(w, report)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, []byte(report), 0644); err != nil {
+		return err
+	}
+	log.Printf("merged %d reports into %s", len(parts), out)
+	return nil
+}
+
+// executiveSummary asks the model to summarize parts, from the whole reports
+// if they fit --max-context-tokens and from a digest of each otherwise. The
+// summary is also saved like the answers of other commands.
+func executiveSummary(ctx context.Context, parts []output.Part) (string, error) {
+	provider, cfg, err := newProvider()
+	if err != nil {
+		return "", err
+	}
+
+	reports := make([]prompt.Summary, len(parts))
+	for i, part := range parts {
+		reports[i] = prompt.Summary{Name: part.Name, Text: part.Body}
+	}
+	p := prompt.BuildExecutiveSummary(reports)
+	if checkContextSize(provider, cfg, p) != nil {
+		log.Printf("the reports are too long to summarize whole; summarizing the first paragraph of each section")
+		for i, part := range parts {
+			reports[i].Text = part.Digest(digestRunes)
+		}
+		p = prompt.BuildExecutiveSummary(reports)
+		if err := checkContextSize(provider, cfg, p); err != nil {
+			return "", fmt.Errorf("executive summary: %w; merge fewer reports or use --no-summary", err)
+		}
+	}
+
+	summary, stats, err := completeWithStats(ctx, provider, cfg, p)
+	if err != nil {
+		return "", err
+	}
+	saveAnswer(newSavedAnswer(cfg, "executive summary", summary, stats))
+	return summary, nil
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/index"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+)
+
+// defaultAskChunks is the number of indexed chunks retrieved for a question.
+const defaultAskChunks = 8
+
+// newAskCmd creates the ask command, which answers a question given as
+// arguments about the selected code. Without files, a directory or piped
+// stdin, the answer is grounded in chunks retrieved from the search index.
+func newAskCmd() *cobra.Command {
+	var (
+		in        inputOptions
+		retrieve  retrieval
+		noExpand  bool
+		noSymbols bool
+		multi     bool
+		assess    bool
+		saved     savedQuery
+		questions string
+		output    string
+		noCache   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "ask <question>",
+		Short: "Ask a question about files, a directory or the indexed repository",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if saved.run != "" || questions != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			retrieve.expand = !noExpand
+			retrieve.symbols = !noSymbols
+			if multi {
+				retrieve.rewrites = multiQueryRewrites
+			}
+			if questions != "" {
+				if saved.save != "" || saved.run != "" {
+					return errors.New("--questions cannot be combined with --save or --run")
+				}
+				return askQuestions(cmd.Context(), cmd.OutOrStdout(), cmd.InOrStdin(), in, questions, retrieve, output, !noCache)
+			}
+
+			question, ok, err := saved.resolve(repomap.FindRoot("."), strings.Join(args, " "))
+			if err != nil || !ok {
+				return err
+			}
+			stdin := cmd.InOrStdin()
+			if len(in.files) == 0 && in.dir == "" {
+				piped, ok := stdinInput(stdin)
+				if !ok {
+					return askIndex(cmd.Context(), cmd.OutOrStdout(), repomap.FindRoot("."), question, retrieve, in.repoMap, assess)
+				}
+				stdin = piped
+			}
+			return in.analyze(cmd.Context(), stdin, question, nil)
+		},
+	}
+
+	addInputFlags(cmd, &in)
+	cmd.Flags().IntVarP(&retrieve.limit, "limit", "k", defaultAskChunks, "number of indexed chunks to answer from when no files are given")
+	cmd.Flags().BoolVar(&noExpand, "no-expand", false, "search the index for the question's own words only, without related identifiers and abbreviations")
+	cmd.Flags().BoolVar(&noSymbols, "no-symbols", false, "do not look up the definitions and references of identifiers the question names before searching the index")
+	cmd.Flags().BoolVar(&multi, "multi-query", false, "also search the index for reformulations of the question written by the model, and merge the results")
+	cmd.Flags().BoolVar(&assess, "confidence", false, "after answering from the index, score the answer by its citations and a check by the model, and list what could not be verified")
+	cmd.Flags().StringVar(&retrieve.rewriteModel, "rewrite-model", "", "with --multi-query, the model writing the reformulations, such as a cheaper one (default: --model)")
+	addSavedQueryFlags(cmd, &saved)
+	cmd.Flags().StringVar(&questions, "questions", "", "answer every question in this file, one per line or Markdown heading, and print a combined report")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "with --questions, file to write the report to (default stdout)")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false, "with --questions, ignore and do not update cached answers")
+	return cmd
+}
+
+// blendCandidates is how many times as many chunks as it answers from ask
+// searches for, to find documentation and code to blend when the best
+// results are all of one type.
+const blendCandidates = 4
+
+// referencesPerSymbol is the number of chunks referring to an identifier
+// named by a question retrieved with its definitions.
+const referencesPerSymbol = 3
+
+// multiQueryRewrites is the number of reformulations of a question
+// --multi-query searches for besides the question.
+const multiQueryRewrites = 3
+
+// retrieval configures how ask retrieves chunks from the search index.
+type retrieval struct {
+	// limit is the number of chunks to answer from.
+	limit int
+	// expand adds indexed identifiers related to the question's words to
+	// its keywords.
+	expand bool
+	// rewrites is the number of reformulations of the question to search
+	// for as well, none if zero.
+	rewrites int
+	// rewriteModel writes the reformulations, the configured model if empty.
+	rewriteModel string
+	// symbols looks up the identifiers a question names before searching.
+	symbols bool
+	// known holds the symbols of the repository map, loaded by loadSymbols,
+	// which questions may name without looking like identifiers.
+	known map[string]bool
+}
+
+// loadSymbols loads the symbols of the repository map of root into r.known
+// when r looks up identifiers. Without them, only words shaped like
+// identifiers are looked up, which it logs.
+func (r *retrieval) loadSymbols(root string) {
+	if !r.symbols {
+		return
+	}
+	m, err := repomap.Generate(root, repomap.Options{})
+	if err != nil {
+		log.Printf("WARNING: could not list the symbols of %s: %v", root, err)
+		return
+	}
+	r.known = make(map[string]bool)
+	for _, file := range m.Files {
+		for _, symbol := range file.Symbols {
+			r.known[symbol] = true
+		}
+	}
+}
+
+// search returns the chunks of ix most related to question. The chunks
+// defining the identifiers question names, and a few referring to them,
+// come first; the rest are searched for. With rewrites, it asks provider
+// for reformulations of question, searches for each and merges the
+// results. Without reformulations, such as when the request fails, it
+// searches for the question alone. The chunks are blended by index.Blend,
+// so answers draw on both documentation and code.
+func (r retrieval) search(ctx context.Context, provider llm.Provider, cfg config.Config, ix *index.Index, embed index.EmbedFunc, model, question string) ([]index.Result, error) {
+	found, err := r.lookup(ctx, ix, question)
+	if err != nil {
+		return nil, err
+	}
+	if r.limit > 0 && len(found) >= r.limit {
+		return found[:r.limit], nil
+	}
+	results, err := r.searchAll(ctx, provider, cfg, ix, embed, model, question)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int64]bool, len(found))
+	for _, f := range found {
+		seen[f.ID] = true
+	}
+	for _, result := range results {
+		if !seen[result.ID] {
+			found = append(found, result)
+		}
+	}
+	return index.Blend(found, r.limit), nil
+}
+
+// lookup returns the chunks defining the identifiers question names, each
+// followed by up to referencesPerSymbol chunks referring to it. Words
+// defined nowhere are not taken for identifiers.
+func (r retrieval) lookup(ctx context.Context, ix *index.Index, question string) ([]index.Result, error) {
+	if !r.symbols {
+		return nil, nil
+	}
+	var found []index.Result
+	seen := make(map[int64]bool)
+	for _, name := range index.Identifiers(question, func(name string) bool { return r.known[name] }) {
+		definitions, references, err := ix.Lookup(ctx, name, referencesPerSymbol)
+		if err != nil {
+			return nil, err
+		}
+		if len(definitions) == 0 {
+			continue
+		}
+		if opts.verbose {
+			log.Printf("found %d definitions and %d references of %s", len(definitions), len(references), name)
+		}
+		for _, result := range append(definitions, references...) {
+			if !seen[result.ID] {
+				seen[result.ID] = true
+				found = append(found, result)
+			}
+		}
+	}
+	return found, nil
+}
+
+// searchAll searches ix for question and, with rewrites, its
+// reformulations, merging the results. It returns blendCandidates times as
+// many chunks as r answers from.
+func (r retrieval) searchAll(ctx context.Context, provider llm.Provider, cfg config.Config, ix *index.Index, embed index.EmbedFunc, model, question string) ([]index.Result, error) {
+	queries := append([]string{question}, r.reformulate(ctx, provider, cfg, question)...)
+	lists := make([][]index.Result, 0, len(queries))
+	for _, query := range queries {
+		results, err := r.searchQuery(ctx, ix, embed, model, query)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, results)
+	}
+	if len(lists) == 1 {
+		return lists[0], nil
+	}
+	return index.Merge(lists, r.limit*blendCandidates), nil
+}
+
+// searchQuery returns the chunks of ix most related to query,
+// blendCandidates times as many as r answers from.
+func (r retrieval) searchQuery(ctx context.Context, ix *index.Index, embed index.EmbedFunc, model, query string) ([]index.Result, error) {
+	if !r.expand {
+		return ix.Search(ctx, embed, model, query, r.limit*blendCandidates)
+	}
+	results, added, err := ix.SearchExpanded(ctx, embed, model, query, r.limit*blendCandidates)
+	if len(added) > 0 && opts.verbose {
+		log.Printf("expanded the query %q with %s", query, strings.Join(added, ", "))
+	}
+	return results, err
+}
+
+// reformulate asks provider for r.rewrites reformulations of question with
+// the rewrite model. It returns none if rewrites is zero, while estimating,
+// or if the request fails, which it logs.
+func (r retrieval) reformulate(ctx context.Context, provider llm.Provider, cfg config.Config, question string) []string {
+	if r.rewrites <= 0 || estimating != nil {
+		return nil
+	}
+	cfg.Model = cmp.Or(r.rewriteModel, cfg.Model)
+	answer, _, err := completeWithStats(ctx, provider, cfg, prompt.BuildRewrite(question, r.rewrites))
+	if err != nil {
+		log.Printf("WARNING: could not reformulate the question, searching for it alone: %v", err)
+		return nil
+	}
+	queries := prompt.ParseRewrites(answer, question, r.rewrites)
+	if opts.verbose {
+		log.Printf("searching for %d reformulations: %s", len(queries), strings.Join(queries, " | "))
+	}
+	return queries
+}
+
+// askIndex answers question from the chunks of root's search index most
+// related to it, citing their locations, and streams the answer. The
+// retrieved locations are listed after the answer on w, followed, with
+// assess, by the confidence in the answer.
+func askIndex(ctx context.Context, w io.Writer, root, question string, retrieve retrieval, withRepoMap, assess bool) error {
+	ix, err := openIndex(root)
+	if err != nil {
+		return err
+	}
+	defer ix.Close()
+
+	provider, cfg, err := newProvider()
+	if err != nil {
+		return err
+	}
+	embed, model, err := queryEmbedderFor(provider, cfg)
+	if err != nil {
+		return err
+	}
+
+	retrieve.loadSymbols(root)
+	results, err := retrieve.search(ctx, provider, cfg, ix, embed, model, question)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("the search index of %s is empty; run \"aicodereader index\" again", root)
+	}
+
+	tree, err := directoryTree(ctx, root, nil, tokenCounter(provider, cfg))
+	if err != nil {
+		return err
+	}
+	p := prompt.WithTree(buildGroundedPrompt(question, results), tree)
+	if withRepoMap {
+		m, err := repomap.Generate(root, repomap.Options{})
+		if err != nil {
+			return err
+		}
+		p = prompt.WithRepoMap(p, m.String())
+	}
+	prompts := []prompt.Prompt{p}
+	if assess {
+		// The answer is not known yet, so the check is estimated without it
+		prompts = append(prompts, prompt.BuildSelfCheck(question, "", p.Snippets))
+	}
+	if err := confirmCost(cfg, estimatePrompts(cfg, prompts)); err != nil {
+		return err
+	}
+
+	var answer string
+	if assess {
+		defer captureAnswer(&answer)()
+	}
+	streaming := true
+	cfg.Stream = &streaming
+	if err := runPrompt(ctx, provider, cfg, p); err != nil {
+		return err
+	}
+	writeSources(w, results)
+	if assess {
+		writeAssessment(w, assessAnswer(ctx, provider, cfg, question, answer, p.Snippets))
+	}
+	return nil
+}
+
+// buildGroundedPrompt asks question about the retrieved chunks.
+func buildGroundedPrompt(question string, results []index.Result) prompt.Prompt {
+	snippets := make([]prompt.Snippet, 0, len(results))
+	for _, r := range results {
+		snippets = append(snippets, prompt.Snippet{
+			ID:            r.ID,
+			Path:          r.Path,
+			StartLine:     r.StartLine,
+			EndLine:       r.EndLine,
+			Content:       r.Lines(),
+			FileHash:      sourceHash(r),
+			Documentation: r.Documentation(),
+		})
+	}
+	return prompt.BuildGrounded(question, snippets)
+}
+
+// sourceHash is the file hash of r in the "sha256:<hex>" form of
+// output.File, empty if the index holds no record of the file.
+func sourceHash(r index.Result) string {
+	if r.FileHash == "" {
+		return ""
+	}
+	return "sha256:" + r.FileHash
+}
+
+// sourceVersion identifies the indexed version of r by its chunk ID and the
+// start of its file hash.
+func sourceVersion(r index.Result) string {
+	if r.FileHash == "" {
+		return fmt.Sprintf("#%d", r.ID)
+	}
+	return fmt.Sprintf("#%d sha256:%.12s", r.ID, r.FileHash)
+}
+
+// sourceMatch says why r was retrieved: the identifier it defines or
+// refers to, or its search score.
+func sourceMatch(r index.Result) string {
+	switch {
+	case r.Definition:
+		return "定义 " + r.Symbol
+	case r.Symbol != "":
+		return "引用 " + r.Symbol
+	}
+	return fmt.Sprintf("%.3f", r.Score)
+}
+
+// writeSources lists the locations an answer was grounded in, each labeled
+// as documentation or code, with the indexed version of each. With --json,
+// the answer's JSON lists them.
+func writeSources(w io.Writer, results []index.Result) {
+	if opts.json {
+		return
+	}
+	fmt.Fprintln(w, "----- 参考片段 -----")
+	for _, r := range results {
+		kind := prompt.Snippet{Documentation: r.Documentation()}.Kind()
+		fmt.Fprintf(w, "[%s] %s:%d-%d (%s) %s\n", kind, r.Path, r.StartLine, r.EndLine, sourceMatch(r), sourceVersion(r))
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/binsize"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
+
+// defaultSizeTop is the number of packages listed and sent to the model,
+// and twice it the number of symbols sent.
+const defaultSizeTop = 25
+
+// packageRow is a package as listed by the binsize command.
+type packageRow struct {
+	Package string  `json:"package"`
+	Size    int64   `json:"size"`
+	Share   float64 `json:"share"`
+	Symbols int     `json:"symbols"`
+}
+
+// newBinsizeCmd creates the binsize command, which breaks the size of a Go
+// binary down by package and asks the model how to shrink it.
+func newBinsizeCmd() *cobra.Command {
+	var (
+		input string
+		top   int
+		list  bool
+	)
+	cmd := &cobra.Command{
+		Use:   "binsize [binary]",
+		Short: "Break the size of a Go binary down by package and suggest how to shrink it",
+		Long: "Binsize reads the symbol sizes of a Go binary with go tool nm -size, or from --input, a saved output of " +
+			"go tool nm -size or of bloaty --csv, adds them up per package and lists the largest packages. The model " +
+			"then suggests concrete ways to shrink the binary, each for a package, from the largest packages and " +
+			"symbols; --list only lists the packages.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (len(args) == 0) == (input == "") {
+				return errors.New("binsize requires either a binary or --input")
+			}
+
+			name, symbols, err := readSymbols(cmd, args, input)
+			if err != nil {
+				return err
+			}
+			if len(symbols) == 0 {
+				return fmt.Errorf("no symbols with a size found in %s", name)
+			}
+			total := binsize.Total(symbols)
+			packages := binsize.ByPackage(symbols)
+			if err := writePackageSizes(cmd.OutOrStdout(), packages[:min(top, len(packages))], total); err != nil {
+				return err
+			}
+			if list {
+				return nil
+			}
+
+			provider, cfg, err := newProvider()
+			if err != nil {
+				return err
+			}
+			var packageSizes, symbolSizes []prompt.SizeEntry
+			for _, p := range packages[:min(top, len(packages))] {
+				packageSizes = append(packageSizes, prompt.SizeEntry{Name: p.Package, Size: p.Size})
+			}
+			for _, s := range binsize.Largest(symbols, 2*top) {
+				symbolSizes = append(symbolSizes, prompt.SizeEntry{Name: s.Name, Size: s.Size})
+			}
+			return runPrompt(cmd.Context(), provider, cfg, prompt.BuildBinarySize(name, total, packageSizes, symbolSizes))
+		},
+	}
+	cmd.Flags().StringVar(&input, "input", "", "read the sizes from this output of go tool nm -size or bloaty --csv instead of a binary (- for standard input)")
+	cmd.Flags().IntVar(&top, "top", defaultSizeTop, "number of largest packages listed and sent to the model, with twice as many of the largest symbols")
+	cmd.Flags().BoolVar(&list, "list", false, "only list the largest packages, without asking the model")
+	return cmd
+}
+
+// readSymbols reads the symbols of the binary in args with go tool nm, or
+// those in input, returning the name of the binary or input with them.
+func readSymbols(cmd *cobra.Command, args []string, input string) (string, []binsize.Symbol, error) {
+	if input != "" {
+		var r io.Reader = cmd.InOrStdin()
+		if input != "-" {
+			f, err := os.Open(input)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to read sizes: %w", err)
+			}
+			defer f.Close()
+			r = f
+		}
+		symbols, err := binsize.Parse(r)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to parse %s: %w", input, err)
+		}
+		return input, symbols, nil
+	}
+
+	binary := args[0]
+	nm := exec.CommandContext(cmd.Context(), "go", "tool", "nm", "-size", binary)
+	var stderr bytes.Buffer
+	nm.Stderr = &stderr
+	output, err := nm.Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("go tool nm failed: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	symbols, err := binsize.ParseNm(bytes.NewReader(output))
+	return binary, symbols, err
+}
+
+// writePackageSizes prints the sizes of packages and their shares of total
+// as a table, or as JSON Lines with --json.
+func writePackageSizes(w io.Writer, packages []binsize.PackageSize, total int64) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if !opts.json {
+		fmt.Fprintln(tw, "PACKAGE\tSIZE\tSHARE\tSYMBOLS")
+	}
+	for _, p := range packages {
+		row := packageRow{Package: p.Package, Size: p.Size, Symbols: p.Symbols}
+		if total > 0 {
+			row.Share = float64(p.Size) / float64(total)
+		}
+		if opts.json {
+			if err := json.NewEncoder(w).Encode(row); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f%%\t%d\n", row.Package, formatBytes(row.Size), row.Share*100, row.Symbols)
+	}
+	if !opts.json {
+		fmt.Fprintf(tw, "total\t%s\n", formatBytes(total))
+	}
+	return tw.Flush()
+}
+
+// formatBytes abbreviates a size in bytes, e.g. "1.2 MiB" or "350 KiB".
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return strconv.FormatFloat(float64(n)/(1<<20), 'f', 1, 64) + " MiB"
+	case n >= 10<<10:
+		return strconv.FormatInt(n>>10, 10) + " KiB"
+	}
+	return strconv.FormatInt(n, 10) + " B"
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/JackDrogon/aicodereader/pkgs/chunker"
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
+
+// messageOverheadTokens approximates the chat framing each message adds.
+const messageOverheadTokens = 4
+
+// partReserveTokens leaves room for the code fence and header of a part,
+// whose exact size depends on the part's content.
+const partReserveTokens = 16
+
+// tokenCounter returns a function counting tokens with the tiktoken encoding
+// of cfg.Model, falling back to the provider's estimate if the encoding
+// cannot be loaded.
+func tokenCounter(provider llm.Provider, cfg config.Config) func(text string) int {
+	tokenizer, err := chunker.TokenizerForModel(cfg.Model)
+	if err != nil {
+		return func(text string) int {
+			return provider.CountTokens([]llm.Message{{Role: llm.RoleUser, Content: text}})
+		}
+	}
+	return func(text string) int { return chunker.CountTokens(tokenizer, text) }
+}
+
+// promptTokens returns the number of tokens p takes up in a request.
+func promptTokens(count func(text string) int, p prompt.Prompt) int {
+	return count(p.System) + count(p.User) + 2*messageOverheadTokens
+}
+
+// analyzeFiles asks question about files in one request. A single file too
+// large for --max-context-tokens is analyzed in parts instead.
+func analyzeFiles(ctx context.Context, provider llm.Provider, cfg config.Config, question string, files []prompt.File) error {
+	p := prompt.WithRepoMap(prompt.Build(question, files...), repoMap)
+	if len(files) == 1 && checkContextSize(provider, cfg, p) != nil {
+		return analyzeInParts(ctx, provider, cfg, question, files[0])
+	}
+
+	return runPrompt(ctx, provider, cfg, p)
+}
+
+// analyzeInParts splits file into chunks that fit the context limit, asks
+// question about each one and then has the model merge the partial answers.
+func analyzeInParts(ctx context.Context, provider llm.Provider, cfg config.Config, question string, file prompt.File) error {
+	chunks, err := splitInParts(provider, cfg, question, file)
+	if err != nil {
+		return err
+	}
+	limit, name := contextLimit(cfg)
+	log.Printf("WARNING: %s is over %s of %d tokens, analyzing it in %d parts", file.Path, name, limit, len(chunks))
+
+	parts := make([]prompt.Part, 0, len(chunks))
+	answers := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		p, part := partPrompt(question, file, chunk, len(chunks))
+		log.Printf("analyzing part %d/%d (lines %d-%d)", part.Index, part.Total, part.StartLine, part.EndLine)
+
+		answer, err := completeText(ctx, provider, cfg, p)
+		if err != nil {
+			return fmt.Errorf("part %d/%d: %w", part.Index, part.Total, err)
+		}
+
+		parts = append(parts, part)
+		answers = append(answers, answer)
+	}
+
+	return runPrompt(ctx, provider, cfg, prompt.BuildMerge(question, file, parts, answers))
+}
+
+// splitInParts splits file into chunks whose part prompts fit the context
+// limit, overlapping by --chunk-overlap tokens.
+func splitInParts(provider llm.Provider, cfg config.Config, question string, file prompt.File) ([]chunker.Chunk, error) {
+	tokenizer, err := chunker.TokenizerForModel(cfg.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	// Whatever the instructions of the longest part prompt leave is for code
+	header := file
+	header.Content = ""
+	overhead := promptTokens(tokenCounter(provider, cfg), prompt.WithRepoMap(prompt.BuildPart(question, header, prompt.Part{
+		Index: 9999, Total: 9999, StartLine: 999999, EndLine: 999999,
+	}), repoMap))
+	limit, name := contextLimit(cfg)
+	budget := limit - overhead - partReserveTokens
+	if budget <= opts.chunkOverlap {
+		return nil, fmt.Errorf("%s: %s of %d tokens leaves no room for code after the prompt and --chunk-overlap",
+			file.Path, name, limit)
+	}
+
+	return chunker.SplitFile(file.Path, file.Content, tokenizer, chunker.Options{MaxTokens: budget, Overlap: opts.chunkOverlap})
+}
+
+// partPrompt returns the prompt asking question about chunk, one of total
+// parts of file, and the part it describes.
+func partPrompt(question string, file prompt.File, chunk chunker.Chunk, total int) (prompt.Prompt, prompt.Part) {
+	part := prompt.Part{Index: chunk.Index + 1, Total: total, StartLine: chunk.StartLine, EndLine: chunk.EndLine}
+	file.Content = chunk.Content
+	return prompt.WithRepoMap(prompt.BuildPart(question, file, part), repoMap), part
+}
+
+// completeText sends p without streaming and returns the answer text.
+func completeText(ctx context.Context, provider llm.Provider, cfg config.Config, p prompt.Prompt) (string, error) {
+	text, _, err := completeWithStats(ctx, provider, cfg, p)
+	return text, err
+}
+
+// completeWithStats is completeText that also returns the request's stats.
+func completeWithStats(ctx context.Context, provider llm.Provider, cfg config.Config, p prompt.Prompt) (string, llm.Stats, error) {
+	start := time.Now()
+	resp, err := provider.Complete(ctx, newRequest(cfg, p))
+	if err != nil {
+		return "", llm.Stats{}, err
+	}
+	return resp.Content, llm.ResponseStats(resp, start), nil
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/output"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
+
+func TestAnalyzeInParts(t *testing.T) {
+	saved := opts
+	t.Cleanup(func() { opts = saved })
+	opts.maxContextTokens = 1000
+	opts.chunkOverlap = 20
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct{ Content string } `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		requests = append(requests, body.Messages[len(body.Messages)-1].Content)
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"answer %d"}}]}`, len(requests))
+	}))
+	defer server.Close()
+
+	var content strings.Builder
+	for i := range 500 {
+		fmt.Fprintf(&content, "func f%d() int { return %d }\n", i, i)
+	}
+	file := prompt.File{Path: "big.go", Language: "Go", Content: content.String()}
+
+	provider := llm.NewOpenAIProvider("key", server.URL)
+	cfg := config.Config{Model: "gpt-4"}
+	if err := analyzeFiles(context.Background(), provider, cfg, "", []prompt.File{file}); err != nil {
+		t.Fatalf("analyzeFiles failed: %v", err)
+	}
+
+	if len(requests) < 3 {
+		t.Fatalf("Expected several part requests and a merge request, got %d requests", len(requests))
+	}
+	parts := len(requests) - 1
+	for i, request := range requests[:parts] {
+		if !strings.Contains(request, fmt.Sprintf("第 %d/%d 段", i+1, parts)) {
+			t.Errorf("Expected request %d to be part %d/%d, got %q", i, i+1, parts, request[:100])
+		}
+		if tokens := promptTokens(tokenCounter(provider, cfg), prompt.Prompt{User: request}); tokens > opts.maxContextTokens {
+			t.Errorf("Part %d is %d tokens, over the limit", i+1, tokens)
+		}
+	}
+
+	merge := requests[parts]
+	for i := range parts {
+		if !strings.Contains(merge, fmt.Sprintf("answer %d", i+1)) {
+			t.Errorf("Expected merge request to include answer %d", i+1)
+		}
+	}
+}
+
+func TestAnalyzeInPartsBudget(t *testing.T) {
+	saved := opts
+	t.Cleanup(func() { opts = saved })
+	opts.maxContextTokens = 50
+	opts.chunkOverlap = 200
+
+	file := prompt.File{Path: "big.go", Content: strings.Repeat("x := 1\n", 100)}
+	err := analyzeInParts(context.Background(), llm.NewOpenAIProvider("", ""), config.Config{}, "", file)
+	if err == nil || !strings.Contains(err.Error(), "no room") {
+		t.Errorf("Expected budget error, got %v", err)
+	}
+}
+
+func TestAskQuestions(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct{ Content string } `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		requests = append(requests, body.Messages[len(body.Messages)-1].Content)
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"answer %d"}}]}`, len(requests))
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.go")
+	questions := filepath.Join(dir, "questions.md")
+	report := filepath.Join(dir, "report.md")
+	if err := os.WriteFile(source, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(questions, []byte("- What does main do?\n- Which packages are imported?\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"ask", "--questions", questions, "-f", source, "-o", report}
+	if _, err := execute(t, args...); err != nil {
+		t.Fatalf("ask --questions failed: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("Expected one request per question, got %d", len(requests))
+	}
+	for _, request := range requests {
+		if !strings.Contains(request, "func main() {}") {
+			t.Errorf("Expected every request to include the shared file, got %q", request)
+		}
+	}
+	content, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# 问答报告", "## What does main do?", "answer 1", "## Which packages are imported?", "answer 2"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Expected the report to contain %q, got:\n%s", want, content)
+		}
+	}
+
+	// A rerun answers from the cache, and saves the answers like other
+	// commands
+	saved := filepath.Join(dir, "saved.md")
+	if _, err := execute(t, append(args, "--report", saved)...); err != nil {
+		t.Fatalf("ask --questions failed: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("Expected cached answers on a rerun, got %d requests", len(requests))
+	}
+	if content, err := os.ReadFile(saved); err != nil || !strings.Contains(string(content), "## Which packages are imported?\n\nanswer 2") {
+		t.Errorf("Expected --report to hold the answers, got %q, %v", content, err)
+	}
+	if _, err := execute(t, append(args, "--no-cache")...); err != nil {
+		t.Fatalf("ask --questions --no-cache failed: %v", err)
+	}
+	if len(requests) != 4 {
+		t.Errorf("Expected --no-cache to ask again, got %d requests", len(requests))
+	}
+
+	if _, err := execute(t, "ask", "--questions", questions, "extra"); err == nil {
+		t.Errorf("Expected --questions to take no question")
+	}
+}
+
+func TestReportAndWebhook(t *testing.T) {
+	var posted struct {
+		Answers []struct{ Source, Summary string } `json:"answers"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hook" {
+			if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+				t.Errorf("Failed to decode webhook payload: %v", err)
+			}
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"the answer"}}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.go")
+	report := filepath.Join(dir, "report.md")
+	if err := os.WriteFile(source, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := execute(t, "read", "-f", source, "--report", report, "--webhook", server.URL+"/hook")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !strings.Contains(out, "----- 最终回答 -----\nthe answer\n") {
+		t.Errorf("Expected the answer in the command's output, got %q", out)
+	}
+	content, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "## "+source+"\n\nthe answer") {
+		t.Errorf("Expected the answer in the report, got:\n%s", content)
+	}
+	if len(posted.Answers) != 1 || posted.Answers[0].Summary != "the answer" {
+		t.Errorf("Expected the answer posted to the webhook, got %+v", posted.Answers)
+	}
+}
+
+func TestCostBudget(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Three answers of gpt-4o cost about $0.03
+	_, err := execute(t, "read", "-d", dir, "--model", "gpt-4o", "--budget", "0.01")
+	if err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Fatalf("Expected the run to need --yes, got %v", err)
+	}
+	if requests != 0 {
+		t.Fatalf("Expected no requests before confirmation, got %d", requests)
+	}
+
+	if _, err := execute(t, "read", "-d", dir, "--model", "gpt-4o", "--budget", "0.01", "--yes"); err != nil {
+		t.Fatalf("read --yes failed: %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("Expected one request per file, got %d", requests)
+	}
+
+	// Models without a known price are not held back
+	if _, err := execute(t, "read", "-d", dir, "--model", "local-model", "--budget", "0.01"); err != nil {
+		t.Fatalf("read with an unpriced model failed: %v", err)
+	}
+	if requests != 6 {
+		t.Errorf("Expected one request per file, got %d", requests)
+	}
+}
+
+func TestEstimateCommand(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+
+	dir := t.TempDir()
+	var big strings.Builder
+	for i := range 500 {
+		fmt.Fprintf(&big, "func f%d() int { return %d }\n", i, i)
+	}
+	files := map[string]string{"a.go": "package main\n", "big.go": big.String()}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := execute(t, "estimate", "read", "-d", dir, "--model", "gpt-4o", "--max-context-tokens", "2000")
+	if err != nil {
+		t.Fatalf("estimate failed: %v", err)
+	}
+	if requests != 0 {
+		t.Fatalf("Expected no requests, got %d", requests)
+	}
+	fields := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if i := strings.LastIndex(line, "  "); i > 0 {
+			fields[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i:])
+		}
+	}
+	if fields["files"] != "2" || fields["parts"] == "0" || fields["model"] != "gpt-4o" || !strings.HasPrefix(fields["cost"], "~$") {
+		t.Errorf("Unexpected estimate %q", out)
+	}
+	// One request for a.go, one per part of big.go and one merging them
+	parts, _ := strconv.Atoi(fields["parts"])
+	if expected := strconv.Itoa(parts + 2); fields["requests"] != expected {
+		t.Errorf("Expected %d parts to take %s requests, got %s", parts, expected, fields["requests"])
+	}
+
+	if _, err := execute(t, "estimate", "scan", dir); err == nil {
+		t.Errorf("Expected commands sending no requests to fail")
+	}
+	if _, err := execute(t, "estimate", "bogus"); err == nil {
+		t.Errorf("Expected unknown commands to fail")
+	}
+}
+
+func TestAppendAndAggregate(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct{ Content string } `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		prompts = append(prompts, body.Messages[len(body.Messages)-1].Content)
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"answer %d"}}]}`, len(prompts))
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+
+	dir := t.TempDir()
+	cli := filepath.Join(dir, "cli.md")
+	for _, name := range []string{"main.go", "flags.go"} {
+		source := filepath.Join(dir, name)
+		if err := os.WriteFile(source, []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := execute(t, "read", "-f", source, "--append-to", cli); err != nil {
+			t.Fatalf("read --append-to failed: %v", err)
+		}
+	}
+	llmReport := filepath.Join(dir, "llm.md")
+	if err := os.WriteFile(llmReport, []byte("# 分析报告\n\n## client.go\n\nTalks to the API.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	merged := filepath.Join(dir, "all.md")
+	saved := filepath.Join(dir, "saved.md")
+	if _, err := execute(t, "aggregate", cli, llmReport, "-o", merged, "--report", saved); err != nil {
+		t.Fatalf("aggregate failed: %v", err)
+	}
+	if len(prompts) != 3 || !strings.Contains(prompts[2], "answer 1") || !strings.Contains(prompts[2], "Talks to the API.") {
+		t.Fatalf("Expected the executive summary to be asked from both reports, got %q", prompts)
+	}
+	content, err := os.ReadFile(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"## 概要\n\nanswer 3", "   - " + filepath.Join(dir, "main.go"), "   - " + filepath.Join(dir, "flags.go"), "### client.go"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Expected the merged report to contain %q, got:\n%s", want, content)
+		}
+	}
+	if content, err := os.ReadFile(saved); err != nil || !strings.Contains(string(content), "## executive summary\n\nanswer 3") {
+		t.Errorf("Expected --report to hold the executive summary, got %q, %v", content, err)
+	}
+
+	if _, err := execute(t, "aggregate", cli, llmReport, "-o", merged, "--no-summary"); err != nil {
+		t.Fatalf("aggregate --no-summary failed: %v", err)
+	}
+	if len(prompts) != 3 {
+		t.Errorf("Expected no request with --no-summary, got %d", len(prompts))
+	}
+}
+
+func TestUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}],"usage":{"prompt_tokens":1000000,"completion_tokens":100000}}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	source := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(source, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := execute(t, "read", "-f", source, "--model", "gpt-4o"); err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+	}
+
+	out, err := execute(t, "usage", "--since", "1d")
+	if err != nil {
+		t.Fatalf("usage failed: %v", err)
+	}
+	// Each run costs $2.50 for a million input and $1 for 100k output tokens
+	fields := strings.Join(strings.Fields(out), " ")
+	for _, want := range []string{"openai/gpt-4o 2 2 2000000 200000 $7.00", "read 2 2 2000000 200000 $7.00"} {
+		if !strings.Contains(fields, want) {
+			t.Errorf("Expected %q in the report, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestDocsDir(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"the answer"}}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.MkdirAll("pkg", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("pkg", "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execute(t, "read", "-f", "pkg/main.go", "--model", "m", "--docs-dir", "site"); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "site", "pkg", "main.go.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"---\ntitle: pkg/main.go\npath: pkg/main.go\nhash: sha256:", "model: m\n", "tokens:\n  input: 12\n  output: 3\n---\n\nthe answer\n"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Expected the page to contain %q, got:\n%s", want, content)
+		}
+	}
+}
+
+func TestDocsDirInParts(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"answer %d"}}]}`, requests)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+
+	var content strings.Builder
+	for i := range 500 {
+		fmt.Fprintf(&content, "func f%d() int { return %d }\n", i, i)
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile("big.go", []byte(content.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := execute(t, "read", "-f", "big.go", "--model", "gpt-4", "--max-context-tokens", "1000", "--docs-dir", "site", "--yes")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if requests < 3 {
+		t.Fatalf("Expected the file to be analyzed in parts, got %d requests", requests)
+	}
+	page, err := os.ReadFile(filepath.Join(dir, "site", "big.go.md"))
+	if err != nil {
+		t.Fatalf("Expected the merged answer's page to be named after the file: %v\n%s", err, out)
+	}
+	hash := output.NewFile("big.go", content.String()).Hash
+	for _, want := range []string{"title: big.go\npath: big.go\nhash: " + hash + "\n", fmt.Sprintf("answer %d\n", requests)} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("Expected the page to contain %q, got:\n%s", want, page)
+		}
+	}
+}
+
+func TestReportOnFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"the summary"}}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.md")
+	if err := os.WriteFile(input, []byte("# 分析报告\n\n## a.go\n\nDoes a.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The merged report cannot be written under a file, after the summary
+	// was answered
+	report := filepath.Join(dir, "report.md")
+	if _, err := execute(t, "aggregate", input, "-o", filepath.Join(input, "all.md"), "--report", report); err == nil {
+		t.Fatalf("Expected aggregate to fail")
+	}
+	content, err := os.ReadFile(report)
+	if err != nil || !strings.Contains(string(content), "the summary") {
+		t.Errorf("Expected the answers given before the failure in the report, got %q, %v", content, err)
+	}
+	if answers != nil || savedAnswers != nil {
+		t.Errorf("Expected the sinks of the failed run to be reset")
+	}
+}
+
+func TestFailedRequests(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "bad.go") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"message":"rejected","type":"invalid_request_error"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "bad.go", "c.go"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := execute(t, "read", "-f", filepath.Join(dir, "bad.go")); err == nil {
+		t.Errorf("Expected read to fail when its request failed")
+	}
+
+	// Directory runs go on past the failed file, then fail
+	requests = 0
+	_, err := execute(t, "read", "-d", dir)
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 files") {
+		t.Errorf("Expected the directory run to report the failed file, got %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("Expected every file to be requested, got %d", requests)
+	}
+}
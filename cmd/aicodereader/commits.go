@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/commitlint"
+	"github.com/JackDrogon/aicodereader/pkgs/git"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
+
+// commitsPerRequest is the number of commit messages rewritten by one
+// request.
+const commitsPerRequest = 20
+
+// commitCheck is a commit as checked by the commits command.
+type commitCheck struct {
+	Hash     string   `json:"hash"`
+	Subject  string   `json:"subject"`
+	Problems []string `json:"problems,omitempty"`
+	// Rewrite is the message suggested by the model, if asked.
+	Rewrite string `json:"rewrite,omitempty"`
+
+	message string
+}
+
+// newCommitsCmd creates the commits command, which checks commit messages
+// against Conventional Commits and suggests rewritten ones.
+func newCommitsCmd() *cobra.Command {
+	var (
+		base, todo string
+		check      bool
+	)
+	cmd := &cobra.Command{
+		Use:   "commits [revision range]",
+		Short: "Check commit messages against Conventional Commits and suggest rewritten ones",
+		Long: "Commits checks the messages of the commits of the current branch since --base, or of a revision range " +
+			"such as HEAD~5..HEAD, against Conventional Commits, skipping merge, fixup! and squash! commits, and asks " +
+			"the model to rewrite the messages that break the rules. --todo also writes a todo list for git rebase -i " +
+			"that applies the rewritten messages. With --check, for CI, the messages are only checked and the command " +
+			"fails if any breaks the rules.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if check && todo != "" {
+				return errors.New("--todo applies rewritten messages, which --check does not ask for")
+			}
+			revs := base + "..HEAD"
+			if len(args) > 0 {
+				revs = args[0]
+			}
+
+			commits, err := git.Commits(cmd.Context(), ".", revs)
+			if err != nil {
+				return err
+			}
+			var checks []commitCheck
+			var invalid []int
+			for _, c := range commits {
+				if commitlint.Skipped(c.Subject) {
+					continue
+				}
+				problems := commitlint.Check(c.Message())
+				if len(problems) > 0 {
+					invalid = append(invalid, len(checks))
+				}
+				checks = append(checks, commitCheck{Hash: c.Hash, Subject: c.Subject, Problems: problems, message: c.Message()})
+			}
+			if len(checks) == 0 {
+				return fmt.Errorf("no commits to check in %s", revs)
+			}
+
+			if !check && len(invalid) > 0 {
+				if err := rewriteCommits(cmd.Context(), checks, invalid); err != nil {
+					return err
+				}
+			}
+			if err := writeCommitChecks(cmd.OutOrStdout(), checks, len(invalid)); err != nil {
+				return err
+			}
+			if todo != "" {
+				if err := os.WriteFile(todo, []byte(rebaseTodo(commits, checks)), 0644); err != nil {
+					return fmt.Errorf("failed to write the rebase todo list: %w", err)
+				}
+			}
+			if check && len(invalid) > 0 {
+				return fmt.Errorf("%d of %d commits do not follow Conventional Commits", len(invalid), len(checks))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&base, "base", "main", "check the commits since the current branch forked from this branch, unless a revision range is given")
+	cmd.Flags().BoolVar(&check, "check", false, "only check the messages, failing if any breaks the rules, as in CI")
+	cmd.Flags().StringVar(&todo, "todo", "", "also write a git rebase -i todo list applying the rewritten messages to this file")
+	return cmd
+}
+
+// rewriteCommits asks the model for messages following the rules replacing
+// those of the checks at invalid, setting their Rewrite. Rewrites still
+// breaking the rules are dropped with a warning.
+func rewriteCommits(ctx context.Context, checks []commitCheck, invalid []int) error {
+	provider, cfg, err := newProvider()
+	if err != nil {
+		return err
+	}
+
+	var prompts []prompt.Prompt
+	for start := 0; start < len(invalid); start += commitsPerRequest {
+		batch := invalid[start:min(start+commitsPerRequest, len(invalid))]
+		messages := make([]prompt.CommitMessage, 0, len(batch))
+		for _, i := range batch {
+			// The diffstat only helps the model pick a type and scope
+			stat, _ := git.Stat(ctx, ".", checks[i].Hash)
+			messages = append(messages, prompt.CommitMessage{Message: checks[i].message, Problems: checks[i].Problems, Stat: stat})
+		}
+		prompts = append(prompts, prompt.BuildCommitRewrite(messages, commitlint.Types()))
+	}
+	if err := confirmCost(cfg, estimatePrompts(cfg, prompts)); err != nil {
+		return err
+	}
+
+	for n, p := range prompts {
+		batch := invalid[n*commitsPerRequest : min((n+1)*commitsPerRequest, len(invalid))]
+		answer, err := completeText(ctx, provider, cfg, p)
+		if err != nil {
+			return fmt.Errorf("failed to rewrite commit messages: %w", err)
+		}
+		for j, rewrite := range prompt.ParseCommitRewrite(answer, len(batch)) {
+			c := &checks[batch[j]]
+			switch problems := commitlint.Check(rewrite); {
+			case rewrite == "":
+				log.Printf("WARNING: The model did not rewrite the message of %s", shortHash(c.Hash))
+			case len(problems) > 0:
+				log.Printf("WARNING: Dropping the rewritten message of %s, which still breaks the rules: %s",
+					shortHash(c.Hash), strings.Join(problems, "; "))
+			default:
+				c.Rewrite = rewrite
+			}
+		}
+	}
+	return nil
+}
+
+// writeCommitChecks prints the checks of commits breaking the rules, with
+// their problems and rewritten messages, or that all of them follow the
+// rules. With --json every check is printed as JSON Lines.
+func writeCommitChecks(w io.Writer, checks []commitCheck, invalid int) error {
+	if opts.json {
+		encoder := json.NewEncoder(w)
+		for _, c := range checks {
+			if err := encoder.Encode(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if invalid == 0 {
+		_, err := fmt.Fprintf(w, "All %d commits follow Conventional Commits\n", len(checks))
+		return err
+	}
+	for _, c := range checks {
+		if len(c.Problems) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s %s\n", shortHash(c.Hash), c.Subject)
+		for _, problem := range c.Problems {
+			fmt.Fprintf(w, "  - %s\n", problem)
+		}
+		if c.Rewrite != "" {
+			fmt.Fprintf(w, "  建议：\n    %s\n", strings.ReplaceAll(c.Rewrite, "\n", "\n    "))
+		}
+	}
+	return nil
+}
+
+// rebaseTodo returns a todo list for git rebase -i picking commits, oldest
+// first, and amending those with a rewritten message in checks to it.
+func rebaseTodo(commits []git.Commit, checks []commitCheck) string {
+	rewrites := make(map[string]string, len(checks))
+	for _, c := range checks {
+		if c.Rewrite != "" {
+			rewrites[c.Hash] = c.Rewrite
+		}
+	}
+
+	var todo strings.Builder
+	for _, c := range commits {
+		fmt.Fprintf(&todo, "pick %s %s\n", c.Hash, c.Subject)
+		rewrite, ok := rewrites[c.Hash]
+		if !ok {
+			continue
+		}
+		// An exec line cannot span lines, so printf passes the message on
+		// line by line
+		todo.WriteString("exec printf '%s\\n'")
+		for _, line := range strings.Split(rewrite, "\n") {
+			todo.WriteString(" " + shellQuote(line))
+		}
+		todo.WriteString(" | git commit --amend --only --quiet --file=-\n")
+	}
+	return todo.String()
+}
+
+// shellQuote quotes s for sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shortHash abbreviates a commit hash the way git log --oneline does.
+func shortHash(hash string) string {
+	return hash[:min(7, len(hash))]
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/JackDrogon/aicodereader/pkgs/confidence"
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
+
+// assessAnswer scores answer, the answer to question grounded in snippets,
+// by the locations it cites and by asking the model to check it against the
+// snippets. If the check fails, the citations alone score the answer.
+func assessAnswer(ctx context.Context, provider llm.Provider, cfg config.Config, question, answer string, snippets []prompt.Snippet) confidence.Assessment {
+	sources := make([]confidence.Source, 0, len(snippets))
+	for _, s := range snippets {
+		sources = append(sources, confidence.Source{Path: s.Path, StartLine: s.StartLine, EndLine: s.EndLine})
+	}
+
+	var check *confidence.SelfCheck
+	content, _, err := completeWithStats(ctx, provider, cfg, prompt.BuildSelfCheck(question, answer, snippets))
+	if err != nil {
+		log.Printf("WARNING: the model could not check its answer, scoring its citations only: %v", err)
+	} else if score, unverified, ok := prompt.ParseSelfCheck(content); ok {
+		check = &confidence.SelfCheck{Score: score, Unverified: unverified}
+	} else {
+		log.Printf("WARNING: the model's check of its answer holds no score, scoring its citations only")
+	}
+	return confidence.Assess(answer, sources, check)
+}
+
+// writeAssessment prints the confidence band of a and how it was reached,
+// then what could not be verified, to w, or to stderr with --json to keep
+// stdout JSON.
+func writeAssessment(w io.Writer, a confidence.Assessment) {
+	if opts.json {
+		w = os.Stderr
+	}
+	fmt.Fprintln(w, "----- 置信度 -----")
+	fmt.Fprintf(w, "%s（%.2f）：引用 %d 处，其中 %d 处在检索到的片段内", a.Band, a.Score, a.Cited, a.Verified)
+	if a.Check != nil {
+		fmt.Fprintf(w, "；模型自评 %.2f", a.Check.Score)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "----- 无法核实的内容 -----")
+	if len(a.Unverified) == 0 {
+		fmt.Fprintln(w, "无")
+	}
+	for _, item := range a.Unverified {
+		fmt.Fprintf(w, "- %s\n", item)
+	}
+}
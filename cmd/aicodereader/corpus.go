@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/corpus"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+)
+
+// defaultCorpusSize is enough files to cover the usual mix of languages and
+// sizes while keeping a prompt tuning run cheap.
+const defaultCorpusSize = 30
+
+// newCorpusCmd creates the corpus command, which samples a representative
+// subset of a repository into a directory for testing prompt changes.
+func newCorpusCmd() *cobra.Command {
+	var (
+		out     string
+		size    int
+		seed    uint64
+		include string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "corpus [dir]",
+		Short: "Sample representative files of a repository into a corpus directory",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if out == "" {
+				return errors.New("an output directory is required (-o)")
+			}
+			if size <= 0 {
+				return errors.New("--size must be positive")
+			}
+			root := repomap.FindRoot(".")
+			if len(args) > 0 {
+				root = args[0]
+			}
+			if !cmd.Flags().Changed("seed") {
+				seed = uint64(time.Now().UnixNano())
+			}
+			return writeCorpus(cmd.Context(), root, out, splitPatterns(include), size, seed)
+		},
+	}
+
+	cmd.Flags().StringVarP(&out, "output", "o", "", "directory to write the corpus to; must not exist or be empty")
+	cmd.Flags().IntVarP(&size, "size", "n", defaultCorpusSize, "number of files to sample")
+	cmd.Flags().Uint64Var(&seed, "seed", 0, "random seed, to reproduce an earlier corpus (default random, recorded in the manifest)")
+	cmd.Flags().StringVar(&include, "include", "", "comma-separated glob patterns selecting files (e.g. \"*.go,*.py\")")
+	return cmd
+}
+
+// writeCorpus samples size files under root into out.
+func writeCorpus(ctx context.Context, root, out string, patterns []string, size int, seed uint64) error {
+	paths, err := listSources(ctx, root, sourceListOptions(patterns))
+	if err != nil {
+		return fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	files, err := corpus.Collect(root, paths)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no text files found in %s", root)
+	}
+
+	sample := corpus.Sample(files, size, seed)
+	if err := corpus.Write(root, out, corpus.Manifest{Seed: seed, Files: sample}); err != nil {
+		return err
+	}
+	log.Printf("sampled %d of %d files from %s into %s (seed %d)", len(sample), len(files), root, out, seed)
+	return nil
+}
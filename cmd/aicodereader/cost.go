@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+	"github.com/JackDrogon/aicodereader/pkgs/tokens"
+)
+
+// defaultBudget is the estimated cost in dollars above which batch runs need
+// --yes, unless --budget or budget in config files says otherwise.
+const defaultBudget = 1.0
+
+// estimatePrompts estimates a run sending prompts with cfg's model.
+func estimatePrompts(cfg config.Config, prompts []prompt.Prompt) tokens.Estimate {
+	count := tokens.Counter(cfg.Model)
+	var e tokens.Estimate
+	for _, p := range prompts {
+		e.Add(promptTokens(count, p))
+	}
+	return e
+}
+
+// estimateFiles estimates asking question about each of paths in turn, as
+// directory runs do. Files that cannot be read are left out, since the run
+// skips them too.
+func estimateFiles(provider llm.Provider, cfg config.Config, question string, paths []string) tokens.Estimate {
+	var e tokens.Estimate
+	for _, path := range paths {
+		files, err := readFiles([]string{path}, nil)
+		if err != nil {
+			continue
+		}
+		e.Combine(estimateAnalysis(provider, cfg, question, files))
+	}
+	return e
+}
+
+// estimateAnalysis estimates asking question about files, as analyzeFiles
+// does: in one request, or, for a single file over the context limit, in one
+// request per part and one merging their answers.
+func estimateAnalysis(provider llm.Provider, cfg config.Config, question string, files []prompt.File) tokens.Estimate {
+	count := tokens.Counter(cfg.Model)
+	e := tokens.Estimate{Files: len(files)}
+	p := prompt.WithRepoMap(prompt.Build(question, files...), repoMap)
+	if len(files) != 1 || checkContextSize(provider, cfg, p) == nil {
+		e.Add(promptTokens(count, p))
+		return e
+	}
+
+	chunks, err := splitInParts(provider, cfg, question, files[0])
+	if err != nil {
+		// The run fails before sending anything, too
+		return e
+	}
+	for _, chunk := range chunks {
+		p, _ := partPrompt(question, files[0], chunk, len(chunks))
+		e.Add(promptTokens(count, p))
+	}
+	e.Parts = len(chunks)
+	e.Add(promptTokens(count, prompt.BuildMerge(question, files[0], nil, nil)) + len(chunks)*tokens.OutputTokensPerRequest)
+	return e
+}
+
+// priceFor returns the price of cfg's model: the price in config files if
+// set, else the one in the built-in price table, if any.
+func priceFor(cfg config.Config) (tokens.Price, bool) {
+	if cfg.Price != (config.PriceConfig{}) {
+		return tokens.Price{Input: cfg.Price.Input, Output: cfg.Price.Output}, true
+	}
+	return tokens.PriceFor(cfg.Model)
+}
+
+// confirmCost logs the estimate of a batch run and, if its cost exceeds the
+// budget and --yes was not given, asks whether to go on when run from a
+// terminal, and returns an error otherwise. Single requests are not
+// batches and pass silently, and runs of models without a known price are
+// not held back. Under the estimate command, it records the estimate and
+// stops the run with errEstimated.
+func confirmCost(cfg config.Config, e tokens.Estimate) error {
+	if estimating != nil {
+		estimating.cfg, estimating.estimate, estimating.done = cfg, e, true
+		return errEstimated
+	}
+	if e.Requests <= 1 {
+		return nil
+	}
+	price, ok := priceFor(cfg)
+	if !ok {
+		log.Printf("estimated %v; the price of model %q is unknown, set price in a config file to estimate the cost", e, cfg.Model)
+		return nil
+	}
+
+	cost := e.Cost(price)
+	log.Printf("estimated %v, about $%.2f", e, cost)
+	budget := cfg.Budget
+	if budget <= 0 {
+		budget = defaultBudget
+	}
+	if cost <= budget || opts.yes {
+		return nil
+	}
+	if isTerminal(os.Stdin) && isTerminal(os.Stderr) {
+		question := fmt.Sprintf("This will send ~%s tokens (~$%.2f). Continue? [y/N] ", formatTokens(e.InputTokens), cost)
+		if askToContinue(os.Stdin, os.Stderr, question) {
+			return nil
+		}
+		return errors.New("cancelled")
+	}
+	return fmt.Errorf("the estimated cost of $%.2f exceeds the budget of $%g; rerun with --yes to proceed or raise --budget", cost, budget)
+}
+
+// askToContinue writes question to w and reports whether the answer read
+// from r is yes. Anything else, including no answer, is no.
+func askToContinue(r io.Reader, w io.Writer, question string) bool {
+	fmt.Fprint(w, question)
+	answer, _ := bufio.NewReader(r).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// formatTokens abbreviates a token count for messages, e.g. "1.2M" or
+// "350k".
+func formatTokens(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return strconv.FormatFloat(float64(n)/1e6, 'f', 1, 64) + "M"
+	case n >= 10_000:
+		return strconv.Itoa(n/1000) + "k"
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/endpoint"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+)
+
+// endpointsPerRequest is the number of endpoints annotated by one request.
+const endpointsPerRequest = 25
+
+// maxHandlerLines is the number of lines of a handler sent for annotation;
+// longer handlers are cut, as their auth and validation usually come first.
+const maxHandlerLines = 80
+
+// endpointRow is an endpoint as listed by the endpoints command.
+type endpointRow struct {
+	Method     string `json:"method"`
+	Route      string `json:"route"`
+	Handler    string `json:"handler"`
+	Definition string `json:"definition,omitempty"`
+	Registered string `json:"registered"`
+	Framework  string `json:"framework"`
+	Notes      string `json:"notes,omitempty"`
+}
+
+// newEndpointsCmd creates the endpoints command, which lists the HTTP
+// routes of a Go repository, optionally annotated by the model.
+func newEndpointsCmd() *cobra.Command {
+	var annotate, asCSV bool
+	cmd := &cobra.Command{
+		Use:   "endpoints [dir]",
+		Short: "List the HTTP routes registered with net/http, gin, echo and chi, with their handlers",
+		Long: "Endpoints finds the HTTP routes a Go repository registers with net/http, gin, echo and chi, without " +
+			"building it, and lists each route with its handler, where the handler is defined and where the route is " +
+			"registered. With --annotate the model notes how each endpoint authenticates requests and validates input.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := repomap.FindRoot(".")
+			if len(args) > 0 {
+				root = args[0]
+			}
+
+			files, err := listSources(cmd.Context(), root, sourceListOptions(nil))
+			if err != nil {
+				return fmt.Errorf("failed to scan directory: %w", err)
+			}
+			endpoints := endpoint.Detect(root, files)
+			if len(endpoints) == 0 {
+				return fmt.Errorf("no HTTP routes registered with net/http, gin, echo or chi found in %s", root)
+			}
+
+			var notes []string
+			if annotate {
+				if notes, err = annotateEndpoints(cmd.Context(), root, endpoints); err != nil {
+					return err
+				}
+			}
+			return writeEndpoints(cmd.OutOrStdout(), endpoints, notes, asCSV)
+		},
+	}
+	cmd.Flags().BoolVar(&annotate, "annotate", false, "ask the model how each endpoint authenticates requests and validates input")
+	cmd.Flags().BoolVar(&asCSV, "csv", false, "print the table as CSV, for spreadsheets")
+	return cmd
+}
+
+// annotateEndpoints asks the model for a note on the auth and validation of
+// each of endpoints, found in root, from the code of its handler.
+func annotateEndpoints(ctx context.Context, root string, endpoints []endpoint.Endpoint) ([]string, error) {
+	provider, cfg, err := newProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	var prompts []prompt.Prompt
+	for start := 0; start < len(endpoints); start += endpointsPerRequest {
+		batch := endpoints[start:min(start+endpointsPerRequest, len(endpoints))]
+		handlers := make([]prompt.EndpointHandler, 0, len(batch))
+		for _, e := range batch {
+			handlers = append(handlers, prompt.EndpointHandler{
+				Route:   e.Method + " " + e.Route,
+				Handler: e.Handler,
+				Code:    handlerCode(root, e),
+			})
+		}
+		prompts = append(prompts, prompt.BuildEndpointNotes(handlers))
+	}
+	if err := confirmCost(cfg, estimatePrompts(cfg, prompts)); err != nil {
+		return nil, err
+	}
+
+	notes := make([]string, 0, len(endpoints))
+	for i, p := range prompts {
+		answer, _, err := completeWithStats(ctx, provider, cfg, p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to annotate endpoints: %w", err)
+		}
+		n := min(endpointsPerRequest, len(endpoints)-i*endpointsPerRequest)
+		notes = append(notes, prompt.ParseEndpointNotes(answer, n)...)
+	}
+	return notes, nil
+}
+
+// handlerCode returns the source of e's handler, up to maxHandlerLines
+// lines, as a file named after its location, or an empty File if it is
+// unknown.
+func handlerCode(root string, e endpoint.Endpoint) prompt.File {
+	if e.HandlerPath == "" {
+		return prompt.File{}
+	}
+	content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(e.HandlerPath)))
+	if err != nil {
+		return prompt.File{}
+	}
+	lines := strings.Split(string(content), "\n")
+	start := min(e.HandlerLine, len(lines)) - 1
+	end := min(max(e.HandlerEndLine, e.HandlerLine), len(lines), start+maxHandlerLines)
+	name := fmt.Sprintf("%s:%d-%d", e.HandlerPath, start+1, end)
+	return prompt.NewFile(name, []byte(strings.Join(lines[start:end], "\n")))
+}
+
+// writeEndpoints prints endpoints, with the notes on them if any, as an
+// aligned table, as CSV with asCSV, or as JSON Lines with --json.
+func writeEndpoints(w io.Writer, endpoints []endpoint.Endpoint, notes []string, asCSV bool) error {
+	rows := make([]endpointRow, 0, len(endpoints))
+	for i, e := range endpoints {
+		row := endpointRow{
+			Method:     e.Method,
+			Route:      e.Route,
+			Handler:    e.Handler,
+			Definition: e.HandlerLocation(),
+			Registered: e.Location(),
+			Framework:  string(e.Framework),
+		}
+		if i < len(notes) {
+			row.Notes = notes[i]
+		}
+		rows = append(rows, row)
+	}
+
+	if opts.json {
+		encoder := json.NewEncoder(w)
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	header := []string{"METHOD", "ROUTE", "HANDLER", "DEFINED AT", "REGISTERED AT", "FRAMEWORK"}
+	if notes != nil {
+		header = append(header, "NOTES")
+	}
+	fields := func(row endpointRow) []string {
+		f := []string{row.Method, row.Route, row.Handler, row.Definition, row.Registered, row.Framework}
+		if notes != nil {
+			f = append(f, row.Notes)
+		}
+		return f
+	}
+
+	if asCSV {
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := cw.Write(fields(row)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		f := fields(row)
+		if f[3] == "" {
+			f[3] = "-"
+		}
+		fmt.Fprintln(tw, strings.Join(f, "\t"))
+	}
+	return tw.Flush()
+}
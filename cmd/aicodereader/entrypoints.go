@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/entrypoint"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+)
+
+// newEntryPointsCmd creates the entrypoints command, which lists where
+// execution of a repository likely starts without contacting a provider.
+func newEntryPointsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "entrypoints [dir]",
+		Short: "List the likely entry points of a repository",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := repomap.FindRoot(".")
+			if len(args) > 0 {
+				root = args[0]
+			}
+
+			files, err := listSources(cmd.Context(), root, sourceListOptions(nil))
+			if err != nil {
+				return fmt.Errorf("failed to scan directory: %w", err)
+			}
+			points := entrypoint.Detect(root, files)
+			if len(points) == 0 {
+				return fmt.Errorf("no entry points found in %s", root)
+			}
+			return writeEntryPoints(cmd.OutOrStdout(), points)
+		},
+	}
+}
+
+// writeEntryPoints prints points as aligned columns of location, kind and
+// detail.
+func writeEntryPoints(w io.Writer, points []entrypoint.EntryPoint) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, p := range points {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", p.Location(), p.Kind, p.Detail)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/tokens"
+)
+
+// estimating holds the estimate of the command the estimate command runs.
+// While it is set, the command stops at its cost check, and its provider
+// refuses requests.
+var estimating *runEstimate
+
+// runEstimate is the estimate of a command and the config it would run with.
+type runEstimate struct {
+	cfg      config.Config
+	estimate tokens.Estimate
+	// done is set once the command reached its cost check.
+	done bool
+}
+
+// errEstimated stops a command at its cost check once the estimate command
+// has its estimate.
+var errEstimated = errors.New("estimated")
+
+// errOffline is returned for requests made while estimating.
+var errOffline = errors.New("the command needs answers before its cost can be estimated, and estimate sends no requests")
+
+// offlineProvider refuses every request, so that estimating a command never
+// reaches the provider.
+type offlineProvider struct {
+	llm.Provider
+}
+
+// Complete implements llm.Provider.
+func (offlineProvider) Complete(context.Context, llm.Request) (llm.Response, error) {
+	return llm.Response{}, errOffline
+}
+
+// Stream implements llm.Provider.
+func (offlineProvider) Stream(context.Context, llm.Request) (llm.Stream, error) {
+	return nil, errOffline
+}
+
+// Embed implements llm.Embedder.
+func (offlineProvider) Embed(context.Context, string, []string) ([][]float32, error) {
+	return nil, errOffline
+}
+
+// newEstimateCmd creates the estimate command, which runs another command's
+// file discovery and chunking and reports the size, duration and cost of the
+// requests it would send, without sending any.
+func newEstimateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "estimate command [args...]",
+		Short: "Estimate the files, requests, tokens, time and cost of a command without running it",
+		Example: "  aicodereader estimate read -d pkgs\n" +
+			"  aicodereader estimate summarize --all",
+		Args: cobra.MinimumNArgs(1),
+		// The arguments are the estimated command's
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if args[0] == "-h" || args[0] == "--help" {
+				return cmd.Help()
+			}
+			root := cmd.Root()
+			target, _, err := root.Find(args)
+			if err != nil || target == root || target == cmd {
+				return fmt.Errorf("%q is not a command to estimate", args[0])
+			}
+
+			run, err := estimateCommand(cmd.Context(), root, args)
+			if err != nil {
+				return err
+			}
+			if !run.done {
+				return fmt.Errorf("%s sends no requests to estimate", target.Name())
+			}
+			return writeEstimate(cmd.OutOrStdout(), run)
+		},
+	}
+}
+
+// estimateCommand runs the command line args of root until the command's
+// cost check and returns its estimate.
+func estimateCommand(ctx context.Context, root *cobra.Command, args []string) (*runEstimate, error) {
+	run := &runEstimate{}
+	estimating = run
+	silence := root.SilenceErrors
+	root.SilenceErrors = true
+	defer func() {
+		estimating = nil
+		root.SilenceErrors = silence
+	}()
+
+	root.SetArgs(args)
+	if err := root.ExecuteContext(ctx); err != nil && !errors.Is(err, errEstimated) {
+		return nil, fmt.Errorf("cannot estimate %s: %w", args[0], err)
+	}
+	return run, nil
+}
+
+// writeEstimate prints the estimate of run to w. Runs under --gentle take at
+// least as long as their rate limit allows.
+func writeEstimate(w io.Writer, run *runEstimate) error {
+	e := run.estimate
+	duration := e.Duration()
+	if opts.gentle {
+		rpm := run.cfg.RateLimit.RequestsPerMinute
+		if run.cfg.RateLimit == (config.RateLimitConfig{}) {
+			rpm = defaultGentleRPM
+		}
+		if rpm > 0 {
+			duration = max(duration, time.Duration(math.Ceil(float64(e.Requests)/float64(rpm)*float64(time.Minute))))
+		}
+	}
+
+	cost := "unknown, set price in a config file"
+	if price, ok := priceFor(run.cfg); ok {
+		cost = fmt.Sprintf("~$%.2f", e.Cost(price))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "model\t%s\n", run.cfg.Model)
+	fmt.Fprintf(tw, "files\t%d\n", e.Files)
+	fmt.Fprintf(tw, "parts\t%d\n", e.Parts)
+	fmt.Fprintf(tw, "requests\t%d\n", e.Requests)
+	fmt.Fprintf(tw, "input tokens\t~%d\n", e.InputTokens)
+	fmt.Fprintf(tw, "output tokens\t~%d\n", e.OutputTokens)
+	fmt.Fprintf(tw, "time\t~%s\n", duration.Round(time.Second))
+	fmt.Fprintf(tw, "cost\t%s\n", cost)
+	return tw.Flush()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
+
+// newExplainCmd creates the explain command, which explains a standalone
+// snippet such as a regular expression without scanning any files.
+func newExplainCmd() *cobra.Command {
+	var kind string
+
+	cmd := &cobra.Command{
+		Use:   "explain --kind <kind> <snippet>",
+		Short: "Explain a regex, SQL query, shell command or cron expression",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if kind == "" {
+				return errors.New("explain requires --kind")
+			}
+
+			p, err := prompt.BuildExplain(kind, strings.Join(args, " "))
+			if err != nil {
+				return err
+			}
+
+			provider, cfg, err := newProvider()
+			if err != nil {
+				return err
+			}
+
+			return runPrompt(cmd.Context(), provider, cfg, p)
+		},
+	}
+
+	cmd.Flags().StringVar(&kind, "kind", "", "snippet kind: "+strings.Join(prompt.ExplainKinds(), ", "))
+	return cmd
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/entrypoint"
+	"github.com/JackDrogon/aicodereader/pkgs/faq"
+	"github.com/JackDrogon/aicodereader/pkgs/index"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+)
+
+// newFAQCmd creates the faq command, which answers the questions newcomers
+// usually ask about a repository and writes them to docs/FAQ.md.
+func newFAQCmd() *cobra.Command {
+	var (
+		out   string
+		limit int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "faq [dir]",
+		Short: "Generate an FAQ about a repository",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := repomap.FindRoot(".")
+			if len(args) > 0 {
+				root = args[0]
+			}
+			if out == "" {
+				out = filepath.Join(root, filepath.FromSlash(faq.DefaultPath))
+			}
+			return writeFAQ(cmd.Context(), root, out, limit)
+		},
+	}
+
+	cmd.Flags().StringVarP(&out, "output", "o", "", "file to write the FAQ to, \"-\" for stdout (default docs/FAQ.md in the repository)")
+	cmd.Flags().IntVarP(&limit, "limit", "k", defaultAskChunks, "number of indexed chunks each answer is grounded in, if the repository is indexed")
+	return cmd
+}
+
+// writeFAQ answers the FAQ questions that apply to root and writes the FAQ
+// to out. Answers are grounded in the search index when root has one, and
+// rely on the repo map alone otherwise.
+func writeFAQ(ctx context.Context, root, out string, limit int) error {
+	paths, err := listSources(ctx, root, sourceListOptions(nil))
+	if err != nil {
+		return fmt.Errorf("failed to scan directory: %w", err)
+	}
+	entryPoints := entrypoint.Format(entrypoint.Detect(root, paths))
+	for i, path := range paths {
+		if rel, err := filepath.Rel(root, path); err == nil {
+			paths[i] = filepath.ToSlash(rel)
+		}
+	}
+
+	m, err := repomap.Generate(root, repomap.Options{})
+	if err != nil {
+		return err
+	}
+
+	provider, cfg, err := newProvider()
+	if err != nil {
+		return err
+	}
+	retrieve, closeIndex := faqRetriever(ctx, root, provider, cfg, limit)
+	defer closeIndex()
+
+	questions := faq.Questions(paths)
+	var entries []faq.Entry
+	for i, question := range questions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		log.Printf("[%d/%d] %s", i+1, len(questions), question)
+		answer, stats, err := answerFAQ(ctx, provider, cfg, question, m.String(), entryPoints, retrieve)
+		if err != nil {
+			log.Printf("skipping %q: %v", question, err)
+			continue
+		}
+		saveAnswer(newSavedAnswer(cfg, question, answer, stats))
+		entries = append(entries, faq.Entry{Question: question, Answer: answer})
+	}
+	if len(entries) == 0 {
+		return errors.New("no question could be answered")
+	}
+
+	doc := faq.Markdown(entries)
+	if out == stdinPath {
+		fmt.Print(doc)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, []byte(doc), 0644); err != nil {
+		return err
+	}
+	log.Printf("wrote %d answers to %s", len(entries), out)
+	return nil
+}
+
+// faqRetriever returns a function retrieving the chunks of root's search
+// index related to a question, nil if root is not indexed, and a function
+// closing the index.
+func faqRetriever(ctx context.Context, root string, provider llm.Provider, cfg config.Config, limit int) (func(string) ([]index.Result, error), func()) {
+	ix, err := openIndex(root)
+	if err != nil {
+		log.Printf("%v; answering from the repo map only", err)
+		return nil, func() {}
+	}
+	embed, model, err := embedderFor(provider, cfg)
+	if err != nil {
+		log.Printf("%v; answering from the repo map only", err)
+		ix.Close()
+		return nil, func() {}
+	}
+
+	retrieve := func(question string) ([]index.Result, error) {
+		return ix.Search(ctx, embed, model, question, limit)
+	}
+	return retrieve, func() { ix.Close() }
+}
+
+// answerFAQ answers question from the repo map, the entry points and, if
+// retrieve is set, the chunks it finds, and returns the request's stats.
+func answerFAQ(ctx context.Context, provider llm.Provider, cfg config.Config, question, repoMap, entryPoints string, retrieve func(string) ([]index.Result, error)) (string, llm.Stats, error) {
+	p := prompt.BuildFAQ(question)
+	if retrieve != nil {
+		results, err := retrieve(question)
+		if err != nil {
+			return "", llm.Stats{}, err
+		}
+		if len(results) > 0 {
+			p = buildGroundedPrompt(question, results)
+		}
+	}
+	p = prompt.WithEntryPoints(prompt.WithRepoMap(p, repoMap), entryPoints)
+
+	if err := checkContextSize(provider, cfg, p); err != nil {
+		return "", llm.Stats{}, err
+	}
+	return completeWithStats(ctx, provider, cfg, p)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+)
+
+// defaultGentleRPM is the request rate of --gentle when no rate limit is
+// configured, low enough for the free tiers of the common providers.
+const defaultGentleRPM = 3
+
+// gentle wraps provider so its requests stay under limit, or under
+// defaultGentleRPM if no limit is configured, counting down on stderr while
+// it waits.
+func gentle(provider llm.Provider, limit config.RateLimitConfig) llm.Provider {
+	if limit == (config.RateLimitConfig{}) {
+		limit.RequestsPerMinute = defaultGentleRPM
+	}
+	return llm.RateLimited(provider, llm.RateLimit{
+		RequestsPerMinute: limit.RequestsPerMinute,
+		TokensPerMinute:   limit.TokensPerMinute,
+	}, countdown(os.Stderr))
+}
+
+// countdown returns a WaitFunc that shows the seconds left on w, redrawing
+// one line each second if w is a terminal and logging the wait once
+// otherwise.
+func countdown(w *os.File) llm.WaitFunc {
+	return func(ctx context.Context, d time.Duration) error {
+		if !isTerminal(w) {
+			log.Printf("waiting %s for the rate limit", d.Round(time.Second))
+			return llm.Sleep(ctx, d)
+		}
+
+		defer fmt.Fprint(w, "\r\033[K")
+		deadline := time.Now().Add(d)
+		for left := time.Until(deadline); left > 0; left = time.Until(deadline) {
+			seconds := math.Ceil(left.Seconds())
+			fmt.Fprintf(w, "\rwaiting %.0fs for the rate limit", seconds)
+			// Sleep to the next whole second, so the count stays even
+			if err := llm.Sleep(ctx, left-time.Duration(seconds-1)*time.Second); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
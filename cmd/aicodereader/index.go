@@ -0,0 +1,401 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/chunker"
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/index"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+)
+
+// searchPreviewLines is the number of lines of each search result printed.
+const searchPreviewLines = 5
+
+// newIndexCmd creates the index command, which embeds a repository's code
+// for the search command. Re-runs only embed files that changed.
+func newIndexCmd() *cobra.Command {
+	var (
+		include string
+		prune   bool
+		noCache bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "index [dir]",
+		Short: "Build or update the semantic search index of a repository",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := repomap.FindRoot(".")
+			if len(args) > 0 {
+				root = args[0]
+			}
+			if prune {
+				return pruneIndex(cmd.Context(), root, splitPatterns(include))
+			}
+			return buildIndex(cmd.Context(), root, splitPatterns(include), !noCache)
+		},
+	}
+
+	cmd.Flags().StringVar(&include, "include", "", "comma-separated glob patterns selecting files (e.g. \"*.go,*.py\")")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false, "embed every changed chunk again instead of reusing cached embeddings")
+	cmd.Flags().BoolVar(&prune, "prune", false, "only drop deleted or excluded files from the index and compact it, without embedding (same as index gc)")
+	cmd.AddCommand(newIndexGCCmd(), newIndexExportCmd(), newIndexImportCmd())
+	return cmd
+}
+
+// newIndexGCCmd creates the index gc command, which drops files that were
+// deleted or are now ignored or excluded from a search index and compacts it,
+// without contacting a provider.
+func newIndexGCCmd() *cobra.Command {
+	var include string
+
+	cmd := &cobra.Command{
+		Use:   "gc [dir]",
+		Short: "Drop deleted and newly ignored files from the search index and compact it",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := repomap.FindRoot(".")
+			if len(args) > 0 {
+				root = args[0]
+			}
+			return pruneIndex(cmd.Context(), root, splitPatterns(include))
+		},
+	}
+
+	cmd.Flags().StringVar(&include, "include", "", "comma-separated glob patterns selecting the files to keep (e.g. \"*.go,*.py\")")
+	return cmd
+}
+
+// newIndexExportCmd creates the index export command, which writes the search
+// index of a repository to an archive, so CI can build it once per commit
+// and other jobs import it instead of embedding the repository again.
+func newIndexExportCmd() *cobra.Command {
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:     "export <file>",
+		Short:   "Write the search index to a .tar.gz archive, - for stdout",
+		Example: "  aicodereader index export index.tar.gz\n  aicodereader index export - | zstd > index.tar.zst",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exportIndex(cmd.Context(), cmd.OutOrStdout(), cmp.Or(dir, repomap.FindRoot(".")), args[0])
+		},
+	}
+
+	cmd.Flags().StringVarP(&dir, "dir", "d", "", "indexed directory (default: the repository root)")
+	return cmd
+}
+
+// newIndexImportCmd creates the index import command, which replaces the
+// search index of a repository with one written by index export.
+func newIndexImportCmd() *cobra.Command {
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:     "import <file>",
+		Short:   "Replace the search index with one from index export, - for stdin",
+		Example: "  aicodereader index import index.tar.gz\n  zstd -dc index.tar.zst | aicodereader index import -",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return importIndex(cmd.Context(), cmd.InOrStdin(), cmp.Or(dir, repomap.FindRoot(".")), args[0])
+		},
+	}
+
+	cmd.Flags().StringVarP(&dir, "dir", "d", "", "directory to import the index for (default: the repository root)")
+	return cmd
+}
+
+// checkArchiveName rejects zstd archives, which this build cannot compress
+// or decompress without a zstd library.
+func checkArchiveName(file string) error {
+	if strings.HasSuffix(file, ".zst") {
+		return fmt.Errorf("%s: zstd is not supported, index archives are .tar.gz; use - and pipe through zstd instead", file)
+	}
+	return nil
+}
+
+// exportIndex writes the search index of root to file, or to w if file is -.
+func exportIndex(ctx context.Context, w io.Writer, root, file string) error {
+	if err := checkArchiveName(file); err != nil {
+		return err
+	}
+	ix, err := openIndex(root)
+	if err != nil {
+		return err
+	}
+	defer ix.Close()
+
+	if file == "-" {
+		return ix.Export(ctx, w)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(ix.Export(ctx, f), f.Close()); err != nil {
+		os.Remove(file)
+		return err
+	}
+	log.Printf("exported the index of %s to %s", root, file)
+	return nil
+}
+
+// importIndex replaces the search index of root with the archive in file,
+// or in r if file is -.
+func importIndex(ctx context.Context, r io.Reader, root, file string) error {
+	if err := checkArchiveName(file); err != nil {
+		return err
+	}
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	path, err := index.DefaultPath(root)
+	if err != nil {
+		return err
+	}
+	model, err := index.Import(ctx, r, path)
+	if err != nil {
+		return err
+	}
+	log.Printf("imported an index embedded with %s for %s; run \"aicodereader index\" to embed files changed since", model, root)
+	return nil
+}
+
+// newSearchCmd creates the search command, which finds the indexed code most
+// related to a query.
+func newSearchCmd() *cobra.Command {
+	var (
+		dir   string
+		limit int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Find the code most related to a query in the search index",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmp.Or(dir, repomap.FindRoot("."))
+			return searchIndex(cmd.Context(), cmd.OutOrStdout(), root, strings.Join(args, " "), limit)
+		},
+	}
+
+	cmd.Flags().StringVarP(&dir, "dir", "d", "", "indexed directory (default: the repository root)")
+	cmd.Flags().IntVarP(&limit, "limit", "k", 10, "number of results to show")
+	return cmd
+}
+
+// newEmbedder creates an embedding function for the configured provider and
+// returns it with the embedding model's name.
+func newEmbedder() (index.EmbedFunc, string, error) {
+	provider, cfg, err := newProvider()
+	if err != nil {
+		return nil, "", err
+	}
+	return embedderFor(provider, cfg)
+}
+
+// embedderFor returns an embedding function using provider and the
+// configured embedding model, and the model's name.
+func embedderFor(provider llm.Provider, cfg config.Config) (index.EmbedFunc, string, error) {
+	embedder, ok := provider.(llm.Embedder)
+	if !ok {
+		return nil, "", fmt.Errorf("provider %q does not support embeddings", cfg.Provider)
+	}
+
+	model := cmp.Or(cfg.EmbeddingModel, index.DefaultEmbeddingModel)
+	embed := func(ctx context.Context, texts []string) ([][]float32, error) {
+		return embedder.Embed(ctx, model, texts)
+	}
+	return embed, model, nil
+}
+
+// queryEmbedderFor is embedderFor for search queries. While estimating,
+// queries are not embedded, so searches rank chunks by keywords alone and the
+// estimate covers chunks like the ones a real search retrieves.
+func queryEmbedderFor(provider llm.Provider, cfg config.Config) (index.EmbedFunc, string, error) {
+	embed, model, err := embedderFor(provider, cfg)
+	if err != nil || estimating == nil {
+		return embed, model, err
+	}
+	return func(_ context.Context, texts []string) ([][]float32, error) {
+		return make([][]float32, len(texts)), nil
+	}, model, nil
+}
+
+// indexFiles lists the files under root that belong in its search index, and
+// reports whether the list is partial: capped by --max-files or --max-depth,
+// or sampled by --sample.
+func indexFiles(ctx context.Context, root string, patterns []string) ([]string, bool, error) {
+	options := sourceListOptions(patterns)
+	files, truncated, err := scanSources(ctx, root, options)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to scan directory: %w", err)
+	}
+	return files, truncated || options.MaxDepth > 0 || options.Sample != "", nil
+}
+
+// buildIndex brings the search index of root up to date, embedding added
+// and modified files. With useCache, chunks embedded before with the same
+// model are taken from the embedding cache.
+func buildIndex(ctx context.Context, root string, patterns []string, useCache bool) error {
+	files, partial, err := indexFiles(ctx, root, patterns)
+	if err != nil {
+		return err
+	}
+	if partial {
+		log.Printf("WARNING: indexing part of %s as limited by --max-files, --max-depth or --sample; indexed files left out are kept", root)
+	}
+
+	embed, model, err := newEmbedder()
+	if err != nil {
+		return err
+	}
+	tokenizer, err := chunker.TokenizerForModel(model)
+	if err != nil {
+		return err
+	}
+
+	path, err := index.DefaultPath(root)
+	if err != nil {
+		return err
+	}
+	ix, err := index.Create(path)
+	if err != nil {
+		return err
+	}
+	defer ix.Close()
+
+	var cache *index.Cache
+	if useCache {
+		if cache, err = openEmbeddingCache(); err != nil {
+			log.Printf("WARNING: %v; embedding every changed chunk", err)
+		} else {
+			defer cache.Close()
+		}
+	}
+
+	log.Printf("checking %d files in %s for changes", len(files), root)
+	b := &index.Builder{
+		Embed:     embed,
+		Model:     model,
+		Tokenizer: tokenizer,
+		Progress:  func(done, total int) { log.Printf("embedded %d/%d chunks", done, total) },
+		OnSkip:    func(path string, err error) { log.Printf("skipping %s: %v", path, err) },
+		Partial:   partial,
+		Cache:     cache,
+	}
+	stats, err := b.Build(ctx, ix, root, files)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%d added, %d updated, %d removed, %d unchanged, %d skipped; embedded %d chunks (%d from cache) with %s into %s",
+		stats.Added, stats.Updated, stats.Removed, stats.Unchanged, stats.Skipped, stats.Chunks, stats.Cached, model, path)
+	return nil
+}
+
+// openEmbeddingCache opens the embedding cache in the per-user cache
+// directory.
+func openEmbeddingCache() (*index.Cache, error) {
+	path, err := index.DefaultCachePath()
+	if err != nil {
+		return nil, err
+	}
+	return index.OpenCache(path)
+}
+
+// pruneIndex drops files that are gone or no longer selected from the
+// search index of root, without contacting a provider. Every file is
+// scanned, whatever --max-files, --max-depth and --sample say, as a partial
+// list would drop the files it leaves out.
+func pruneIndex(ctx context.Context, root string, patterns []string) error {
+	options := sourceListOptions(patterns)
+	options.MaxFiles, options.MaxDepth, options.Sample = 0, 0, ""
+	files, truncated, err := scanSources(ctx, root, options)
+	if err != nil {
+		return fmt.Errorf("failed to scan directory: %w", err)
+	}
+	if truncated {
+		return fmt.Errorf("the scan of %s is partial, refusing to prune its index", root)
+	}
+
+	ix, err := openIndex(root)
+	if err != nil {
+		return err
+	}
+	defer ix.Close()
+
+	removed, err := ix.Prune(ctx, root, files)
+	if err != nil {
+		return err
+	}
+	log.Printf("pruned %d files from the index of %s", removed, root)
+	return nil
+}
+
+// searchIndex writes the results of query against root's search index to w.
+func searchIndex(ctx context.Context, w io.Writer, root, query string, limit int) error {
+	ix, err := openIndex(root)
+	if err != nil {
+		return err
+	}
+	defer ix.Close()
+
+	embed, model, err := newEmbedder()
+	if err != nil {
+		return err
+	}
+
+	results, err := ix.Search(ctx, embed, model, query, limit)
+	if err != nil {
+		return err
+	}
+	writeSearchResults(w, results)
+	return nil
+}
+
+// openIndex opens the search index of root.
+func openIndex(root string) (*index.Index, error) {
+	path, err := index.DefaultPath(root)
+	if err != nil {
+		return nil, err
+	}
+	ix, err := index.Open(path)
+	if errors.Is(err, index.ErrNotFound) {
+		return nil, fmt.Errorf("%s has no search index; run \"aicodereader index\" first", root)
+	}
+	return ix, err
+}
+
+// writeSearchResults prints each result's location and score followed by
+// the first lines of the matching code.
+func writeSearchResults(w io.Writer, results []index.Result) {
+	for i, r := range results {
+		fmt.Fprintf(w, "%d. %s:%d-%d (%.3f)\n", i+1, r.Path, r.StartLine, r.EndLine, r.Score)
+
+		lines := strings.Split(strings.TrimRight(r.Lines(), "\n"), "\n")
+		for _, line := range lines[:min(len(lines), searchPreviewLines)] {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+		if len(lines) > searchPreviewLines {
+			fmt.Fprintln(w, "    ...")
+		}
+	}
+}
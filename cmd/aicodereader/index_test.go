@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/JackDrogon/aicodereader/pkgs/chunker"
+	"github.com/JackDrogon/aicodereader/pkgs/index"
+)
+
+func TestWriteSearchResults(t *testing.T) {
+	var out bytes.Buffer
+	writeSearchResults(&out, []index.Result{
+		{Path: "a.go", StartLine: 3, EndLine: 4, Content: "package a\n\nfunc A() {\n}\n", Score: 0.5},
+		{Path: "b.txt", StartLine: 1, EndLine: 7, Content: "1\n2\n3\n4\n5\n6\n7\n", Score: 0.25},
+	})
+
+	expected := "1. a.go:3-4 (0.500)\n    func A() {\n    }\n" +
+		"2. b.txt:1-7 (0.250)\n    1\n    2\n    3\n    4\n    5\n    ...\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestBuildGroundedPrompt(t *testing.T) {
+	p := buildGroundedPrompt("where?", []index.Result{
+		{Path: "a.go", StartLine: 3, EndLine: 3, Content: "package a\n\nfunc A() {}\n"},
+	})
+	if len(p.Files) != 1 || p.Files[0].Path != "a.go:3-3" || p.Files[0].Content != "func A() {}\n" {
+		t.Errorf("Expected the retrieved lines labeled with their location, got %+v", p.Files)
+	}
+}
+
+func TestIndexGCIgnoresScanLimits(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	root := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte("package "+strings.TrimSuffix(name, ".go")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	path, err := index.DefaultPath(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix, err := index.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embed := func(_ context.Context, texts []string) ([][]float32, error) {
+		vectors := make([][]float32, len(texts))
+		for i := range vectors {
+			vectors[i] = []float32{1}
+		}
+		return vectors, nil
+	}
+	tokenizer, err := chunker.TokenizerForEncoding(chunker.DefaultEncoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &index.Builder{Embed: embed, Model: "m", Tokenizer: tokenizer}
+	if _, err := b.Build(context.Background(), ix, root, files); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	ix.Close()
+
+	if err := os.Remove(files[2]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute(t, "index", "gc", root, "--max-files", "1"); err != nil {
+		t.Fatalf("index gc failed: %v", err)
+	}
+
+	if ix, err = index.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	results, err := ix.Search(context.Background(), embed, "m", "package", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, r := range results {
+		paths = append(paths, r.Path)
+	}
+	sort.Strings(paths)
+	if !slices.Equal(paths, []string{"a.go", "b.go"}) {
+		t.Errorf("Expected only the deleted file pruned, got %v", paths)
+	}
+}
+
+func TestEstimateAskIndex(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	root := t.TempDir()
+	path := filepath.Join(root, "a.go")
+	if err := os.WriteFile(path, []byte("package a\n\nfunc Parse() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestIndex(t, root, []string{path})
+
+	t.Chdir(root)
+	out, err := execute(t, "estimate", "ask", "where is Parse?", "--model", "gpt-4o")
+	if err != nil {
+		t.Fatalf("estimate ask failed: %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no requests, got %d", requests)
+	}
+	fields := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if name, value, ok := strings.Cut(line, "  "); ok {
+			fields[name] = strings.TrimSpace(value)
+		}
+	}
+	if fields["requests"] != "1" || !strings.HasPrefix(fields["cost"], "~$") {
+		t.Errorf("Unexpected estimate %q", out)
+	}
+}
+
+// writeTestIndex builds the search index of root from files, embedding
+// every chunk as an empty vector with the default model.
+func writeTestIndex(t *testing.T, root string, files []string) {
+	t.Helper()
+	path, err := index.DefaultPath(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix, err := index.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	embed := func(_ context.Context, texts []string) ([][]float32, error) {
+		return make([][]float32, len(texts)), nil
+	}
+	tokenizer, err := chunker.TokenizerForEncoding(chunker.DefaultEncoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &index.Builder{Embed: embed, Model: index.DefaultEmbeddingModel, Tokenizer: tokenizer}
+	if _, err := b.Build(context.Background(), ix, root, files); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+}
+
+func TestIndexExportImport(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	root := t.TempDir()
+	path := filepath.Join(root, "a.go")
+	if err := os.WriteFile(path, []byte("package a\n\nfunc Parse() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeTestIndex(t, root, []string{path})
+
+	archive := filepath.Join(t.TempDir(), "index.tar.gz")
+	if _, err := execute(t, "index", "export", archive, "-d", root); err != nil {
+		t.Fatalf("index export failed: %v", err)
+	}
+	if _, err := execute(t, "index", "export", archive+".zst", "-d", root); err == nil || !strings.Contains(err.Error(), "zstd") {
+		t.Errorf("Expected zstd archives to be rejected, got %v", err)
+	}
+
+	// Another checkout of the repository
+	other := t.TempDir()
+	if _, err := execute(t, "index", "import", archive, "-d", other); err != nil {
+		t.Fatalf("index import failed: %v", err)
+	}
+	ix, err := openIndex(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	results, err := ix.Search(context.Background(), func(_ context.Context, texts []string) ([][]float32, error) {
+		return make([][]float32, len(texts)), nil
+	}, index.DefaultEmbeddingModel, "Parse", 1)
+	if err != nil || len(results) != 1 || results[0].Path != "a.go" {
+		t.Errorf("Expected the imported index to hold a.go, got %+v, %v", results, err)
+	}
+}
+
+func TestAskMultiQuery(t *testing.T) {
+	var embedded []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input  []string `json:"input"`
+			Stream bool     `json:"stream"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/embeddings"):
+			embedded = append(embedded, req.Input...)
+			fmt.Fprint(w, `{"data":[{"index":0,"embedding":[1]}]}`)
+		case req.Stream:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"the answer\"}}]}\n\ndata: [DONE]\n\n")
+		default:
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"1. where is Parse defined\n2. Parse function"}}]}`)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	root := t.TempDir()
+	path := filepath.Join(root, "a.go")
+	if err := os.WriteFile(path, []byte("package a\n\nfunc Parse() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeTestIndex(t, root, []string{path})
+	t.Chdir(root)
+
+	out, err := execute(t, "ask", "how is input parsed?", "--multi-query")
+	if err != nil {
+		t.Fatalf("ask failed: %v", err)
+	}
+	expected := []string{"how is input parsed?", "where is Parse defined", "Parse function"}
+	if !slices.Equal(embedded, expected) {
+		t.Errorf("Expected the question and its reformulations to be searched, got %q", embedded)
+	}
+	// Found by all three searches
+	if !strings.Contains(out, "a.go:3-3 (0.049)") {
+		t.Errorf("Expected the merged sources to be listed, got %q", out)
+	}
+}
+
+func TestAskConfidence(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Stream bool `json:"stream"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/embeddings"):
+			fmt.Fprint(w, `{"data":[{"index":0,"embedding":[1]}]}`)
+		case req.Stream:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Parse is in a.go:3, called from b.go:9\"}}]}\n\ndata: [DONE]\n\n")
+		default:
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"置信度: 80\n- Parse 会校验输入"}}]}`)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	root := t.TempDir()
+	path := filepath.Join(root, "a.go")
+	if err := os.WriteFile(path, []byte("package a\n\nfunc Parse() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeTestIndex(t, root, []string{path})
+	t.Chdir(root)
+
+	out, err := execute(t, "ask", "how is input parsed?", "--confidence")
+	if err != nil {
+		t.Fatalf("ask failed: %v", err)
+	}
+	// One of two citations retrieved, averaged with the model's 0.8
+	for _, expected := range []string{
+		"中（0.65）：引用 2 处，其中 1 处在检索到的片段内；模型自评 0.80",
+		"- 引用的 b.go:9 不在检索到的片段中",
+		"- Parse 会校验输入",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in the output, got %q", expected, out)
+		}
+	}
+}
+
+func TestAskSymbols(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/embeddings") {
+			fmt.Fprint(w, `{"data":[{"index":0,"embedding":[1]}]}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Parse is in a.go:3\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	root := t.TempDir()
+	var paths []string
+	for name, content := range map[string]string{
+		"a.go": "package a\n\nfunc Parse() {}\n",
+		"b.go": "package a\n\nfunc run() { Parse() }\n",
+		"c.go": "package a\n\nfunc Other() {}\n",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	writeTestIndex(t, root, paths)
+	t.Chdir(root)
+
+	out, err := execute(t, "ask", "what does Parse do?", "-k", "2")
+	if err != nil {
+		t.Fatalf("ask failed: %v", err)
+	}
+	if !strings.Contains(out, "----- 参考片段 -----\n[代码] a.go:3-3 (定义 Parse) ") || !strings.Contains(out, "\n[代码] b.go:3-3 (引用 Parse) ") {
+		t.Errorf("Expected the definition and reference of Parse as sources, got %q", out)
+	}
+
+	out, err = execute(t, "ask", "what does Parse do?", "-k", "3", "--no-symbols")
+	if err != nil {
+		t.Fatalf("ask --no-symbols failed: %v", err)
+	}
+	if strings.Contains(out, "定义") || strings.Count(out, ".go:3-3 (0.") != 3 {
+		t.Errorf("Expected searched sources only, got %q", out)
+	}
+}
+
+func TestAskDocumentation(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/embeddings") {
+			fmt.Fprint(w, `{"data":[{"index":0,"embedding":[1]}]}`)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"See a.go:3-3（代码）\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	root := t.TempDir()
+	var paths []string
+	for name, content := range map[string]string{
+		"a.go":      "package a\n\nfunc A() {}\n",
+		"b.go":      "package a\n\nfunc B() {}\n",
+		"c.go":      "package a\n\nfunc C() {}\n",
+		"d.go":      "package a\n\nfunc D() {}\n",
+		"README.md": "# Usage\n\nRun the tool.\n",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	writeTestIndex(t, root, paths)
+	t.Chdir(root)
+
+	// Equal embeddings rank the files alike, so documentation gets its share
+	out, err := execute(t, "ask", "how does it work", "-k", "4", "--no-symbols")
+	if err != nil {
+		t.Fatalf("ask failed: %v", err)
+	}
+	if !strings.Contains(out, "\n[文档] README.md:1-3 (") || strings.Count(out, "\n[代码] ") != 3 {
+		t.Errorf("Expected documentation and code sources labeled by type, got %q", out)
+	}
+	if len(requests) != 1 || !strings.Contains(requests[0], "类型: 文档") || !strings.Contains(requests[0], "类型: 代码") {
+		t.Errorf("Expected snippets labeled by type in the prompt, got %q", requests)
+	}
+}
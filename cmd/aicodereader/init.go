@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/keyring"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+)
+
+// keyringService is the service API keys are stored under in the system
+// keyring, one per provider.
+const keyringService = "aicodereader"
+
+// initProviders lists the providers init offers, with the model it suggests
+// for each. Azure models are deployments, which have no common name.
+var initProviders = []struct{ name, model string }{
+	{llm.ProviderOpenAI, "gpt-4o-mini"},
+	{llm.ProviderAnthropic, "claude-sonnet-4-5"},
+	{llm.ProviderGemini, "gemini-2.5-flash"},
+	{llm.ProviderAzure, ""},
+}
+
+// providerName returns the provider cfg selects.
+func providerName(cfg config.Config) string {
+	return cmp.Or(cfg.Provider, llm.ProviderOpenAI)
+}
+
+// initSettings are the settings init writes to the user config file.
+type initSettings struct {
+	Provider string `yaml:"provider"`
+	Model    string `yaml:"model,omitempty"`
+	BaseURL  string `yaml:"base_url,omitempty"`
+	APIKey   string `yaml:"api_key,omitempty"`
+	Keyring  bool   `yaml:"keyring,omitempty"`
+}
+
+func newInitCmd() *cobra.Command {
+	var force, noKeyring bool
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Set up the provider, API key and default model interactively",
+		Long: "Init asks for the provider, its API key and the default model, stores the key in the system keyring " +
+			"and writes the rest to the user config file, ~/.config/aicodereader/config.yaml. It then offers to write " +
+			"the provider and model, without the key, to " + config.ProjectFileName + " at the root of the current " +
+			"repository, to share them with everyone working on it.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			w := wizard{in: bufio.NewReader(cmd.InOrStdin()), out: cmd.OutOrStdout(), hide: hideInput(cmd.InOrStdin())}
+			return runInit(w, repomap.FindRoot("."), force, noKeyring)
+		},
+	}
+	cmd.Flags().BoolVar(&force, "force", false, "replace existing config files")
+	cmd.Flags().BoolVar(&noKeyring, "no-keyring", false, "write the API key to the user config file instead of the system keyring")
+	return cmd
+}
+
+// runInit asks w for the settings and writes the config files: the user
+// one, and the project one at root if asked to.
+func runInit(w wizard, root string, force, noKeyring bool) error {
+	userPath := config.UserFilePath()
+	if userPath == "" {
+		return errors.New("no home directory to write the user config file to")
+	}
+	if _, err := os.Stat(userPath); err == nil && !force {
+		return fmt.Errorf("%s already exists; rerun with --force to replace it", userPath)
+	}
+
+	names := make([]string, 0, len(initProviders))
+	for _, p := range initProviders {
+		names = append(names, p.name)
+	}
+	provider, err := w.ask("Provider ("+strings.Join(names, ", ")+")", llm.ProviderOpenAI)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(initProviders, func(p struct{ name, model string }) bool { return p.name == provider })
+	if i < 0 {
+		return fmt.Errorf("unknown provider %q, expected one of %s", provider, strings.Join(names, ", "))
+	}
+	settings := initSettings{Provider: provider}
+
+	baseURLQuestion := "Base URL (leave empty for the provider's own)"
+	if provider == llm.ProviderAzure {
+		baseURLQuestion = "Resource endpoint, such as https://my-resource.openai.azure.com"
+	}
+	if settings.BaseURL, err = w.ask(baseURLQuestion, ""); err != nil {
+		return err
+	}
+
+	key, err := w.askSecret("API key (leave empty to keep reading it from the environment)")
+	if err != nil {
+		return err
+	}
+	if key != "" {
+		settings.Keyring = !noKeyring
+		if settings.Keyring {
+			if err := keyring.Set(keyringService, provider, key); err != nil {
+				log.Printf("WARNING: could not store the API key in the system keyring, writing it to %s instead: %v", userPath, err)
+				settings.Keyring = false
+			}
+		}
+		if !settings.Keyring {
+			settings.APIKey = key
+		}
+	}
+
+	modelQuestion := "Default model"
+	if provider == llm.ProviderAzure {
+		modelQuestion = "Deployment name"
+	}
+	if settings.Model, err = w.ask(modelQuestion, initProviders[i].model); err != nil {
+		return err
+	}
+
+	// The file may hold the key, so only the user may read it
+	if err := writeYAML(userPath, settings, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(w.out, "Wrote %s\n", userPath)
+
+	if _, err := os.Stat(filepath.Join(root, ".git")); err != nil {
+		return nil
+	}
+	projectPath := filepath.Join(root, config.ProjectFileName)
+	if _, err := os.Stat(projectPath); err == nil && !force {
+		fmt.Fprintf(w.out, "Kept the existing %s\n", projectPath)
+		return nil
+	}
+	answer, err := w.ask("Also write the provider and model to "+projectPath+" for everyone working on the repository? (y/n)", "y")
+	if err != nil {
+		return err
+	}
+	if answer = strings.ToLower(answer); answer != "y" && answer != "yes" {
+		return nil
+	}
+	if err := writeYAML(projectPath, initSettings{Provider: settings.Provider, Model: settings.Model}, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(w.out, "Wrote %s\n", projectPath)
+	return nil
+}
+
+// writeYAML writes v to path as YAML with perm, creating its directory.
+func writeYAML(path string, v any, perm os.FileMode) error {
+	content, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, content, perm); err != nil {
+		return err
+	}
+	// WriteFile keeps the permissions of existing files
+	return os.Chmod(path, perm)
+}
+
+// wizard asks questions on out and reads the answers from in, one per line.
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+	// hide turns off the echo of the input while a secret is typed, and
+	// returns a function turning it back on. It is nil when the input is not
+	// a terminal.
+	hide func() (func(), error)
+}
+
+// ask asks question and returns the answer, or def if it is empty.
+func (w wizard) ask(question, def string) (string, error) {
+	if def != "" {
+		question += " [" + def + "]"
+	}
+	fmt.Fprint(w.out, question+": ")
+	answer, err := w.in.ReadString('\n')
+	if errors.Is(err, io.EOF) && answer == "" {
+		return "", errors.New("init needs an answer to each question on standard input")
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return cmp.Or(strings.TrimSpace(answer), def), nil
+}
+
+// askSecret is ask for secrets, which are not shown as they are typed.
+func (w wizard) askSecret(question string) (string, error) {
+	if w.hide != nil {
+		show, err := w.hide()
+		if err != nil {
+			log.Printf("WARNING: the API key will be shown as it is typed: %v", err)
+		} else {
+			defer func() {
+				show()
+				fmt.Fprintln(w.out)
+			}()
+		}
+	}
+	return w.ask(question, "")
+}
+
+// hideInput returns how to hide what is typed on in, if it is a terminal,
+// with stty.
+func hideInput(in io.Reader) func() (func(), error) {
+	f, ok := in.(*os.File)
+	if !ok || !isTerminal(f) {
+		return nil
+	}
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = f
+		return cmd.Run()
+	}
+	return func() (func(), error) {
+		if err := stty("-echo"); err != nil {
+			return nil, err
+		}
+		return func() { _ = stty("echo") }, nil
+	}
+}
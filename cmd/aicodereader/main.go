@@ -1,164 +1,526 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	"flag"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/sashabaranov/go-openai"
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/keyring"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+	"github.com/JackDrogon/aicodereader/pkgs/tokens"
+	"github.com/JackDrogon/aicodereader/pkgs/utils"
 )
 
-// flags for cli
-var (
-	filename = flag.String("f", "", "path to the file to read")
-)
+// buildMessages converts a prompt into chat messages.
+func buildMessages(p prompt.Prompt) []llm.Message {
+	return []llm.Message{
+		{
+			Role:    llm.RoleSystem,
+			Content: p.System,
+		},
+		{
+			Role:    llm.RoleUser,
+			Content: p.User,
+		},
+	}
+}
 
-type Config struct {
-	APIKey  string
-	Model   string
-	BaseURL string
-	Stream  bool
+// newRequest builds the request for p with the configured model, reasoning
+// controls, stop sequences and explanation depth.
+func newRequest(cfg config.Config, p prompt.Prompt) llm.Request {
+	return llm.Request{
+		Model:           cfg.Model,
+		Messages:        buildMessages(prompt.WithDepth(p, opts.depth)),
+		Stop:            opts.stop,
+		ReasoningEffort: cfg.ReasoningEffort,
+		ThinkingBudget:  cfg.ThinkingBudget,
+	}
 }
 
-func LoadConfig() Config {
-	config := Config{
-		APIKey:  os.Getenv("ARK_API_KEY"),
-		Model:   os.Getenv("MODEL"),
-		BaseURL: os.Getenv("BASE_URL"),
-		Stream:  os.Getenv("STREAM") != "",
+func test_standard_request(ctx context.Context, provider llm.Provider, cfg config.Config, p prompt.Prompt) error {
+	log.Println("----- standard request -----")
+	start := time.Now()
+	resp, err := provider.Complete(ctx, newRequest(cfg, p))
+	if err != nil {
+		return fmt.Errorf("ChatCompletion error: %w", err)
 	}
 
-	return config
+	answer := newAnswer(cfg, p, resp.ReasoningContent, resp.Content, llm.ResponseStats(resp, start))
+	reportResult(newResult(answer))
+	return answerSink().Done(answer)
 }
 
-func test_standard_request(config Config) {
-	openaiConfig := openai.DefaultConfig(config.APIKey)
-	openaiConfig.BaseURL = config.BaseURL
-	model := config.Model
-
-	client := openai.NewClientWithConfig(openaiConfig)
-	log.Println("----- standard request -----")
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "你是人工智能助手",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: "常见的十字花科植物有哪些？",
-				},
-			},
-		},
-	)
+func test_stream_request(ctx context.Context, provider llm.Provider, cfg config.Config, p prompt.Prompt) error {
+	log.Println("----- streaming request -----")
+	req := newRequest(cfg, p)
+	req.Temperature = 0.7
+	start := time.Now()
+	s, err := provider.Stream(ctx, req)
 	if err != nil {
-		log.Printf("ChatCompletion error: %v\n", err)
+		return fmt.Errorf("stream chat error: %w", err)
+	}
+	defer s.Close()
+	stream := llm.Measure(s, start)
+
+	sink := answerSink()
+	var reasoning, content strings.Builder
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			answer := newAnswer(cfg, p, reasoning.String(), content.String(), stream.Stats())
+			reportResult(newResult(answer))
+			return sink.Done(answer)
+		}
+
+		if err != nil {
+			return fmt.Errorf("stream chat error: %w", err)
+		}
+
+		switch event.Type {
+		case llm.ReasoningDelta:
+			reasoning.WriteString(event.Text)
+		case llm.ContentDelta:
+			content.WriteString(event.Text)
+		}
+		if err := sink.Delta(event); err != nil {
+			return err
+		}
+	}
+}
+
+// streamRenderer prints stream events, starting a new section whenever the
+// model switches between reasoning, calling tools and answering.
+type streamRenderer struct {
+	section llm.EventType
+	// usage is the token usage reported by the stream, if any.
+	usage *llm.Usage
+}
+
+// streamSections are the headers printed when a section starts.
+var streamSections = map[llm.EventType]string{
+	llm.ReasoningDelta: "----- 模型思考过程 -----",
+	llm.ToolCallDelta:  "----- 工具调用 -----",
+	llm.ContentDelta:   "----- 模型最终回答 -----",
+}
+
+// render prints event to w. Usage is recorded rather than printed.
+func (r *streamRenderer) render(w io.Writer, event llm.Event) {
+	if event.Type == llm.UsageEvent {
+		r.usage = &event.Usage
 		return
 	}
-	fmt.Println("----- 推理过程  -----")
-	fmt.Println(resp.Choices[0].Message.ReasoningContent)
 
-	fmt.Println("----- 最终回答 -----")
-	fmt.Println(resp.Choices[0].Message.Content)
+	if event.Type != r.section {
+		// The answer header only separates the answer from what came
+		// before, so a plain answer is printed without it
+		if r.section != 0 || event.Type != llm.ContentDelta {
+			if r.section != 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintln(w, streamSections[event.Type])
+		}
+		r.section = event.Type
+	}
+
+	switch event.Type {
+	case llm.ReasoningDelta, llm.ContentDelta:
+		fmt.Fprint(w, event.Text)
+	case llm.ToolCallDelta:
+		if event.ToolCall.Name != "" {
+			fmt.Fprintf(w, "%s: ", event.ToolCall.Name)
+		}
+		fmt.Fprint(w, event.ToolCall.Arguments)
+	}
 }
 
-func test_stream_request(config Config) {
-	openaiConfig := openai.DefaultConfig(config.APIKey)
-	openaiConfig.BaseURL = config.BaseURL
-	model := config.Model
+// stdinPath is the file argument that reads from standard input.
+const stdinPath = "-"
 
-	client := openai.NewClientWithConfig(openaiConfig)
+// readFiles reads the files at paths as prompt files. The path "-" reads
+// stdin, whose language is detected from the content.
+func readFiles(paths []string, stdin io.Reader) ([]prompt.File, error) {
+	files := make([]prompt.File, 0, len(paths))
+	for _, path := range paths {
+		if path == stdinPath {
+			content, err := io.ReadAll(stdin)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read stdin: %w", err)
+			}
+			if len(bytes.TrimSpace(content)) == 0 {
+				return nil, errors.New("stdin is empty, nothing to analyze")
+			}
+			files = append(files, prompt.NewStdinFile(content))
+			continue
+		}
 
-	log.Println("----- streaming request -----")
-	stream, err := client.CreateChatCompletionStream(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "你是人工智能助手",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: "常见的十字花科植物有哪些？",
-				},
-			},
-			Temperature: 0.7,
-			Stream:      true,
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		files = append(files, prompt.NewFile(path, content))
+	}
+	return files, nil
+}
+
+// contextLimit returns the largest prompt to send in tokens, zero for no
+// limit: --max-context-tokens or, if smaller, the context window of the
+// configured model, which providers would otherwise fill by truncating the
+// prompt silently. It names the limit for messages.
+func contextLimit(cfg config.Config) (int, string) {
+	if opts.maxContextTokens <= 0 {
+		return 0, ""
+	}
+	if window, ok := tokens.ContextWindowFor(cfg.Model); ok && window < opts.maxContextTokens {
+		return window, "the context window of " + cfg.Model
+	}
+	return opts.maxContextTokens, "--max-context-tokens"
+}
+
+// checkContextSize returns an error if p exceeds the limit of contextLimit.
+// A --max-context-tokens of zero or less disables the check.
+func checkContextSize(provider llm.Provider, cfg config.Config, p prompt.Prompt) error {
+	limit, name := contextLimit(cfg)
+	if limit <= 0 {
+		return nil
+	}
+
+	size := promptTokens(tokenCounter(provider, cfg), p)
+	if size > limit {
+		return fmt.Errorf("%s: prompt is about %d tokens, over %s of %d; send fewer files, raise --max-context-tokens or use a model with a larger context window",
+			promptLabel(p), size, name, limit)
+	}
+	return nil
+}
+
+// sendPrompt sends p to the provider, streaming the answer if configured.
+func sendPrompt(ctx context.Context, provider llm.Provider, cfg config.Config, p prompt.Prompt) error {
+	if cfg.Streaming() {
+		return test_stream_request(ctx, provider, cfg, p)
+	}
+	return test_standard_request(ctx, provider, cfg, p)
+}
+
+// runPrompt sends p to the provider and reports the outcome. Prompts over the
+// context size limit are not sent. Content filter rejections are reported
+// explicitly rather than as an empty answer and, with --retry-filtered,
+// retried once with a softened prompt. It returns the error of a prompt that
+// got no answer.
+func runPrompt(ctx context.Context, provider llm.Provider, cfg config.Config, p prompt.Prompt) error {
+	if err := checkContextSize(provider, cfg, p); err != nil {
+		writeContextBreakdown(os.Stderr, contextContributions(provider, cfg, p))
+		return err
+	}
+
+	err := sendPrompt(ctx, provider, cfg, p)
+
+	var filterErr *llm.ContentFilterError
+	if errors.As(err, &filterErr) && opts.retryFiltered {
+		log.Printf("%s: %v; retrying with a softened prompt", promptLabel(p), filterErr)
+		err = sendPrompt(ctx, provider, cfg, prompt.Soften(p))
+	}
+
+	if errors.As(err, &filterErr) {
+		fmt.Println("----- 内容被过滤 -----")
+		fmt.Printf("%s: %v\n", promptLabel(p), filterErr)
+	}
+
+	if opts.explainContext || len(p.Files) > 1 {
+		writeContextBreakdown(os.Stderr, contextContributions(provider, cfg, p))
+	}
+	return err
+}
+
+// contextContributions counts the tokens of each part of p.
+func contextContributions(provider llm.Provider, cfg config.Config, p prompt.Prompt) []prompt.Contribution {
+	return p.Contributions(tokenCounter(provider, cfg))
+}
+
+// promptLabel names the files a prompt is about, for messages.
+func promptLabel(p prompt.Prompt) string {
+	paths := make([]string, 0, len(p.Files))
+	for _, file := range p.Files {
+		if file.Path != "" {
+			paths = append(paths, file.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return "snippet"
+	}
+	return strings.Join(paths, ", ")
+}
+
+// writeContextBreakdown prints each prompt part's token count and share,
+// largest first, so users can see what made a request expensive.
+func writeContextBreakdown(w io.Writer, contributions []prompt.Contribution) {
+	sorted := slices.Clone(contributions)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Tokens > sorted[j].Tokens })
+
+	total := 0
+	for _, c := range sorted {
+		total += c.Tokens
+	}
+
+	fmt.Fprintln(w, "----- context breakdown -----")
+	fmt.Fprintf(w, "%8s %7s  %s\n", "tokens", "share", "part")
+	for _, c := range sorted {
+		share := 0.0
+		if total > 0 {
+			share = float64(c.Tokens) * 100 / float64(total)
+		}
+		fmt.Fprintf(w, "%8d %6.1f%%  %s\n", c.Tokens, share, c.Label)
+	}
+	fmt.Fprintf(w, "%8d %6.1f%%  total (estimated)\n", total, 100.0)
+}
+
+// newProvider loads configuration from config files, with the profile of
+// the subcommand or --profile, the environment and the global flags, and
+// creates the configured provider, rate limited with
+// --gentle.
+func newProvider() (llm.Provider, config.Config, error) {
+	if err := prompt.ValidateDepth(opts.depth); err != nil {
+		return nil, config.Config{}, err
+	}
+
+	provider, cfg, err := newBaseProvider()
+	if err != nil {
+		return nil, cfg, err
+	}
+	if estimating != nil {
+		provider = offlineProvider{provider}
+	}
+	provider = llm.Metered(provider, runMeter().record(cfg))
+	if opts.gentle {
+		provider = gentle(provider, cfg.RateLimit)
+	}
+	return provider, cfg, nil
+}
+
+// newBaseProvider is newProvider without the wrappers metering, rate
+// limiting and estimating requests, for the optional interfaces of the
+// provider.
+func newBaseProvider() (llm.Provider, config.Config, error) {
+	cfg, err := config.LoadFor(config.Config{
+		Provider:        opts.provider,
+		Model:           opts.model,
+		ReasoningEffort: opts.reasoningEffort,
+		ThinkingBudget:  opts.thinkingBudget,
+		Budget:          opts.budget,
+		RateLimit: config.RateLimitConfig{
+			RequestsPerMinute: opts.rpm,
+			TokensPerMinute:   opts.tpm,
 		},
-	)
+	}, runCommand, opts.profile)
 	if err != nil {
-		log.Printf("stream chat error: %v\n", err)
-		return
+		return nil, cfg, err
+	}
+	if opts.model != "" && cfg.Provider != llm.ProviderAzure {
+		if cfg.Model, err = resolveModel(opts.model); err != nil {
+			return nil, cfg, err
+		}
+	}
+	if cfg.APIKey == "" && cfg.Keyring {
+		if cfg.APIKey, err = keyring.Get(keyringService, providerName(cfg)); err != nil {
+			return nil, cfg, fmt.Errorf("failed to read the API key from the system keyring: %w", err)
+		}
 	}
-	defer stream.Close()
 
-	isThinking := false
+	var auth llm.AuthOptions
+	if cfg.Auth != nil {
+		auth = llm.AuthOptions{Type: cfg.Auth.Type, Params: cfg.Auth.ExpandedParams()}
+	}
+	provider, err := llm.New(llm.Options{
+		Provider:              cfg.Provider,
+		APIKey:                cfg.APIKey,
+		BaseURL:               cfg.BaseURL,
+		GeminiSafetyThreshold: cfg.GeminiSafetyThreshold,
+		AzureAPIVersion:       cfg.AzureAPIVersion,
+		AzureDeployment:       cfg.AzureDeployment,
+		AzureADToken:          cfg.AzureADToken,
+		HTTP: llm.HTTPOptions{
+			ConnectTimeout: cfg.HTTP.ConnectTimeout,
+			ReadTimeout:    cfg.HTTP.ReadTimeout,
+			KeepAlive:      cfg.HTTP.KeepAlive,
+			TLSMinVersion:  cfg.HTTP.TLSMinVersion,
+			MaxIdleConns:   cfg.HTTP.MaxIdleConns,
+		},
+		Auth: auth,
+	})
+	return provider, cfg, err
+}
 
-	for {
-		recv, err := stream.Recv()
-		if err == io.EOF {
-			return
+// analyzeDir scans dir with gitignore rules applied and analyzes every matching
+// file, printing a section header before each one. Files that fail are
+// skipped, and the run fails once the other files are done.
+func analyzeDir(ctx context.Context, provider llm.Provider, cfg config.Config, dir, question string, patterns []string) error {
+	files, err := listSources(ctx, dir, sourceListOptions(patterns))
+	if err != nil {
+		return fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	log.Printf("found %d files in %s", len(files), dir)
+	if err := confirmCost(cfg, estimateFiles(provider, cfg, question, files)); err != nil {
+		return err
+	}
+	failed := 0
+	for i, path := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !opts.json {
+			fmt.Printf("===== [%d/%d] %s =====\n", i+1, len(files), path)
 		}
+		files, err := readFiles([]string{path}, nil)
+		if err == nil {
+			err = analyzeFiles(ctx, provider, cfg, question, files)
+		}
+		if err != nil {
+			log.Printf("skipping %s: %v", path, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files in %s could not be analyzed", failed, len(files), dir)
+	}
+	return nil
+}
+
+// sourceListOptions selects the files of a directory to analyze: files
+// matching patterns, if any, that are not ignored by git, binary, generated
+// or over the --max-file-size limit, down to --max-depth and up to
+// --max-files, sampled as set by --sample.
+func sourceListOptions(patterns []string) *utils.GetSourceListOptions {
+	return &utils.GetSourceListOptions{
+		RespectGitignore:      true,
+		IncludePatterns:       patterns,
+		MaxFileSizeBytes:      opts.maxFileSize,
+		SkipBinary:            true,
+		RespectSparseCheckout: opts.sparseCheckout,
+		IncludeSubmodules:     opts.submodules,
+		FetchLFS:              opts.fetchLFS,
+		FollowSymlinks:        opts.followSymlinks,
+		UseGitLsFiles:         opts.gitFiles,
+		SkipGenerated:         !opts.includeGenerated,
+		MaxDepth:              opts.maxDepth,
+		MaxFiles:              opts.maxFiles,
+		Sample:                utils.Sample(opts.sample),
+	}
+}
+
+// listSources is utils.GetSourceListContext, except that a scan stopped at
+// --max-files returns the files found after a warning. It is for callers that
+// only read the files; those that delete what the list lacks use scanSources.
+func listSources(ctx context.Context, dir string, options *utils.GetSourceListOptions) ([]string, error) {
+	files, err := utils.GetSourceListContext(ctx, dir, options)
+	return files, partialScan(err)
+}
+
+// scanSources is utils.GetSourceListContext, except that a scan stopped at
+// --max-files returns the files found and reports them as truncated instead
+// of failing, so that the caller decides whether a partial list will do.
+func scanSources(ctx context.Context, dir string, options *utils.GetSourceListOptions) ([]string, bool, error) {
+	files, err := utils.GetSourceListContext(ctx, dir, options)
+	var truncated *utils.TruncatedError
+	if errors.As(err, &truncated) {
+		return files, true, nil
+	}
+	return files, false, err
+}
+
+// partialScan logs a warning and returns nil if err reports a scan stopped
+// at --max-files, and returns err otherwise.
+func partialScan(err error) error {
+	var truncated *utils.TruncatedError
+	if errors.As(err, &truncated) {
+		log.Printf("WARNING: %v; results are partial, raise --max-files to scan everything", err)
+		return nil
+	}
+	return err
+}
 
+// directoryTree draws the layout of the files under root for a
+// repository-level prompt, as set by --tree-format, --tree-depth and
+// --tree-tokens, counting tokens with count. Without files, root is scanned.
+func directoryTree(ctx context.Context, root string, files []string, count func(text string) int) (string, error) {
+	format, err := repomap.ParseTreeFormat(opts.treeFormat)
+	if err != nil || format == repomap.TreeNone {
+		return "", err
+	}
+	if files == nil {
+		if files, err = listSources(ctx, root, sourceListOptions(nil)); err != nil {
+			return "", fmt.Errorf("failed to scan directory: %w", err)
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file)
 		if err != nil {
-			log.Printf("Stream chat error: %v\n", err)
-			return
-		}
-
-		if len(recv.Choices) > 0 {
-			if recv.Choices[0].Delta.ToolCalls != nil ||
-				(recv.Choices[0].Delta.Role == "assistant" && !isThinking) {
-				if !isThinking {
-					fmt.Println("----- 模型思考过程 -----")
-					isThinking = true
-				}
-
-				if recv.Choices[0].Delta.ToolCalls != nil {
-					for _, toolCall := range recv.Choices[0].Delta.ToolCalls {
-						if toolCall.Function.Arguments != "" {
-							fmt.Print(toolCall.Function.Arguments)
-						}
-					}
-				}
-			} else if recv.Choices[0].Delta.Content != "" {
-				if isThinking {
-					log.Println("----- 模型最终回答 -----")
-					isThinking = false
-				}
-
-				fmt.Print(recv.Choices[0].Delta.Content)
-			}
+			return "", err
 		}
+		paths = append(paths, filepath.ToSlash(rel))
 	}
+	return repomap.Tree(paths, repomap.TreeOptions{
+		Format:    format,
+		MaxDepth:  opts.treeDepth,
+		MaxTokens: opts.treeTokens,
+		Count:     count,
+	}), nil
 }
 
-func main() {
-	flag.Parse()
+// splitPatterns parses a comma-separated list of glob patterns, dropping empty entries.
+func splitPatterns(list string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(list, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
 
-	if *filename == "" {
-		fmt.Println("filename is required")
-		flag.Usage()
-		return
+// loadQuestion returns the user's question from -p or --prompt-file.
+// An empty result means the default question should be used.
+func loadQuestion(text, file string) (string, error) {
+	if text != "" && file != "" {
+		return "", errors.New("-p and --prompt-file are mutually exclusive")
+	}
+	if file == "" {
+		return strings.TrimSpace(text), nil
 	}
 
-	content, err := os.ReadFile(*filename)
+	content, err := os.ReadFile(file)
 	if err != nil {
-		log.Fatalf("failed to read file: %v", err)
-		return
+		return "", fmt.Errorf("failed to read prompt file: %w", err)
 	}
+	return strings.TrimSpace(string(content)), nil
+}
 
-	fmt.Println(string(content))
-
-	config := LoadConfig()
+func main() {
+	// The first Ctrl-C cancels the running scan or request; once cancelled,
+	// the default handling is restored so a second one exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
-	test_standard_request(config)
-	// test_stream_request(config)
+	err := newRootCmd().ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		os.Exit(1)
+	}
 }
@@ -2,85 +2,137 @@ package main
 
 import (
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
-)
 
-func TestLoadConfig(t *testing.T) {
-	// Save original env vars
-	originalAPIKey := os.Getenv("ARK_API_KEY")
-	originalModel := os.Getenv("MODEL")
-	originalBaseURL := os.Getenv("BASE_URL")
-	originalStream := os.Getenv("STREAM")
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
 
-	// Clean up after test
-	defer func() {
-		os.Setenv("ARK_API_KEY", originalAPIKey)
-		os.Setenv("MODEL", originalModel)
-		os.Setenv("BASE_URL", originalBaseURL)
-		os.Setenv("STREAM", originalStream)
-	}()
+func TestSplitPatterns(t *testing.T) {
+	cases := map[string][]string{
+		"":                nil,
+		"*.go":            {"*.go"},
+		"*.go,*.py":       {"*.go", "*.py"},
+		" *.go , ,*.js, ": {"*.go", "*.js"},
+	}
 
-	// Test with empty environment
-	os.Unsetenv("ARK_API_KEY")
-	os.Unsetenv("MODEL")
-	os.Unsetenv("BASE_URL")
-	os.Unsetenv("STREAM")
+	for input, expected := range cases {
+		if got := splitPatterns(input); !reflect.DeepEqual(got, expected) {
+			t.Errorf("splitPatterns(%q) = %v, expected %v", input, got, expected)
+		}
+	}
+}
 
-	config := LoadConfig()
+func TestAskToContinue(t *testing.T) {
+	cases := map[string]bool{"y\n": true, " Yes \n": true, "n\n": false, "\n": false, "": false, "yep\n": false}
+	for input, expected := range cases {
+		var out strings.Builder
+		if got := askToContinue(strings.NewReader(input), &out, "Continue? [y/N] "); got != expected {
+			t.Errorf("askToContinue(%q) = %v, expected %v", input, got, expected)
+		}
+		if out.String() != "Continue? [y/N] " {
+			t.Errorf("Expected the question to be asked, got %q", out.String())
+		}
+	}
 
-	if config.APIKey != "" {
-		t.Errorf("Expected empty APIKey, got %s", config.APIKey)
+	for n, expected := range map[int]string{900: "900", 35_000: "35k", 1_234_567: "1.2M"} {
+		if got := formatTokens(n); got != expected {
+			t.Errorf("formatTokens(%d) = %q, expected %q", n, got, expected)
+		}
 	}
-	if config.Model != "" {
-		t.Errorf("Expected empty Model, got %s", config.Model)
+}
+
+func TestLoadQuestion(t *testing.T) {
+	question, err := loadQuestion("  explain this module \n", "")
+	if err != nil || question != "explain this module" {
+		t.Errorf("loadQuestion(text) = %q, %v", question, err)
 	}
-	if config.BaseURL != "" {
-		t.Errorf("Expected empty BaseURL, got %s", config.BaseURL)
+
+	question, err = loadQuestion("", "")
+	if err != nil || question != "" {
+		t.Errorf("loadQuestion() = %q, %v, expected empty question", question, err)
 	}
-	if config.Stream {
-		t.Errorf("Expected Stream to be false, got true")
+
+	promptPath := filepath.Join(t.TempDir(), "prompt.txt")
+	if err := os.WriteFile(promptPath, []byte("find concurrency bugs\n"), 0644); err != nil {
+		t.Fatalf("Failed to write prompt file: %v", err)
 	}
 
-	// Test with set environment variables
-	os.Setenv("ARK_API_KEY", "test-key")
-	os.Setenv("MODEL", "test-model")
-	os.Setenv("BASE_URL", "https://test.com")
-	os.Setenv("STREAM", "true")
+	question, err = loadQuestion("", promptPath)
+	if err != nil || question != "find concurrency bugs" {
+		t.Errorf("loadQuestion(file) = %q, %v", question, err)
+	}
 
-	config = LoadConfig()
+	if _, err := loadQuestion("text", promptPath); err == nil {
+		t.Errorf("Expected error when both -p and --prompt-file are set")
+	}
 
-	if config.APIKey != "test-key" {
-		t.Errorf("Expected APIKey 'test-key', got %s", config.APIKey)
+	if _, err := loadQuestion("", filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Errorf("Expected error for missing prompt file")
 	}
-	if config.Model != "test-model" {
-		t.Errorf("Expected Model 'test-model', got %s", config.Model)
+}
+
+func TestWriteContextBreakdown(t *testing.T) {
+	var b strings.Builder
+	writeContextBreakdown(&b, []prompt.Contribution{
+		{Label: "system prompt", Tokens: 10},
+		{Label: "question", Tokens: 0},
+		{Label: "big.go", Tokens: 70},
+		{Label: "small.go", Tokens: 20},
+	})
+
+	expected := "----- context breakdown -----\n" +
+		"  tokens   share  part\n" +
+		"      70   70.0%  big.go\n" +
+		"      20   20.0%  small.go\n" +
+		"      10   10.0%  system prompt\n" +
+		"       0    0.0%  question\n" +
+		"     100  100.0%  total (estimated)\n"
+	if b.String() != expected {
+		t.Errorf("Unexpected breakdown:\n%s\nexpected:\n%s", b.String(), expected)
 	}
-	if config.BaseURL != "https://test.com" {
-		t.Errorf("Expected BaseURL 'https://test.com', got %s", config.BaseURL)
+}
+
+func TestPromptLabel(t *testing.T) {
+	if label := promptLabel(prompt.Build("q", prompt.File{Content: "x"})); label != "snippet" {
+		t.Errorf("Expected snippet label, got %q", label)
 	}
-	if !config.Stream {
-		t.Errorf("Expected Stream to be true, got false")
+
+	p := prompt.Build("q", prompt.NewFile("a.go", nil), prompt.NewFile("b.go", nil))
+	if label := promptLabel(p); label != "a.go, b.go" {
+		t.Errorf("Expected file paths label, got %q", label)
 	}
 }
 
-func TestConfigStruct(t *testing.T) {
-	config := Config{
-		APIKey:  "test-api-key",
-		Model:   "test-model",
-		BaseURL: "https://test.example.com",
-		Stream:  true,
+func TestStreamRenderer(t *testing.T) {
+	var out strings.Builder
+	var r streamRenderer
+	for _, event := range []llm.Event{
+		{Type: llm.ReasoningDelta, Text: "think"},
+		{Type: llm.ReasoningDelta, Text: "ing"},
+		{Type: llm.ToolCallDelta, ToolCall: llm.ToolCall{Name: "grep", Arguments: `{"q":`}},
+		{Type: llm.ToolCallDelta, ToolCall: llm.ToolCall{Arguments: `1}`}},
+		{Type: llm.ContentDelta, Text: "answer"},
+		{Type: llm.UsageEvent, Usage: llm.Usage{PromptTokens: 3, CompletionTokens: 2}},
+	} {
+		r.render(&out, event)
 	}
 
-	if config.APIKey != "test-api-key" {
-		t.Errorf("Expected APIKey 'test-api-key', got %s", config.APIKey)
+	expected := "----- 模型思考过程 -----\nthinking\n----- 工具调用 -----\ngrep: {\"q\":1}\n----- 模型最终回答 -----\nanswer"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
 	}
-	if config.Model != "test-model" {
-		t.Errorf("Expected Model 'test-model', got %s", config.Model)
+	if r.usage == nil || r.usage.PromptTokens != 3 {
+		t.Errorf("Expected usage to be recorded, got %+v", r.usage)
 	}
-	if config.BaseURL != "https://test.example.com" {
-		t.Errorf("Expected BaseURL 'https://test.example.com', got %s", config.BaseURL)
-	}
-	if !config.Stream {
-		t.Errorf("Expected Stream to be true, got false")
+
+	var plain strings.Builder
+	var answerOnly streamRenderer
+	answerOnly.render(&plain, llm.Event{Type: llm.ContentDelta, Text: "answer"})
+	if plain.String() != "answer" {
+		t.Errorf("Expected a plain answer without a header, got %q", plain.String())
 	}
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/tokens"
+)
+
+// modelInfo is a model as listed by the models command. Context windows and
+// prices come from the bundled tables, zero when a model is not in them.
+type modelInfo struct {
+	Model         string  `json:"model"`
+	ContextWindow int     `json:"context_window,omitempty"`
+	InputPrice    float64 `json:"input_price,omitempty"`
+	OutputPrice   float64 `json:"output_price,omitempty"`
+}
+
+func newModelsCmd() *cobra.Command {
+	var known bool
+	cmd := &cobra.Command{
+		Use:   "models",
+		Short: "List the models of the configured provider with their context windows and prices",
+		Long: "Models asks the configured provider, or the one given with --provider, for the models it serves, and lists " +
+			"them with their context windows and prices in dollars per million input and output tokens from the bundled " +
+			"tables. Providers that cannot list their models, such as Azure OpenAI, get the models of the tables.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if known {
+				return writeModels(cmd.OutOrStdout(), tokens.Models())
+			}
+			provider, cfg, err := newBaseProvider()
+			if err != nil {
+				return err
+			}
+			lister, ok := provider.(llm.ModelLister)
+			if !ok {
+				log.Printf("WARNING: %s cannot list its models; listing the models of the bundled tables", providerName(cfg))
+				return writeModels(cmd.OutOrStdout(), tokens.Models())
+			}
+			models, err := lister.ListModels(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to list the models of %s: %w", providerName(cfg), err)
+			}
+			return writeModels(cmd.OutOrStdout(), models)
+		},
+	}
+	cmd.Flags().BoolVar(&known, "known", false, "list the models of the bundled tables without asking the provider")
+	return cmd
+}
+
+// writeModels prints models with their context windows and prices, as JSON
+// Lines with --json.
+func writeModels(w io.Writer, models []string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if !opts.json {
+		fmt.Fprintln(tw, "MODEL\tCONTEXT\tINPUT $/M\tOUTPUT $/M")
+	}
+	for _, model := range models {
+		info := modelInfo{Model: model}
+		info.ContextWindow, _ = tokens.ContextWindowFor(model)
+		if price, ok := tokens.PriceFor(model); ok {
+			info.InputPrice, info.OutputPrice = price.Input, price.Output
+		}
+		if opts.json {
+			if err := json.NewEncoder(w).Encode(info); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", model, formatWindow(info.ContextWindow),
+			formatPrice(info.InputPrice), formatPrice(info.OutputPrice))
+	}
+	return tw.Flush()
+}
+
+// formatWindow abbreviates a context window, returning "-" for unknown
+// ones.
+func formatWindow(n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return formatTokens(n)
+}
+
+// formatPrice formats a price in dollars per million tokens, returning "-"
+// for unknown ones.
+func formatPrice(price float64) string {
+	if price == 0 {
+		return "-"
+	}
+	return "$" + strconv.FormatFloat(price, 'f', -1, 64)
+}
+
+// resolveModel resolves name, given with --model, against the bundled
+// tables: parts of a model name are completed, and names that look like
+// typos of models fail with suggestions. Names close to no model, such as
+// those of self-hosted models, are used as they are.
+func resolveModel(name string) (string, error) {
+	model, suggestions := tokens.MatchModel(name)
+	switch {
+	case model != "":
+		if model != name {
+			log.Printf("using model %s for --model %s", model, name)
+		}
+		return model, nil
+	case len(suggestions) > 0:
+		return "", fmt.Errorf("unknown model %q; did you mean %s? Run \"aicodereader models\" to list the models",
+			name, strings.Join(suggestions, ", "))
+	default:
+		return name, nil
+	}
+}
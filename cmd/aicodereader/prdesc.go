@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/git"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
+
+// newPRDescCmd creates the pr-desc command, which writes the description of
+// a pull request from the commits and diff of the current branch.
+func newPRDescCmd() *cobra.Command {
+	var base, templatePath string
+	cmd := &cobra.Command{
+		Use:   "pr-desc",
+		Short: "Write a pull request title, description, risk notes and test plan from the branch diff",
+		Long: "Pr-desc collects the commits of the current branch since it forked from --base and the changes they make, " +
+			"and asks the model for a pull request title, a description, risk notes and a test plan. With --template " +
+			"the model fills in the project's pull request template instead.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			commits, err := git.Commits(ctx, ".", base+"..HEAD")
+			if err != nil {
+				return err
+			}
+			diffs, err := git.BranchDiff(ctx, ".", base)
+			if err != nil {
+				return err
+			}
+			if len(diffs) == 0 {
+				return fmt.Errorf("HEAD changes nothing since it forked from %s", base)
+			}
+
+			var template prompt.File
+			if templatePath != "" {
+				content, err := os.ReadFile(templatePath)
+				if err != nil {
+					return fmt.Errorf("failed to read the PR template: %w", err)
+				}
+				template = prompt.NewFile(templatePath, content)
+			}
+
+			provider, cfg, err := newProvider()
+			if err != nil {
+				return err
+			}
+			messages := make([]string, 0, len(commits))
+			for _, c := range commits {
+				messages = append(messages, c.Message())
+			}
+			limit, _ := contextLimit(cfg)
+			p := buildPRDescription(tokenCounter(provider, cfg), limit, messages, diffs, template)
+			return runPrompt(ctx, provider, cfg, p)
+		},
+	}
+	cmd.Flags().StringVar(&base, "base", "main", "branch the pull request merges into; changes are taken from where HEAD forked from it")
+	cmd.Flags().StringVar(&templatePath, "template", "", "pull request template to fill in, such as .github/pull_request_template.md")
+	return cmd
+}
+
+// buildPRDescription builds the pr-desc prompt for commits and diffs. While
+// it exceeds limit tokens, the largest diff is left out, the prompt only
+// naming its file, and a warning lists the files left out. A limit of zero
+// keeps every diff.
+func buildPRDescription(count func(text string) int, limit int, commits []string, diffs []git.FileDiff, template prompt.File) prompt.Prompt {
+	files := make([]prompt.File, 0, len(diffs))
+	for _, d := range diffs {
+		files = append(files, prompt.File{Path: d.Path, Language: "Diff", Content: d.Patch})
+	}
+
+	var omitted []string
+	p := prompt.BuildPRDescription(commits, files, omitted, template)
+	for limit > 0 && len(files) > 0 && promptTokens(count, p) > limit {
+		largest := 0
+		for i, f := range files {
+			if len(f.Content) > len(files[largest].Content) {
+				largest = i
+			}
+		}
+		omitted = append(omitted, files[largest].Path)
+		files = slices.Delete(files, largest, largest+1)
+		p = prompt.BuildPRDescription(commits, files, omitted, template)
+	}
+	if len(omitted) > 0 {
+		log.Printf("WARNING: Left out the diffs of %d files to stay under the context limit of %d tokens: %s",
+			len(omitted), limit, strings.Join(omitted, ", "))
+	}
+	return p
+}
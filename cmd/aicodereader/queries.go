@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/queries"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+)
+
+// savedQuery selects a saved query to save or run instead of asking a
+// one-off question.
+type savedQuery struct {
+	save   string
+	run    string
+	params []string
+}
+
+// addSavedQueryFlags registers the --save, --run and --param flags on cmd.
+func addSavedQueryFlags(cmd *cobra.Command, q *savedQuery) {
+	flags := cmd.Flags()
+	flags.StringVar(&q.save, "save", "", "save the question under this name in "+queries.FileName+", then answer it")
+	flags.StringVar(&q.run, "run", "", "answer the question saved under this name")
+	flags.StringArrayVar(&q.params, "param", nil, "value of a {{name}} parameter of the question, as name=value; repeat for several")
+	cmd.MarkFlagsMutuallyExclusive("save", "run")
+}
+
+// resolve returns the question to ask: question itself, or the saved query
+// with its parameters filled in, in which case its answers are recorded in
+// the query's history. It saves question first with --save, and reports
+// false if there is nothing to ask, as when a saved question has parameters
+// without values.
+func (q *savedQuery) resolve(root, question string) (string, bool, error) {
+	if q.save == "" && q.run == "" {
+		if len(q.params) > 0 {
+			return "", false, errors.New("--param needs --save or --run")
+		}
+		return question, true, nil
+	}
+
+	values, err := parseParams(q.params)
+	if err != nil {
+		return "", false, err
+	}
+
+	path := queries.Path(root)
+	name := q.save
+	if q.save != "" {
+		if err := queries.Save(path, name, queries.Query{Question: question}); err != nil {
+			return "", false, err
+		}
+		log.Printf("saved query %q to %s", name, path)
+	} else {
+		saved, err := queries.Load(path)
+		if err != nil {
+			return "", false, err
+		}
+		query, ok := saved[q.run]
+		if !ok {
+			return "", false, fmt.Errorf("no saved query %q in %s", q.run, path)
+		}
+		name, question = q.run, query.Question
+	}
+
+	expanded, err := queries.Expand(question, values)
+	if err != nil {
+		if q.save != "" {
+			log.Printf("%v; run it with --run %s --param name=value", err, name)
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	historyDir, err := queries.DefaultHistoryDir(root)
+	if err != nil {
+		return "", false, err
+	}
+	onResult = func(r result) {
+		err := queries.Record(historyDir, name, queries.Run{
+			Time:     time.Now(),
+			Question: expanded,
+			Params:   values,
+			Source:   r.Source,
+			Provider: r.Provider,
+			Model:    r.Model,
+			Content:  r.Content,
+		})
+		if err != nil {
+			log.Printf("WARNING: Could not record the answer to %q: %v", name, err)
+		}
+	}
+	return expanded, true, nil
+}
+
+// parseParams parses name=value parameters.
+func parseParams(params []string) (map[string]string, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+	values := make(map[string]string, len(params))
+	for _, param := range params {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --param %q, expected name=value", param)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// newQueriesCmd creates the queries command, which lists the saved queries
+// of the repository or the recorded answers to one of them.
+func newQueriesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "queries [name]",
+		Short: "List saved queries, or the answers recorded for one",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := repomap.FindRoot(".")
+			if len(args) == 0 {
+				return listQueries(cmd.OutOrStdout(), root)
+			}
+
+			historyDir, err := queries.DefaultHistoryDir(root)
+			if err != nil {
+				return err
+			}
+			runs, err := queries.History(historyDir, args[0])
+			if err != nil {
+				return err
+			}
+			if len(runs) == 0 {
+				return fmt.Errorf("no recorded answers to %q; run it with ask --run %s", args[0], args[0])
+			}
+			return writeRuns(cmd.OutOrStdout(), runs)
+		},
+	}
+}
+
+// listQueries prints the names and questions of the queries saved in root.
+func listQueries(w io.Writer, root string) error {
+	path := queries.Path(root)
+	saved, err := queries.Load(path)
+	if err != nil {
+		return err
+	}
+	if len(saved) == 0 {
+		return fmt.Errorf("no saved queries in %s; save one with ask --save <name>", path)
+	}
+
+	names := make([]string, 0, len(saved))
+	for name := range saved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t%s\n", name, saved[name].Question)
+	}
+	return tw.Flush()
+}
+
+// writeRuns prints the recorded answers to a query, oldest first, as JSON
+// Lines with --json.
+func writeRuns(w io.Writer, runs []queries.Run) error {
+	for _, run := range runs {
+		if opts.json {
+			if err := json.NewEncoder(w).Encode(run); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(w, "===== %s %s/%s: %s =====\n", run.Time.Local().Format(time.DateTime), run.Provider, run.Model, run.Source)
+		fmt.Fprintln(w, run.Question)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, strings.TrimSpace(run.Content))
+		fmt.Fprintln(w)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JackDrogon/aicodereader/pkgs/faq"
+	"github.com/JackDrogon/aicodereader/pkgs/index"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+	"github.com/JackDrogon/aicodereader/pkgs/summary"
+)
+
+// reportTitle heads the report of answers to a questions file.
+const reportTitle = "问答报告"
+
+// askQuestions answers every question in the questions file at path in one
+// run and writes the answers as a Markdown report to out, or to w if out is
+// "" or "-". The questions share one context: the files selected by in,
+// read once, or else the search index and directory tree of the
+// repository. Answers are cached unless useCache is false, so a rerun only
+// asks the questions whose prompt changed.
+func askQuestions(ctx context.Context, w io.Writer, stdin io.Reader, in inputOptions, path string, retrieve retrieval, out string, useCache bool) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read questions: %w", err)
+	}
+	questions := faq.ParseQuestions(string(content))
+	if len(questions) == 0 {
+		return fmt.Errorf("no questions in %s", path)
+	}
+	if in.dir != "" {
+		return errors.New("--questions answers from files (-f) or the search index, not a directory (-d)")
+	}
+
+	provider, cfg, err := newProvider()
+	if err != nil {
+		return err
+	}
+
+	root := repomap.FindRoot(".")
+	if in.repoMap {
+		m, err := repomap.Generate(root, repomap.Options{})
+		if err != nil {
+			return err
+		}
+		repoMap = m.String()
+	}
+
+	// build returns the prompt for a question and the chunks it cites
+	var build func(question string) (prompt.Prompt, []index.Result, error)
+	hasStdin := false
+	if len(in.files) == 0 {
+		var piped io.Reader
+		if piped, hasStdin = stdinInput(stdin); hasStdin {
+			stdin = piped
+		}
+	}
+	if len(in.files) > 0 || hasStdin {
+		paths, err := expandPaths(in.files)
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			paths = []string{stdinPath}
+		}
+		files, err := readFiles(paths, stdin)
+		if err != nil {
+			return err
+		}
+		build = func(question string) (prompt.Prompt, []index.Result, error) {
+			return prompt.WithRepoMap(prompt.Build(question, files...), repoMap), nil, nil
+		}
+	} else {
+		ix, err := openIndex(root)
+		if err != nil {
+			return err
+		}
+		defer ix.Close()
+		embed, model, err := queryEmbedderFor(provider, cfg)
+		if err != nil {
+			return err
+		}
+		tree, err := directoryTree(ctx, root, nil, tokenCounter(provider, cfg))
+		if err != nil {
+			return err
+		}
+		retrieve.loadSymbols(root)
+		build = func(question string) (prompt.Prompt, []index.Result, error) {
+			results, err := retrieve.search(ctx, provider, cfg, ix, embed, model, question)
+			if err != nil {
+				return prompt.Prompt{}, nil, err
+			}
+			if len(results) == 0 {
+				return prompt.Prompt{}, nil, fmt.Errorf("the search index of %s is empty; run \"aicodereader index\" again", root)
+			}
+			p := prompt.WithTree(buildGroundedPrompt(question, results), tree)
+			return prompt.WithRepoMap(p, repoMap), results, nil
+		}
+	}
+
+	var cache *summary.Cache
+	if useCache {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return err
+		}
+		cache = summary.NewCache(filepath.Join(cacheDir, "aicodereader", "answers"))
+	}
+
+	// Build every prompt first, so the run's cost is known before asking
+	var asks []question
+	var uncached []prompt.Prompt
+	for _, text := range questions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		heading, _, _ := strings.Cut(text, "\n")
+		p, results, err := build(text)
+		if err == nil {
+			err = checkContextSize(provider, cfg, p)
+		}
+		if err != nil {
+			log.Printf("skipping %q: %v", heading, err)
+			continue
+		}
+
+		q := question{heading: heading, prompt: p, results: results}
+		q.key = summary.Key("answer", cfg.Provider, cfg.Model, opts.depth, p.System, p.User)
+		if cache != nil {
+			q.answer, q.cached = cache.Get(q.key)
+		}
+		if !q.cached {
+			uncached = append(uncached, p)
+		}
+		asks = append(asks, q)
+	}
+	if err := confirmCost(cfg, estimatePrompts(cfg, uncached)); err != nil {
+		return err
+	}
+
+	var entries []faq.Entry
+	cached := 0
+	for i, q := range asks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		log.Printf("[%d/%d] %s", i+1, len(asks), q.heading)
+
+		var stats llm.Stats
+		if q.cached {
+			cached++
+		} else {
+			answer, answerStats, err := completeWithStats(ctx, provider, cfg, q.prompt)
+			if err != nil {
+				log.Printf("skipping %q: %v", q.heading, err)
+				continue
+			}
+			if cache != nil && strings.TrimSpace(answer) != "" {
+				// A failed write only costs a recomputation next time
+				_ = cache.Put(q.key, answer)
+			}
+			q.answer, stats = answer, answerStats
+		}
+		entry := faq.Entry{Question: q.heading, Answer: q.answer + markdownSources(q.results)}
+		saveAnswer(newSavedAnswer(cfg, entry.Question, entry.Answer, stats))
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return errors.New("no question could be answered")
+	}
+	log.Printf("answered %d of %d questions (%d cached)", len(entries), len(questions), cached)
+
+	report := faq.Report(reportTitle, entries)
+	if out == "" || out == stdinPath {
+		_, err := io.WriteString(w, report)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, []byte(report), 0644); err != nil {
+		return err
+	}
+	log.Printf("wrote the report to %s", out)
+	return nil
+}
+
+// question is a question of a questions file, ready to ask.
+type question struct {
+	heading string
+	prompt  prompt.Prompt
+	// results are the chunks the prompt cites.
+	results []index.Result
+	key     string
+	// answer is the cached answer, if cached.
+	answer string
+	cached bool
+}
+
+// markdownSources lists the locations an answer was grounded in as a
+// Markdown list, each labeled as documentation or code, or returns "" if
+// there are none.
+func markdownSources(results []index.Result) string {
+	if len(results) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n参考片段：\n")
+	for _, r := range results {
+		kind := prompt.Snippet{Documentation: r.Documentation()}.Kind()
+		fmt.Fprintf(&b, "\n- [%s] `%s:%d-%d` (%s)", kind, r.Path, r.StartLine, r.EndLine, sourceVersion(r))
+	}
+	return b.String()
+}
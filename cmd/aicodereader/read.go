@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+)
+
+// inputOptions selects the code a command analyzes.
+type inputOptions struct {
+	files   []string
+	dir     string
+	include string
+	repoMap bool
+}
+
+// repoMap is the repository map prepended to file prompts, set by
+// inputOptions.analyze when --repo-map is given.
+var repoMap string
+
+// addInputFlags registers the -f, -d and --include flags on cmd.
+func addInputFlags(cmd *cobra.Command, in *inputOptions) {
+	flags := cmd.Flags()
+	flags.StringArrayVarP(&in.files, "file", "f", nil, "path or glob of a file to read (\"-\" for stdin); repeat to send several files as one context")
+	flags.StringVarP(&in.dir, "dir", "d", "", "path to a directory to scan; every matching file is analyzed")
+	flags.StringVar(&in.include, "include", "", "comma-separated glob patterns selecting files in -d mode (e.g. \"*.go,*.py\")")
+	flags.BoolVar(&in.repoMap, "repo-map", false, "prepend a map of the repository's files and exported symbols to each prompt")
+	cmd.MarkFlagsMutuallyExclusive("file", "dir")
+}
+
+// analyze asks question about the selected files together, or about every
+// file in the selected directory one at a time. args are extra file paths or
+// globs given as positional arguments. Without any input, piped stdin is read
+// as if "-f -" had been given.
+func (in *inputOptions) analyze(ctx context.Context, stdin io.Reader, question string, args []string) error {
+	paths, err := expandPaths(append(slices.Clone(in.files), args...))
+	if err != nil {
+		return err
+	}
+	if in.dir != "" && len(paths) > 0 {
+		return errors.New("files cannot be combined with a directory (-d)")
+	}
+	if in.dir == "" && len(paths) == 0 {
+		if !isPiped(stdin) {
+			return errors.New("a file (-f), directory (-d) or piped stdin is required")
+		}
+		paths = []string{stdinPath}
+	}
+
+	if in.repoMap {
+		root := repomap.FindRoot(cmp.Or(in.dir, "."))
+		m, err := repomap.Generate(root, repomap.Options{})
+		if err != nil {
+			return err
+		}
+		repoMap = m.String()
+	}
+
+	if in.dir != "" {
+		provider, cfg, err := newProvider()
+		if err != nil {
+			return err
+		}
+		return analyzeDir(ctx, provider, cfg, in.dir, question, splitPatterns(in.include))
+	}
+
+	files, err := readFiles(paths, stdin)
+	if err != nil {
+		return err
+	}
+
+	provider, cfg, err := newProvider()
+	if err != nil {
+		return err
+	}
+	if err := confirmCost(cfg, estimateAnalysis(provider, cfg, question, files)); err != nil {
+		return err
+	}
+
+	return analyzeFiles(ctx, provider, cfg, question, files)
+}
+
+// isPiped reports whether stdin is redirected from a pipe or file rather than
+// attached to a terminal. Readers other than *os.File count as piped.
+func isPiped(stdin io.Reader) bool {
+	file, ok := stdin.(*os.File)
+	if !ok {
+		return true
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+
+// stdinInput returns stdin, to be read as input, if it is redirected from a
+// pipe or file holding data. A terminal, a socket, /dev/null or an empty
+// input, as cron, CI runners and ssh sessions leave commands with, does not
+// count. Readers other than *os.File count if they hold data. It waits for
+// the first byte of a pipe or its end.
+func stdinInput(stdin io.Reader) (io.Reader, bool) {
+	if file, ok := stdin.(*os.File); ok {
+		info, err := file.Stat()
+		if err != nil || (info.Mode()&os.ModeNamedPipe == 0 && !info.Mode().IsRegular()) {
+			return nil, false
+		}
+	}
+	r := bufio.NewReader(stdin)
+	if _, err := r.Peek(1); err != nil {
+		return nil, false
+	}
+	return r, true
+}
+
+// expandPaths expands glob patterns in paths, keeping the first occurrence of
+// each file. Paths without glob characters are kept as they are, so missing
+// files are reported when read. A glob matching nothing is an error.
+func expandPaths(paths []string) ([]string, error) {
+	var expanded []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		matches := []string{path}
+		// A file whose name contains glob characters, such as
+		// "notes [draft].md", is taken as is
+		if _, statErr := os.Stat(path); statErr != nil && strings.ContainsAny(path, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(path); err != nil {
+				return nil, fmt.Errorf("invalid glob %q: %w", path, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", path)
+			}
+		}
+
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				expanded = append(expanded, match)
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// newReadCmd creates the read command, which explains code or answers a
+// question given with -p or --prompt-file. Several files are sent as one
+// combined context.
+func newReadCmd() *cobra.Command {
+	var (
+		in         inputOptions
+		promptText string
+		promptFile string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "read [file...]",
+		Short: "Explain files or every file in a directory",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			question, err := loadQuestion(promptText, promptFile)
+			if err != nil {
+				return err
+			}
+			return in.analyze(cmd.Context(), cmd.InOrStdin(), question, args)
+		},
+	}
+
+	addInputFlags(cmd, &in)
+	cmd.Flags().StringVarP(&promptText, "prompt", "p", "", "question to ask about the loaded code")
+	cmd.Flags().StringVar(&promptFile, "prompt-file", "", "path to a file containing the question to ask about the loaded code")
+	return cmd
+}
+
+// newReviewCmd creates the review command.
+func newReviewCmd() *cobra.Command {
+	return newTaskCmd("review", "Review files or a directory for bugs and risks", prompt.ReviewQuestion)
+}
+
+// newQuizCmd creates the quiz command, which writes comprehension questions
+// about the selected code for learners, with answers.
+func newQuizCmd() *cobra.Command {
+	var count int
+
+	cmd := newQuestionCmd("quiz", "Generate comprehension questions about files for learners", func() string {
+		return prompt.QuizQuestion(count)
+	})
+	cmd.Flags().IntVarP(&count, "questions", "n", prompt.DefaultQuizQuestions, "number of questions to ask")
+	return cmd
+}
+
+// newTaskCmd creates a command that asks a fixed question about the selected
+// code. Text given with -p is appended to the question as extra instructions.
+func newTaskCmd(use, short, question string) *cobra.Command {
+	return newQuestionCmd(use, short, func() string { return question })
+}
+
+// newQuestionCmd is newTaskCmd with a question that depends on the command's
+// flags, built when the command runs.
+func newQuestionCmd(use, short string, question func() string) *cobra.Command {
+	var (
+		in    inputOptions
+		extra string
+	)
+
+	cmd := &cobra.Command{
+		Use:   use + " [file...]",
+		Short: short,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return in.analyze(cmd.Context(), cmd.InOrStdin(), taskQuestion(question(), extra), args)
+		},
+	}
+
+	addInputFlags(cmd, &in)
+	cmd.Flags().StringVarP(&extra, "prompt", "p", "", "extra instructions appended to the "+use+" question")
+	return cmd
+}
+
+// taskQuestion appends the user's extra instructions, if any, to question.
+func taskQuestion(question, extra string) string {
+	if extra == "" {
+		return question
+	}
+	return question + "\n\n" + extra
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"time"
+
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/output"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
+
+// result is the JSON form of an answer printed with --json.
+type result struct {
+	Source    string `json:"source"`
+	Provider  string `json:"provider"`
+	Model     string `json:"model"`
+	Reasoning string `json:"reasoning,omitempty"`
+	Content   string `json:"content"`
+	// Sources are the retrieved chunks the answer was grounded in.
+	Sources []resultSource `json:"sources,omitempty"`
+	Usage   resultUsage    `json:"usage"`
+	Latency resultLatency  `json:"latency"`
+}
+
+// resultSource is a chunk a result was grounded in, identifying the exact
+// version of the code the answer is based on.
+type resultSource struct {
+	ID        int64  `json:"id"`
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	FileHash  string `json:"file_hash,omitempty"`
+	Type      string `json:"type,omitempty"`
+}
+
+// resultUsage is the token usage of a result.
+type resultUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	// Estimated is set when the provider did not report completion tokens.
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// resultLatency is the latency of a result in milliseconds. TTFT is only
+// measured for streamed answers.
+type resultLatency struct {
+	TTFTMs          int64   `json:"ttft_ms,omitempty"`
+	DurationMs      int64   `json:"duration_ms"`
+	TokensPerSecond float64 `json:"tokens_per_second"`
+}
+
+// onResult, if set, is called with every answer, such as to keep the
+// history of a saved query.
+var onResult func(r result)
+
+// newAnswer describes the answer to p.
+func newAnswer(cfg config.Config, p prompt.Prompt, reasoning, content string, stats llm.Stats) output.Answer {
+	var files []output.File
+	for _, file := range p.Files {
+		if file.Path != "" {
+			files = append(files, output.NewFile(file.Path, file.Content))
+		}
+	}
+	var sources []output.Source
+	for _, snippet := range p.Snippets {
+		sources = append(sources, output.Source{
+			ID:        snippet.ID,
+			Path:      snippet.Path,
+			StartLine: snippet.StartLine,
+			EndLine:   snippet.EndLine,
+			FileHash:  snippet.FileHash,
+			Type:      sourceType(snippet.Documentation),
+		})
+	}
+	return output.Answer{
+		Source:    promptLabel(p),
+		Files:     files,
+		Sources:   sources,
+		Provider:  cmp.Or(cfg.Provider, llm.ProviderOpenAI),
+		Model:     cfg.Model,
+		Reasoning: reasoning,
+		Content:   content,
+		Stats:     stats,
+	}
+}
+
+// sourceType is the Type of a source of documentation or, if not
+// documentation, of code.
+func sourceType(documentation bool) string {
+	if documentation {
+		return "documentation"
+	}
+	return "code"
+}
+
+// newSavedAnswer describes content, the answer about source that a command
+// writes into a document of its own, such as one question of an FAQ.
+func newSavedAnswer(cfg config.Config, source, content string, stats llm.Stats) output.Answer {
+	answer := newAnswer(cfg, prompt.Prompt{}, "", content, stats)
+	answer.Source = source
+	return answer
+}
+
+// newResult is the JSON form of answer.
+func newResult(answer output.Answer) result {
+	stats := answer.Stats
+	var sources []resultSource
+	for _, source := range answer.Sources {
+		sources = append(sources, resultSource(source))
+	}
+	return result{
+		Source:    answer.Source,
+		Provider:  answer.Provider,
+		Model:     answer.Model,
+		Reasoning: answer.Reasoning,
+		Content:   answer.Content,
+		Sources:   sources,
+		Usage: resultUsage{
+			PromptTokens:     stats.Usage.PromptTokens,
+			CompletionTokens: stats.Usage.CompletionTokens,
+			Estimated:        stats.Estimated,
+		},
+		Latency: resultLatency{
+			TTFTMs:          stats.TTFT.Milliseconds(),
+			DurationMs:      stats.Duration.Milliseconds(),
+			TokensPerSecond: math.Round(stats.TokensPerSecond()*10) / 10,
+		},
+	}
+}
+
+// captureAnswer sets content to the content of each answer reported from
+// now on, besides calling onResult, until the returned function is called.
+func captureAnswer(content *string) func() {
+	previous := onResult
+	onResult = func(r result) {
+		*content = r.Content
+		if previous != nil {
+			previous(r)
+		}
+	}
+	return func() { onResult = previous }
+}
+
+// reportResult passes r to onResult, if set.
+func reportResult(r result) {
+	if onResult != nil {
+		onResult(r)
+	}
+}
+
+// writeResult prints r as one line of JSON, so answers from a directory
+// form a JSON Lines stream.
+func writeResult(w io.Writer, r result) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// logStats logs the latency of a request with --verbose.
+func logStats(stats llm.Stats) {
+	if !opts.verbose {
+		return
+	}
+
+	tokens := fmt.Sprintf("%d", stats.Usage.CompletionTokens)
+	if stats.Estimated {
+		tokens = "~" + tokens
+	}
+	if stats.TTFT > 0 {
+		log.Printf("latency: first token after %s, done after %s, %s completion tokens at %.1f tokens/s",
+			stats.TTFT.Round(time.Millisecond), stats.Duration.Round(time.Millisecond), tokens, stats.TokensPerSecond())
+		return
+	}
+	log.Printf("latency: done after %s, %s completion tokens at %.1f tokens/s",
+		stats.Duration.Round(time.Millisecond), tokens, stats.TokensPerSecond())
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/index"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
+
+func TestWriteResult(t *testing.T) {
+	stats := llm.Stats{
+		TTFT:     250 * time.Millisecond,
+		Duration: 2250 * time.Millisecond,
+		Usage:    llm.Usage{PromptTokens: 100, CompletionTokens: 50},
+	}
+	r := newResult(newAnswer(config.Config{Provider: "openai", Model: "m"}, prompt.Build("q", prompt.NewFile("a.go", nil)), "", "answer", stats))
+
+	var b strings.Builder
+	if err := writeResult(&b, r); err != nil {
+		t.Fatalf("writeResult failed: %v", err)
+	}
+
+	expected := `{"source":"a.go","provider":"openai","model":"m","content":"answer",` +
+		`"usage":{"prompt_tokens":100,"completion_tokens":50},` +
+		`"latency":{"ttft_ms":250,"duration_ms":2250,"tokens_per_second":25}}` + "\n"
+	if b.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, b.String())
+	}
+}
+
+func TestWriteResultSources(t *testing.T) {
+	p := buildGroundedPrompt("q", []index.Result{
+		{ID: 7, Path: "a.go", FileHash: "abc", StartLine: 3, EndLine: 5, Content: "package a\n\nfunc A() {\n}\n"},
+		{ID: 8, Path: "docs/a.md", StartLine: 1, EndLine: 1, Content: "# A\n"},
+	})
+	r := newResult(newAnswer(config.Config{Provider: "openai", Model: "m"}, p, "", "answer", llm.Stats{}))
+
+	var b strings.Builder
+	if err := writeResult(&b, r); err != nil {
+		t.Fatalf("writeResult failed: %v", err)
+	}
+	expected := `"sources":[{"id":7,"path":"a.go","start_line":3,"end_line":5,"file_hash":"sha256:abc","type":"code"},` +
+		`{"id":8,"path":"docs/a.md","start_line":1,"end_line":1,"type":"documentation"}]`
+	if !strings.Contains(b.String(), expected) {
+		t.Errorf("Expected %s in %s", expected, b.String())
+	}
+}
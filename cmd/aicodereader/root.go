@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+	"github.com/JackDrogon/aicodereader/pkgs/utils"
+)
+
+// globalOptions holds the flags shared by every subcommand.
+type globalOptions struct {
+	provider         string
+	model            string
+	profile          string
+	explainContext   bool
+	retryFiltered    bool
+	verbose          bool
+	json             bool
+	sparseCheckout   bool
+	submodules       bool
+	fetchLFS         bool
+	followSymlinks   bool
+	gitFiles         bool
+	includeGenerated bool
+	gentle           bool
+	yes              bool
+	report           string
+	appendTo         string
+	docsDir          string
+	webhook          string
+	rpm              int
+	tpm              int
+	budget           float64
+
+	maxContextTokens int
+	chunkOverlap     int
+	maxFileSize      int64
+	maxDepth         int
+	maxFiles         int
+	sample           string
+
+	reasoningEffort string
+	thinkingBudget  int
+	stop            []string
+
+	depth string
+
+	treeFormat string
+	treeDepth  int
+	treeTokens int
+
+	gitDir   string
+	workTree string
+}
+
+// defaultMaxContextTokens matches the context window of current mainstream models.
+const defaultMaxContextTokens = 128000
+
+// defaultChunkOverlap keeps a few dozen lines of shared context between parts.
+const defaultChunkOverlap = 200
+
+// opts is populated from the root command's persistent flags.
+var opts globalOptions
+
+// runCommand is the subcommand being run, such as ask or "index export",
+// whose profile newProvider applies.
+var runCommand string
+
+// registerFinalizers registers the cobra finalizers once, however many root
+// commands are built.
+var registerFinalizers sync.Once
+
+// newRootCmd builds the aicodereader command tree.
+func newRootCmd() *cobra.Command {
+	// Finalizers also run after a command fails, unlike PersistentPostRunE
+	registerFinalizers.Do(func() { cobra.OnFinalize(recordUsage, closeAnswers) })
+
+	root := &cobra.Command{
+		Use:          "aicodereader",
+		Short:        "Read, summarize and review source code with an LLM",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			answers, savedAnswers = newAnswerSinks(cmd.OutOrStdout())
+			meter = &usageMeter{command: cmd.Name()}
+			runCommand = strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+			if _, err := utils.ParseSample(opts.sample); err != nil {
+				return err
+			}
+			if opts.sample != "" && opts.maxFiles <= 0 {
+				return errors.New("--sample picks --max-files files, set --max-files too")
+			}
+			return applyGitOverrides(opts.gitDir, opts.workTree)
+		},
+		// Sinks are closed here so that a failure fails the command, and
+		// by closeAnswers if the command failed first
+		PersistentPostRunE: func(cmd *cobra.Command, _ []string) error {
+			sink := answerSink()
+			answers, savedAnswers = nil, nil
+			return sink.Close(cmd.Context())
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&opts.provider, "provider", "", "LLM provider to use: openai, anthropic, gemini or azure (default from PROVIDER or config files, else openai)")
+	flags.StringVar(&opts.model, "model", "", "model to use (overrides MODEL and config files)")
+	flags.StringVar(&opts.profile, "profile", "", "apply this profile of the config files instead of the one they bind the subcommand to")
+	flags.BoolVar(&opts.explainContext, "explain-context", false, "print a per-file token breakdown of each prompt (always on for multi-file prompts)")
+	flags.BoolVarP(&opts.verbose, "verbose", "v", false, "log the latency of each request: time to first token, total time and tokens per second")
+	flags.BoolVar(&opts.json, "json", false, "print each answer as a JSON object with its usage and latency metadata")
+	flags.StringVar(&opts.report, "report", "", "also write the answers as a Markdown report to this file")
+	flags.StringVar(&opts.appendTo, "append-to", "", "also add the answers to this Markdown report, after those of earlier runs, creating it if needed")
+	flags.StringVar(&opts.docsDir, "docs-dir", "", "also write each answer as a Markdown page with YAML front matter under this directory, for MkDocs, Hugo and other static site generators")
+	flags.StringVar(&opts.webhook, "webhook", "", "also post a JSON summary of the answers to this URL once the command is done")
+	flags.BoolVar(&opts.gentle, "gentle", false, "space requests to stay under the provider's rate limit, as on free tiers, instead of failing with 429 errors")
+	flags.IntVar(&opts.rpm, "rpm", 0, "requests per minute --gentle stays under (overrides rate_limit in config files; default 3 if no limit is configured)")
+	flags.IntVar(&opts.tpm, "tpm", 0, "tokens per minute --gentle stays under (overrides rate_limit in config files)")
+	flags.BoolVarP(&opts.yes, "yes", "y", false, "run batches whose estimated cost exceeds the budget without asking")
+	flags.Float64Var(&opts.budget, "budget", 0, "estimated cost in dollars above which directory runs, summarize --all and ask --questions need --yes (overrides budget in config files; default 1)")
+	flags.BoolVar(&opts.retryFiltered, "retry-filtered", false, "retry once with a softened prompt when a provider's content filter rejects a request")
+
+	flags.IntVar(&opts.maxContextTokens, "max-context-tokens", defaultMaxContextTokens, "largest prompt to send, in tokens; bigger files are analyzed in parts (0 disables the check)")
+	flags.Int64Var(&opts.maxFileSize, "max-file-size", utils.DefaultMaxFileSizeBytes, "skip files larger than this many bytes when scanning directories (0 disables the limit)")
+	flags.IntVar(&opts.maxDepth, "max-depth", 0, "scan at most this many directory levels below the scanned directory (0 disables the limit)")
+	flags.IntVar(&opts.maxFiles, "max-files", 0, "stop scanning a directory after this many files, with a warning that the results are partial (0 disables the limit)")
+	flags.StringVar(&opts.sample, "sample", "", "with --max-files, scan everything and keep that many files picked by "+strings.Join(utils.Samples(), ", ")+" instead of the first found")
+	flags.BoolVar(&opts.submodules, "include-submodules", false, "scan git submodules when scanning directories")
+	flags.BoolVar(&opts.followSymlinks, "follow-symlinks", false, "follow symbolic links to files and directories when scanning directories")
+	flags.BoolVar(&opts.fetchLFS, "fetch-lfs", false, "download the content of Git LFS files with git lfs pull instead of skipping their pointer files")
+	flags.BoolVar(&opts.gitFiles, "git-files", false, "list the files of git work trees with git ls-files, applying git's ignore rules exactly, instead of walking directories")
+	flags.BoolVar(&opts.includeGenerated, "include-generated", false, "scan generated files: code marked \"DO NOT EDIT\" or @generated, linguist-generated files and minified JavaScript and CSS")
+	flags.BoolVar(&opts.sparseCheckout, "sparse-checkout", false, "skip files outside the git sparse-checkout patterns when scanning directories")
+	flags.IntVar(&opts.chunkOverlap, "chunk-overlap", defaultChunkOverlap, "tokens repeated between consecutive parts of a file analyzed in parts")
+
+	flags.StringVar(&opts.reasoningEffort, "reasoning-effort", "", "reasoning depth for reasoning models: low, medium or high (overrides REASONING_EFFORT and config files)")
+	flags.IntVar(&opts.thinkingBudget, "thinking-budget", 0, "tokens reasoning may use on Anthropic and Gemini models (default derived from --reasoning-effort)")
+	flags.StringArrayVar(&opts.stop, "stop", nil, "stop generating when the model outputs this sequence; repeat for several")
+
+	flags.StringVar(&opts.gitDir, "git-dir", "", "git directory of the repository, for bare repositories or unusual layouts (sets GIT_DIR)")
+	flags.StringVar(&opts.workTree, "work-tree", "", "work tree of the repository (sets GIT_WORK_TREE)")
+	flags.StringVar(&opts.treeFormat, "tree-format", "", "directory tree prepended to repository-level prompts: "+strings.Join(repomap.TreeFormats(), ", ")+" (default indent)")
+	flags.IntVar(&opts.treeDepth, "tree-depth", repomap.DefaultTreeDepth, "directory levels shown in the tree of repository-level prompts")
+	flags.IntVar(&opts.treeTokens, "tree-tokens", repomap.DefaultTreeTokens, "token budget of the directory tree; deeper levels are dropped to fit (0 disables the budget)")
+	flags.StringVar(&opts.depth, "depth", "", "pitch explanations at a reader's level: "+strings.Join(prompt.Depths(), ", "))
+
+	root.AddCommand(
+		newInitCmd(),
+		newModelsCmd(),
+		newReadCmd(),
+		newSummarizeCmd(),
+		newReviewCmd(),
+		newPRDescCmd(),
+		newCommitsCmd(),
+		newQuizCmd(),
+		newAskCmd(),
+		newQueriesCmd(),
+		newScanCmd(),
+		newEntryPointsCmd(),
+		newEndpointsCmd(),
+		newBinsizeCmd(),
+		newCorpusCmd(),
+		newFAQCmd(),
+		newIndexCmd(),
+		newSearchCmd(),
+		newExplainCmd(),
+		newSnippetCmd(),
+		newAggregateCmd(),
+		newUsageCmd(),
+		newEstimateCmd(),
+	)
+	return root
+}
+
+// applyGitOverrides exports --git-dir and --work-tree as GIT_DIR and
+// GIT_WORK_TREE, the way git itself passes them on, so repository discovery
+// (see utils.FindRepository) and any git subprocess agree on the repository.
+func applyGitOverrides(gitDir, workTree string) error {
+	for name, value := range map[string]string{"GIT_DIR": gitDir, "GIT_WORK_TREE": workTree} {
+		if value == "" {
+			continue
+		}
+		abs, err := filepath.Abs(value)
+		if err != nil {
+			return err
+		}
+		if err := os.Setenv(name, abs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,790 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/git"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+	"github.com/JackDrogon/aicodereader/pkgs/queries"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+	"github.com/JackDrogon/aicodereader/pkgs/utils"
+)
+
+// execute runs the root command with args and returns its output.
+func execute(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	return executeInput(t, "", args...)
+}
+
+// executeInput is execute with input on standard input.
+func executeInput(t *testing.T, input string, args ...string) (string, error) {
+	t.Helper()
+	// Keep the usage ledger and caches of test runs out of the user's cache
+	if ledgerPath == "" {
+		ledgerPath = filepath.Join(t.TempDir(), "usage.jsonl")
+		t.Cleanup(func() { ledgerPath = "" })
+	}
+	if os.Getenv("XDG_CACHE_HOME") == "" {
+		t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	}
+
+	var out bytes.Buffer
+	root := newRootCmd()
+	root.SetOut(&out)
+	root.SetErr(&out)
+	root.SetIn(strings.NewReader(input))
+	root.SetArgs(args)
+	err := root.Execute()
+	return out.String(), err
+}
+
+func TestScanCommand(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"main.go", "util.py", ".env"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x\n"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	out, err := execute(t, "scan", dir, "--include", "*.go")
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if expected := filepath.Join(dir, "main.go") + "\n"; out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+
+	out, err = execute(t, "scan", dir, "--hidden")
+	if err != nil {
+		t.Fatalf("scan --hidden failed: %v", err)
+	}
+	if !strings.Contains(out, ".env") {
+		t.Errorf("Expected hidden file in output, got %q", out)
+	}
+
+	out, err = execute(t, "scan", dir, "--include", "*.go", "--long")
+	if err != nil {
+		t.Fatalf("scan --long failed: %v", err)
+	}
+	if expected := "Go  1  2  " + filepath.Join(dir, "main.go") + "\n"; out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+
+	out, err = execute(t, "scan", dir, "--languages")
+	if err != nil {
+		t.Fatalf("scan --languages failed: %v", err)
+	}
+	expected := "language  files  lines  bytes\n" +
+		"Go        1      1      2\n" +
+		"Python    1      1      2\n" +
+		"total     2      2      4\n"
+	if out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+
+	out, err = execute(t, "scan", dir, "--max-files", "1", "--sample", "top-by-size")
+	if err != nil {
+		t.Fatalf("scan --sample failed: %v", err)
+	}
+	if lines := strings.Count(out, "\n"); lines != 1 {
+		t.Errorf("Expected a sample of 1 file, got %q", out)
+	}
+	if _, err := execute(t, "scan", dir, "--sample", "random"); err == nil {
+		t.Errorf("Expected --sample without --max-files to fail")
+	}
+	if _, err := execute(t, "scan", dir, "--max-files", "1", "--sample", "largest"); err == nil {
+		t.Errorf("Expected an unknown sampling strategy to fail")
+	}
+}
+
+func TestScanSources(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("package a\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	options := &utils.GetSourceListOptions{MaxFiles: 1}
+
+	files, truncated, err := scanSources(context.Background(), dir, options)
+	if err != nil || !truncated || len(files) != 1 {
+		t.Errorf("Expected 1 file reported as truncated, got %v, %v, %v", files, truncated, err)
+	}
+	if files, err := listSources(context.Background(), dir, options); err != nil || len(files) != 1 {
+		t.Errorf("Expected listSources to go on with the partial list, got %v, %v", files, err)
+	}
+	if files, truncated, err = scanSources(context.Background(), dir, &utils.GetSourceListOptions{}); err != nil || truncated || len(files) != 2 {
+		t.Errorf("Expected every file, got %v, %v, %v", files, truncated, err)
+	}
+}
+
+func TestEntryPointsCommand(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"cmd/app/main.go": "package main\n\nfunc main() {}\n",
+		"Dockerfile":      "FROM scratch\nENTRYPOINT [\"/app\"]\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	out, err := execute(t, "entrypoints", dir)
+	if err != nil {
+		t.Fatalf("entrypoints failed: %v", err)
+	}
+	expected := "Dockerfile:2       dockerfile  ENTRYPOINT [\"/app\"]\ncmd/app/main.go:3  go-main     func main\n"
+	if out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+
+	if _, err := execute(t, "entrypoints", t.TempDir()); err == nil {
+		t.Errorf("Expected an error without entry points")
+	}
+}
+
+func TestEndpointsCommand(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"1. 鉴权: 无；校验: 无"}}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+
+	dir := t.TempDir()
+	content := "package main\n\nimport \"net/http\"\n\nfunc main() {\n\thttp.HandleFunc(\"GET /health\", health)\n}\n\n" +
+		"func health(w http.ResponseWriter, r *http.Request) {}\n"
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := execute(t, "endpoints", dir)
+	if err != nil {
+		t.Fatalf("endpoints failed: %v", err)
+	}
+	expected := "METHOD  ROUTE    HANDLER  DEFINED AT  REGISTERED AT  FRAMEWORK\n" +
+		"GET     /health  health   main.go:9   main.go:6      net/http\n"
+	if out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+
+	out, err = execute(t, "endpoints", dir, "--annotate", "--csv")
+	if err != nil {
+		t.Fatalf("endpoints --annotate failed: %v", err)
+	}
+	expected = "METHOD,ROUTE,HANDLER,DEFINED AT,REGISTERED AT,FRAMEWORK,NOTES\n" +
+		"GET,/health,health,main.go:9,main.go:6,net/http,鉴权: 无；校验: 无\n"
+	if out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+
+	if _, err := execute(t, "endpoints", t.TempDir()); err == nil {
+		t.Errorf("Expected an error without routes")
+	}
+}
+
+func TestSavedQuery(t *testing.T) {
+	t.Cleanup(func() { onResult = nil })
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	root := t.TempDir()
+
+	if question, ok, err := (&savedQuery{}).resolve(root, "q"); err != nil || !ok || question != "q" {
+		t.Errorf("Expected a one-off question unchanged, got %q, %v, %v", question, ok, err)
+	}
+	if _, _, err := (&savedQuery{params: []string{"a=b"}}).resolve(root, "q"); err == nil {
+		t.Errorf("Expected --param without a saved query to fail")
+	}
+
+	save := &savedQuery{save: "callers"}
+	if _, ok, err := save.resolve(root, "who calls {{func}}?"); err != nil || ok {
+		t.Errorf("Expected a parameterized query to be saved without asking, got %v, %v", ok, err)
+	}
+
+	run := &savedQuery{run: "callers", params: []string{"func=Load"}}
+	question, ok, err := run.resolve(root, "")
+	if err != nil || !ok || question != "who calls Load?" {
+		t.Fatalf("Expected the saved question with its parameter, got %q, %v, %v", question, ok, err)
+	}
+	onResult(result{Source: "index", Content: "answer"})
+
+	historyDir, err := queries.DefaultHistoryDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs, err := queries.History(historyDir, "callers"); err != nil || len(runs) != 1 || runs[0].Question != "who calls Load?" || runs[0].Content != "answer" {
+		t.Errorf("Expected the answer in the query's history, got %+v, %v", runs, err)
+	}
+
+	if _, _, err := (&savedQuery{run: "missing"}).resolve(root, ""); err == nil {
+		t.Errorf("Expected an unknown query to fail")
+	}
+	if _, err := execute(t, "ask", "--run", "callers", "extra"); err == nil {
+		t.Errorf("Expected --run to take no question")
+	}
+}
+
+func TestGitOverrides(t *testing.T) {
+	t.Setenv("GIT_DIR", "")
+	t.Setenv("GIT_WORK_TREE", "")
+	dir := t.TempDir()
+
+	if _, err := execute(t, "scan", dir, "--git-dir", filepath.Join(dir, "repo.git"), "--work-tree", "."); err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if got := os.Getenv("GIT_DIR"); got != filepath.Join(dir, "repo.git") {
+		t.Errorf("Expected GIT_DIR set from --git-dir, got %q", got)
+	}
+	if cwd, _ := os.Getwd(); os.Getenv("GIT_WORK_TREE") != cwd {
+		t.Errorf("Expected GIT_WORK_TREE made absolute, got %q", os.Getenv("GIT_WORK_TREE"))
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		models = append(models, req.Model)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("MODEL", "")
+	t.Setenv("STREAM", "false")
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.MkdirAll(filepath.Join(home, "aicodereader"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	configFile := "profiles:\n  fast:\n    model: fast-model\n  strong:\n    model: strong-model\ncommands:\n  read: fast\n"
+	if err := os.WriteFile(filepath.Join(home, "aicodereader", "config.yaml"), []byte(configFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("a.go", []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"read", "-f", "a.go"},
+		{"read", "-f", "a.go", "--profile", "strong"},
+		{"read", "-f", "a.go", "--profile", "strong", "--model", "flag-model"},
+	} {
+		if _, err := execute(t, args...); err != nil {
+			t.Fatalf("%v failed: %v", args, err)
+		}
+	}
+	expected := []string{"fast-model", "strong-model", "flag-model"}
+	if !slices.Equal(models, expected) {
+		t.Errorf("Expected models %q, got %q", expected, models)
+	}
+
+	if _, err := execute(t, "read", "-f", "a.go", "--profile", "missing"); err == nil || !strings.Contains(err.Error(), `unknown profile "missing"`) {
+		t.Errorf("Expected an unknown profile to fail, got %v", err)
+	}
+}
+
+func TestInit(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	repo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+
+	// Provider, base URL, key, model and the project file
+	if _, err := executeInput(t, "anthropic\n\nsk-test\n\ny\n", "init", "--no-keyring"); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	userPath := filepath.Join(home, "aicodereader", "config.yaml")
+	user, err := config.ReadFile(userPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := config.Config{Provider: "anthropic", APIKey: "sk-test", Model: "claude-sonnet-4-5"}
+	if user.Config != expected {
+		t.Errorf("Expected user config %+v, got %+v", expected, user.Config)
+	}
+	if info, err := os.Stat(userPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the user config file to be private, got %v, %v", info.Mode(), err)
+	}
+	project, err := config.ReadFile(filepath.Join(repo, config.ProjectFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = config.Config{Provider: "anthropic", Model: "claude-sonnet-4-5"}
+	if project.Config != expected {
+		t.Errorf("Expected project config without the key %+v, got %+v", expected, project.Config)
+	}
+
+	if _, err := executeInput(t, "openai\n", "init"); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("Expected init to keep an existing config file, got %v", err)
+	}
+	if _, err := executeInput(t, "mistral\n", "init", "--force"); err == nil || !strings.Contains(err.Error(), "unknown provider") {
+		t.Errorf("Expected an unknown provider to fail, got %v", err)
+	}
+	if _, err := executeInput(t, "openai\n", "init", "--force"); err == nil || !strings.Contains(err.Error(), "standard input") {
+		t.Errorf("Expected init to fail without answers, got %v", err)
+	}
+}
+
+func TestModels(t *testing.T) {
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/models" {
+			fmt.Fprint(w, `{"object":"list","data":[{"id":"gpt-4o-mini"},{"id":"my-finetune"}]}`)
+			return
+		}
+		var req struct {
+			Model string `json:"model"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		models = append(models, req.Model)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("STREAM", "false")
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("a.go", []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := execute(t, "models")
+	if err != nil {
+		t.Fatalf("models failed: %v", err)
+	}
+	for _, expected := range []string{"gpt-4o-mini  128k     $0.15      $0.6", "my-finetune  -        -          -"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in the model list, got %q", expected, out)
+		}
+	}
+
+	if _, err := execute(t, "read", "-f", "a.go", "--model", "4o-mini"); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if _, err := execute(t, "read", "-f", "a.go", "--model", "gtp-4o"); err == nil || !strings.Contains(err.Error(), "did you mean gpt-4o") {
+		t.Errorf("Expected a typo to fail with suggestions, got %v", err)
+	}
+	if !slices.Equal(models, []string{"gpt-4o-mini"}) {
+		t.Errorf("Expected only the completed model to be requested, got %q", models)
+	}
+}
+
+func TestCommandsRequireInput(t *testing.T) {
+	for _, command := range []string{"read", "summarize", "review"} {
+		if _, err := execute(t, command); err == nil {
+			t.Errorf("Expected %s without -f or -d to fail", command)
+		}
+	}
+
+	if _, err := execute(t, "read", "-f", "a.go", "-d", "."); err == nil {
+		t.Errorf("Expected -f and -d together to fail")
+	}
+
+	if _, err := execute(t, "summarize", "--all", "a.go"); err == nil {
+		t.Errorf("Expected summarize --all with files to fail")
+	}
+
+	if _, err := execute(t, "ask", "-f", "a.go"); err == nil {
+		t.Errorf("Expected ask without a question to fail")
+	}
+
+	if _, err := execute(t, "search"); err == nil {
+		t.Errorf("Expected search without a query to fail")
+	}
+	if _, err := execute(t, "index", "gc", t.TempDir()); err == nil || !strings.Contains(err.Error(), "no search index") {
+		t.Errorf("Expected index gc without an index to fail, got %v", err)
+	}
+
+	if _, err := execute(t, "explain", "a+b"); err == nil {
+		t.Errorf("Expected explain without --kind to fail")
+	}
+}
+
+func TestTaskQuestion(t *testing.T) {
+	if got := taskQuestion("review", ""); got != "review" {
+		t.Errorf("Expected %q, got %q", "review", got)
+	}
+	if got := taskQuestion("review", "focus on locking"); got != "review\n\nfocus on locking" {
+		t.Errorf("Expected extra instructions appended, got %q", got)
+	}
+}
+
+func TestExpandPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.py"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x\n"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+
+	paths, err := expandPaths([]string{a, filepath.Join(dir, "*.go"), "missing.txt"})
+	if err != nil {
+		t.Fatalf("expandPaths failed: %v", err)
+	}
+	if expected := []string{a, b, "missing.txt"}; !slices.Equal(paths, expected) {
+		t.Errorf("Expected %v, got %v", expected, paths)
+	}
+
+	if _, err := expandPaths([]string{filepath.Join(dir, "*.rs")}); err == nil {
+		t.Errorf("Expected error for glob matching nothing")
+	}
+
+	literal := filepath.Join(dir, "notes [draft].md")
+	if err := os.WriteFile(literal, []byte("x\n"), 0644); err != nil {
+		t.Fatalf("Failed to create %s: %v", literal, err)
+	}
+	if paths, err := expandPaths([]string{literal}); err != nil || !slices.Equal(paths, []string{literal}) {
+		t.Errorf("Expected %q taken literally, got %v, %v", literal, paths, err)
+	}
+}
+
+func TestCheckContextSize(t *testing.T) {
+	saved := opts
+	t.Cleanup(func() { opts = saved })
+
+	provider := llm.NewOpenAIProvider("", "")
+	p := prompt.Build("q", prompt.File{Path: "a.go", Content: strings.Repeat("x", 4000)})
+
+	opts.maxContextTokens = 100
+	if err := checkContextSize(provider, config.Config{}, p); err == nil || !strings.Contains(err.Error(), "a.go") {
+		t.Errorf("Expected over-limit error naming the file, got %v", err)
+	}
+
+	opts.maxContextTokens = 10000
+	if err := checkContextSize(provider, config.Config{}, p); err != nil {
+		t.Errorf("Expected prompt within limit, got %v", err)
+	}
+
+	// The context window of a known model bounds prompts under the flag
+	large := prompt.Build("q", prompt.File{Path: "b.go", Content: strings.Repeat("word ", 9000)})
+	opts.maxContextTokens = defaultMaxContextTokens
+	if err := checkContextSize(provider, config.Config{Model: "gpt-4"}, large); err == nil || !strings.Contains(err.Error(), "over the context window of gpt-4 of 8192") {
+		t.Errorf("Expected an error naming the context window of gpt-4, got %v", err)
+	}
+	if err := checkContextSize(provider, config.Config{Model: "gpt-4o"}, large); err != nil {
+		t.Errorf("Expected the prompt within the context window of gpt-4o, got %v", err)
+	}
+
+	opts.maxContextTokens = 0
+	if err := checkContextSize(provider, config.Config{}, p); err != nil {
+		t.Errorf("Expected zero limit to disable the check, got %v", err)
+	}
+}
+
+func TestDirectoryTree(t *testing.T) {
+	saved := opts
+	t.Cleanup(func() { opts = saved })
+	opts.treeDepth = repomap.DefaultTreeDepth
+	opts.treeTokens = repomap.DefaultTreeTokens
+
+	dir := t.TempDir()
+	for _, name := range []string{"main.go", "pkgs/a/a.go"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package a\n"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	tree, err := directoryTree(context.Background(), dir, nil, nil)
+	if err != nil || tree != "pkgs/\n  a/\n    a.go\nmain.go\n" {
+		t.Errorf("Expected indented tree of the scanned files, got %q, %v", tree, err)
+	}
+
+	opts.treeFormat = "none"
+	if tree, err := directoryTree(context.Background(), dir, nil, nil); err != nil || tree != "" {
+		t.Errorf("Expected no tree with --tree-format none, got %q, %v", tree, err)
+	}
+
+	opts.treeFormat = "xml"
+	if _, err := directoryTree(context.Background(), dir, nil, nil); err == nil {
+		t.Errorf("Expected unknown tree format to fail")
+	}
+}
+
+func TestFilesAndDirAreExclusive(t *testing.T) {
+	if _, err := execute(t, "review", "-d", ".", "a.go"); err == nil {
+		t.Errorf("Expected positional files with -d to fail")
+	}
+}
+
+func TestReadFilesStdin(t *testing.T) {
+	diff := "diff --git a/a.go b/a.go\n@@ -1 +1 @@\n-x := 1\n+x := 2\n"
+	files, err := readFiles([]string{"-"}, strings.NewReader(diff))
+	if err != nil {
+		t.Fatalf("readFiles failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "stdin.diff" || files[0].Content != diff {
+		t.Errorf("Expected stdin read as stdin.diff, got %+v", files)
+	}
+
+	if _, err := readFiles([]string{"-"}, strings.NewReader(" \n")); err == nil {
+		t.Errorf("Expected error for empty stdin")
+	}
+
+	if !isPiped(strings.NewReader("")) {
+		t.Errorf("Expected non-file readers to count as piped")
+	}
+}
+
+func TestStdinInput(t *testing.T) {
+	if _, ok := stdinInput(strings.NewReader("")); ok {
+		t.Errorf("Expected an empty reader not to count as input")
+	}
+	r, ok := stdinInput(strings.NewReader("package a\n"))
+	if content, err := io.ReadAll(r); !ok || err != nil || string(content) != "package a\n" {
+		t.Errorf("Expected the whole input, got %q, %v, %v", content, ok, err)
+	}
+
+	// A pipe closed without data, as cron and CI jobs may get
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+	defer reader.Close()
+	if _, ok := stdinInput(reader); ok {
+		t.Errorf("Expected an empty pipe not to count as input")
+	}
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	if _, ok := stdinInput(devNull); ok {
+		t.Errorf("Expected %s not to count as input", os.DevNull)
+	}
+
+	if _, err := execute(t, "ask", "what does it do?"); err == nil || !strings.Contains(err.Error(), "no search index") {
+		t.Errorf("Expected ask with empty stdin to use the search index, got %v", err)
+	}
+}
+
+// gitRepo creates a repository in a temporary directory, made the working
+// directory, with a.go committed on main and changed on the checked out
+// branch feature. It returns a function running git in the repository.
+func gitRepo(t *testing.T) func(args ...string) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@example.com",
+			"GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@example.com")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile("a.go", []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	write("package a\n")
+	git("add", "a.go")
+	git("commit", "-q", "-m", "initial")
+	git("checkout", "-q", "-b", "feature")
+	write("package a\n\nfunc Parse() {}\n")
+	git("commit", "-q", "-am", "feat: add Parse")
+	return git
+}
+
+func TestPRDesc(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		prompts = append(prompts, req.Messages[len(req.Messages)-1].Content)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"# Add Parse"}}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("STREAM", "false")
+	gitRepo(t)
+	if err := os.WriteFile("template.md", []byte("## Summary\n\n## Checklist\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execute(t, "pr-desc"); err != nil {
+		t.Fatalf("pr-desc failed: %v", err)
+	}
+	if _, err := execute(t, "pr-desc", "--base", "main", "--template", "template.md"); err != nil {
+		t.Fatalf("pr-desc --template failed: %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(prompts))
+	}
+	for _, p := range prompts {
+		if !strings.Contains(p, "- feat: add Parse") || !strings.Contains(p, "+func Parse() {}") {
+			t.Errorf("Expected the commits and diff in the prompt, got %q", p)
+		}
+	}
+	if !strings.Contains(prompts[1], "## Checklist") {
+		t.Errorf("Expected the template in the prompt, got %q", prompts[1])
+	}
+
+	if _, err := execute(t, "pr-desc", "--base", "feature"); err == nil {
+		t.Errorf("Expected a branch without changes to fail")
+	}
+	if _, err := execute(t, "pr-desc", "--base", "missing"); err == nil {
+		t.Errorf("Expected an unknown base to fail")
+	}
+}
+
+func TestBuildPRDescription(t *testing.T) {
+	count := func(text string) int { return len(text) }
+	diffs := []git.FileDiff{
+		{Path: "a.go", Patch: "+a\n"},
+		{Path: "go.sum", Patch: strings.Repeat("+sum\n", 100)},
+	}
+
+	if p := buildPRDescription(count, 0, nil, diffs, prompt.File{}); len(p.Files) != 2 {
+		t.Errorf("Expected every diff without a limit, got %+v", p.Files)
+	}
+	p := buildPRDescription(count, 1000, nil, diffs, prompt.File{})
+	if len(p.Files) != 1 || p.Files[0].Path != "a.go" || !strings.Contains(p.User, "go.sum") {
+		t.Errorf("Expected the go.sum diff left out and named, got %+v", p)
+	}
+}
+
+func TestCommits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"### 1\nfix: handle empty input\n\nParse returns early."}}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	git := gitRepo(t)
+
+	out, err := execute(t, "commits", "--check")
+	if err != nil || out != "All 1 commits follow Conventional Commits\n" {
+		t.Fatalf("Expected the commit to pass, got %q, %v", out, err)
+	}
+
+	if err := os.WriteFile("a.go", []byte("package a\n\nfunc Parse() { return }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("commit", "-q", "-am", "Handle empty input.")
+	out, err = execute(t, "commits", "--check")
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 commits") {
+		t.Errorf("Expected --check to fail, got %v", err)
+	}
+	if !strings.Contains(out, " Handle empty input.\n  - header is not of the form") || strings.Contains(out, "建议") {
+		t.Errorf("Expected the failing commit without a rewrite, got %q", out)
+	}
+
+	todo := filepath.Join(t.TempDir(), "todo")
+	out, err = execute(t, "commits", "--todo", todo)
+	if err != nil {
+		t.Fatalf("commits failed: %v", err)
+	}
+	if !strings.Contains(out, "  建议：\n    fix: handle empty input\n    \n    Parse returns early.\n") {
+		t.Errorf("Expected the rewritten message, got %q", out)
+	}
+	// Applying the todo list rewrites the message
+	git("-c", "sequence.editor=cp "+todo, "rebase", "-q", "-i", "main")
+	message, err := exec.Command("git", "log", "-1", "--format=%B").Output()
+	if err != nil || strings.TrimSpace(string(message)) != "fix: handle empty input\n\nParse returns early." {
+		t.Errorf("Expected the rebase to apply the rewritten message, got %q, %v", message, err)
+	}
+
+	if _, err := execute(t, "commits", "--check", "--todo", todo); err == nil {
+		t.Errorf("Expected --check with --todo to fail")
+	}
+	if _, err := execute(t, "commits", "HEAD..HEAD"); err == nil {
+		t.Errorf("Expected an empty range to fail")
+	}
+}
+
+func TestBinsize(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		prompts = append(prompts, req.Messages[len(req.Messages)-1].Content)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"**modernc.org/sqlite/lib**: use a smaller driver"}}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("STREAM", "false")
+	nm := "  8531e0    2097152 T modernc.org/sqlite/lib._sqlite3VdbeExec\n" +
+		"  8c3e00      20480 T modernc.org/sqlite/lib._sqlite3Pragma\n" +
+		"  7b94e0        512 T main.main\n" +
+		" 17f0020   33554432 B crypto/internal/fips140/drbg.memory\n"
+
+	out, err := executeInput(t, nm, "binsize", "--input", "-", "--list")
+	if err != nil {
+		t.Fatalf("binsize --list failed: %v", err)
+	}
+	expected := "PACKAGE                 SIZE     SHARE   SYMBOLS\n" +
+		"modernc.org/sqlite/lib  2.0 MiB  100.0%  2\n" +
+		"main                    512 B    0.0%    1\n" +
+		"total                   2.0 MiB\n"
+	if out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+	if len(prompts) != 0 {
+		t.Errorf("Expected --list not to ask the model")
+	}
+
+	if _, err := executeInput(t, nm, "binsize", "--input", "-", "--top", "1"); err != nil {
+		t.Fatalf("binsize failed: %v", err)
+	}
+	if len(prompts) != 1 || !strings.Contains(prompts[0], "modernc.org/sqlite/lib") || strings.Contains(prompts[0], "\tmain\n") {
+		t.Errorf("Expected the largest package in the prompt, got %q", prompts)
+	}
+
+	if _, err := execute(t, "binsize"); err == nil {
+		t.Errorf("Expected binsize without a binary or --input to fail")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/utils"
+)
+
+// newScanCmd creates the scan command, which lists the files a directory run
+// would analyze without contacting a provider.
+func newScanCmd() *cobra.Command {
+	var (
+		include     string
+		hidden      bool
+		noGitignore bool
+		binary      bool
+		long        bool
+		languages   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "scan [dir]",
+		Short: "List the files that would be analyzed in a directory",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+
+			options := &utils.GetSourceListOptions{
+				RespectGitignore:      !noGitignore,
+				IncludeHidden:         hidden,
+				IncludePatterns:       splitPatterns(include),
+				MaxFileSizeBytes:      opts.maxFileSize,
+				SkipBinary:            !binary,
+				RespectSparseCheckout: opts.sparseCheckout,
+				IncludeSubmodules:     opts.submodules,
+				FetchLFS:              opts.fetchLFS,
+				FollowSymlinks:        opts.followSymlinks,
+				UseGitLsFiles:         opts.gitFiles,
+				SkipGenerated:         !opts.includeGenerated,
+				MaxDepth:              opts.maxDepth,
+				MaxFiles:              opts.maxFiles,
+				Sample:                utils.Sample(opts.sample),
+			}
+			if long || languages {
+				entries, err := utils.GetSourceEntriesContext(cmd.Context(), dir, options)
+				if err = partialScan(err); err != nil {
+					return fmt.Errorf("failed to scan directory: %w", err)
+				}
+				if languages {
+					return writeLanguages(cmd.OutOrStdout(), entries)
+				}
+				return writeEntries(cmd.OutOrStdout(), entries)
+			}
+
+			files, err := listSources(cmd.Context(), dir, options)
+			if err != nil {
+				return fmt.Errorf("failed to scan directory: %w", err)
+			}
+
+			for _, path := range files {
+				fmt.Fprintln(cmd.OutOrStdout(), path)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&include, "include", "", "comma-separated glob patterns selecting files (e.g. \"*.go,*.py\")")
+	cmd.Flags().BoolVar(&hidden, "hidden", false, "include hidden files")
+	cmd.Flags().BoolVar(&noGitignore, "no-gitignore", false, "do not apply .gitignore rules")
+	cmd.Flags().BoolVar(&binary, "binary", false, "include binary files")
+	cmd.Flags().BoolVarP(&long, "long", "l", false, "also print each file's language, line count and size")
+	cmd.Flags().BoolVar(&languages, "languages", false, "print the number of files, lines and bytes per language instead of the files")
+	return cmd
+}
+
+// writeEntries prints entries as aligned columns of language, lines, bytes
+// and path.
+func writeEntries(w io.Writer, entries []utils.SourceEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", cmp.Or(entry.Language, "-"), entry.Lines, entry.Size, entry.Path)
+	}
+	return tw.Flush()
+}
+
+// languageStats totals the files of one language.
+type languageStats struct {
+	language string
+	files    int
+	lines    int
+	bytes    int64
+}
+
+// writeLanguages prints the files, lines and bytes of each language in
+// entries, most lines first, followed by the totals.
+func writeLanguages(w io.Writer, entries []utils.SourceEntry) error {
+	byLanguage := make(map[string]*languageStats)
+	total := languageStats{language: "total"}
+	for _, entry := range entries {
+		language := cmp.Or(entry.Language, "other")
+		stats, ok := byLanguage[language]
+		if !ok {
+			stats = &languageStats{language: language}
+			byLanguage[language] = stats
+		}
+		for _, s := range []*languageStats{stats, &total} {
+			s.files++
+			s.lines += entry.Lines
+			s.bytes += entry.Size
+		}
+	}
+
+	sorted := make([]*languageStats, 0, len(byLanguage))
+	for _, stats := range byLanguage {
+		sorted = append(sorted, stats)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].lines != sorted[j].lines {
+			return sorted[i].lines > sorted[j].lines
+		}
+		return sorted[i].language < sorted[j].language
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "language\tfiles\tlines\tbytes")
+	for _, stats := range append(sorted, &total) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", stats.language, stats.files, stats.lines, stats.bytes)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/output"
+)
+
+// answers receives the answers of the run. The root command sets it up from
+// --report, --append-to, --docs-dir and --webhook before a command runs and
+// closes it after.
+var answers output.Sink
+
+// savedAnswers is the part of answers that saves them, without the terminal.
+var savedAnswers output.Sink
+
+// newAnswerSinks returns the sink answers are delivered to: the terminal,
+// written to w, plus the sink saving them, which it also returns.
+func newAnswerSinks(w io.Writer) (output.Sink, output.Sink) {
+	saved := newSavedAnswerSink()
+	return output.Multi(&terminalSink{w: w}, saved), saved
+}
+
+// newSavedAnswerSink returns the sink saving answers to the --report and
+// --append-to files, the --docs-dir pages and the --webhook URL if set.
+func newSavedAnswerSink() output.Sink {
+	var sinks []output.Sink
+	if opts.report != "" {
+		sinks = append(sinks, output.NewReportSink(opts.report))
+	}
+	if opts.appendTo != "" {
+		sinks = append(sinks, output.NewAppendSink(opts.appendTo))
+	}
+	if opts.docsDir != "" {
+		sinks = append(sinks, output.NewDocsSink(opts.docsDir))
+	}
+	if opts.webhook != "" {
+		sinks = append(sinks, output.NewWebhookSink(opts.webhook, nil))
+	}
+	return output.Multi(sinks...)
+}
+
+// answerSink returns answers, printing to the terminal only if the root
+// command did not set it up.
+func answerSink() output.Sink {
+	if answers == nil {
+		answers = &terminalSink{w: os.Stdout}
+	}
+	return answers
+}
+
+// closeAnswers closes the sinks of a run that failed before the root
+// command's PersistentPostRunE closed them, so that reports and webhooks keep
+// the answers given before the failure.
+func closeAnswers() {
+	sink := answers
+	answers, savedAnswers = nil, nil
+	if sink == nil {
+		return
+	}
+	if err := sink.Close(context.Background()); err != nil {
+		log.Printf("WARNING: %v", err)
+	}
+}
+
+// saveAnswer delivers answer to savedAnswers only, for commands that print
+// their answers as a document of their own, such as faq. A failure is
+// logged, as the document is written all the same.
+func saveAnswer(answer output.Answer) {
+	if savedAnswers == nil {
+		return
+	}
+	if err := savedAnswers.Done(answer); err != nil {
+		log.Printf("WARNING: %s: %v", answer.Source, err)
+	}
+}
+
+// terminalSink prints answers to w: streamed answers as they arrive, other
+// answers once finished, and with --json each answer as a line of JSON.
+type terminalSink struct {
+	w io.Writer
+	r streamRenderer
+	// streamed is set once the current answer started printing.
+	streamed bool
+}
+
+// Delta implements output.Sink.
+func (s *terminalSink) Delta(event llm.Event) error {
+	if !opts.json {
+		s.r.render(s.w, event)
+		s.streamed = true
+	}
+	return nil
+}
+
+// Done implements output.Sink.
+func (s *terminalSink) Done(answer output.Answer) error {
+	defer func() { s.r, s.streamed = streamRenderer{}, false }()
+	if opts.json {
+		return writeResult(s.w, newResult(answer))
+	}
+
+	if s.streamed {
+		fmt.Fprintln(s.w)
+		if s.r.usage != nil {
+			log.Printf("usage: %d prompt tokens, %d completion tokens", s.r.usage.PromptTokens, s.r.usage.CompletionTokens)
+		}
+	} else {
+		fmt.Fprintln(s.w, "----- 推理过程  -----")
+		fmt.Fprintln(s.w, answer.Reasoning)
+
+		fmt.Fprintln(s.w, "----- 最终回答 -----")
+		fmt.Fprintln(s.w, answer.Content)
+	}
+	logStats(answer.Stats)
+	return nil
+}
+
+// Close implements output.Sink.
+func (s *terminalSink) Close(context.Context) error {
+	return nil
+}
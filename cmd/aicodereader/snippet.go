@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/lang"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
+
+// newSnippetCmd creates the snippet command: it opens $EDITOR on a scratch
+// file, detects the language of whatever is pasted there and asks a question
+// about it.
+func newSnippetCmd() *cobra.Command {
+	var question string
+
+	cmd := &cobra.Command{
+		Use:   "snippet",
+		Short: "Paste code into $EDITOR and ask about it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			snippet, err := editScratch()
+			if err != nil {
+				return err
+			}
+			if strings.TrimSpace(snippet) == "" {
+				return errors.New("snippet is empty, nothing to ask about")
+			}
+
+			language := lang.FromContent(snippet)
+			if language != "" {
+				log.Printf("detected language: %s", language)
+			}
+
+			provider, cfg, err := newProvider()
+			if err != nil {
+				return err
+			}
+
+			return runPrompt(cmd.Context(), provider, cfg, prompt.Build(question, prompt.File{Language: language, Content: snippet}))
+		},
+	}
+
+	cmd.Flags().StringVarP(&question, "prompt", "p", "", "question to ask about the snippet")
+	return cmd
+}
+
+// editScratch opens $EDITOR (vi if unset) on an empty temporary file and
+// returns what the user saved.
+func editScratch() (string, error) {
+	scratch, err := os.CreateTemp("", "aicodereader-snippet-*.txt")
+	if err != nil {
+		return "", err
+	}
+	scratch.Close()
+	defer os.Remove(scratch.Name())
+
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+
+	cmd := exec.Command(editor[0], append(editor[1:], scratch.Name())...) // #nosec G204 -- user-chosen editor
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to run editor %q: %w", editor[0], err)
+	}
+
+	content, err := os.ReadFile(scratch.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/entrypoint"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+	"github.com/JackDrogon/aicodereader/pkgs/summary"
+	"github.com/JackDrogon/aicodereader/pkgs/tokens"
+)
+
+// newSummarizeCmd creates the summarize command. With --all it summarizes
+// the whole repository instead of the selected files.
+func newSummarizeCmd() *cobra.Command {
+	var (
+		all     bool
+		noCache bool
+	)
+
+	cmd := newTaskCmd("summarize", "Summarize what files or a directory do", prompt.SummarizeQuestion)
+	runTask := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if !all {
+			return runTask(cmd, args)
+		}
+		if len(args) > 0 || cmd.Flags().Changed("file") || cmd.Flags().Changed("prompt") {
+			return errors.New("--all summarizes a whole directory and cannot be combined with files or -p")
+		}
+
+		dir, _ := cmd.Flags().GetString("dir")
+		include, _ := cmd.Flags().GetString("include")
+		return summarizeAll(cmd.Context(), cmd.OutOrStdout(), cmp.Or(dir, repomap.FindRoot(".")), splitPatterns(include), !noCache)
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "summarize every file, then each directory, then the whole repository (-d or the repository root)")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false, "with --all, ignore and do not update cached summaries")
+	return cmd
+}
+
+// summarizeAll writes a hierarchical summary of the files under root to w.
+// Intermediate summaries are cached unless useCache is false.
+func summarizeAll(ctx context.Context, w io.Writer, root string, patterns []string, useCache bool) error {
+	files, err := listSources(ctx, root, sourceListOptions(patterns))
+	if err != nil {
+		return fmt.Errorf("failed to scan directory: %w", err)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files to summarize in %s", root)
+	}
+
+	provider, cfg, err := newProvider()
+	if err != nil {
+		return err
+	}
+
+	tree, err := directoryTree(ctx, root, files, tokenCounter(provider, cfg))
+	if err != nil {
+		return err
+	}
+
+	s := &summary.Summarizer{
+		Complete: func(ctx context.Context, p prompt.Prompt) (string, error) {
+			return summaryText(ctx, provider, cfg, p)
+		},
+		Model: cfg.Provider + "/" + cfg.Model,
+		Progress: func(name string, cached bool) {
+			if cached {
+				log.Printf("%s: cached", name)
+			} else {
+				log.Printf("%s: summarizing", name)
+			}
+		},
+		OnSkip:      func(name string, err error) { log.Printf("skipping %s: %v", name, err) },
+		Tree:        tree,
+		EntryPoints: entrypoint.Format(entrypoint.Detect(root, files)),
+	}
+	if useCache {
+		cacheDir, err := summary.DefaultCacheDir()
+		if err != nil {
+			return err
+		}
+		s.Cache = summary.NewCache(cacheDir)
+	}
+
+	log.Printf("found %d files in %s", len(files), root)
+	prompts, folds, err := s.Plan(root, files)
+	if err != nil {
+		return err
+	}
+	e := estimateSummary(cfg, prompts, folds)
+	e.Files = len(files)
+	if err := confirmCost(cfg, e); err != nil {
+		return err
+	}
+	text, stats, err := s.Summarize(ctx, root, files)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, text)
+	saveAnswer(newSavedAnswer(cfg, root, text, llm.Stats{}))
+	log.Printf("summarized %d files and %d directories (%d cached, %d skipped)",
+		stats.Files, stats.Dirs, stats.Cached, stats.Skipped)
+	return nil
+}
+
+// estimateSummary estimates a summarize --all run sending the file prompts,
+// then folds directory and repository prompts. Each fold's prompt holds the
+// summaries of its entries, and every file and directory summary is folded
+// once, so the folds take up about as many input tokens as the summaries
+// before them output.
+func estimateSummary(cfg config.Config, prompts []prompt.Prompt, folds int) tokens.Estimate {
+	e := estimatePrompts(cfg, prompts)
+	if folds == 0 {
+		return e
+	}
+	for range folds {
+		e.Add(0)
+	}
+	e.InputTokens += (len(prompts) + folds - 1) * tokens.OutputTokensPerRequest
+	return e
+}
+
+// summaryText answers one step of the --all pipeline. Prompts over the
+// context size limit are rejected rather than sent, so the file is skipped.
+func summaryText(ctx context.Context, provider llm.Provider, cfg config.Config, p prompt.Prompt) (string, error) {
+	if err := checkContextSize(provider, cfg, p); err != nil {
+		return "", err
+	}
+	return completeText(ctx, provider, cfg, p)
+}
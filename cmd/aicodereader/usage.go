@@ -0,0 +1,180 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JackDrogon/aicodereader/pkgs/config"
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+	"github.com/JackDrogon/aicodereader/pkgs/repomap"
+	"github.com/JackDrogon/aicodereader/pkgs/tokens"
+	"github.com/JackDrogon/aicodereader/pkgs/usage"
+)
+
+// meter tallies the usage of the run. The root command sets it up before a
+// command runs, and the tally goes to the ledger when the command is done,
+// even if it failed.
+var meter *usageMeter
+
+// ledgerPath is the path of the usage ledger, usage.DefaultPath() if empty.
+// Tests point it at a temporary file.
+var ledgerPath string
+
+// usageLedger returns the usage ledger.
+func usageLedger() (*usage.Ledger, error) {
+	path := ledgerPath
+	if path == "" {
+		var err error
+		if path, err = usage.DefaultPath(); err != nil {
+			return nil, err
+		}
+	}
+	return usage.NewLedger(path), nil
+}
+
+// usageMeter tallies usage by provider and model.
+type usageMeter struct {
+	command string
+
+	mu      sync.Mutex
+	entries []*usage.Entry
+}
+
+// runMeter returns meter, setting one up if the root command did not.
+func runMeter() *usageMeter {
+	if meter == nil {
+		meter = &usageMeter{}
+	}
+	return meter
+}
+
+// record returns a MeterFunc tallying the requests sent with cfg. Requests
+// to cfg's model are priced as in cost estimates, and others, such as
+// embedding requests, from the price table only.
+func (m *usageMeter) record(cfg config.Config) llm.MeterFunc {
+	return func(model string, u llm.Usage) {
+		price, ok := tokens.PriceFor(model)
+		if model == cfg.Model {
+			price, ok = priceFor(cfg)
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		entry := m.entry(cmp.Or(cfg.Provider, llm.ProviderOpenAI), model)
+		entry.Requests++
+		entry.InputTokens += u.PromptTokens
+		entry.OutputTokens += u.CompletionTokens
+		if ok {
+			entry.Cost += price.Cost(u.PromptTokens, u.CompletionTokens)
+		}
+	}
+}
+
+// entry returns the entry of provider and model, adding it if needed.
+func (m *usageMeter) entry(provider, model string) *usage.Entry {
+	for _, entry := range m.entries {
+		if entry.Provider == provider && entry.Model == model {
+			return entry
+		}
+	}
+	entry := &usage.Entry{Command: m.command, Provider: provider, Model: model}
+	m.entries = append(m.entries, entry)
+	return entry
+}
+
+// recordUsage adds the run's usage to the ledger. A ledger that cannot be
+// written only costs the record, not the run.
+func recordUsage() {
+	m := meter
+	meter = nil
+	if m == nil || len(m.entries) == 0 {
+		return
+	}
+
+	project, err := filepath.Abs(repomap.FindRoot("."))
+	if err != nil {
+		project = repomap.FindRoot(".")
+	}
+	now := time.Now()
+	entries := make([]usage.Entry, len(m.entries))
+	for i, entry := range m.entries {
+		entries[i] = *entry
+		entries[i].Time, entries[i].Project = now, project
+	}
+
+	ledger, err := usageLedger()
+	if err == nil {
+		err = ledger.Append(entries...)
+	}
+	if err != nil {
+		log.Printf("WARNING: failed to record usage: %v", err)
+	}
+}
+
+// newUsageCmd creates the usage command, which reports the tokens and cost
+// of past runs from the ledger.
+func newUsageCmd() *cobra.Command {
+	var since string
+
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Report the tokens and cost of past runs per model, project and command",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			start, err := usage.ParseSince(since, time.Now())
+			if err != nil {
+				return err
+			}
+			ledger, err := usageLedger()
+			if err != nil {
+				return err
+			}
+			entries, err := ledger.Read(start)
+			if err != nil {
+				return err
+			}
+			return printUsage(cmd.OutOrStdout(), entries)
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "30d", "report runs since this long ago, such as 7d, 2w or 12h, or since a date such as 2024-01-31")
+	return cmd
+}
+
+// printUsage writes the totals of entries per model, project and command to
+// w.
+func printUsage(w io.Writer, entries []usage.Entry) error {
+	if len(entries) == 0 {
+		_, err := fmt.Fprintln(w, "no usage recorded in this period")
+		return err
+	}
+
+	groups := []struct {
+		title string
+		key   func(usage.Entry) string
+	}{
+		{"MODEL", func(e usage.Entry) string { return e.Provider + "/" + e.Model }},
+		{"PROJECT", func(e usage.Entry) string { return e.Project }},
+		{"COMMAND", func(e usage.Entry) string { return e.Command }},
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, group := range groups {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%s\tRUNS\tREQUESTS\tINPUT\tOUTPUT\tCOST\n", group.title)
+		for _, total := range usage.Totals(entries, group.key) {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t$%.2f\n",
+				total.Key, total.Runs, total.Requests, total.InputTokens, total.OutputTokens, total.Cost)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,181 @@
+// Package binsize reads the symbol sizes of a Go binary, as printed by go
+// tool nm -size or by bloaty --csv, and adds them up per package, to find
+// what makes a binary large.
+package binsize
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Other groups the symbols that belong to no Go package, such as C
+// functions and runtime metadata like the function tables.
+const Other = "(other)"
+
+// Symbol is a symbol of a binary.
+type Symbol struct {
+	Name string
+	// Size is the number of bytes the symbol takes up in the file.
+	Size int64
+}
+
+// PackageSize is the size of the symbols of a package.
+type PackageSize struct {
+	Package string
+	Size    int64
+	Symbols int
+}
+
+// Parse reads the output of go tool nm -size, or of bloaty --csv, told apart
+// by the header bloaty starts with.
+func Parse(r io.Reader) ([]Symbol, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(256)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	header, _, _ := strings.Cut(string(head), "\n")
+	if strings.Contains(header, ",vmsize") || strings.Contains(header, ",filesize") {
+		return ParseBloaty(br)
+	}
+	return ParseNm(br)
+}
+
+// ParseNm reads the output of go tool nm -size: lines of an address, a size,
+// a type and a name. Undefined symbols and those of the BSS segments, which
+// take up no space in the file, are left out.
+func ParseNm(r io.Reader) ([]Symbol, error) {
+	var symbols []Symbol
+	scanner := bufio.NewScanner(r)
+	// Names of generic instantiations run to kilobytes
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		// Undefined symbols have no address
+		if len(fields) == 3 && fields[1] == "U" {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("line %d: expected an address, size, type and name, as printed by go tool nm -size", n)
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid size %q", n, fields[1])
+		}
+		switch fields[2] {
+		case "B", "b", "U":
+			continue
+		}
+		// Names may hold spaces, as in struct types of generic instantiations
+		name := strings.TrimSpace(line[strings.Index(line, " "+fields[2]+" ")+len(fields[2])+2:])
+		symbols = append(symbols, Symbol{Name: name, Size: size})
+	}
+	return symbols, scanner.Err()
+}
+
+// ParseBloaty reads the CSV output of bloaty --csv, taking the size of each
+// row from its filesize column, or its vmsize column if it has none.
+func ParseBloaty(r io.Reader) ([]Symbol, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	column := -1
+	for i, name := range records[0] {
+		if name == "filesize" || (name == "vmsize" && column < 0) {
+			column = i
+		}
+	}
+	if column < 0 {
+		return nil, errors.New("expected a filesize or vmsize column, as printed by bloaty --csv")
+	}
+
+	symbols := make([]Symbol, 0, len(records)-1)
+	for n, record := range records[1:] {
+		size, err := strconv.ParseInt(record[column], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid size %q", n+2, record[column])
+		}
+		symbols = append(symbols, Symbol{Name: record[0], Size: size})
+	}
+	return symbols, nil
+}
+
+// Package returns the import path of the package symbol belongs to, with
+// the types and interface tables of a package counted as its own, or Other.
+func Package(symbol string) string {
+	name := symbol
+	switch {
+	case strings.HasPrefix(name, "go:itab."):
+		name, _, _ = strings.Cut(strings.TrimPrefix(name, "go:itab."), ",")
+	case strings.HasPrefix(name, "type:"):
+		name = strings.TrimPrefix(name, "type:")
+	case strings.HasPrefix(name, "go:"):
+		return Other
+	}
+	name = strings.TrimLeft(name, "*")
+	// Type arguments name other packages
+	name, _, _ = strings.Cut(name, "[")
+
+	slash := strings.LastIndex(name, "/") + 1
+	dot := strings.Index(name[slash:], ".")
+	if dot <= 0 {
+		return Other
+	}
+	// The linker escapes dots in the last element of import paths
+	return strings.ReplaceAll(name[:slash+dot], "%2e", ".")
+}
+
+// ByPackage adds up the sizes of symbols per package, largest first.
+func ByPackage(symbols []Symbol) []PackageSize {
+	sizes := make(map[string]*PackageSize)
+	for _, s := range symbols {
+		pkg := Package(s.Name)
+		if sizes[pkg] == nil {
+			sizes[pkg] = &PackageSize{Package: pkg}
+		}
+		sizes[pkg].Size += s.Size
+		sizes[pkg].Symbols++
+	}
+
+	packages := make([]PackageSize, 0, len(sizes))
+	for _, size := range sizes {
+		packages = append(packages, *size)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Size != packages[j].Size {
+			return packages[i].Size > packages[j].Size
+		}
+		return packages[i].Package < packages[j].Package
+	})
+	return packages
+}
+
+// Largest returns the n largest of symbols, largest first.
+func Largest(symbols []Symbol, n int) []Symbol {
+	largest := append([]Symbol(nil), symbols...)
+	sort.SliceStable(largest, func(i, j int) bool { return largest[i].Size > largest[j].Size })
+	return largest[:min(n, len(largest))]
+}
+
+// Total returns the size of symbols.
+func Total(symbols []Symbol) int64 {
+	var total int64
+	for _, s := range symbols {
+		total += s.Size
+	}
+	return total
+}
@@ -0,0 +1,90 @@
+// nolint:testpackage
+package binsize
+
+import (
+	"strings"
+	"testing"
+)
+
+const nmOutput = ` 17f0020   33554432 B crypto/internal/fips140/drbg.memory
+ 156d828     752992 r go:func.*
+  8531e0      70548 T modernc.org/sqlite/lib._sqlite3VdbeExec
+  7b94e0      17071 T github.com/dlclark/regexp2.(*runner).execute
+  604920       4549 T slices.partitionCmpFunc[go.shape.struct { encoding/json/v2.id int }]
+  7c0000       1000 R type:*github.com/dlclark/regexp2.Regexp
+  7c1000        100 R go:itab.*os.File,io.Writer
+                  0 U abort
+`
+
+func TestParse(t *testing.T) {
+	symbols, err := Parse(strings.NewReader(nmOutput))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(symbols) != 6 {
+		t.Fatalf("Expected 6 symbols without BSS and undefined ones, got %+v", symbols)
+	}
+	if s := symbols[3]; s.Name != "slices.partitionCmpFunc[go.shape.struct { encoding/json/v2.id int }]" || s.Size != 4549 {
+		t.Errorf("Expected a name with spaces, got %+v", s)
+	}
+	if total := Total(symbols); total != 752992+70548+17071+4549+1000+100 {
+		t.Errorf("Unexpected total %d", total)
+	}
+
+	symbols, err = Parse(strings.NewReader("symbols,vmsize,filesize\n\"main.main\",120,100\n[section .bss],4096,0\n"))
+	if err != nil {
+		t.Fatalf("Parse of bloaty output failed: %v", err)
+	}
+	if len(symbols) != 2 || symbols[0] != (Symbol{Name: "main.main", Size: 100}) {
+		t.Errorf("Expected the file sizes of bloaty output, got %+v", symbols)
+	}
+
+	if _, err := Parse(strings.NewReader("not nm output\n")); err == nil {
+		t.Errorf("Expected other input to fail")
+	}
+}
+
+func TestPackage(t *testing.T) {
+	for symbol, expected := range map[string]string{
+		"runtime.mallocgc": "runtime",
+		"github.com/dlclark/regexp2.(*runner).execute": "github.com/dlclark/regexp2",
+		"slices.Sort[go.shape.*github.com/a/b.T]":      "slices",
+		"type:*github.com/dlclark/regexp2.Regexp":      "github.com/dlclark/regexp2",
+		"go:itab.*os.File,io.Writer":                   "os",
+		"gopkg.in/yaml%2ev3.(*parser).parse":           "gopkg.in/yaml.v3",
+		"go:func.*":                                    Other,
+		"[section .bss]":                               Other,
+		"abort":                                        Other,
+	} {
+		if pkg := Package(symbol); pkg != expected {
+			t.Errorf("Package(%q): expected %q, got %q", symbol, expected, pkg)
+		}
+	}
+}
+
+func TestByPackage(t *testing.T) {
+	symbols, err := ParseNm(strings.NewReader(nmOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages := ByPackage(symbols)
+	expected := []PackageSize{
+		{Package: Other, Size: 752992, Symbols: 1},
+		{Package: "modernc.org/sqlite/lib", Size: 70548, Symbols: 1},
+		{Package: "github.com/dlclark/regexp2", Size: 18071, Symbols: 2},
+		{Package: "slices", Size: 4549, Symbols: 1},
+		{Package: "os", Size: 100, Symbols: 1},
+	}
+	if len(packages) != len(expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, packages)
+	}
+	for i := range expected {
+		if packages[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], packages[i])
+		}
+	}
+
+	if largest := Largest(symbols, 2); len(largest) != 2 || largest[1].Name != "modernc.org/sqlite/lib._sqlite3VdbeExec" {
+		t.Errorf("Unexpected largest symbols %+v", largest)
+	}
+}
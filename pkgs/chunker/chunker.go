@@ -0,0 +1,125 @@
+// Package chunker splits files that are too large for a model's context
+// window into chunks: overlapping, line-aligned windows in general, whole
+// top-level declarations for Go, whole top-level keys and blocks for
+// configuration files, and whole sections for Markdown.
+package chunker
+
+import (
+	"errors"
+	"strings"
+)
+
+// Options configures Split.
+type Options struct {
+	// MaxTokens is the largest number of tokens in a chunk.
+	MaxTokens int
+	// Overlap is the number of tokens at the end of a chunk that are repeated
+	// at the start of the next one, so code spanning a boundary is seen whole
+	// at least once. It must be smaller than MaxTokens.
+	Overlap int
+}
+
+// Chunk is a contiguous piece of a file.
+type Chunk struct {
+	// Index is the chunk's position, starting at 0.
+	Index int
+	// StartLine and EndLine are the 1-based, inclusive lines the chunk covers.
+	StartLine int
+	EndLine   int
+	// Content is the chunk's text. Chunks from SplitGo start with the file's
+	// package clause and imports, which lie outside StartLine and EndLine.
+	Content string
+	// Tokens is the number of tokens in Content, counted line by line. It can
+	// differ slightly from encoding Content as a whole.
+	Tokens int
+}
+
+// piece is a line, or part of an overlong line, with its token count.
+type piece struct {
+	line   int
+	text   string
+	tokens int
+}
+
+// Split divides content into chunks of at most opts.MaxTokens tokens.
+// Chunks break at line boundaries; a single line longer than MaxTokens is cut
+// at token boundaries. Content that fits is returned as one chunk.
+func Split(content string, tokenizer Tokenizer, opts Options) ([]Chunk, error) {
+	if opts.MaxTokens <= 0 {
+		return nil, errors.New("chunk size must be positive")
+	}
+	if opts.Overlap < 0 || opts.Overlap >= opts.MaxTokens {
+		return nil, errors.New("chunk overlap must be non-negative and smaller than the chunk size")
+	}
+	if content == "" {
+		return nil, nil
+	}
+
+	pieces := splitPieces(content, tokenizer, opts.MaxTokens)
+
+	var chunks []Chunk
+	for start := 0; start < len(pieces); {
+		end, tokens := start, 0
+		for end < len(pieces) && tokens+pieces[end].tokens <= opts.MaxTokens {
+			tokens += pieces[end].tokens
+			end++
+		}
+
+		chunks = append(chunks, newChunk(len(chunks), pieces[start:end], tokens))
+		if end == len(pieces) {
+			break
+		}
+		start = overlapStart(pieces, start, end, opts.Overlap)
+	}
+	return chunks, nil
+}
+
+// splitPieces breaks content into lines, keeping line endings, and cuts lines
+// longer than maxTokens into token windows.
+func splitPieces(content string, tokenizer Tokenizer, maxTokens int) []piece {
+	var pieces []piece
+	for i, line := range strings.SplitAfter(content, "\n") {
+		if line == "" {
+			continue
+		}
+
+		tokens := tokenizer.Encode(line)
+		if len(tokens) <= maxTokens {
+			pieces = append(pieces, piece{line: i + 1, text: line, tokens: len(tokens)})
+			continue
+		}
+
+		for len(tokens) > 0 {
+			n := min(maxTokens, len(tokens))
+			pieces = append(pieces, piece{line: i + 1, text: tokenizer.Decode(tokens[:n]), tokens: n})
+			tokens = tokens[n:]
+		}
+	}
+	return pieces
+}
+
+// overlapStart returns where the chunk after pieces[start:end] begins: as far
+// back as overlap tokens allow, but always after start so Split progresses.
+func overlapStart(pieces []piece, start, end, overlap int) int {
+	next, tokens := end, 0
+	for next-1 > start && tokens+pieces[next-1].tokens <= overlap {
+		next--
+		tokens += pieces[next].tokens
+	}
+	return next
+}
+
+// newChunk joins pieces into a Chunk.
+func newChunk(index int, pieces []piece, tokens int) Chunk {
+	var content strings.Builder
+	for _, p := range pieces {
+		content.WriteString(p.text)
+	}
+	return Chunk{
+		Index:     index,
+		StartLine: pieces[0].line,
+		EndLine:   pieces[len(pieces)-1].line,
+		Content:   content.String(),
+		Tokens:    tokens,
+	}
+}
@@ -0,0 +1,144 @@
+// nolint:testpackage
+package chunker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// byteTokenizer treats every byte as a token, making chunk sizes easy to predict.
+type byteTokenizer struct{}
+
+func (byteTokenizer) Encode(text string) []int {
+	tokens := make([]int, len(text))
+	for i := range len(text) {
+		tokens[i] = int(text[i])
+	}
+	return tokens
+}
+
+func (byteTokenizer) Decode(tokens []int) string {
+	b := make([]byte, len(tokens))
+	for i, token := range tokens {
+		b[i] = byte(token)
+	}
+	return string(b)
+}
+
+func TestSplitFits(t *testing.T) {
+	chunks, err := Split("a\nb\n", byteTokenizer{}, Options{MaxTokens: 10})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	expected := []Chunk{{Index: 0, StartLine: 1, EndLine: 2, Content: "a\nb\n", Tokens: 4}}
+	if fmt.Sprint(chunks) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, chunks)
+	}
+
+	if chunks, err := Split("", byteTokenizer{}, Options{MaxTokens: 10}); err != nil || len(chunks) != 0 {
+		t.Errorf("Expected no chunks for empty content, got %v, %v", chunks, err)
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	// Six 4-byte lines: "l1\n" is 3 bytes, so use two-digit line numbers
+	var content strings.Builder
+	for i := 10; i < 16; i++ {
+		fmt.Fprintf(&content, "l%d\n", i)
+	}
+
+	chunks, err := Split(content.String(), byteTokenizer{}, Options{MaxTokens: 8})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d: %v", len(chunks), chunks)
+	}
+	for i, chunk := range chunks {
+		if chunk.StartLine != 2*i+1 || chunk.EndLine != 2*i+2 || chunk.Tokens != 8 {
+			t.Errorf("Unexpected chunk %d: %+v", i, chunk)
+		}
+	}
+	if chunks[1].Content != "l12\nl13\n" {
+		t.Errorf("Expected chunk to hold whole lines, got %q", chunks[1].Content)
+	}
+}
+
+func TestSplitOverlap(t *testing.T) {
+	content := "aaa\nbbb\nccc\nddd\n"
+
+	chunks, err := Split(content, byteTokenizer{}, Options{MaxTokens: 8, Overlap: 4})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	var ranges []string
+	for _, chunk := range chunks {
+		ranges = append(ranges, fmt.Sprintf("%d-%d", chunk.StartLine, chunk.EndLine))
+	}
+	if got := strings.Join(ranges, ","); got != "1-2,2-3,3-4" {
+		t.Errorf("Expected overlapping ranges 1-2,2-3,3-4, got %s", got)
+	}
+}
+
+func TestSplitLongLine(t *testing.T) {
+	content := "short\n" + strings.Repeat("x", 25) + "\nend\n"
+
+	chunks, err := Split(content, byteTokenizer{}, Options{MaxTokens: 10})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	var joined strings.Builder
+	for _, chunk := range chunks {
+		if chunk.Tokens > 10 {
+			t.Errorf("Chunk %d has %d tokens, over the limit", chunk.Index, chunk.Tokens)
+		}
+		joined.WriteString(chunk.Content)
+	}
+	if joined.String() != content {
+		t.Errorf("Expected chunks to reassemble the content, got %q", joined.String())
+	}
+	if chunks[1].StartLine != 2 || chunks[1].EndLine != 2 {
+		t.Errorf("Expected the long line to keep its line number, got %+v", chunks[1])
+	}
+}
+
+func TestSplitInvalidOptions(t *testing.T) {
+	for _, opts := range []Options{{MaxTokens: 0}, {MaxTokens: 10, Overlap: 10}, {MaxTokens: 10, Overlap: -1}} {
+		if _, err := Split("a\n", byteTokenizer{}, opts); err == nil {
+			t.Errorf("Expected error for %+v", opts)
+		}
+	}
+}
+
+func TestTokenizerForModel(t *testing.T) {
+	cases := map[string]string{
+		"gpt-4o":            "o200k_base",
+		"gpt-4o-2024-05-13": "o200k_base",
+		"gpt-4":             "cl100k_base",
+		"deepseek-chat":     DefaultEncoding,
+	}
+	for model, expected := range cases {
+		if got := encodingForModel(model); got != expected {
+			t.Errorf("encodingForModel(%q) = %s, expected %s", model, got, expected)
+		}
+	}
+
+	tokenizer, err := TokenizerForModel("gpt-4")
+	if err != nil {
+		t.Fatalf("TokenizerForModel failed: %v", err)
+	}
+	if got := CountTokens(tokenizer, "hello world"); got != 2 {
+		t.Errorf("Expected 2 tokens for \"hello world\", got %d", got)
+	}
+	if got := tokenizer.Decode(tokenizer.Encode("func main() {}")); got != "func main() {}" {
+		t.Errorf("Expected round trip, got %q", got)
+	}
+
+	cached, err := TokenizerForEncoding(DefaultEncoding)
+	if err != nil || cached != tokenizer {
+		t.Errorf("Expected cached tokenizer, got %v, %v", cached, err)
+	}
+}
@@ -0,0 +1,227 @@
+package chunker
+
+import (
+	"regexp"
+	"strings"
+)
+
+// heredocPattern matches the start of an HCL heredoc, capturing its marker.
+var heredocPattern = regexp.MustCompile(`^<<-?([A-Za-z_]\w*)`)
+
+// syntax describes the strings and comments of a configuration language,
+// which SplitConfig skips when tracking nesting.
+type syntax struct {
+	hashComments  bool
+	slashComments bool
+	singleQuotes  bool
+	tripleQuotes  bool
+	heredocs      bool
+}
+
+// configSyntax is the syntax of each language SplitConfig handles by nesting.
+var configSyntax = map[string]syntax{
+	"JSON": {slashComments: true},
+	"TOML": {hashComments: true, singleQuotes: true, tripleQuotes: true},
+	"HCL":  {hashComments: true, slashComments: true, heredocs: true},
+}
+
+// SplitConfig divides a configuration file in language, one of "YAML",
+// "JSON", "TOML" and "HCL", into chunks along its top-level entries, so each
+// key, table or block arrives whole with the comments before it: the keys of
+// a YAML mapping or items of a YAML sequence, the members of a JSON object or
+// array, the tables of TOML and the keys before them, and the blocks and
+// attributes of HCL files such as Terraform's.
+//
+// An entry too large for a chunk on its own is split by lines with
+// opts.Overlap. Content without top-level entries, such as minified JSON, and
+// other languages are split with Split.
+func SplitConfig(language, content string, tokenizer Tokenizer, opts Options) ([]Chunk, error) {
+	if opts.MaxTokens <= 0 || content == "" {
+		return Split(content, tokenizer, opts)
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	starts := configStarts(language, lines)
+	if len(starts) == 0 {
+		return Split(content, tokenizer, opts)
+	}
+
+	offsets := make([]int, len(lines)+1)
+	for i, line := range lines {
+		offsets[i+1] = offsets[i] + len(line)
+	}
+	var units []unit
+	start := 0
+	for _, next := range starts[1:] {
+		end := offsets[leadingStart(language, lines, next)]
+		units = append(units, newUnit(content, start, end, tokenizer))
+		start = end
+	}
+	units = append(units, newUnit(content, start, len(content), tokenizer))
+	return packUnits(content, units, "", 0, opts.MaxTokens, opts.Overlap, tokenizer)
+}
+
+// configStarts returns the indexes of the lines of a file in language that
+// start its top-level entries.
+func configStarts(language string, lines []string) []int {
+	var starts []int
+	if language == "YAML" {
+		for i, line := range lines {
+			// Indented lines, comments and document markers belong to an entry
+			if line != "" && !strings.ContainsRune(" \t\r\n#", rune(line[0])) && !isComment(language, line) {
+				starts = append(starts, i)
+			}
+		}
+		return starts
+	}
+
+	syn, ok := configSyntax[language]
+	if !ok {
+		return nil
+	}
+	// The entries of a JSON file are the members of its outermost value
+	depth := 0
+	if language == "JSON" {
+		depth = 1
+	}
+	inTable := false
+	for i, state := range scanNesting(lines, syn) {
+		text := strings.TrimSpace(lines[i])
+		if state.inside || state.depth != depth || text == "" || isComment(language, lines[i]) {
+			continue
+		}
+		switch language {
+		case "JSON":
+			if text[0] == '}' || text[0] == ']' {
+				continue
+			}
+		case "TOML":
+			// Keys after a table header belong to the table
+			if strings.HasPrefix(text, "[") {
+				inTable = true
+			} else if inTable {
+				continue
+			}
+		}
+		starts = append(starts, i)
+	}
+	return starts
+}
+
+// leadingStart returns the index of the first of the comment lines right
+// before lines[start], indented as it is, or start if there are none. Blank
+// lines between them are kept with the comments.
+func leadingStart(language string, lines []string, start int) int {
+	indent := indentation(lines[start])
+	first := start
+	for i := start - 1; i >= 0; i-- {
+		text := strings.TrimSpace(lines[i])
+		if text == "" {
+			continue
+		}
+		if !isComment(language, lines[i]) || indentation(lines[i]) != indent {
+			break
+		}
+		first = i
+	}
+	return first
+}
+
+// isComment reports whether line is a comment in language, or for YAML a
+// document marker.
+func isComment(language, line string) bool {
+	text := strings.TrimSpace(line)
+	switch language {
+	case "YAML":
+		return strings.HasPrefix(text, "#") || text == "---" || text == "..."
+	case "JSON":
+		return strings.HasPrefix(text, "//")
+	case "TOML":
+		return strings.HasPrefix(text, "#")
+	case "HCL":
+		return strings.HasPrefix(text, "#") || strings.HasPrefix(text, "//")
+	}
+	return false
+}
+
+// indentation returns the leading blanks of line.
+func indentation(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// nesting is the state of a configuration file at the start of a line.
+type nesting struct {
+	// depth is the number of brackets, braces and parentheses open.
+	depth int
+	// inside is whether the line continues a string, comment or heredoc.
+	inside bool
+}
+
+// scanNesting returns the nesting at the start of each of lines, skipping
+// brackets in strings and comments of syn.
+func scanNesting(lines []string, syn syntax) []nesting {
+	states := make([]nesting, len(lines))
+	depth := 0
+	quote, heredoc := "", ""
+	inComment := false
+	for n, line := range lines {
+		states[n] = nesting{depth: depth, inside: quote != "" || heredoc != "" || inComment}
+		if heredoc != "" {
+			if strings.TrimSpace(line) == heredoc {
+				heredoc = ""
+			}
+			continue
+		}
+
+		pending := ""
+	scan:
+		for i := 0; i < len(line); {
+			rest := line[i:]
+			switch {
+			case inComment:
+				if strings.HasPrefix(rest, "*/") {
+					inComment = false
+					i += 2
+					continue
+				}
+			case quote != "":
+				if quote[0] == '"' && rest[0] == '\\' {
+					i += 2
+					continue
+				}
+				if strings.HasPrefix(rest, quote) {
+					i += len(quote)
+					quote = ""
+					continue
+				}
+			case syn.hashComments && rest[0] == '#', syn.slashComments && strings.HasPrefix(rest, "//"):
+				break scan
+			case syn.slashComments && strings.HasPrefix(rest, "/*"):
+				inComment = true
+				i += 2
+				continue
+			case syn.tripleQuotes && (strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, "'''")):
+				quote = rest[:3]
+				i += 3
+				continue
+			case rest[0] == '"' || (syn.singleQuotes && rest[0] == '\''):
+				quote = rest[:1]
+			case syn.heredocs && strings.HasPrefix(rest, "<<"):
+				if m := heredocPattern.FindStringSubmatch(rest); m != nil {
+					pending = m[1]
+				}
+			case strings.ContainsRune("{[(", rune(rest[0])):
+				depth++
+			case strings.ContainsRune("}])", rune(rest[0])):
+				depth = max(depth-1, 0)
+			}
+			i++
+		}
+		// Only triple-quoted strings span lines
+		if len(quote) == 1 {
+			quote = ""
+		}
+		heredoc = pending
+	}
+	return states
+}
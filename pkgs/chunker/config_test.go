@@ -0,0 +1,133 @@
+// nolint:testpackage
+package chunker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JackDrogon/aicodereader/pkgs/lang"
+)
+
+func TestSplitConfig(t *testing.T) {
+	cases := []struct {
+		path, content string
+		// entries are the first lines of the top-level entries
+		entries []string
+	}{
+		{"deploy.yaml", `# Service settings
+name: api
+server:
+  port: 8080
+  # TLS is optional
+  tls:
+    cert: a.pem
+
+# Workers
+workers:
+  - name: mail
+  - name: sms
+`, []string{"# Service settings", "server:", "# Workers"}},
+		{"tasks.yml", "- name: build\n  run: make\n- name: test\n  run: make test\n", []string{"- name: build", "- name: test"}},
+		{"package.json", `{
+  "name": "app",
+  "scripts": {
+    "build": "tsc",
+    "test": "jest {a}"
+  },
+  "files": [
+    "dist"
+  ]
+}
+`, []string{"{", `  "scripts": {`, `  "files": [`}},
+		{"Cargo.toml", `name = "app"
+version = "0.1.0"
+description = """
+[not a table]
+"""
+
+[dependencies]
+serde = "1"
+matrix = [
+  [1, 2],
+]
+
+# Release settings
+[profile.release]
+lto = true
+`, []string{`name = "app"`, `version = "0.1.0"`, `description = """`, "[dependencies]", "# Release settings"}},
+		{"main.tf", `variable "region" {
+  default = "us-east-1"
+}
+
+# The bucket
+resource "aws_s3_bucket" "logs" {
+  bucket = "logs"
+  policy = <<EOF
+}
+EOF
+  tags = {
+    env = "prod"
+  }
+}
+region = "eu" // override
+`, []string{`variable "region" {`, "# The bucket", `region = "eu" // override`}},
+	}
+
+	for _, c := range cases {
+		language := lang.FromPath(c.path)
+		lines := strings.SplitAfter(c.content, "\n")
+		var firsts []string
+		for i, start := range configStarts(language, lines) {
+			// The first entry takes the lines before it
+			if start = leadingStart(language, lines, start); i == 0 {
+				start = 0
+			}
+			firsts = append(firsts, strings.TrimSuffix(lines[start], "\n"))
+		}
+		if strings.Join(firsts, "|") != strings.Join(c.entries, "|") {
+			t.Errorf("%s: expected entries starting with %q, got %q", c.path, c.entries, firsts)
+		}
+
+		chunks, err := SplitFile(c.path, c.content, byteTokenizer{}, Options{MaxTokens: 40})
+		if err != nil {
+			t.Fatalf("SplitFile(%s) failed: %v", c.path, err)
+		}
+		var joined strings.Builder
+		for _, chunk := range chunks {
+			joined.WriteString(chunk.Content)
+		}
+		if len(chunks) < len(c.entries) || joined.String() != c.content {
+			t.Errorf("%s: expected chunks covering the file, got %+v", c.path, chunks)
+		}
+	}
+}
+
+func TestSplitConfigPacksEntries(t *testing.T) {
+	content := "a: 1\nb: 2\nc:\n  - x\n"
+	chunks, err := SplitConfig("YAML", content, byteTokenizer{}, Options{MaxTokens: 100})
+	if err != nil {
+		t.Fatalf("SplitConfig failed: %v", err)
+	}
+	if len(chunks) != 1 || chunks[0].Content != content || chunks[0].StartLine != 1 || chunks[0].EndLine != 4 {
+		t.Errorf("Expected the entries packed into one chunk, got %+v", chunks)
+	}
+
+	var items strings.Builder
+	for range 20 {
+		items.WriteString("  - item\n")
+	}
+	content = "small: 1\nlarge:\n" + items.String()
+	chunks, err = SplitConfig("YAML", content, byteTokenizer{}, Options{MaxTokens: 50, Overlap: 10})
+	if err != nil {
+		t.Fatalf("SplitConfig failed: %v", err)
+	}
+	if len(chunks) < 3 || chunks[0].Content != "small: 1\n" || chunks[1].StartLine != 2 || chunks[len(chunks)-1].EndLine != 22 {
+		t.Errorf("Expected the large entry split by lines, got %+v", chunks)
+	}
+
+	// Minified JSON has no entries on lines of their own
+	minified := `{"a":1,"b":[2,3]}`
+	if chunks, err := SplitConfig("JSON", minified, byteTokenizer{}, Options{MaxTokens: 100}); err != nil || len(chunks) != 1 || chunks[0].Content != minified {
+		t.Errorf("Expected minified JSON as one chunk, got %+v, %v", chunks, err)
+	}
+}
@@ -0,0 +1,188 @@
+package chunker
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"github.com/JackDrogon/aicodereader/pkgs/lang"
+)
+
+// SplitFile divides the content of the file at path into chunks, using
+// SplitGo for Go files, SplitConfig for configuration files, SplitMarkdown
+// for Markdown and Split for everything else.
+func SplitFile(path, content string, tokenizer Tokenizer, opts Options) ([]Chunk, error) {
+	switch language := lang.FromPath(path); language {
+	case "Go":
+		return SplitGo(content, tokenizer, opts)
+	case "YAML", "JSON", "TOML", "HCL":
+		return SplitConfig(language, content, tokenizer, opts)
+	case "Markdown":
+		return SplitMarkdown(content, tokenizer, opts)
+	}
+	return Split(content, tokenizer, opts)
+}
+
+// unit is a top-level declaration with its doc comment and any free-floating
+// comments before it.
+type unit struct {
+	text      string
+	offset    int
+	startLine int
+	endLine   int
+	tokens    int
+}
+
+// SplitGo divides Go source into chunks along top-level declarations, so each
+// function, method, type or var/const block arrives whole with its doc
+// comment. Every chunk starts with the file's package clause and imports; the
+// chunk's line range covers only its declarations.
+//
+// A declaration too large for a chunk on its own is split by lines with
+// opts.Overlap. Declarations are otherwise complete, so chunks do not
+// overlap. Source that does not parse, or whose imports alone exceed the
+// chunk size, is split with Split.
+func SplitGo(content string, tokenizer Tokenizer, opts Options) ([]Chunk, error) {
+	if opts.MaxTokens <= 0 {
+		return Split(content, tokenizer, opts)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return Split(content, tokenizer, opts)
+	}
+
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+	header := content[:lineEnd(content, offset(headerEnd(file)))]
+	headerTokens := CountTokens(tokenizer, header+"\n")
+	budget := opts.MaxTokens - headerTokens
+	if budget <= opts.Overlap {
+		return Split(content, tokenizer, opts)
+	}
+
+	units := goUnits(content, file, offset, len(header), tokenizer)
+	if len(units) == 0 {
+		return Split(content, tokenizer, opts)
+	}
+
+	return packUnits(content, units, header+"\n", headerTokens, budget, opts.Overlap, tokenizer)
+}
+
+// packUnits packs consecutive units of content into chunks of at most budget
+// tokens, each starting with prefix, whose headerTokens are added to the
+// chunk's. A unit larger than budget is split by lines with overlap.
+func packUnits(content string, units []unit, prefix string, headerTokens, budget, overlap int, tokenizer Tokenizer) ([]Chunk, error) {
+	var chunks []Chunk
+	for i := 0; i < len(units); {
+		if units[i].tokens > budget {
+			// Leading blank lines would only make the first part's range start early
+			text := strings.TrimLeft(units[i].text, "\r\n")
+			parts, err := Split(text, tokenizer, Options{MaxTokens: budget, Overlap: overlap})
+			if err != nil {
+				return nil, err
+			}
+			firstLine := lineAt(content, units[i].offset+len(units[i].text)-len(text))
+			for _, part := range parts {
+				chunks = append(chunks, Chunk{
+					Index:     len(chunks),
+					StartLine: firstLine + part.StartLine - 1,
+					EndLine:   firstLine + part.EndLine - 1,
+					Content:   prefix + strings.TrimLeft(part.Content, "\n"),
+					Tokens:    headerTokens + part.Tokens,
+				})
+			}
+			i++
+			continue
+		}
+
+		end, tokens := i, 0
+		for end < len(units) && units[end].tokens <= budget-tokens {
+			tokens += units[end].tokens
+			end++
+		}
+		chunks = append(chunks, Chunk{
+			Index:     len(chunks),
+			StartLine: units[i].startLine,
+			EndLine:   units[end-1].endLine,
+			Content:   prefix + strings.TrimLeft(strings.Join(collectText(units[i:end]), ""), "\n"),
+			Tokens:    headerTokens + tokens,
+		})
+		i = end
+	}
+	return chunks, nil
+}
+
+// headerEnd returns the end of the file's last import declaration, or of the
+// package clause if there are no imports.
+func headerEnd(file *ast.File) token.Pos {
+	end := file.Name.End()
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			end = gen.End()
+		}
+	}
+	return end
+}
+
+// goUnits cuts content after the header into one unit per top-level
+// declaration. Each unit runs from the end of the previous one to the end of
+// its declaration's last line, so comments between declarations stay with
+// the declaration that follows them.
+func goUnits(content string, file *ast.File, offset func(token.Pos) int, start int, tokenizer Tokenizer) []unit {
+	var units []unit
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+
+		end := lineEnd(content, offset(decl.End()))
+		units = append(units, newUnit(content, start, end, tokenizer))
+		start = end
+	}
+
+	// Trailing comments belong to the last declaration
+	if len(units) > 0 && start < len(content) {
+		last := &units[len(units)-1]
+		*last = newUnit(content, last.offset, len(content), tokenizer)
+	}
+	return units
+}
+
+// newUnit creates the unit for content[start:end].
+func newUnit(content string, start, end int, tokenizer Tokenizer) unit {
+	text := content[start:end]
+	first := start + len(text) - len(strings.TrimLeft(text, " \t\r\n"))
+	// Blank lines before the next unit are not part of the range
+	last := start + len(strings.TrimRight(text, " \t\r\n"))
+	return unit{
+		text:      text,
+		offset:    start,
+		startLine: lineAt(content, first),
+		endLine:   lineAt(content, max(first, last)),
+		tokens:    CountTokens(tokenizer, text),
+	}
+}
+
+// collectText returns the text of each unit.
+func collectText(units []unit) []string {
+	texts := make([]string, len(units))
+	for i, u := range units {
+		texts[i] = u.text
+	}
+	return texts
+}
+
+// lineEnd returns the offset just past the end of the line containing offset.
+func lineEnd(content string, offset int) int {
+	if i := strings.IndexByte(content[offset:], '\n'); i >= 0 {
+		return offset + i + 1
+	}
+	return len(content)
+}
+
+// lineAt returns the 1-based line number of offset.
+func lineAt(content string, offset int) int {
+	return strings.Count(content[:offset], "\n") + 1
+}
@@ -0,0 +1,131 @@
+// nolint:testpackage
+package chunker
+
+import (
+	"strings"
+	"testing"
+)
+
+const goSource = `// Package demo is a test fixture.
+package demo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Greet says hello.
+func Greet(name string) string {
+	return fmt.Sprintf("hello %s", name)
+}
+
+// Shout upper-cases s.
+func Shout(s string) string {
+	return strings.ToUpper(s)
+}
+
+// Pair holds two values.
+type Pair struct {
+	A, B int
+}
+
+// Sum adds the pair.
+func (p Pair) Sum() int {
+	return p.A + p.B
+}
+`
+
+func TestSplitGoDeclarations(t *testing.T) {
+	header := CountTokens(byteTokenizer{}, "// Package demo is a test fixture.\npackage demo\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n\n")
+	chunks, err := SplitGo(goSource, byteTokenizer{}, Options{MaxTokens: header + 100})
+	if err != nil {
+		t.Fatalf("SplitGo failed: %v", err)
+	}
+
+	expected := []struct {
+		start, end int
+		doc, decl  string
+	}{
+		{9, 12, "// Greet says hello.", "func Greet"},
+		{14, 17, "// Shout upper-cases s.", "func Shout"},
+		{19, 22, "// Pair holds two values.", "type Pair struct"},
+		{24, 27, "// Sum adds the pair.", "func (p Pair) Sum() int"},
+	}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d: %+v", len(expected), len(chunks), chunks)
+	}
+
+	for i, chunk := range chunks {
+		want := expected[i]
+		if chunk.StartLine != want.start || chunk.EndLine != want.end {
+			t.Errorf("Chunk %d: expected lines %d-%d, got %d-%d", i, want.start, want.end, chunk.StartLine, chunk.EndLine)
+		}
+		for _, text := range []string{"package demo", "\"strings\"", want.doc, want.decl} {
+			if !strings.Contains(chunk.Content, text) {
+				t.Errorf("Chunk %d: expected %q in %q", i, text, chunk.Content)
+			}
+		}
+		if !strings.HasSuffix(chunk.Content, "}\n") {
+			t.Errorf("Chunk %d: expected a complete declaration, got %q", i, chunk.Content)
+		}
+		if chunk.Tokens > header+100 {
+			t.Errorf("Chunk %d: %d tokens, over the limit", i, chunk.Tokens)
+		}
+	}
+}
+
+func TestSplitGoPacksDeclarations(t *testing.T) {
+	chunks, err := SplitGo(goSource, byteTokenizer{}, Options{MaxTokens: 300})
+	if err != nil {
+		t.Fatalf("SplitGo failed: %v", err)
+	}
+	if len(chunks) != 2 || chunks[0].StartLine != 9 || chunks[1].EndLine != 27 {
+		t.Errorf("Expected declarations packed into 2 chunks, got %+v", chunks)
+	}
+	if strings.Count(chunks[1].Content, "package demo") != 1 {
+		t.Errorf("Expected header once per chunk, got %q", chunks[1].Content)
+	}
+}
+
+func TestSplitGoLargeDeclaration(t *testing.T) {
+	var body strings.Builder
+	for range 20 {
+		body.WriteString("\tx++\n")
+	}
+	source := "package big\n\nfunc Small() {}\n\nfunc Big() {\n\tx := 0\n" + body.String() + "}\n"
+
+	chunks, err := SplitGo(source, byteTokenizer{}, Options{MaxTokens: 60, Overlap: 5})
+	if err != nil {
+		t.Fatalf("SplitGo failed: %v", err)
+	}
+	if len(chunks) < 3 {
+		t.Fatalf("Expected the large function to be split, got %+v", chunks)
+	}
+	if chunks[0].StartLine != 3 || chunks[0].EndLine != 3 {
+		t.Errorf("Expected Small alone in the first chunk, got %+v", chunks[0])
+	}
+	if chunks[1].StartLine != 5 {
+		t.Errorf("Expected Big to start at line 5, got %+v", chunks[1])
+	}
+	if last := chunks[len(chunks)-1]; last.EndLine != 27 {
+		t.Errorf("Expected the last chunk to end at line 27, got %+v", last)
+	}
+	for _, chunk := range chunks {
+		if !strings.HasPrefix(chunk.Content, "package big\n") || chunk.Tokens > 60 {
+			t.Errorf("Unexpected chunk %+v", chunk)
+		}
+	}
+}
+
+func TestSplitFileFallback(t *testing.T) {
+	// Unparsable Go and other languages are split by lines
+	for path, content := range map[string]string{"broken.go": "package x\nfunc {\n", "a.py": "def f():\n    pass\n"} {
+		chunks, err := SplitFile(path, content, byteTokenizer{}, Options{MaxTokens: 100})
+		if err != nil {
+			t.Fatalf("SplitFile(%s) failed: %v", path, err)
+		}
+		if len(chunks) != 1 || chunks[0].Content != content {
+			t.Errorf("SplitFile(%s): expected the content as one chunk, got %+v", path, chunks)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package chunker
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// headingPattern matches an ATX heading, such as "## Usage".
+	headingPattern = regexp.MustCompile(`^ {0,3}#{1,6}(?:[ \t\r\n]|$)`)
+	// fencePattern matches the start or end of a fenced code block,
+	// capturing the fence.
+	fencePattern = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+)
+
+// SplitMarkdown divides Markdown documentation into chunks along its
+// sections, so each heading arrives with the text under it, and the text
+// before the first heading, such as badges, with the first section. Small
+// sections are packed together; a section too large for a chunk on its own
+// is split by lines with opts.Overlap. Headings inside fenced code blocks
+// do not start sections, and content without headings is split with Split.
+func SplitMarkdown(content string, tokenizer Tokenizer, opts Options) ([]Chunk, error) {
+	if opts.MaxTokens <= 0 || content == "" {
+		return Split(content, tokenizer, opts)
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	headings := markdownHeadings(lines)
+	if len(headings) == 0 {
+		return Split(content, tokenizer, opts)
+	}
+
+	offsets := make([]int, len(lines)+1)
+	for i, line := range lines {
+		offsets[i+1] = offsets[i] + len(line)
+	}
+	var units []unit
+	start := 0
+	for _, heading := range headings[1:] {
+		units = append(units, newUnit(content, start, offsets[heading], tokenizer))
+		start = offsets[heading]
+	}
+	units = append(units, newUnit(content, start, len(content), tokenizer))
+	return packUnits(content, units, "", 0, opts.MaxTokens, opts.Overlap, tokenizer)
+}
+
+// markdownHeadings returns the indexes of the heading lines of lines,
+// outside fenced code blocks.
+func markdownHeadings(lines []string) []int {
+	var headings []int
+	fence := ""
+	for i, line := range lines {
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			// A fence is closed by a longer or equal one of the same character
+			switch {
+			case fence == "":
+				fence = m[1]
+			case m[1][0] == fence[0] && len(m[1]) >= len(fence) && strings.TrimSpace(line[len(m[0]):]) == "":
+				fence = ""
+			}
+			continue
+		}
+		if fence == "" && headingPattern.MatchString(line) {
+			headings = append(headings, i)
+		}
+	}
+	return headings
+}
@@ -0,0 +1,58 @@
+// nolint:testpackage
+package chunker
+
+import (
+	"strings"
+	"testing"
+)
+
+const markdownSource = `[![build](badge.svg)](ci)
+
+# Tool
+
+Reads code.
+
+## Install
+
+` + "```sh" + `
+# not a heading
+go install ./...
+` + "```" + `
+
+## Usage
+
+Run it.
+`
+
+func TestSplitMarkdownSections(t *testing.T) {
+	chunks, err := SplitFile("README.md", markdownSource, byteTokenizer{}, Options{MaxTokens: 60})
+	if err != nil {
+		t.Fatalf("SplitFile failed: %v", err)
+	}
+
+	expected := []struct {
+		start, end int
+		first      string
+	}{
+		{1, 5, "[![build](badge.svg)](ci)"},
+		{7, 12, "## Install"},
+		{14, 16, "## Usage"},
+	}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %+v", len(expected), chunks)
+	}
+	for i, chunk := range chunks {
+		want := expected[i]
+		if chunk.StartLine != want.start || chunk.EndLine != want.end || !strings.HasPrefix(chunk.Content, want.first+"\n") {
+			t.Errorf("Chunk %d: expected lines %d-%d starting with %q, got %+v", i, want.start, want.end, want.first, chunk)
+		}
+	}
+	if !strings.Contains(chunks[1].Content, "# not a heading\ngo install") {
+		t.Errorf("Expected the code block kept whole, got %q", chunks[1].Content)
+	}
+
+	chunks, err = SplitMarkdown(markdownSource, byteTokenizer{}, Options{MaxTokens: 500})
+	if err != nil || len(chunks) != 1 || chunks[0].Content != markdownSource {
+		t.Errorf("Expected the sections packed into one chunk, got %+v, %v", chunks, err)
+	}
+}
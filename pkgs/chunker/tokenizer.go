@@ -0,0 +1,94 @@
+package chunker
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// DefaultEncoding is the tiktoken encoding used for models tiktoken does not
+// know, such as non-OpenAI models. Counts for those models are approximate.
+const DefaultEncoding = "cl100k_base"
+
+// Tokenizer converts between text and model tokens.
+type Tokenizer interface {
+	Encode(text string) []int
+	Decode(tokens []int) string
+}
+
+// tiktokenTokenizer adapts a tiktoken encoding to Tokenizer. Special tokens
+// such as "<|endoftext|>" are encoded as plain text, since source files may
+// legitimately contain them.
+type tiktokenTokenizer struct {
+	encoding *tiktoken.Tiktoken
+}
+
+// Encode implements Tokenizer.
+func (t tiktokenTokenizer) Encode(text string) []int {
+	return t.encoding.EncodeOrdinary(text)
+}
+
+// Decode implements Tokenizer.
+func (t tiktokenTokenizer) Decode(tokens []int) string {
+	return t.encoding.Decode(tokens)
+}
+
+var (
+	// tokenizers caches tokenizers by encoding name, since building one
+	// parses the whole BPE vocabulary.
+	tokenizers   = make(map[string]Tokenizer)
+	tokenizersMu sync.Mutex
+
+	loaderOnce sync.Once
+)
+
+// TokenizerForModel returns the tiktoken tokenizer for model, falling back to
+// DefaultEncoding for unknown models. Vocabularies are embedded in the
+// binary, so no network access is needed.
+func TokenizerForModel(model string) (Tokenizer, error) {
+	return TokenizerForEncoding(encodingForModel(model))
+}
+
+// TokenizerForEncoding returns the tokenizer for a tiktoken encoding name
+// such as "cl100k_base" or "o200k_base".
+func TokenizerForEncoding(name string) (Tokenizer, error) {
+	loaderOnce.Do(func() { tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader()) })
+
+	tokenizersMu.Lock()
+	defer tokenizersMu.Unlock()
+
+	if tokenizer, ok := tokenizers[name]; ok {
+		return tokenizer, nil
+	}
+
+	encoding, err := tiktoken.GetEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+	tokenizer := tiktokenTokenizer{encoding: encoding}
+	tokenizers[name] = tokenizer
+	return tokenizer, nil
+}
+
+// encodingForModel returns the tiktoken encoding name for model.
+func encodingForModel(model string) string {
+	if name, ok := tiktoken.MODEL_TO_ENCODING[model]; ok {
+		return name
+	}
+
+	// Prefer the longest matching prefix, e.g. "gpt-4o-" over "gpt-4-"
+	name, longest := DefaultEncoding, 0
+	for prefix, encoding := range tiktoken.MODEL_PREFIX_TO_ENCODING {
+		if strings.HasPrefix(model, prefix) && len(prefix) > longest {
+			name, longest = encoding, len(prefix)
+		}
+	}
+	return name
+}
+
+// CountTokens returns the number of tokens in text.
+func CountTokens(tokenizer Tokenizer, text string) int {
+	return len(tokenizer.Encode(text))
+}
@@ -0,0 +1,89 @@
+// Package commitlint checks commit messages against the Conventional Commits
+// specification (https://www.conventionalcommits.org), with the types and
+// header length of the widely used config-conventional rules.
+package commitlint
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxHeaderLength is the longest header, the first line of a message,
+// allowed.
+const MaxHeaderLength = 100
+
+// types are the commit types allowed.
+var types = []string{"build", "chore", "ci", "docs", "feat", "fix", "perf", "refactor", "revert", "style", "test"}
+
+// headerPattern matches a header of the form "type(scope)!: description",
+// the scope and ! being optional.
+var headerPattern = regexp.MustCompile(`^(\w+)(?:\(([^()]*)\))?(!)?:(.*)$`)
+
+// Types returns the commit types allowed, sorted.
+func Types() []string {
+	return slices.Clone(types)
+}
+
+// Skipped reports whether the commit with subject is left unchecked, as
+// merge commits and the fixup! and squash! commits of git rebase --autosquash
+// are.
+func Skipped(subject string) bool {
+	for _, prefix := range []string{"Merge ", "fixup! ", "squash! ", "amend! "} {
+		if strings.HasPrefix(subject, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns the problems of message, a full commit message, as
+// sentences, or nil if it follows the specification.
+func Check(message string) []string {
+	header, rest, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	var problems []string
+	if n := utf8.RuneCountInString(header); n > MaxHeaderLength {
+		problems = append(problems, fmt.Sprintf("header is %d characters long, over %d", n, MaxHeaderLength))
+	}
+	if rest != "" && !strings.HasPrefix(rest, "\n") {
+		problems = append(problems, "body is not separated from the header by a blank line")
+	}
+
+	match := headerPattern.FindStringSubmatch(header)
+	if match == nil {
+		return append(problems, `header is not of the form "type(scope): description"`)
+	}
+	typ, scope, description := match[1], match[2], match[4]
+	switch {
+	case typ != strings.ToLower(typ):
+		problems = append(problems, fmt.Sprintf("type %q is not lower case", typ))
+	case !slices.Contains(types, typ):
+		problems = append(problems, fmt.Sprintf("type %q is not one of %s", typ, strings.Join(types, ", ")))
+	}
+	if scope == "" && strings.HasPrefix(header[len(typ):], "()") {
+		problems = append(problems, "scope is empty")
+	} else if strings.TrimSpace(scope) != scope {
+		problems = append(problems, fmt.Sprintf("scope %q has surrounding spaces", scope))
+	}
+	switch {
+	case strings.TrimSpace(description) == "":
+		problems = append(problems, "description is empty")
+	case !strings.HasPrefix(description, " "):
+		problems = append(problems, "colon is not followed by a space")
+	case strings.HasSuffix(description, "."):
+		problems = append(problems, "description ends with a period")
+	case unicode.IsUpper([]rune(description)[1]) && !isAcronym(description[1:]):
+		problems = append(problems, "description starts with an upper case letter")
+	}
+	return problems
+}
+
+// isAcronym reports whether the first word of s is all upper case, such as
+// an acronym like API, which may start a description.
+func isAcronym(s string) bool {
+	word, _, _ := strings.Cut(s, " ")
+	return utf8.RuneCountInString(word) > 1 && strings.ToUpper(word) == word
+}
@@ -0,0 +1,48 @@
+// nolint:testpackage
+package commitlint
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		message  string
+		problems []string
+	}{
+		{"feat: add the endpoints command", nil},
+		{"fix(parser)!: reject empty input\n\nBREAKING CHANGE: empty input is an error.", nil},
+		{"docs: document the API of llm", nil},
+		{"refactor: call Parse() once", nil},
+		{"update stuff", []string{`header is not of the form "type(scope): description"`}},
+		{"feature: add x", []string{`type "feature" is not one of build, chore, ci, docs, feat, fix, perf, refactor, revert, style, test`}},
+		{"Fix: add x", []string{`type "Fix" is not lower case`}},
+		{"fix(): add x", []string{"scope is empty"}},
+		{"fix( cli ): add x", []string{`scope " cli " has surrounding spaces`}},
+		{"fix: Add x.", []string{"description ends with a period"}},
+		{"fix: Add x", []string{"description starts with an upper case letter"}},
+		{"fix: ", []string{"description is empty"}},
+		{"fix:add x", []string{"colon is not followed by a space"}},
+		{"fix: add x\nmore", []string{"body is not separated from the header by a blank line"}},
+		{"fix: " + strings.Repeat("x", 100), []string{"header is 105 characters long, over 100"}},
+	}
+	for _, tt := range tests {
+		problems := Check(tt.message)
+		if strings.Join(problems, "|") != strings.Join(tt.problems, "|") {
+			t.Errorf("Check(%q): expected %q, got %q", tt.message, tt.problems, problems)
+		}
+	}
+}
+
+func TestSkipped(t *testing.T) {
+	for subject, expected := range map[string]bool{
+		"Merge branch 'main' into feature": true,
+		"fixup! feat: add x":               true,
+		"feat: add x":                      false,
+	} {
+		if Skipped(subject) != expected {
+			t.Errorf("Skipped(%q): expected %v", subject, expected)
+		}
+	}
+}
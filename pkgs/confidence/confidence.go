@@ -0,0 +1,147 @@
+// Package confidence scores answers grounded in retrieved code, from how
+// many of the locations they cite were retrieved and from the model's own
+// check of the answer against the code, so users know when to double-check.
+package confidence
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+)
+
+// Bands, from the most confident. Scores from HighScore up are High, from
+// MediumScore up Medium, and Low below.
+const (
+	High   = "高"
+	Medium = "中"
+	Low    = "低"
+
+	HighScore   = 0.75
+	MediumScore = 0.45
+)
+
+// Source is a retrieved range of lines an answer could cite.
+type Source struct {
+	Path      string
+	StartLine int
+	EndLine   int
+}
+
+// Citation is a location an answer cites, written "path:line" or
+// "path:start-end".
+type Citation struct {
+	Path      string
+	StartLine int
+	EndLine   int
+}
+
+// String returns c as cited.
+func (c Citation) String() string {
+	if c.StartLine == c.EndLine {
+		return fmt.Sprintf("%s:%d", c.Path, c.StartLine)
+	}
+	return fmt.Sprintf("%s:%d-%d", c.Path, c.StartLine, c.EndLine)
+}
+
+// citationPattern matches file paths with an extension, which starts with a
+// letter so that version numbers are not taken for paths, followed by a line
+// or line range.
+var citationPattern = regexp.MustCompile(`([\w./-]+\.[A-Za-z]\w*):(\d+)(?:-(\d+))?`)
+
+// Citations returns the distinct locations answer cites, in order.
+func Citations(answer string) []Citation {
+	seen := make(map[Citation]bool)
+	var citations []Citation
+	for _, m := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		start, err := strconv.Atoi(m[2])
+		if err != nil {
+			continue
+		}
+		end := start
+		if m[3] != "" {
+			if end, err = strconv.Atoi(m[3]); err != nil || end < start {
+				continue
+			}
+		}
+		c := Citation{Path: path.Clean(m[1]), StartLine: start, EndLine: end}
+		if !seen[c] {
+			seen[c] = true
+			citations = append(citations, c)
+		}
+	}
+	return citations
+}
+
+// within reports whether c overlaps a line range of sources.
+func (c Citation) within(sources []Source) bool {
+	for _, s := range sources {
+		if s.Path == c.Path && c.StartLine <= s.EndLine && c.EndLine >= s.StartLine {
+			return true
+		}
+	}
+	return false
+}
+
+// SelfCheck is the model's check of its answer against the retrieved code.
+type SelfCheck struct {
+	// Score is the model's confidence, from 0 to 1.
+	Score float64
+	// Unverified lists the claims the code does not support.
+	Unverified []string
+}
+
+// Assessment is the confidence in an answer.
+type Assessment struct {
+	// Score is from 0 to 1.
+	Score float64
+	Band  string
+	// Cited is the number of locations the answer cites, and Verified the
+	// number of them within the retrieved sources.
+	Cited    int
+	Verified int
+	// Check is the model's check, nil if there was none.
+	Check *SelfCheck
+	// Unverified lists what could not be verified: cited locations that
+	// were not retrieved and the claims the model's check names.
+	Unverified []string
+}
+
+// Assess scores answer, grounded in sources, by the share of its citations
+// within sources and, if check is set, the model's own score, weighted
+// equally. Answers citing nothing get no credit for citations.
+func Assess(answer string, sources []Source, check *SelfCheck) Assessment {
+	a := Assessment{Check: check}
+	for _, c := range Citations(answer) {
+		a.Cited++
+		if c.within(sources) {
+			a.Verified++
+		} else {
+			a.Unverified = append(a.Unverified, fmt.Sprintf("引用的 %s 不在检索到的片段中", c))
+		}
+	}
+
+	coverage := 0.0
+	if a.Cited > 0 {
+		coverage = float64(a.Verified) / float64(a.Cited)
+	}
+	a.Score = coverage
+	if check != nil {
+		a.Score = (coverage + min(max(check.Score, 0), 1)) / 2
+		a.Unverified = append(a.Unverified, check.Unverified...)
+	}
+	a.Band = band(a.Score)
+	return a
+}
+
+// band returns the band of score.
+func band(score float64) string {
+	switch {
+	case score >= HighScore:
+		return High
+	case score >= MediumScore:
+		return Medium
+	default:
+		return Low
+	}
+}
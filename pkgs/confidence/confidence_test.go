@@ -0,0 +1,50 @@
+// nolint:testpackage
+package confidence
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCitations(t *testing.T) {
+	answer := "See pkgs/index/search.go:10-20 and ./main.go:5; main.go:5 again, not version 1.2:3 or a.go:9-3."
+	var cited []string
+	for _, c := range Citations(answer) {
+		cited = append(cited, c.String())
+	}
+	expected := []string{"pkgs/index/search.go:10-20", "main.go:5"}
+	if !slices.Equal(cited, expected) {
+		t.Errorf("Expected citations %q, got %q", expected, cited)
+	}
+}
+
+func TestAssess(t *testing.T) {
+	sources := []Source{{Path: "a.go", StartLine: 1, EndLine: 10}}
+	tests := []struct {
+		name       string
+		answer     string
+		check      *SelfCheck
+		score      float64
+		band       string
+		unverified []string
+	}{
+		{"all cited retrieved", "a.go:3 and a.go:8-12", nil, 1, High, nil},
+		{"nothing cited", "it parses input", nil, 0, Low, nil},
+		{
+			"half cited retrieved with check", "a.go:3 and b.go:4",
+			&SelfCheck{Score: 0.8, Unverified: []string{"claim"}}, 0.65, Medium,
+			[]string{"引用的 b.go:4 不在检索到的片段中", "claim"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Assess(tt.answer, sources, tt.check)
+			if a.Score < tt.score-1e-9 || a.Score > tt.score+1e-9 || a.Band != tt.band {
+				t.Errorf("Expected %s (%.2f), got %s (%.2f)", tt.band, tt.score, a.Band, a.Score)
+			}
+			if !slices.Equal(a.Unverified, tt.unverified) {
+				t.Errorf("Expected unverified %q, got %q", tt.unverified, a.Unverified)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Environment variables consulted for each setting, in precedence order.
+// The first variable with a non-empty value wins. The provider-neutral
+// ARK_API_KEY and BASE_URL variables apply to every provider; the names a
+// vendor's own SDKs read, such as OPENAI_API_KEY, only apply to that vendor,
+// so its key is never sent to another one.
+var (
+	// APIKeyEnvVars lists the provider-neutral variables that may hold the API key.
+	APIKeyEnvVars = []string{"ARK_API_KEY"}
+
+	// BaseURLEnvVars lists the provider-neutral variables that may hold the API base URL.
+	BaseURLEnvVars = []string{"BASE_URL"}
+
+	// ModelEnvVars lists the variables that may hold the model name.
+	ModelEnvVars = []string{"MODEL"}
+
+	// EmbeddingModelEnvVars lists the variables that may hold the embedding model name.
+	EmbeddingModelEnvVars = []string{"EMBEDDING_MODEL"}
+
+	// ReasoningEffortEnvVars lists the variables that may hold the reasoning effort.
+	ReasoningEffortEnvVars = []string{"REASONING_EFFORT"}
+
+	// StreamEnvVars lists the variables that enable streaming when set to any non-empty value
+	// other than one strconv.ParseBool reads as false, such as "0" or "false", which disables it.
+	StreamEnvVars = []string{"STREAM"}
+
+	// ProviderEnvVars lists the variables that may hold the provider name.
+	ProviderEnvVars = []string{"PROVIDER"}
+
+	// providerAPIKeyEnvVars lists, per provider, the vendor's variables; see envVarsFor.
+	providerAPIKeyEnvVars = map[string][]string{
+		"openai":    {"OPENAI_API_KEY"},
+		"anthropic": {"ANTHROPIC_API_KEY"},
+		"gemini":    {"GEMINI_API_KEY", "GOOGLE_API_KEY"},
+		"azure":     {"AZURE_OPENAI_API_KEY"},
+	}
+
+	// providerBaseURLEnvVars lists, per provider, the vendor's variables; see envVarsFor.
+	providerBaseURLEnvVars = map[string][]string{
+		"openai":    {"OPENAI_BASE_URL"},
+		"anthropic": {"ANTHROPIC_BASE_URL"},
+		"gemini":    {"GEMINI_BASE_URL"},
+		"azure":     {"AZURE_OPENAI_ENDPOINT"},
+	}
+
+	// GeminiSafetyThresholdEnvVars lists the variables that may hold the Gemini safety threshold.
+	GeminiSafetyThresholdEnvVars = []string{"GEMINI_SAFETY_THRESHOLD"}
+
+	// AzureAPIVersionEnvVars lists the variables that may hold the Azure OpenAI api-version.
+	AzureAPIVersionEnvVars = []string{"AZURE_OPENAI_API_VERSION"}
+
+	// AzureDeploymentEnvVars lists the variables that may hold the Azure OpenAI deployment name.
+	AzureDeploymentEnvVars = []string{"AZURE_OPENAI_DEPLOYMENT"}
+
+	// AzureADTokenEnvVars lists the variables that may hold a Microsoft Entra ID access token.
+	AzureADTokenEnvVars = []string{"AZURE_OPENAI_AD_TOKEN"}
+)
+
+// Config holds the settings needed to talk to an LLM provider. The yaml tags
+// name the corresponding keys in config files.
+type Config struct {
+	// Provider names the LLM backend: "openai", "anthropic", "gemini" or "azure". Empty means "openai".
+	Provider string `yaml:"provider"`
+	APIKey   string `yaml:"api_key"`
+	Model    string `yaml:"model"`
+	BaseURL  string `yaml:"base_url"`
+	// Keyring reads the API key from the system keyring, where the init
+	// command stores it, when no other source sets one.
+	Keyring bool `yaml:"keyring"`
+	// Stream chooses whether answers are streamed; nil leaves the choice to
+	// the sources below. A pointer lets a higher source turn streaming off.
+	Stream *bool `yaml:"stream"`
+
+	// EmbeddingModel is the model used to embed code for semantic search.
+	EmbeddingModel string `yaml:"embedding_model"`
+
+	// ReasoningEffort ("low", "medium" or "high") and ThinkingBudget (tokens)
+	// trade latency and cost against reasoning depth; see llm.Request.
+	ReasoningEffort string `yaml:"reasoning_effort"`
+	ThinkingBudget  int    `yaml:"thinking_budget"`
+
+	// GeminiSafetyThreshold is the block threshold for Gemini harm categories.
+	GeminiSafetyThreshold string `yaml:"gemini_safety_threshold"`
+
+	// AzureAPIVersion, AzureDeployment and AzureADToken configure Azure OpenAI.
+	AzureAPIVersion string `yaml:"azure_api_version"`
+	AzureDeployment string `yaml:"azure_deployment"`
+	AzureADToken    string `yaml:"azure_ad_token"`
+
+	// HTTP tunes the HTTP client. It is only set from config files, usually
+	// in a providers section.
+	HTTP HTTPConfig `yaml:"http"`
+
+	// RateLimit is the rate the provider accepts requests at, which the
+	// --gentle mode stays under. It is set from config files, usually in a
+	// providers section, and from flags.
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+
+	// Auth authorizes requests another way than with APIKey, for gateways
+	// that need signed requests or OAuth2 tokens. It is only set from config
+	// files, usually in a providers section. A pointer keeps Config
+	// comparable.
+	Auth *AuthConfig `yaml:"auth"`
+
+	// Budget is the estimated cost in dollars above which batch runs ask
+	// for confirmation. Zero keeps the default.
+	Budget float64 `yaml:"budget"`
+
+	// Price overrides the price of the model in cost estimates, for models
+	// missing from the built-in price table such as self-hosted ones.
+	Price PriceConfig `yaml:"price"`
+}
+
+// PriceConfig is what a model charges, in dollars per million tokens. A zero
+// PriceConfig means the built-in price table applies.
+type PriceConfig struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+// AuthConfig selects an auth scheme and configures it.
+//
+//	auth:
+//	  type: oauth2
+//	  token_url: https://login.example.com/oauth2/token
+//	  client_id: aicodereader
+//	  client_secret: ${GATEWAY_CLIENT_SECRET}
+type AuthConfig struct {
+	// Type names the scheme, such as "hmac" or "oauth2".
+	Type string `yaml:"type"`
+	// Params are the other keys of the section. In the user config file,
+	// values may reference environment variables as $NAME or ${NAME}, so
+	// that secrets need not be written in config files; see ExpandedParams.
+	Params map[string]string `yaml:",inline"`
+
+	// expand is set for sections of the user config file, whose params
+	// may reference environment variables.
+	expand bool
+}
+
+// ExpandedParams returns the params of a with environment variable references
+// replaced by their values. Only sections of the user config file are
+// expanded: a project file could otherwise send any variable to an endpoint
+// of its choosing, such as an OAuth2 token URL.
+func (a *AuthConfig) ExpandedParams() map[string]string {
+	params := make(map[string]string, len(a.Params))
+	for key, value := range a.Params {
+		if a.expand {
+			value = os.ExpandEnv(value)
+		}
+		params[key] = value
+	}
+	return params
+}
+
+// RateLimitConfig is a provider's rate limit, such as that of a free tier.
+// Zero fields are not limited.
+type RateLimitConfig struct {
+	RequestsPerMinute int `yaml:"requests_per_minute"`
+	TokensPerMinute   int `yaml:"tokens_per_minute"`
+}
+
+// HTTPConfig tunes the HTTP client used to reach a provider. Zero fields keep
+// the defaults. Durations are written like "10s" or "2m".
+type HTTPConfig struct {
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	ReadTimeout    time.Duration `yaml:"read_timeout"`
+	// KeepAlive is the TCP keep-alive interval; a negative value disables keep-alive.
+	KeepAlive time.Duration `yaml:"keep_alive"`
+	// TLSMinVersion is "1.2" or "1.3".
+	TLSMinVersion string `yaml:"tls_min_version"`
+	MaxIdleConns  int    `yaml:"max_idle_conns"`
+}
+
+// LoadConfig builds a Config from environment variables for the provider
+// named by PROVIDER. Config files are not consulted; see Load.
+//
+// Precedence, highest first:
+//   - APIKey:  ARK_API_KEY, OPENAI_API_KEY
+//   - BaseURL: BASE_URL, OPENAI_BASE_URL
+//   - Model:   MODEL
+//   - Stream:  STREAM (0, false and other false values disable streaming, any other non-empty value enables it)
+//   - EmbeddingModel: EMBEDDING_MODEL
+//   - ReasoningEffort: REASONING_EFFORT
+//
+// The OPENAI_ variables only apply to openai. Other providers consult their
+// own variables instead, before ARK_API_KEY and BASE_URL: ANTHROPIC_API_KEY
+// and ANTHROPIC_BASE_URL for anthropic; GEMINI_API_KEY, GOOGLE_API_KEY and
+// GEMINI_BASE_URL for gemini; AZURE_OPENAI_API_KEY and AZURE_OPENAI_ENDPOINT
+// for azure. GEMINI_SAFETY_THRESHOLD sets the Gemini safety threshold, and
+// AZURE_OPENAI_API_VERSION, AZURE_OPENAI_DEPLOYMENT and AZURE_OPENAI_AD_TOKEN
+// configure Azure OpenAI.
+func LoadConfig() Config {
+	return LoadProviderConfig(lookupEnv(ProviderEnvVars))
+}
+
+// LoadProviderConfig builds a Config from environment variables for provider,
+// ignoring PROVIDER. It is used when the provider is chosen on the command line.
+func LoadProviderConfig(provider string) Config {
+	config := Config{
+		Provider: provider,
+		APIKey:   lookupEnv(envVarsFor(provider, providerAPIKeyEnvVars, APIKeyEnvVars)),
+		Model:    lookupEnv(ModelEnvVars),
+		BaseURL:  lookupEnv(envVarsFor(provider, providerBaseURLEnvVars, BaseURLEnvVars)),
+		Stream:   lookupBool(StreamEnvVars),
+
+		EmbeddingModel:  lookupEnv(EmbeddingModelEnvVars),
+		ReasoningEffort: lookupEnv(ReasoningEffortEnvVars),
+
+		GeminiSafetyThreshold: lookupEnv(GeminiSafetyThresholdEnvVars),
+
+		AzureAPIVersion: lookupEnv(AzureAPIVersionEnvVars),
+		AzureDeployment: lookupEnv(AzureDeploymentEnvVars),
+		AzureADToken:    lookupEnv(AzureADTokenEnvVars),
+	}
+
+	return config
+}
+
+// envVarsFor returns the variables consulted for a setting of provider, in
+// precedence order: the vendor's variables in byProvider, then the
+// provider-neutral ones. For openai, the default, the neutral variables come
+// first, as they did before the OpenAI-standard names were read.
+func envVarsFor(provider string, byProvider map[string][]string, neutral []string) []string {
+	if provider == "" || provider == "openai" {
+		return append(slices.Clone(neutral), byProvider["openai"]...)
+	}
+	return append(slices.Clone(byProvider[provider]), neutral...)
+}
+
+// Streaming reports whether answers are streamed, which they are only when
+// Stream enables it.
+func (c Config) Streaming() bool {
+	return c.Stream != nil && *c.Stream
+}
+
+// lookupBool returns the value of the first non-empty variable in keys as a
+// bool, nil if none is set. Values strconv.ParseBool does not read count as
+// true.
+func lookupBool(keys []string) *bool {
+	value := lookupEnv(keys)
+	if value == "" {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	enabled = enabled || err != nil
+	return &enabled
+}
+
+// lookupEnv returns the value of the first non-empty variable in keys.
+func lookupEnv(keys []string) string {
+	for _, key := range keys {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
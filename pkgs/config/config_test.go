@@ -0,0 +1,237 @@
+// nolint:testpackage
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+// clearConfigEnv blanks every variable LoadConfig consults for the duration of the test.
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+	envVars := [][]string{APIKeyEnvVars, BaseURLEnvVars, ModelEnvVars, StreamEnvVars, ProviderEnvVars,
+		GeminiSafetyThresholdEnvVars, AzureAPIVersionEnvVars, AzureDeploymentEnvVars, AzureADTokenEnvVars}
+	for _, byProvider := range []map[string][]string{providerAPIKeyEnvVars, providerBaseURLEnvVars} {
+		for _, keys := range byProvider {
+			envVars = append(envVars, keys)
+		}
+	}
+
+	for _, keys := range envVars {
+		for _, key := range keys {
+			t.Setenv(key, "")
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	// Test with empty environment
+	clearConfigEnv(t)
+
+	config := LoadConfig()
+
+	if config.APIKey != "" {
+		t.Errorf("Expected empty APIKey, got %s", config.APIKey)
+	}
+	if config.Model != "" {
+		t.Errorf("Expected empty Model, got %s", config.Model)
+	}
+	if config.BaseURL != "" {
+		t.Errorf("Expected empty BaseURL, got %s", config.BaseURL)
+	}
+	if config.Stream != nil {
+		t.Errorf("Expected Stream to be unset, got %v", *config.Stream)
+	}
+
+	// Test with set environment variables
+	os.Setenv("ARK_API_KEY", "test-key")
+	os.Setenv("MODEL", "test-model")
+	os.Setenv("BASE_URL", "https://test.com")
+	os.Setenv("STREAM", "true")
+
+	config = LoadConfig()
+
+	if config.APIKey != "test-key" {
+		t.Errorf("Expected APIKey 'test-key', got %s", config.APIKey)
+	}
+	if config.Model != "test-model" {
+		t.Errorf("Expected Model 'test-model', got %s", config.Model)
+	}
+	if config.BaseURL != "https://test.com" {
+		t.Errorf("Expected BaseURL 'https://test.com', got %s", config.BaseURL)
+	}
+	if !config.Streaming() {
+		t.Errorf("Expected Stream to be true, got false")
+	}
+
+	os.Setenv("STREAM", "0")
+	if config = LoadConfig(); config.Stream == nil || config.Streaming() {
+		t.Errorf("Expected STREAM=0 to disable streaming, got %v", config.Stream)
+	}
+}
+
+func TestConfigStruct(t *testing.T) {
+	config := Config{
+		APIKey:  "test-api-key",
+		Model:   "test-model",
+		BaseURL: "https://test.example.com",
+	}
+	streaming := true
+	config.Stream = &streaming
+
+	if config.APIKey != "test-api-key" {
+		t.Errorf("Expected APIKey 'test-api-key', got %s", config.APIKey)
+	}
+	if config.Model != "test-model" {
+		t.Errorf("Expected Model 'test-model', got %s", config.Model)
+	}
+	if config.BaseURL != "https://test.example.com" {
+		t.Errorf("Expected BaseURL 'https://test.example.com', got %s", config.BaseURL)
+	}
+	if !config.Streaming() {
+		t.Errorf("Expected Stream to be true, got false")
+	}
+}
+
+func TestLoadConfigProviderAgnosticEnv(t *testing.T) {
+	clearConfigEnv(t)
+
+	os.Setenv("OPENAI_API_KEY", "openai-key")
+	os.Setenv("OPENAI_BASE_URL", "https://api.openai.example.com/v1")
+
+	config := LoadConfig()
+
+	if config.APIKey != "openai-key" {
+		t.Errorf("Expected APIKey 'openai-key', got %s", config.APIKey)
+	}
+	if config.BaseURL != "https://api.openai.example.com/v1" {
+		t.Errorf("Expected BaseURL 'https://api.openai.example.com/v1', got %s", config.BaseURL)
+	}
+
+	// Vendor keys are never sent to another vendor
+	os.Unsetenv("OPENAI_API_KEY")
+	os.Setenv("ANTHROPIC_API_KEY", "anthropic-key")
+
+	config = LoadConfig()
+
+	if config.APIKey != "" {
+		t.Errorf("Expected ANTHROPIC_API_KEY to be ignored for openai, got %s", config.APIKey)
+	}
+	if config = LoadProviderConfig("anthropic"); config.APIKey != "anthropic-key" || config.BaseURL != "" {
+		t.Errorf("Expected only the anthropic variables for anthropic, got %+v", config)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	clearConfigEnv(t)
+
+	os.Setenv("ARK_API_KEY", "ark-key")
+	os.Setenv("OPENAI_API_KEY", "openai-key")
+	os.Setenv("ANTHROPIC_API_KEY", "anthropic-key")
+	os.Setenv("BASE_URL", "https://legacy.example.com")
+	os.Setenv("OPENAI_BASE_URL", "https://openai.example.com")
+
+	config := LoadConfig()
+
+	if config.APIKey != "ark-key" {
+		t.Errorf("Expected ARK_API_KEY to take precedence, got %s", config.APIKey)
+	}
+	if config.BaseURL != "https://legacy.example.com" {
+		t.Errorf("Expected BASE_URL to take precedence, got %s", config.BaseURL)
+	}
+
+	os.Unsetenv("ARK_API_KEY")
+
+	config = LoadConfig()
+
+	if config.APIKey != "openai-key" {
+		t.Errorf("Expected OPENAI_API_KEY to take precedence over ANTHROPIC_API_KEY, got %s", config.APIKey)
+	}
+}
+
+func TestLoadConfigAnthropicProvider(t *testing.T) {
+	clearConfigEnv(t)
+
+	os.Setenv("PROVIDER", "anthropic")
+	os.Setenv("ARK_API_KEY", "ark-key")
+	os.Setenv("ANTHROPIC_API_KEY", "anthropic-key")
+	os.Setenv("BASE_URL", "https://legacy.example.com")
+	os.Setenv("ANTHROPIC_BASE_URL", "https://anthropic.example.com")
+
+	config := LoadConfig()
+
+	if config.Provider != "anthropic" {
+		t.Errorf("Expected Provider 'anthropic', got %s", config.Provider)
+	}
+	if config.APIKey != "anthropic-key" {
+		t.Errorf("Expected ANTHROPIC_API_KEY to take precedence for anthropic, got %s", config.APIKey)
+	}
+	if config.BaseURL != "https://anthropic.example.com" {
+		t.Errorf("Expected ANTHROPIC_BASE_URL to take precedence for anthropic, got %s", config.BaseURL)
+	}
+
+	config = LoadProviderConfig("openai")
+
+	if config.Provider != "openai" {
+		t.Errorf("Expected Provider 'openai', got %s", config.Provider)
+	}
+	if config.APIKey != "ark-key" {
+		t.Errorf("Expected ARK_API_KEY for openai, got %s", config.APIKey)
+	}
+	if config.BaseURL != "https://legacy.example.com" {
+		t.Errorf("Expected BASE_URL for openai, got %s", config.BaseURL)
+	}
+}
+
+func TestLoadConfigGeminiProvider(t *testing.T) {
+	clearConfigEnv(t)
+
+	os.Setenv("OPENAI_API_KEY", "openai-key")
+	os.Setenv("GOOGLE_API_KEY", "google-key")
+	os.Setenv("GEMINI_BASE_URL", "https://gemini.example.com")
+	os.Setenv("GEMINI_SAFETY_THRESHOLD", "BLOCK_NONE")
+
+	config := LoadProviderConfig("gemini")
+
+	if config.APIKey != "google-key" {
+		t.Errorf("Expected GOOGLE_API_KEY for gemini, got %s", config.APIKey)
+	}
+	if config.BaseURL != "https://gemini.example.com" {
+		t.Errorf("Expected GEMINI_BASE_URL for gemini, got %s", config.BaseURL)
+	}
+	if config.GeminiSafetyThreshold != "BLOCK_NONE" {
+		t.Errorf("Expected GeminiSafetyThreshold 'BLOCK_NONE', got %s", config.GeminiSafetyThreshold)
+	}
+
+	os.Setenv("GEMINI_API_KEY", "gemini-key")
+
+	if config = LoadProviderConfig("gemini"); config.APIKey != "gemini-key" {
+		t.Errorf("Expected GEMINI_API_KEY to take precedence over GOOGLE_API_KEY, got %s", config.APIKey)
+	}
+
+	os.Unsetenv("GEMINI_API_KEY")
+	os.Unsetenv("GOOGLE_API_KEY")
+	if config = LoadProviderConfig("gemini"); config.APIKey != "" {
+		t.Errorf("Expected OPENAI_API_KEY to be ignored for gemini, got %s", config.APIKey)
+	}
+}
+
+func TestLoadConfigAzureProvider(t *testing.T) {
+	clearConfigEnv(t)
+
+	os.Setenv("AZURE_OPENAI_API_KEY", "azure-key")
+	os.Setenv("AZURE_OPENAI_ENDPOINT", "https://res.openai.azure.com")
+	os.Setenv("AZURE_OPENAI_API_VERSION", "2024-06-01")
+	os.Setenv("AZURE_OPENAI_DEPLOYMENT", "gpt4o-prod")
+	os.Setenv("AZURE_OPENAI_AD_TOKEN", "entra-token")
+
+	config := LoadProviderConfig("azure")
+
+	if config.APIKey != "azure-key" || config.BaseURL != "https://res.openai.azure.com" {
+		t.Errorf("Expected Azure key and endpoint, got %s, %s", config.APIKey, config.BaseURL)
+	}
+	if config.AzureAPIVersion != "2024-06-01" || config.AzureDeployment != "gpt4o-prod" ||
+		config.AzureADToken != "entra-token" {
+		t.Errorf("Unexpected Azure settings: %+v", config)
+	}
+}
@@ -0,0 +1,359 @@
+package config
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileName is the repo-local config file, looked up from the working
+// directory upwards to the repository root.
+const ProjectFileName = ".aicodereader.yaml"
+
+// File is the content of a config file. Top-level settings apply to every
+// provider; entries in Providers override them when that provider is selected.
+//
+//	provider: anthropic
+//	model: claude-sonnet-4-5
+//	providers:
+//	  gemini:
+//	    model: gemini-2.5-pro
+//	    http:
+//	      connect_timeout: 5s
+//	      read_timeout: 2m
+//	    rate_limit:
+//	      requests_per_minute: 15
+//
+// Profiles are named sets of settings applied on top of the rest of the
+// files, and Commands binds subcommands to the profile they use unless
+// --profile picks another:
+//
+//	profiles:
+//	  fast:
+//	    model: gpt-4o-mini
+//	  strong:
+//	    model: o3
+//	    reasoning_effort: high
+//	commands:
+//	  ask: fast
+//	  review: strong
+type File struct {
+	Config    `yaml:",inline"`
+	Providers map[string]Config `yaml:"providers"`
+
+	Profiles map[string]Config `yaml:"profiles"`
+	// Commands maps subcommands, such as ask or "index export", to the name
+	// of a profile. A subcommand without an entry uses the one of the
+	// command it belongs to, such as index for "index export".
+	Commands map[string]string `yaml:"commands"`
+
+	// TrustedProjects lists the repositories, by the directory of their
+	// ProjectFileName, whose project file may set credentials and endpoints.
+	// Only the user config file can set it.
+	TrustedProjects []string `yaml:"trusted_projects"`
+}
+
+// untrusted returns f without the settings a project file may only make
+// when its repository is trusted: credentials and endpoints, which a file in
+// an untrusted checkout could otherwise use to send the user's keys, taken
+// from the environment, elsewhere. It logs the settings left out of the file
+// at path.
+func (f File) untrusted(path string) File {
+	var dropped []string
+	strip := func(section string, config *Config) {
+		for key, set := range map[string]bool{
+			"api_key":        config.APIKey != "",
+			"base_url":       config.BaseURL != "",
+			"auth":           config.Auth != nil,
+			"azure_ad_token": config.AzureADToken != "",
+		} {
+			if set {
+				dropped = append(dropped, section+key)
+			}
+		}
+		config.APIKey, config.BaseURL, config.Auth, config.AzureADToken = "", "", nil, ""
+	}
+
+	strip("", &f.Config)
+	providers := make(map[string]Config, len(f.Providers))
+	for name, config := range f.Providers {
+		strip("providers."+name+".", &config)
+		providers[name] = config
+	}
+	f.Providers = providers
+	profiles := make(map[string]Config, len(f.Profiles))
+	for name, config := range f.Profiles {
+		strip("profiles."+name+".", &config)
+		profiles[name] = config
+	}
+	f.Profiles = profiles
+	if len(f.TrustedProjects) > 0 {
+		dropped = append(dropped, "trusted_projects")
+		f.TrustedProjects = nil
+	}
+
+	if len(dropped) > 0 {
+		sort.Strings(dropped)
+		log.Printf("WARNING: ignoring %s in %s; add %s to trusted_projects in %s to allow it",
+			strings.Join(dropped, ", "), path, filepath.Dir(path), UserFilePath())
+	}
+	return f
+}
+
+// trusts reports whether f, a user config file, trusts the project file at
+// path.
+func (f File) trusts(path string) bool {
+	dir := filepath.Dir(path)
+	for _, trusted := range f.TrustedProjects {
+		if abs, err := filepath.Abs(trusted); err == nil && abs == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// expandAuth lets the auth sections of f, the user config file, reference
+// environment variables; see AuthConfig.ExpandedParams.
+func (f File) expandAuth() {
+	if f.Auth != nil {
+		f.Auth.expand = true
+	}
+	for _, configs := range []map[string]Config{f.Providers, f.Profiles} {
+		for _, config := range configs {
+			if config.Auth != nil {
+				config.Auth.expand = true
+			}
+		}
+	}
+}
+
+// forProvider returns the file's settings with provider's section applied.
+func (f File) forProvider(provider string) Config {
+	return Merge(f.Config, f.Providers[provider])
+}
+
+// boundProfile returns the name of the profile f binds command to, or to
+// the command it belongs to, and "" if there is none.
+func (f File) boundProfile(command string) string {
+	for command != "" {
+		if profile, ok := f.Commands[command]; ok {
+			return profile
+		}
+		command = command[:max(strings.LastIndex(command, " "), 0)]
+	}
+	return ""
+}
+
+// profile returns the settings of the profile called name, those of the
+// project file applied on top of those of the user file.
+func profile(name string, user, project File) (Config, error) {
+	userProfile, inUser := user.Profiles[name]
+	projectProfile, inProject := project.Profiles[name]
+	if !inUser && !inProject {
+		names := slices.Concat(slices.Collect(maps.Keys(user.Profiles)), slices.Collect(maps.Keys(project.Profiles)))
+		slices.Sort(names)
+		names = slices.Compact(names)
+		if len(names) == 0 {
+			return Config{}, fmt.Errorf("unknown profile %q: no profiles are defined in config files", name)
+		}
+		return Config{}, fmt.Errorf("unknown profile %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return Merge(userProfile, projectProfile), nil
+}
+
+// ReadFile parses the config file at path. A missing file yields an empty
+// File and no error. Unknown keys are rejected so typos do not go unnoticed.
+func ReadFile(path string) (File, error) {
+	var file File
+	if path == "" {
+		return file, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return file, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return file, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// UserFilePath returns the per-user config file,
+// $XDG_CONFIG_HOME/aicodereader/config.yaml or ~/.config/aicodereader/config.yaml.
+// It returns "" if no home directory is known.
+func UserFilePath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "aicodereader", "config.yaml")
+}
+
+// ProjectFilePath returns the nearest ProjectFileName in dir or its parents,
+// stopping at the first directory that contains .git. It returns "" if there
+// is none.
+func ProjectFilePath(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Load builds the effective Config by merging, from lowest to highest
+// precedence, the user config file, the project config file, environment
+// variables and flags. Non-empty fields of flags hold command-line values.
+// It applies no profile; see LoadFor.
+//
+// The project file comes with the repository, so it cannot set credentials
+// or endpoints unless the user config lists its repository in
+// trusted_projects.
+//
+// The provider is resolved first, from the same sources in the same order, so
+// that the matching providers section and provider-specific environment
+// variables are used.
+func Load(flags Config) (Config, error) {
+	return LoadFor(flags, "", "")
+}
+
+// LoadFor is Load for command, a subcommand such as ask or "index export",
+// applying a profile of the config files on top of the files: the one named
+// profile, if set, or else the one the files bind command to. The project
+// file binds commands over the user file.
+func LoadFor(flags Config, command, profileName string) (Config, error) {
+	user, err := ReadFile(UserFilePath())
+	if err != nil {
+		return Config{}, err
+	}
+	user.expandAuth()
+
+	projectPath := ProjectFilePath(".")
+	project, err := ReadFile(projectPath)
+	if err != nil {
+		return Config{}, err
+	}
+	if !user.trusts(projectPath) {
+		project = project.untrusted(projectPath)
+	}
+
+	profileName = firstNonEmpty(profileName, project.boundProfile(command), user.boundProfile(command))
+	var settings Config
+	if profileName != "" {
+		if settings, err = profile(profileName, user, project); err != nil {
+			return Config{}, err
+		}
+	}
+
+	provider := firstNonEmpty(flags.Provider, lookupEnv(ProviderEnvVars), settings.Provider, project.Provider, user.Provider)
+
+	config := Merge(user.forProvider(provider), project.forProvider(provider))
+	config = Merge(config, settings)
+	config = Merge(config, LoadProviderConfig(provider))
+	config = Merge(config, flags)
+	config.Provider = provider
+	return config, nil
+}
+
+// Merge returns base with every non-empty field of override applied on top.
+func Merge(base, override Config) Config {
+	return Config{
+		Provider: firstNonEmpty(override.Provider, base.Provider),
+		APIKey:   firstNonEmpty(override.APIKey, base.APIKey),
+		Model:    firstNonEmpty(override.Model, base.Model),
+		BaseURL:  firstNonEmpty(override.BaseURL, base.BaseURL),
+		Keyring:  override.Keyring || base.Keyring,
+		Stream:   cmp.Or(override.Stream, base.Stream),
+
+		EmbeddingModel:  firstNonEmpty(override.EmbeddingModel, base.EmbeddingModel),
+		ReasoningEffort: firstNonEmpty(override.ReasoningEffort, base.ReasoningEffort),
+		ThinkingBudget:  cmp.Or(override.ThinkingBudget, base.ThinkingBudget),
+
+		GeminiSafetyThreshold: firstNonEmpty(override.GeminiSafetyThreshold, base.GeminiSafetyThreshold),
+
+		AzureAPIVersion: firstNonEmpty(override.AzureAPIVersion, base.AzureAPIVersion),
+		AzureDeployment: firstNonEmpty(override.AzureDeployment, base.AzureDeployment),
+		AzureADToken:    firstNonEmpty(override.AzureADToken, base.AzureADToken),
+
+		HTTP: mergeHTTP(base.HTTP, override.HTTP),
+
+		// An auth section replaces the one below it as a whole: the params of
+		// different schemes do not mix
+		Auth: cmp.Or(override.Auth, base.Auth),
+
+		RateLimit: RateLimitConfig{
+			RequestsPerMinute: cmp.Or(override.RateLimit.RequestsPerMinute, base.RateLimit.RequestsPerMinute),
+			TokensPerMinute:   cmp.Or(override.RateLimit.TokensPerMinute, base.RateLimit.TokensPerMinute),
+		},
+
+		Budget: cmp.Or(override.Budget, base.Budget),
+		// Input and output prices belong together
+		Price: cmp.Or(override.Price, base.Price),
+	}
+}
+
+// mergeHTTP returns base with every non-zero field of override applied on top.
+func mergeHTTP(base, override HTTPConfig) HTTPConfig {
+	if override.ConnectTimeout != 0 {
+		base.ConnectTimeout = override.ConnectTimeout
+	}
+	if override.ReadTimeout != 0 {
+		base.ReadTimeout = override.ReadTimeout
+	}
+	if override.KeepAlive != 0 {
+		base.KeepAlive = override.KeepAlive
+	}
+	if override.TLSMinVersion != "" {
+		base.TLSMinVersion = override.TLSMinVersion
+	}
+	if override.MaxIdleConns != 0 {
+		base.MaxIdleConns = override.MaxIdleConns
+	}
+	return base
+}
+
+// firstNonEmpty returns the first non-empty value.
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
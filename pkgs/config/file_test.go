@@ -0,0 +1,401 @@
+// nolint:testpackage
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes content to path, creating parent directories.
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// setupConfigFiles points the user config at a temporary XDG_CONFIG_HOME and
+// changes into a temporary repository, returning the user and project file paths.
+func setupConfigFiles(t *testing.T) (string, string) {
+	t.Helper()
+	clearConfigEnv(t)
+
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+
+	repo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git: %v", err)
+	}
+	t.Chdir(repo)
+
+	return filepath.Join(home, "aicodereader", "config.yaml"), filepath.Join(repo, ProjectFileName)
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	file, err := ReadFile(path)
+	if err != nil {
+		t.Errorf("Expected missing file to be ignored, got %v", err)
+	}
+	if file.Provider != "" || file.Providers != nil {
+		t.Errorf("Expected empty File for missing file, got %+v", file)
+	}
+
+	writeConfigFile(t, path, "provider: gemini\nmodel: m\nstream: true\nproviders:\n  gemini:\n    model: gemini-pro\n")
+	file, err = ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if file.Provider != "gemini" || file.Model != "m" || !file.Streaming() {
+		t.Errorf("Unexpected top-level settings: %+v", file.Config)
+	}
+	if got := file.forProvider("gemini").Model; got != "gemini-pro" {
+		t.Errorf("Expected providers section to override model, got %s", got)
+	}
+	if got := file.forProvider("openai").Model; got != "m" {
+		t.Errorf("Expected top-level model for other providers, got %s", got)
+	}
+
+	writeConfigFile(t, path, "")
+	if _, err := ReadFile(path); err != nil {
+		t.Errorf("Expected empty file to parse, got %v", err)
+	}
+
+	writeConfigFile(t, path, "modle: typo\n")
+	if _, err := ReadFile(path); err == nil {
+		t.Errorf("Expected error for unknown key")
+	}
+}
+
+func TestProjectFilePath(t *testing.T) {
+	repo := t.TempDir()
+	sub := filepath.Join(repo, "pkgs", "deep")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git: %v", err)
+	}
+
+	if got := ProjectFilePath(sub); got != "" {
+		t.Errorf("Expected no project file, got %s", got)
+	}
+
+	path := filepath.Join(repo, ProjectFileName)
+	writeConfigFile(t, path, "model: m\n")
+	if got := ProjectFilePath(sub); got != path {
+		t.Errorf("Expected %s, got %s", path, got)
+	}
+
+	// Files above the repository root are not picked up
+	nested := filepath.Join(sub, "nested")
+	if err := os.MkdirAll(filepath.Join(nested, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create nested repo: %v", err)
+	}
+	if got := ProjectFilePath(nested); got != "" {
+		t.Errorf("Expected lookup to stop at the repository root, got %s", got)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	userPath, projectPath := setupConfigFiles(t)
+
+	writeConfigFile(t, userPath, "api_key: user-key\nmodel: user-model\nbase_url: https://user\nazure_deployment: user-deploy\n")
+	writeConfigFile(t, projectPath, "model: project-model\nbase_url: https://project\n")
+	t.Setenv("BASE_URL", "https://env")
+
+	config, err := Load(Config{Model: "flag-model"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	expected := Config{
+		APIKey:          "user-key",
+		Model:           "flag-model",
+		BaseURL:         "https://env",
+		AzureDeployment: "user-deploy",
+	}
+	if config != expected {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+
+	config, err = Load(Config{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Model != "project-model" {
+		t.Errorf("Expected project file to override user file, got %s", config.Model)
+	}
+}
+
+func TestLoadProviderSelection(t *testing.T) {
+	userPath, projectPath := setupConfigFiles(t)
+
+	writeConfigFile(t, userPath, "provider: anthropic\nproviders:\n  anthropic:\n    model: claude\n  gemini:\n    model: gemini-pro\n")
+	writeConfigFile(t, projectPath, "providers:\n  gemini:\n    api_key: project-gemini-key\n")
+	t.Setenv("ANTHROPIC_API_KEY", "anthropic-key")
+	t.Setenv("GEMINI_API_KEY", "gemini-key")
+
+	config, err := Load(Config{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Provider != "anthropic" || config.Model != "claude" || config.APIKey != "anthropic-key" {
+		t.Errorf("Expected anthropic settings from user file and env, got %+v", config)
+	}
+
+	t.Setenv("PROVIDER", "gemini")
+	config, err = Load(Config{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Provider != "gemini" || config.Model != "gemini-pro" || config.APIKey != "gemini-key" {
+		t.Errorf("Expected PROVIDER to select gemini settings, got %+v", config)
+	}
+
+	config, err = Load(Config{Provider: "openai"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Provider != "openai" || config.Model != "" {
+		t.Errorf("Expected flag to select openai without provider sections, got %+v", config)
+	}
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	_, projectPath := setupConfigFiles(t)
+	writeConfigFile(t, projectPath, "model: [unterminated\n")
+
+	if _, err := Load(Config{}); err == nil {
+		t.Errorf("Expected error for malformed project file")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	streaming, off := true, false
+	base := Config{Provider: "openai", APIKey: "base-key", Model: "base-model", Stream: &streaming, EmbeddingModel: "embed"}
+	override := Config{Model: "override-model", AzureADToken: "token", ThinkingBudget: 2048}
+
+	expected := Config{Provider: "openai", APIKey: "base-key", Model: "override-model", Stream: &streaming,
+		EmbeddingModel: "embed", ThinkingBudget: 2048, AzureADToken: "token"}
+	if got := Merge(base, override); got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+
+	if got := Merge(base, Config{Stream: &off}); got.Streaming() {
+		t.Errorf("Expected the override to turn streaming off")
+	}
+}
+
+func TestLoadStream(t *testing.T) {
+	userPath, projectPath := setupConfigFiles(t)
+	writeConfigFile(t, userPath, "stream: true\n")
+
+	config, err := Load(Config{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !config.Streaming() {
+		t.Errorf("Expected the user file to enable streaming")
+	}
+
+	writeConfigFile(t, projectPath, "stream: false\n")
+	if config, err = Load(Config{}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Streaming() {
+		t.Errorf("Expected the project file to disable streaming")
+	}
+
+	t.Setenv("STREAM", "1")
+	if config, err = Load(Config{}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !config.Streaming() {
+		t.Errorf("Expected STREAM to enable streaming over the project file")
+	}
+}
+
+func TestLoadHTTPProfile(t *testing.T) {
+	userPath, projectPath := setupConfigFiles(t)
+
+	writeConfigFile(t, userPath, "http:\n  connect_timeout: 5s\n  max_idle_conns: 8\nproviders:\n  gemini:\n    http:\n      read_timeout: 2m\n")
+	writeConfigFile(t, projectPath, "providers:\n  gemini:\n    http:\n      tls_min_version: \"1.3\"\n      keep_alive: -1s\n")
+
+	config, err := Load(Config{Provider: "gemini"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	expected := HTTPConfig{
+		ConnectTimeout: 5 * time.Second,
+		ReadTimeout:    2 * time.Minute,
+		KeepAlive:      -time.Second,
+		TLSMinVersion:  "1.3",
+		MaxIdleConns:   8,
+	}
+	if config.HTTP != expected {
+		t.Errorf("Expected %+v, got %+v", expected, config.HTTP)
+	}
+
+	config, err = Load(Config{Provider: "openai"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if expected := (HTTPConfig{ConnectTimeout: 5 * time.Second, MaxIdleConns: 8}); config.HTTP != expected {
+		t.Errorf("Expected only top-level HTTP settings for openai, got %+v", config.HTTP)
+	}
+}
+
+func TestLoadRateLimit(t *testing.T) {
+	userPath, _ := setupConfigFiles(t)
+	writeConfigFile(t, userPath, "rate_limit:\n  tokens_per_minute: 40000\nproviders:\n  gemini:\n    rate_limit:\n      requests_per_minute: 15\n")
+
+	config, err := Load(Config{Provider: "gemini"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if expected := (RateLimitConfig{RequestsPerMinute: 15, TokensPerMinute: 40000}); config.RateLimit != expected {
+		t.Errorf("Expected %+v, got %+v", expected, config.RateLimit)
+	}
+
+	config, err = Load(Config{Provider: "gemini", RateLimit: RateLimitConfig{RequestsPerMinute: 2}})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.RateLimit.RequestsPerMinute != 2 {
+		t.Errorf("Expected the flag to override the config file, got %+v", config.RateLimit)
+	}
+}
+
+func TestLoadBudgetAndPrice(t *testing.T) {
+	userPath, projectPath := setupConfigFiles(t)
+	writeConfigFile(t, userPath, "budget: 5\nprice:\n  input: 0.1\n  output: 0.2\n")
+	writeConfigFile(t, projectPath, "providers:\n  openai:\n    price:\n      input: 1\n")
+
+	config, err := Load(Config{Provider: "openai"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Budget != 5 {
+		t.Errorf("Expected the user budget, got %v", config.Budget)
+	}
+	if expected := (PriceConfig{Input: 1}); config.Price != expected {
+		t.Errorf("Expected the provider's price to replace the user price, got %+v", config.Price)
+	}
+
+	config, err = Load(Config{Provider: "openai", Budget: 0.5})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Budget != 0.5 {
+		t.Errorf("Expected the flag to override the config file, got %v", config.Budget)
+	}
+}
+
+func TestLoadAuth(t *testing.T) {
+	userPath, projectPath := setupConfigFiles(t)
+	t.Setenv("GATEWAY_SECRET", "s3cret")
+	writeConfigFile(t, userPath, "trusted_projects: ["+filepath.Dir(projectPath)+"]\nauth:\n  type: hmac\n  key_id: user\n  secret: x\n")
+	writeConfigFile(t, projectPath, "providers:\n  openai:\n    auth:\n      type: oauth2\n      client_secret: ${GATEWAY_SECRET}\n")
+
+	config, err := Load(Config{Provider: "openai"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Auth == nil || config.Auth.Type != "oauth2" {
+		t.Fatalf("Expected the provider's auth section, got %+v", config.Auth)
+	}
+	params := config.Auth.ExpandedParams()
+	if len(params) != 1 || params["client_secret"] != "${GATEWAY_SECRET}" {
+		t.Errorf("Expected only the oauth2 params, with variables of the project file left as written, got %v", params)
+	}
+
+	writeConfigFile(t, userPath, "providers:\n  openai:\n    auth:\n      type: hmac\n      secret: ${GATEWAY_SECRET}\n")
+	writeConfigFile(t, projectPath, "")
+	if config, err = Load(Config{Provider: "openai"}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if params := config.Auth.ExpandedParams(); params["secret"] != "s3cret" {
+		t.Errorf("Expected variables of the user file expanded, got %v", params)
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	userPath, projectPath := setupConfigFiles(t)
+	writeConfigFile(t, userPath, "model: default-model\n"+
+		"profiles:\n  fast:\n    model: fast-model\n  strong:\n    model: strong-model\n    reasoning_effort: high\n"+
+		"commands:\n  ask: fast\n  index: strong\n")
+	writeConfigFile(t, projectPath, "profiles:\n  fast:\n    provider: gemini\n    api_key: project-key\n"+
+		"commands:\n  review: fast\n")
+
+	tests := []struct {
+		command, profile string
+		expected         Config
+	}{
+		{"summarize", "", Config{Model: "default-model"}},
+		// The project's fast profile sets the provider, but not its key
+		{"ask", "", Config{Provider: "gemini", Model: "fast-model"}},
+		{"review", "", Config{Provider: "gemini", Model: "fast-model"}},
+		{"index export", "", Config{Model: "strong-model", ReasoningEffort: "high"}},
+		{"ask", "strong", Config{Model: "strong-model", ReasoningEffort: "high"}},
+	}
+	for _, tt := range tests {
+		config, err := LoadFor(Config{}, tt.command, tt.profile)
+		if err != nil {
+			t.Fatalf("LoadFor(%q, %q) failed: %v", tt.command, tt.profile, err)
+		}
+		if config != tt.expected {
+			t.Errorf("LoadFor(%q, %q): expected %+v, got %+v", tt.command, tt.profile, tt.expected, config)
+		}
+	}
+
+	t.Setenv("MODEL", "env-model")
+	config, err := LoadFor(Config{ReasoningEffort: "low"}, "index", "")
+	if err != nil {
+		t.Fatalf("LoadFor failed: %v", err)
+	}
+	if config.Model != "env-model" || config.ReasoningEffort != "low" {
+		t.Errorf("Expected the environment and flags to override the profile, got %+v", config)
+	}
+
+	_, err = LoadFor(Config{}, "ask", "missing")
+	if err == nil || !strings.Contains(err.Error(), "expected one of fast, strong") {
+		t.Errorf("Expected an unknown profile to be rejected with the known ones, got %v", err)
+	}
+}
+
+func TestLoadUntrustedProject(t *testing.T) {
+	userPath, projectPath := setupConfigFiles(t)
+	t.Setenv("OPENAI_API_KEY", "env-key")
+	writeConfigFile(t, projectPath, "model: project-model\nbase_url: https://attacker\nazure_ad_token: t\n"+
+		"auth:\n  type: oauth2\n  token_url: https://attacker\n"+
+		"providers:\n  openai:\n    api_key: project-key\n"+
+		"trusted_projects: [/]\n")
+
+	config, err := Load(Config{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	expected := Config{Model: "project-model", APIKey: "env-key"}
+	if config != expected {
+		t.Errorf("Expected only the project's model, got %+v", config)
+	}
+
+	writeConfigFile(t, userPath, "trusted_projects:\n  - "+filepath.Dir(projectPath)+"\n")
+	config, err = Load(Config{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.BaseURL != "https://attacker" || config.APIKey != "env-key" || config.AzureADToken != "t" || config.Auth == nil {
+		t.Errorf("Expected a trusted project to set endpoints and credentials, got %+v", config)
+	}
+}
@@ -0,0 +1,173 @@
+// Package corpus samples a representative subset of a repository's files
+// into a directory, so prompt changes can be tried on realistic inputs
+// rather than on whichever files come to mind.
+package corpus
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JackDrogon/aicodereader/pkgs/lang"
+	"github.com/JackDrogon/aicodereader/pkgs/utils"
+)
+
+// ManifestName is the file listing the sampled files in a corpus directory.
+const ManifestName = "corpus.json"
+
+// Size buckets separate files that fit a prompt easily, typical source
+// files and files large enough to be analyzed in parts.
+const (
+	smallFileSize  = 4 << 10
+	mediumFileSize = 32 << 10
+)
+
+// File is a candidate file.
+type File struct {
+	// Path is relative to the repository root, with forward slashes.
+	Path string `json:"path"`
+	// Language is detected by lang.Detect, "other" if unknown.
+	Language string `json:"language"`
+	Size     int64  `json:"size"`
+	// Copy is where Write copied the file in the corpus, relative to it,
+	// when Path itself cannot be created on every platform.
+	Copy string `json:"copy,omitempty"`
+}
+
+// Manifest describes a corpus directory.
+type Manifest struct {
+	// Seed reproduces the sample from the same repository.
+	Seed  uint64 `json:"seed"`
+	Files []File `json:"files"`
+}
+
+// Collect describes files, given as paths under root from
+// utils.GetSourceList, leaving out empty and non-text files.
+func Collect(root string, files []string) ([]File, error) {
+	out := make([]File, 0, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if len(content) == 0 || !utf8.Valid(content) {
+			continue
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return nil, err
+		}
+		language := cmp.Or(lang.Detect(file, content), "other")
+		out = append(out, File{Path: filepath.ToSlash(rel), Language: language, Size: int64(len(content))})
+	}
+	return out, nil
+}
+
+// stratum groups files by language, size bucket and top-level directory.
+func stratum(f File) string {
+	bucket := "large"
+	switch {
+	case f.Size < smallFileSize:
+		bucket = "small"
+	case f.Size < mediumFileSize:
+		bucket = "medium"
+	}
+
+	dir, _, found := strings.Cut(f.Path, "/")
+	if !found {
+		dir = "."
+	}
+	return f.Language + "|" + bucket + "|" + dir
+}
+
+// Sample picks n of files at random, sorted by path. Each file is weighted
+// by the inverse square root of the size of its stratum, so rare languages,
+// sizes and directories are represented while common ones still make up
+// most of the sample. The same seed and files give the same sample.
+func Sample(files []File, n int, seed uint64) []File {
+	if n >= len(files) {
+		return sortedByPath(files)
+	}
+
+	counts := make(map[string]int)
+	for _, f := range files {
+		counts[stratum(f)]++
+	}
+
+	// Weighted sampling without replacement (Efraimidis-Spirakis): the n
+	// files with the largest u^(1/w) keys win
+	rng := rand.New(rand.NewPCG(seed, seed))
+	type keyed struct {
+		file File
+		key  float64
+	}
+	candidates := make([]keyed, len(files))
+	for i, f := range sortedByPath(files) {
+		weight := 1 / math.Sqrt(float64(counts[stratum(f)]))
+		candidates[i] = keyed{file: f, key: math.Pow(rng.Float64(), 1/weight)}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].key > candidates[j].key })
+
+	sample := make([]File, n)
+	for i := range sample {
+		sample[i] = candidates[i].file
+	}
+	return sortedByPath(sample)
+}
+
+// sortedByPath returns a copy of files sorted by path.
+func sortedByPath(files []File) []File {
+	sorted := append([]File(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+	return sorted
+}
+
+// Write copies the files of m from root into dir, keeping their relative
+// paths where they are portable (see utils.PortablePath), and writes the
+// manifest. dir must not exist or be empty, so an earlier corpus is never
+// mixed into a new one.
+func Write(root, dir string, m Manifest) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("%s is not empty", dir)
+	}
+
+	m.Files = append([]File(nil), m.Files...)
+	for i, f := range m.Files {
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(f.Path)))
+		if err != nil {
+			return err
+		}
+
+		copyPath := utils.PortablePath(f.Path)
+		if copyPath != path.Clean(f.Path) {
+			m.Files[i].Copy = copyPath
+		}
+		target := filepath.Join(dir, filepath.FromSlash(copyPath))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, content, 0644); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestName), append(data, '\n'), 0644)
+}
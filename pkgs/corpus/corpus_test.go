@@ -0,0 +1,167 @@
+// nolint:testpackage
+package corpus
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeRepo creates files under a temporary root and returns the root and
+// the file paths.
+func writeRepo(t *testing.T, files map[string]string) (string, []string) {
+	t.Helper()
+	root := t.TempDir()
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+	return root, paths
+}
+
+func TestCollect(t *testing.T) {
+	root, paths := writeRepo(t, map[string]string{
+		"cmd/main.go": "package main\n",
+		"notes":       "text\n",
+		"empty.go":    "",
+		"logo.png":    "\x89PNG\r\n\x1a\n\xff\xfe",
+	})
+
+	files, err := Collect(root, paths)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	expected := []File{
+		{Path: "cmd/main.go", Language: "Go", Size: 13},
+		{Path: "notes", Language: "other", Size: 5},
+	}
+	if got := sortedByPath(files); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestSample(t *testing.T) {
+	// 90 Go files in one directory and a handful of others
+	var files []File
+	for i := range 90 {
+		files = append(files, File{Path: fmt.Sprintf("pkg/f%02d.go", i), Language: "Go", Size: 1000})
+	}
+	files = append(files,
+		File{Path: "scripts/run.sh", Language: "Shell", Size: 500},
+		File{Path: "web/app.ts", Language: "TypeScript", Size: 50000},
+		File{Path: "README.md", Language: "Markdown", Size: 2000},
+	)
+
+	sample := Sample(files, 10, 1)
+	if len(sample) != 10 {
+		t.Fatalf("Expected 10 files, got %d", len(sample))
+	}
+	if !reflect.DeepEqual(sample, Sample(files, 10, 1)) {
+		t.Errorf("Expected the same seed to give the same sample")
+	}
+
+	// Averaged over seeds, each rare file is far more likely to be picked
+	// than its 10/93 share of a uniform sample
+	rare := 0
+	for seed := range uint64(100) {
+		for _, f := range Sample(files, 10, seed) {
+			if f.Language != "Go" {
+				rare++
+			}
+		}
+	}
+	if rare < 100 {
+		t.Errorf("Expected rare strata to be oversampled, got %d rare files in 100 samples", rare)
+	}
+
+	if all := Sample(files[:3], 10, 1); len(all) != 3 {
+		t.Errorf("Expected every file when asking for more than there are, got %d", len(all))
+	}
+}
+
+func TestWrite(t *testing.T) {
+	root, _ := writeRepo(t, map[string]string{"pkg/a.go": "package pkg\n"})
+	dir := filepath.Join(t.TempDir(), "corpus")
+	m := Manifest{Seed: 7, Files: []File{{Path: "pkg/a.go", Language: "Go", Size: 12}}}
+
+	if err := Write(root, dir, m); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "pkg", "a.go")); err != nil || string(content) != "package pkg\n" {
+		t.Errorf("Expected the file copied, got %q, %v", content, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var got Manifest
+	if err := json.Unmarshal(data, &got); err != nil || !reflect.DeepEqual(got, m) {
+		t.Errorf("Expected manifest %+v, got %+v, %v", m, got, err)
+	}
+
+	if err := Write(root, dir, m); err == nil {
+		t.Errorf("Expected error writing into a non-empty directory")
+	}
+}
+
+func TestWriteSpecialNames(t *testing.T) {
+	names := []string{"dir with spaces/file name.go", "测试/文件.go", "aux.go", "notes: draft?.md"}
+	files := make(map[string]string)
+	for _, name := range names {
+		files[name] = name + "\n"
+	}
+	root, paths := writeRepo(t, files)
+
+	collected, err := Collect(root, paths)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	dir := filepath.Join(t.TempDir(), "corpus")
+	if err := Write(root, dir, Manifest{Files: collected}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("Failed to decode manifest: %v", err)
+	}
+
+	copies := make(map[string]string)
+	for _, f := range m.Files {
+		copyPath := f.Path
+		if f.Copy != "" {
+			copyPath = f.Copy
+		}
+		copies[f.Path] = copyPath
+		if content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(copyPath))); err != nil || string(content) != f.Path+"\n" {
+			t.Errorf("Expected %s copied to %s, got %q, %v", f.Path, copyPath, content, err)
+		}
+	}
+
+	expected := map[string]string{
+		"dir with spaces/file name.go": "dir with spaces/file name.go",
+		"测试/文件.go":                     "测试/文件.go",
+		"aux.go":                       "aux_.go",
+		"notes: draft?.md":             "notes_ draft_.md",
+	}
+	for name, copyPath := range expected {
+		if copies[name] != copyPath {
+			t.Errorf("Expected %q copied to %q, got %q", name, copyPath, copies[name])
+		}
+	}
+}
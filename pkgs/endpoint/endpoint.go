@@ -0,0 +1,403 @@
+// Package endpoint finds the HTTP routes a Go repository registers with
+// net/http, gin, echo and chi, with the handler of each and where it is
+// defined, by reading the code without building it. Routes registered with
+// paths computed at run time are left out.
+package endpoint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Framework names the router a route is registered with.
+type Framework string
+
+// Supported routers.
+const (
+	NetHTTP Framework = "net/http"
+	Gin     Framework = "gin"
+	Echo    Framework = "echo"
+	Chi     Framework = "chi"
+)
+
+// frameworkImports maps import path prefixes to their routers.
+var frameworkImports = map[string]Framework{
+	"github.com/gin-gonic/gin": Gin,
+	"github.com/labstack/echo": Echo,
+	"github.com/go-chi/chi":    Chi,
+}
+
+// AnyMethod is the method of routes that match every method.
+const AnyMethod = "*"
+
+// Endpoint is a registered route.
+type Endpoint struct {
+	// Method is the HTTP method, such as GET, or AnyMethod.
+	Method string
+	// Route is the path pattern, including the prefixes of the groups it
+	// is registered in.
+	Route string
+	// Handler is the handler as written at the registration, such as
+	// h.ListUsers.
+	Handler   string
+	Framework Framework
+	// Path and Line locate the registration; Path is relative to the
+	// repository root, with forward slashes.
+	Path string
+	Line int
+	// HandlerPath and HandlerLine locate the handler's definition, when a
+	// single function or method of the scanned files has its name, and
+	// HandlerEndLine is its last line. Inline handlers are defined at the
+	// registration.
+	HandlerPath    string
+	HandlerLine    int
+	HandlerEndLine int
+}
+
+// Location returns where e is registered.
+func (e Endpoint) Location() string {
+	return fmt.Sprintf("%s:%d", e.Path, e.Line)
+}
+
+// HandlerLocation returns where e's handler is defined, or "" if unknown.
+func (e Endpoint) HandlerLocation() string {
+	if e.HandlerPath == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", e.HandlerPath, e.HandlerLine)
+}
+
+// Detect returns the endpoints registered in files, paths below root as
+// returned by utils.GetSourceList, sorted by route and method. Files that
+// cannot be read or parsed are left out.
+func Detect(root string, files []string) []Endpoint {
+	var endpoints []Endpoint
+	defs := make(definitions)
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".go") || strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			continue
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+
+		rel = filepath.ToSlash(rel)
+		defs.add(fset, rel, f)
+		s := &scanner{fset: fset, rel: rel, routers: routers(f)}
+		if len(s.routers) > 0 {
+			s.scan(f)
+			endpoints = append(endpoints, s.endpoints...)
+		}
+	}
+
+	for i, e := range endpoints {
+		if e.HandlerPath == "" {
+			if def, ok := defs.find(e.Handler); ok {
+				endpoints[i].HandlerPath, endpoints[i].HandlerLine, endpoints[i].HandlerEndLine = def.path, def.line, def.endLine
+			}
+		}
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		if endpoints[i].Route != endpoints[j].Route {
+			return endpoints[i].Route < endpoints[j].Route
+		}
+		return endpoints[i].Method < endpoints[j].Method
+	})
+	return endpoints
+}
+
+// routers returns the routers f imports.
+func routers(f *ast.File) map[Framework]bool {
+	found := make(map[Framework]bool)
+	for _, imp := range f.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		if path == "net/http" {
+			found[NetHTTP] = true
+		}
+		for prefix, framework := range frameworkImports {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				found[framework] = true
+			}
+		}
+	}
+	return found
+}
+
+// definition is where a function or method is defined.
+type definition struct {
+	path    string
+	line    int
+	endLine int
+}
+
+// definitions maps function and method names to where they are defined,
+// with several definitions for names defined more than once.
+type definitions map[string][]definition
+
+// add records the functions and methods of f, in the file rel.
+func (d definitions) add(fset *token.FileSet, rel string, f *ast.File) {
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			def := definition{rel, fset.Position(fn.Pos()).Line, fset.Position(fn.End()).Line}
+			d[fn.Name.Name] = append(d[fn.Name.Name], def)
+		}
+	}
+}
+
+// find returns where the function or method named by handler, such as
+// ListUsers or h.ListUsers, is defined, if only one has the name.
+func (d definitions) find(handler string) (definition, bool) {
+	name := handler[strings.LastIndex(handler, ".")+1:]
+	if defs := d[name]; len(defs) == 1 {
+		return defs[0], true
+	}
+	return definition{}, false
+}
+
+// httpMethods are the methods gin and echo register routes with.
+var httpMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS", "CONNECT", "TRACE"}
+
+// scanner finds the routes registered in one file.
+type scanner struct {
+	fset      *token.FileSet
+	rel       string
+	routers   map[Framework]bool
+	endpoints []Endpoint
+}
+
+// scan finds the routes registered in f, following the prefixes of route
+// groups assigned to variables, such as v1 := r.Group("/v1"), and of chi's
+// Route callbacks.
+func (s *scanner) scan(f *ast.File) {
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+			s.walk(fn.Body, make(map[string]string))
+		}
+	}
+}
+
+// walk scans node, where prefixes maps the variables holding route groups
+// to their prefixes.
+func (s *scanner) walk(node ast.Node, prefixes map[string]string) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) == 1 && len(n.Rhs) == 1 {
+				if name, ok := n.Lhs[0].(*ast.Ident); ok {
+					if prefix, ok := s.group(n.Rhs[0], prefixes); ok {
+						prefixes[name.Name] = prefix
+					}
+				}
+			}
+		case *ast.CallExpr:
+			return s.call(n, prefixes)
+		}
+		return true
+	})
+}
+
+// group returns the prefix of the route group expr creates, such as
+// r.Group("/v1") with gin and echo, or chi's r.Route("/v1", nil).
+func (s *scanner) group(expr ast.Expr, prefixes map[string]string) (string, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Group" && sel.Sel.Name != "Route" {
+		return "", false
+	}
+	pattern, ok := stringLit(call.Args[0])
+	if !ok {
+		return "", false
+	}
+	return join(receiverPrefix(sel, prefixes), pattern), true
+}
+
+// call records the route call registers, if any, and reports whether to
+// look inside it.
+func (s *scanner) call(call *ast.CallExpr, prefixes map[string]string) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return true
+	}
+	name, args := sel.Sel.Name, call.Args
+	prefix := receiverPrefix(sel, prefixes)
+
+	// chi's r.Route("/api", func(r chi.Router) { ... }) registers the
+	// routes of the callback below the prefix
+	if name == "Route" && s.routers[Chi] && len(args) == 2 {
+		pattern, ok := stringLit(args[0])
+		fn, isFunc := args[1].(*ast.FuncLit)
+		if !ok || !isFunc {
+			return true
+		}
+		inner := maps.Clone(prefixes)
+		for _, param := range fn.Type.Params.List {
+			for _, ident := range param.Names {
+				inner[ident.Name] = join(prefix, pattern)
+			}
+		}
+		s.walk(fn.Body, inner)
+		return false
+	}
+
+	upper := strings.ToUpper(name)
+	switch {
+	// gin and echo: r.GET("/users", h)
+	case name == upper && isMethod(name) && (s.routers[Gin] || s.routers[Echo]) && len(args) >= 2:
+		s.add(call, s.pathFramework(), name, prefix, args[0], s.handlerArg(args[1:]))
+	case name == "Any" && (s.routers[Gin] || s.routers[Echo]) && len(args) >= 2:
+		s.add(call, s.pathFramework(), AnyMethod, prefix, args[0], s.handlerArg(args[1:]))
+	// chi: r.Get("/users", h)
+	case s.routers[Chi] && name != upper && isMethod(upper) && len(args) == 2:
+		s.add(call, Chi, upper, prefix, args[0], args[1])
+	// chi: r.Method("GET", "/users", h); gin: r.Handle("GET", "/users", h);
+	// echo: e.Add("GET", "/users", h)
+	case (name == "Method" || name == "MethodFunc" || name == "Handle" || name == "Add") && len(args) >= 3:
+		method, ok := stringLit(args[0])
+		if !ok || !isMethod(strings.ToUpper(method)) {
+			return true
+		}
+		framework := Chi
+		if name == "Handle" || name == "Add" {
+			framework = s.pathFramework()
+		}
+		s.add(call, framework, strings.ToUpper(method), prefix, args[1], s.handlerArg(args[2:]))
+	// net/http and chi: mux.HandleFunc("GET /users/{id}", h)
+	case (name == "Handle" || name == "HandleFunc") && len(args) == 2:
+		framework := NetHTTP
+		if s.routers[Chi] {
+			framework = Chi
+		}
+		pattern, ok := stringLit(args[0])
+		if !ok {
+			return true
+		}
+		method := AnyMethod
+		// Go 1.22 patterns may start with a method
+		if m, rest, found := strings.Cut(pattern, " "); found && isMethod(m) {
+			method, pattern = m, strings.TrimSpace(rest)
+		}
+		s.addPattern(call, framework, method, join(prefix, pattern), args[1])
+	}
+	return true
+}
+
+// pathFramework returns the router of gin-style registrations: echo if the
+// file uses echo and not gin, else gin.
+func (s *scanner) pathFramework() Framework {
+	if s.routers[Echo] && !s.routers[Gin] {
+		return Echo
+	}
+	return Gin
+}
+
+// handlerArg returns the handler among args, the arguments after the path:
+// gin takes middleware before the handler, echo after it.
+func (s *scanner) handlerArg(args []ast.Expr) ast.Expr {
+	if s.pathFramework() == Echo {
+		return args[0]
+	}
+	return args[len(args)-1]
+}
+
+// add records the route registered by call if path is a literal.
+func (s *scanner) add(call *ast.CallExpr, framework Framework, method, prefix string, path, handler ast.Expr) {
+	pattern, ok := stringLit(path)
+	if !ok {
+		return
+	}
+	s.addPattern(call, framework, method, join(prefix, pattern), handler)
+}
+
+// addPattern records a route registered by call.
+func (s *scanner) addPattern(call *ast.CallExpr, framework Framework, method, route string, handler ast.Expr) {
+	if !strings.HasPrefix(route, "/") {
+		return
+	}
+	line := s.fset.Position(call.Pos()).Line
+	e := Endpoint{Method: method, Route: route, Framework: framework, Path: s.rel, Line: line}
+	handler = unwrap(handler)
+	if fn, ok := handler.(*ast.FuncLit); ok {
+		e.Handler = "func literal"
+		e.HandlerPath, e.HandlerLine, e.HandlerEndLine = s.rel, s.fset.Position(fn.Pos()).Line, s.fset.Position(fn.End()).Line
+	} else {
+		e.Handler = types.ExprString(handler)
+	}
+	s.endpoints = append(s.endpoints, e)
+}
+
+// unwrap returns the handler inside conversions and adapters taking one
+// argument, such as http.HandlerFunc(h.List) or echo.WrapHandler(h).
+func unwrap(expr ast.Expr) ast.Expr {
+	for {
+		call, ok := expr.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return expr
+		}
+		expr = call.Args[0]
+	}
+}
+
+// receiverPrefix returns the prefix of the route group sel is called on, or
+// "" if it is not a known group.
+func receiverPrefix(sel *ast.SelectorExpr, prefixes map[string]string) string {
+	if ident, ok := sel.X.(*ast.Ident); ok {
+		return prefixes[ident.Name]
+	}
+	return ""
+}
+
+// isMethod reports whether name is an HTTP method in upper case.
+func isMethod(name string) bool {
+	for _, method := range httpMethods {
+		if name == method {
+			return true
+		}
+	}
+	return false
+}
+
+// stringLit returns the value of expr if it is a string literal.
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	return value, err == nil
+}
+
+// join appends pattern to the group prefix, with one slash between them.
+func join(prefix, pattern string) string {
+	if prefix == "" {
+		return pattern
+	}
+	if pattern == "" || pattern == "/" {
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(pattern, "/")
+}
@@ -0,0 +1,101 @@
+// nolint:testpackage
+package endpoint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"cmd/server/main.go": `package main
+
+import "net/http"
+
+func main() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", getItem)
+	mux.Handle("/static/", http.StripPrefix("/static/", nil))
+	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {})
+	http.HandleFunc(dynamicPath(), getItem)
+}
+`,
+		"cmd/server/items.go": "package main\n\nfunc getItem() {}\n",
+		"api/gin.go": `package api
+
+import "github.com/gin-gonic/gin"
+
+func Routes(r *gin.Engine, h *Handler) {
+	v1 := r.Group("/v1")
+	v1.GET("/users", auth, h.ListUsers)
+	v1.Handle("DELETE", "/users/:id", h.DeleteUser)
+	r.Any("/ping", h.Ping)
+}
+`,
+		"api/handler.go": "package api\n\ntype Handler struct{}\n\nfunc (h *Handler) ListUsers() {}\n",
+		"web/echo.go": `package web
+
+import "github.com/labstack/echo/v4"
+
+func Routes(e *echo.Echo) {
+	g := e.Group("/admin")
+	g.POST("/login", login, limit)
+}
+`,
+		"web/chi.go": `package web
+
+import "github.com/go-chi/chi/v5"
+
+func Router() chi.Router {
+	r := chi.NewRouter()
+	r.Route("/orders", func(r chi.Router) {
+		r.Get("/", listOrders)
+		r.Method("PUT", "/{id}", http.HandlerFunc(updateOrder))
+	})
+	r.Get("/", index)
+	return r
+}
+`,
+		"web/chi_test.go": "package web\n\nimport \"net/http\"\n\nfunc init() { http.HandleFunc(\"/test\", nil) }\n",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	endpoints := Detect(root, paths)
+	var got []string
+	for _, e := range endpoints {
+		got = append(got, fmt.Sprintf("%s %s %s %s %s %s", e.Method, e.Route, e.Handler, e.Framework, e.Location(), e.HandlerLocation()))
+	}
+	expected := []string{
+		"GET / index chi web/chi.go:11 ",
+		"POST /admin/login login echo web/echo.go:7 ",
+		"* /health func literal net/http cmd/server/main.go:9 cmd/server/main.go:9",
+		"GET /items/{id} getItem net/http cmd/server/main.go:7 cmd/server/items.go:3",
+		"GET /orders listOrders chi web/chi.go:8 ",
+		"PUT /orders/{id} updateOrder chi web/chi.go:9 ",
+		"* /ping h.Ping gin api/gin.go:9 ",
+		"* /static/ http.StripPrefix(\"/static/\", nil) net/http cmd/server/main.go:8 ",
+		"GET /v1/users h.ListUsers gin api/gin.go:7 api/handler.go:5",
+		"DELETE /v1/users/:id h.DeleteUser gin api/gin.go:8 ",
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected\n%q, got\n%q", expected, got)
+	}
+	// The inline handler spans its line only
+	if e := endpoints[2]; e.HandlerLine != 9 || e.HandlerEndLine != 9 {
+		t.Errorf("Expected the inline handler on line 9, got %d-%d", e.HandlerLine, e.HandlerEndLine)
+	}
+}
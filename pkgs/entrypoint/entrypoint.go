@@ -0,0 +1,311 @@
+// Package entrypoint finds where execution of a repository likely starts:
+// Go main packages, cmd/ directories, Dockerfile commands, package.json
+// scripts and Python main modules. Reading and summarizing a repository
+// from its entry points follows the code the way it runs.
+package entrypoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kind classifies an entry point.
+type Kind string
+
+// Entry point kinds.
+const (
+	// GoMain is the main function of a Go main package.
+	GoMain Kind = "go-main"
+	// CmdDir is a directory below cmd/, by convention one per command,
+	// without a detected Go main function.
+	CmdDir Kind = "cmd"
+	// Dockerfile is the ENTRYPOINT or CMD of a Dockerfile's final stage.
+	Dockerfile Kind = "dockerfile"
+	// NodeMain is the main or bin module of a package.json.
+	NodeMain Kind = "node-main"
+	// NPMScript is a package.json script that starts the program.
+	NPMScript Kind = "npm-script"
+	// PythonMain is a Python module run as a script.
+	PythonMain Kind = "python-main"
+)
+
+// EntryPoint is a place where execution likely starts.
+type EntryPoint struct {
+	// Path is relative to the repository root, with forward slashes.
+	// Directories end in "/".
+	Path string
+	// Line is the 1-based line of the entry point, 0 for a whole file or
+	// directory.
+	Line int
+	Kind Kind
+	// Detail describes the entry point, such as the command it runs.
+	Detail string
+}
+
+// Location returns e's path, with its line if known.
+func (e EntryPoint) Location() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d", e.Path, e.Line)
+	}
+	return e.Path
+}
+
+// String returns e as "location (kind) detail".
+func (e EntryPoint) String() string {
+	return strings.TrimSpace(fmt.Sprintf("%s (%s) %s", e.Location(), e.Kind, e.Detail))
+}
+
+// Format renders points one per line, for prompts.
+func Format(points []EntryPoint) string {
+	var b strings.Builder
+	for _, p := range points {
+		b.WriteString(p.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// startScripts are the package.json scripts that run a program, as opposed
+// to building, testing or linting it. Variants such as "start:prod" count.
+var startScripts = []string{"start", "dev", "serve", "develop"}
+
+// pythonMainGuard matches the idiom running a Python module as a script.
+var pythonMainGuard = regexp.MustCompile(`(?m)^if\s+__name__\s*==\s*['"]__main__['"]\s*:`)
+
+// Detect returns the entry points among files, paths below root as returned
+// by utils.GetSourceList, sorted by path and line. Files that cannot be read
+// or parsed are left out.
+func Detect(root string, files []string) []EntryPoint {
+	var points []EntryPoint
+	cmdDirs := make(map[string]bool)
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if dir := cmdDir(rel); dir != "" {
+			cmdDirs[dir] = true
+		}
+
+		var detect func(rel string, content []byte) []EntryPoint
+		switch name := path.Base(rel); {
+		case strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go"):
+			detect = goMain
+		case isDockerfile(name):
+			detect = dockerfile
+		case name == "package.json" && !strings.Contains("/"+rel, "/node_modules/"):
+			detect = packageJSON
+		case strings.HasSuffix(name, ".py"):
+			detect = pythonMain
+		default:
+			continue
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		points = append(points, detect(rel, content)...)
+	}
+
+	// A cmd/ directory is worth listing on its own only if its main
+	// function was not found, as for commands in other languages
+	for dir := range cmdDirs {
+		if !containsMain(points, dir) {
+			points = append(points, EntryPoint{Path: dir, Kind: CmdDir})
+		}
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		if points[i].Path != points[j].Path {
+			return points[i].Path < points[j].Path
+		}
+		return points[i].Line < points[j].Line
+	})
+	return points
+}
+
+// cmdDir returns the directory directly below a cmd/ directory containing
+// rel, such as "cmd/server/" for "cmd/server/main.go", or "".
+func cmdDir(rel string) string {
+	parts := strings.Split(rel, "/")
+	for i := 0; i+2 < len(parts); i++ {
+		if parts[i] == "cmd" {
+			return strings.Join(parts[:i+2], "/") + "/"
+		}
+	}
+	return ""
+}
+
+// containsMain reports whether points has a Go main function inside dir.
+func containsMain(points []EntryPoint, dir string) bool {
+	for _, p := range points {
+		if p.Kind == GoMain && strings.HasPrefix(p.Path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// goMain returns the main function of a file in package main.
+func goMain(rel string, content []byte) []EntryPoint {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+	if err != nil || file.Name.Name != "main" {
+		return nil
+	}
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+			return []EntryPoint{{Path: rel, Line: fset.Position(fn.Pos()).Line, Kind: GoMain, Detail: "func main"}}
+		}
+	}
+	return nil
+}
+
+// isDockerfile reports whether name is a Dockerfile, such as "Dockerfile",
+// "Dockerfile.dev" or "api.Dockerfile".
+func isDockerfile(name string) bool {
+	name = strings.ToLower(name)
+	return name == "dockerfile" || strings.HasPrefix(name, "dockerfile.") || strings.HasSuffix(name, ".dockerfile")
+}
+
+// dockerfile returns the ENTRYPOINT and CMD in effect for the final stage of
+// a Dockerfile: the last of each after the last FROM.
+func dockerfile(rel string, content []byte) []EntryPoint {
+	var entrypoint, cmd *EntryPoint
+	lines := strings.Split(string(content), "\n")
+	for i := 0; i < len(lines); i++ {
+		start := i + 1
+		instruction := strings.TrimSpace(lines[i])
+		// Join continuation lines
+		for strings.HasSuffix(instruction, "\\") && i+1 < len(lines) {
+			i++
+			instruction = strings.TrimSuffix(instruction, "\\") + " " + strings.TrimSpace(lines[i])
+		}
+		if instruction == "" || strings.HasPrefix(instruction, "#") {
+			continue
+		}
+
+		keyword, args, _ := strings.Cut(instruction, " ")
+		point := &EntryPoint{
+			Path:   rel,
+			Line:   start,
+			Kind:   Dockerfile,
+			Detail: strings.ToUpper(keyword) + " " + strings.Join(strings.Fields(args), " "),
+		}
+		switch strings.ToUpper(keyword) {
+		case "FROM":
+			entrypoint, cmd = nil, nil
+		case "ENTRYPOINT":
+			entrypoint = point
+		case "CMD":
+			cmd = point
+		}
+	}
+
+	var points []EntryPoint
+	for _, p := range []*EntryPoint{entrypoint, cmd} {
+		if p != nil {
+			points = append(points, *p)
+		}
+	}
+	return points
+}
+
+// packageJSON returns the main and bin modules and the start scripts of a
+// package.json.
+func packageJSON(rel string, content []byte) []EntryPoint {
+	var pkg struct {
+		Main    string            `json:"main"`
+		Bin     json.RawMessage   `json:"bin"`
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		return nil
+	}
+
+	var points []EntryPoint
+	if pkg.Main != "" {
+		points = append(points, EntryPoint{Path: rel, Line: lineOf(content, 0, `"main"`), Kind: NodeMain, Detail: "main: " + pkg.Main})
+	}
+	// bin is either one path or an object of command names to paths
+	var bin string
+	var bins map[string]string
+	if json.Unmarshal(pkg.Bin, &bin) == nil && bin != "" {
+		points = append(points, EntryPoint{Path: rel, Line: lineOf(content, 0, `"bin"`), Kind: NodeMain, Detail: "bin: " + bin})
+	} else if json.Unmarshal(pkg.Bin, &bins) == nil {
+		for _, name := range sortedKeys(bins) {
+			points = append(points, EntryPoint{Path: rel, Line: lineOf(content, 0, `"bin"`), Kind: NodeMain, Detail: "bin " + name + ": " + bins[name]})
+		}
+	}
+
+	scripts := bytes.Index(content, []byte(`"scripts"`))
+	for _, name := range sortedKeys(pkg.Scripts) {
+		if !isStartScript(name) {
+			continue
+		}
+		line := lineOf(content, scripts, fmt.Sprintf("%q", name))
+		points = append(points, EntryPoint{Path: rel, Line: line, Kind: NPMScript, Detail: "npm run " + name + ": " + pkg.Scripts[name]})
+	}
+	return points
+}
+
+// isStartScript reports whether a package.json script name runs the program.
+func isStartScript(name string) bool {
+	for _, script := range startScripts {
+		if name == script || strings.HasPrefix(name, script+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// pythonMain returns the main guard of a Python file, or the file itself if
+// it is a package's __main__.py.
+func pythonMain(rel string, content []byte) []EntryPoint {
+	if path.Base(rel) == "__main__.py" {
+		detail := "python ."
+		if dir := path.Dir(rel); dir != "." {
+			detail = "python -m " + strings.ReplaceAll(dir, "/", ".")
+		}
+		return []EntryPoint{{Path: rel, Kind: PythonMain, Detail: detail}}
+	}
+	loc := pythonMainGuard.FindIndex(content)
+	if loc == nil {
+		return nil
+	}
+	return []EntryPoint{{Path: rel, Line: bytes.Count(content[:loc[0]], []byte{'\n'}) + 1, Kind: PythonMain, Detail: "__main__ guard"}}
+}
+
+// lineOf returns the 1-based line of the first occurrence of needle in
+// content at or after offset, or 0 if there is none.
+func lineOf(content []byte, offset int, needle string) int {
+	if offset < 0 {
+		return 0
+	}
+	i := bytes.Index(content[offset:], []byte(needle))
+	if i < 0 {
+		return 0
+	}
+	return bytes.Count(content[:offset+i], []byte{'\n'}) + 1
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
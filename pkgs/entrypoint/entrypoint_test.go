@@ -0,0 +1,63 @@
+// nolint:testpackage
+package entrypoint
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"cmd/server/main.go":          "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println()\n}\n",
+		"cmd/server/flags.go":         "package main\n\nfunc parse() {}\n",
+		"cmd/tool/run.sh":             "#!/bin/sh\n",
+		"pkg/lib.go":                  "package lib\n\nfunc main() {}\n",
+		"main_test.go":                "package main\n\nfunc main() {}\n",
+		"Dockerfile":                  "FROM golang AS build\nCMD [\"go\", \"test\"]\n\nFROM alpine\n# comment\nENTRYPOINT [\"/server\", \\\n    \"--port=80\"]\nCMD [\"serve\"]\n",
+		"web/package.json":            "{\n  \"main\": \"index.js\",\n  \"bin\": {\"web\": \"bin/web.js\"},\n  \"scripts\": {\n    \"test\": \"jest\",\n    \"start\": \"node index.js\",\n    \"dev:watch\": \"nodemon\"\n  }\n}\n",
+		"node_modules/x/package.json": "{\"main\": \"x.js\"}\n",
+		"tools/gen.py":                "import sys\n\nif __name__ == '__main__':\n    sys.exit(0)\n",
+		"app/__main__.py":             "print()\n",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	var got []string
+	for _, p := range Detect(root, paths) {
+		got = append(got, p.String())
+	}
+	expected := []string{
+		`Dockerfile:6 (dockerfile) ENTRYPOINT ["/server", "--port=80"]`,
+		`Dockerfile:8 (dockerfile) CMD ["serve"]`,
+		"app/__main__.py (python-main) python -m app",
+		"cmd/server/main.go:5 (go-main) func main",
+		"cmd/tool/ (cmd)",
+		"tools/gen.py:3 (python-main) __main__ guard",
+		"web/package.json:2 (node-main) main: index.js",
+		"web/package.json:3 (node-main) bin web: bin/web.js",
+		"web/package.json:6 (npm-script) npm run start: node index.js",
+		"web/package.json:7 (npm-script) npm run dev:watch: nodemon",
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	points := []EntryPoint{{Path: "main.go", Line: 3, Kind: GoMain, Detail: "func main"}, {Path: "cmd/x/", Kind: CmdDir}}
+	if got := Format(points); got != "main.go:3 (go-main) func main\ncmd/x/ (cmd)\n" {
+		t.Errorf("Unexpected format %q", got)
+	}
+}
@@ -0,0 +1,151 @@
+// Package faq picks the frequently asked questions worth answering for a
+// repository from its structure, reads questions from a file, and renders
+// the answers as Markdown.
+package faq
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DefaultPath is where the FAQ is written, relative to the repository root.
+const DefaultPath = "docs/FAQ.md"
+
+// template is a question asked when the repository shows one of its signals.
+type template struct {
+	question string
+	// signals are lowercase words hinting that the question applies. A
+	// signal matches a word of a file path it is a prefix of, so "handler"
+	// matches "handlers/user.go" but "router" does not match "openrouter.go".
+	// No signals means the question always applies.
+	signals []string
+}
+
+// templates are the questions in the order they appear in the FAQ, from
+// getting started to extending the project.
+var templates = []template{
+	{question: "这个项目是做什么的？阅读代码应该从哪里开始？"},
+	{question: "如何构建、运行和测试这个项目？"},
+	{question: "配置是如何加载的？有哪些配置项？", signals: []string{"config", "settings", "env"}},
+	{question: "如何新增一个命令行子命令？", signals: []string{"cmd", "cli", "commands"}},
+	{question: "如何新增一个 HTTP 接口？", signals: []string{"handler", "router", "routes", "controller", "endpoint", "api", "server"}},
+	{question: "数据库迁移在哪里定义，在什么时候执行？", signals: []string{"migration", "migrate"}},
+	{question: "接口定义（Protobuf/GraphQL/OpenAPI）在哪里？如何生成代码？", signals: []string{"proto", "graphql", "openapi", "swagger"}},
+	{question: "如何新增一个扩展（如 provider、插件或驱动）？", signals: []string{"provider", "plugin", "adapter", "driver"}},
+	{question: "测试是如何组织的？如何新增一个测试？", signals: []string{"test", "spec"}},
+	{question: "项目如何打包和部署？CI 会做哪些检查？", signals: []string{"dockerfile", "docker", "makefile", "workflows", "gitlab", "helm", "k8s"}},
+}
+
+// Questions returns the FAQ questions that apply to a repository with the
+// given files, relative to its root.
+func Questions(paths []string) []string {
+	words := make(map[string]bool)
+	for _, p := range paths {
+		for _, word := range strings.FieldsFunc(strings.ToLower(p), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			words[word] = true
+		}
+	}
+
+	var questions []string
+	for _, t := range templates {
+		if t.applies(words) {
+			questions = append(questions, t.question)
+		}
+	}
+	return questions
+}
+
+// applies reports whether one of t's signals matches words.
+func (t template) applies(words map[string]bool) bool {
+	if len(t.signals) == 0 {
+		return true
+	}
+	for word := range words {
+		for _, signal := range t.signals {
+			if strings.HasPrefix(word, signal) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Entry is an answered question.
+type Entry struct {
+	Question string
+	Answer   string
+}
+
+// Markdown renders entries as an FAQ document with a table of contents.
+func Markdown(entries []Entry) string {
+	return Report("常见问题", entries)
+}
+
+// Report renders entries as a Markdown document titled title, with a table
+// of contents.
+func Report(title string, entries []Entry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	b.WriteString("> 本文档由 aicodereader 根据仓库代码自动生成，内容可能有误或过时，请以代码为准。\n\n")
+	for i, entry := range entries {
+		fmt.Fprintf(&b, "%d. [%s](#q%d)\n", i+1, entry.Question, i+1)
+	}
+	for i, entry := range entries {
+		fmt.Fprintf(&b, "\n<a id=\"q%d\"></a>\n\n## %s\n\n%s\n", i+1, entry.Question, strings.TrimSpace(entry.Answer))
+	}
+	return b.String()
+}
+
+var (
+	// headingPattern matches a Markdown ATX heading; the group is its text.
+	headingPattern = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*\s*$`)
+	// listMarkerPattern matches the marker of a Markdown list item.
+	listMarkerPattern = regexp.MustCompile(`^(?:[-*+]|\d+[.)])\s+`)
+)
+
+// ParseQuestions reads questions from a questions file. If the file has
+// Markdown headings, each heading is a question and the text below it, up
+// to the next heading, adds detail to it. Otherwise each non-empty line is a
+// question, with any list marker removed.
+func ParseQuestions(content string) []string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	var questions []string
+	hasHeadings := false
+	for _, line := range lines {
+		if headingPattern.MatchString(line) {
+			hasHeadings = true
+			break
+		}
+	}
+	if !hasHeadings {
+		for _, line := range lines {
+			if line = strings.TrimSpace(listMarkerPattern.ReplaceAllString(strings.TrimSpace(line), "")); line != "" {
+				questions = append(questions, line)
+			}
+		}
+		return questions
+	}
+
+	// Text before the first heading is an introduction, not a question
+	var question []string
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(question, "\n")); text != "" {
+			questions = append(questions, text)
+		}
+	}
+	for _, line := range lines {
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			flush()
+			question = []string{match[1]}
+		} else if question != nil {
+			question = append(question, line)
+		}
+	}
+	flush()
+	return questions
+}
@@ -0,0 +1,75 @@
+// nolint:testpackage
+package faq
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestQuestions(t *testing.T) {
+	questions := Questions([]string{"cmd/app/main.go", "pkgs/llm/provider.go", "pkgs/llm/provider_test.go"})
+
+	expected := []string{
+		templates[0].question,
+		templates[1].question,
+		"如何新增一个命令行子命令？",
+		"如何新增一个扩展（如 provider、插件或驱动）？",
+		"测试是如何组织的？如何新增一个测试？",
+	}
+	if !slices.Equal(questions, expected) {
+		t.Errorf("Expected %v, got %v", expected, questions)
+	}
+
+	if questions := Questions([]string{"pkgs/llm/testdata/openrouter.sse"}); slices.Contains(questions, "如何新增一个 HTTP 接口？") {
+		t.Errorf("Expected signals to match whole words only, got %v", questions)
+	}
+
+	if questions := Questions([]string{"db/Migrations/001_init.sql", "Dockerfile"}); !slices.Contains(questions, "数据库迁移在哪里定义，在什么时候执行？") ||
+		!slices.Contains(questions, "项目如何打包和部署？CI 会做哪些检查？") {
+		t.Errorf("Expected migration and deployment questions, got %v", questions)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	doc := Markdown([]Entry{
+		{Question: "Q1?", Answer: "A1\n"},
+		{Question: "Q2?", Answer: "  A2"},
+	})
+
+	for _, part := range []string{
+		"# 常见问题\n",
+		"1. [Q1?](#q1)\n2. [Q2?](#q2)\n",
+		"<a id=\"q1\"></a>\n\n## Q1?\n\nA1\n",
+		"## Q2?\n\nA2\n",
+	} {
+		if !strings.Contains(doc, part) {
+			t.Errorf("Expected %q in:\n%s", part, doc)
+		}
+	}
+}
+
+func TestParseQuestions(t *testing.T) {
+	lines := "Where is config loaded?\n\n- How are errors reported?\n2. What does the cache key cover?\n"
+	expected := []string{"Where is config loaded?", "How are errors reported?", "What does the cache key cover?"}
+	if questions := ParseQuestions(lines); !slices.Equal(questions, expected) {
+		t.Errorf("Expected %q, got %q", expected, questions)
+	}
+
+	headings := "Architecture review questions.\n\n## Where is config loaded? ##\n\nConsider env vars too.\n\n## How are errors reported?\n"
+	expected = []string{"Where is config loaded?\n\nConsider env vars too.", "How are errors reported?"}
+	if questions := ParseQuestions(headings); !slices.Equal(questions, expected) {
+		t.Errorf("Expected %q, got %q", expected, questions)
+	}
+
+	if questions := ParseQuestions("\n  \n"); len(questions) != 0 {
+		t.Errorf("Expected no questions, got %q", questions)
+	}
+}
+
+func TestReport(t *testing.T) {
+	report := Report("架构评审", []Entry{{Question: "Q1", Answer: "A1\n"}})
+	if !strings.HasPrefix(report, "# 架构评审\n\n") || !strings.Contains(report, "1. [Q1](#q1)") || !strings.HasSuffix(report, "## Q1\n\nA1\n") {
+		t.Errorf("Unexpected report %q", report)
+	}
+}
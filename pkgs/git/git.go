@@ -0,0 +1,139 @@
+// Package git reads the history of a repository with the git command: the
+// commits of a branch and the changes it makes, per file, for commands that
+// describe or check a branch rather than the files of a work tree.
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Commit is a commit of the repository.
+type Commit struct {
+	Hash    string
+	Subject string
+	// Body is the message after the subject line, empty if there is none.
+	Body string
+}
+
+// Message returns the full commit message.
+func (c Commit) Message() string {
+	if c.Body == "" {
+		return c.Subject
+	}
+	return c.Subject + "\n\n" + c.Body
+}
+
+// FileDiff is the change a diff makes to one file.
+type FileDiff struct {
+	// Path is the path of the file after the change, or before it for a
+	// deleted file, relative to the repository.
+	Path string
+	// Patch is the unified diff of the file, from its "diff --git" line.
+	Patch string
+}
+
+// Commits returns the commits of revs, a revision range such as
+// "main..HEAD", oldest first, leaving out merge commits.
+func Commits(ctx context.Context, dir, revs string) ([]Commit, error) {
+	output, err := run(ctx, dir, "log", "--reverse", "--no-merges", "--format=%H%x00%s%x00%b%x00", revs, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Split(output, "\x00")
+	var commits []Commit
+	for i := 0; i+2 < len(fields); i += 3 {
+		commits = append(commits, Commit{
+			Hash:    strings.TrimSpace(fields[i]),
+			Subject: fields[i+1],
+			Body:    strings.TrimSpace(fields[i+2]),
+		})
+	}
+	return commits, nil
+}
+
+// Stat returns the diffstat of the commit rev, the files it changes with
+// the number of lines added and removed.
+func Stat(ctx context.Context, dir, rev string) (string, error) {
+	return run(ctx, dir, "show", "--stat", "--format=", "--no-color", rev, "--")
+}
+
+// BranchDiff returns the changes HEAD makes since it forked from base, per
+// file in diff order, the way a pull request into base shows them.
+func BranchDiff(ctx context.Context, dir, base string) ([]FileDiff, error) {
+	output, err := run(ctx, dir, "diff", "--no-color", "--no-ext-diff", base+"...HEAD", "--")
+	if err != nil {
+		return nil, err
+	}
+	return splitDiff(output), nil
+}
+
+// splitDiff splits a unified diff of several files at their "diff --git"
+// lines.
+func splitDiff(diff string) []FileDiff {
+	var (
+		diffs []FileDiff
+		patch strings.Builder
+	)
+	flush := func() {
+		if patch.Len() > 0 {
+			diffs = append(diffs, FileDiff{Path: diffPath(patch.String()), Patch: patch.String()})
+			patch.Reset()
+		}
+	}
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+		}
+		patch.WriteString(line)
+	}
+	flush()
+	return diffs
+}
+
+// diffPath returns the path of the file patch changes: the new path, or the
+// old one of a deleted file.
+func diffPath(patch string) string {
+	var old string
+	for _, line := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ b/"):
+			return strings.TrimPrefix(line, "+++ b/")
+		case strings.HasPrefix(line, "--- a/"):
+			old = strings.TrimPrefix(line, "--- a/")
+		case strings.HasPrefix(line, "@@"):
+			return old
+		}
+	}
+	if old != "" {
+		return old
+	}
+	// Binary files and renames without changes have no ---/+++ lines
+	header, _, _ := strings.Cut(patch, "\n")
+	if i := strings.LastIndex(header, " b/"); i >= 0 {
+		return header[i+len(" b/"):]
+	}
+	return header
+}
+
+// run runs git with args in dir and returns its output.
+func run(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s failed: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return string(output), nil
+}
@@ -0,0 +1,98 @@
+// nolint:testpackage
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newRepo creates a repository with a commit on main and two on the branch
+// feature, checked out.
+func newRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@example.com",
+			"GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@example.com")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q", "-b", "main")
+	write("a.go", "package a\n")
+	write("old.go", "package a\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	git("checkout", "-q", "-b", "feature")
+	write("a.go", "package a\n\nfunc A() {}\n")
+	git("commit", "-q", "-am", "feat: add A", "-m", "A does nothing yet.")
+	write("b.go", "package a\n")
+	git("rm", "-q", "old.go")
+	git("add", "b.go")
+	git("commit", "-q", "-m", "chore: replace old.go")
+	return dir
+}
+
+func TestCommits(t *testing.T) {
+	dir := newRepo(t)
+
+	commits, err := Commits(context.Background(), dir, "main..HEAD")
+	if err != nil {
+		t.Fatalf("Commits failed: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("Expected 2 commits, got %+v", commits)
+	}
+	if c := commits[0]; len(c.Hash) != 40 || c.Message() != "feat: add A\n\nA does nothing yet." {
+		t.Errorf("Unexpected first commit %+v", c)
+	}
+	if c := commits[1]; c.Subject != "chore: replace old.go" || c.Body != "" || c.Message() != c.Subject {
+		t.Errorf("Unexpected second commit %+v", c)
+	}
+
+	if _, err := Commits(context.Background(), dir, "missing..HEAD"); err == nil {
+		t.Errorf("Expected an unknown revision to fail")
+	}
+
+	stat, err := Stat(context.Background(), dir, commits[0].Hash)
+	if err != nil || !strings.Contains(stat, "a.go | 2 ++") {
+		t.Errorf("Expected the diffstat of the first commit, got %q, %v", stat, err)
+	}
+}
+
+func TestBranchDiff(t *testing.T) {
+	dir := newRepo(t)
+
+	diffs, err := BranchDiff(context.Background(), dir, "main")
+	if err != nil {
+		t.Fatalf("BranchDiff failed: %v", err)
+	}
+	var paths []string
+	for _, d := range diffs {
+		paths = append(paths, d.Path)
+	}
+	// old.go is renamed to b.go, with the same content
+	if len(paths) != 2 || paths[0] != "a.go" || paths[1] != "b.go" {
+		t.Fatalf("Expected diffs of a.go and b.go, got %q", paths)
+	}
+	if !strings.Contains(diffs[1].Patch, "rename from old.go\n") {
+		t.Errorf("Expected the rename of old.go, got %q", diffs[1].Patch)
+	}
+	if patch := diffs[0].Patch; !strings.HasPrefix(patch, "diff --git a/a.go b/a.go\n") || !strings.HasSuffix(patch, "+func A() {}\n") {
+		t.Errorf("Unexpected patch %q", patch)
+	}
+}
@@ -0,0 +1,117 @@
+package index
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// archiveEntry is the name of the database in an index archive.
+const archiveEntry = "index.db"
+
+// Export writes a compacted copy of the index to w as a gzip-compressed tar
+// archive, so it can be built once, for example in CI, and imported where
+// the same repository is checked out. Indexed paths are relative to the
+// repository root, so the archive does not depend on where it was built.
+func (ix *Index) Export(ctx context.Context, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "aicodereader-index-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	snapshot := filepath.Join(dir, archiveEntry)
+	if _, err := ix.db.ExecContext(ctx, `VACUUM INTO ?`, snapshot); err != nil {
+		return fmt.Errorf("failed to copy index: %w", err)
+	}
+	f, err := os.Open(snapshot)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	header := &tar.Header{Name: archiveEntry, Mode: 0644, Size: info.Size(), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return err
+	}
+	return errors.Join(tw.Close(), zw.Close())
+}
+
+// Import replaces the index at path with the one in r, an archive written
+// by Export, and returns the embedding model it was built with. The index at
+// path is left alone if the archive holds no valid index.
+func Import(ctx context.Context, r io.Reader, path string) (string, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return "", fmt.Errorf("not an index archive: %w", err)
+	}
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("not an index archive: no %s in it", archiveEntry)
+		}
+		if err != nil {
+			return "", fmt.Errorf("not an index archive: %w", err)
+		}
+		if header.Name == archiveEntry && header.Typeflag == tar.TypeReg {
+			break
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".import-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, copyErr := io.Copy(tmp, tr)
+	if err := errors.Join(copyErr, tmp.Close()); err != nil {
+		return "", err
+	}
+
+	model, err := importedModel(ctx, tmp.Name())
+	if err != nil {
+		return "", err
+	}
+	return model, os.Rename(tmp.Name(), path)
+}
+
+// importedModel returns the embedding model of the index database at path,
+// failing if it is not an index built by Build.
+func importedModel(ctx context.Context, path string) (string, error) {
+	ix, err := open(path)
+	if err != nil {
+		return "", err
+	}
+	defer ix.Close()
+
+	if err := ix.db.PingContext(ctx); err != nil {
+		return "", err
+	}
+	model, err := ix.meta("model")
+	if err != nil {
+		return "", fmt.Errorf("not an index archive: %w", err)
+	}
+	if model == "" {
+		return "", errors.New("not an index archive: the index records no embedding model")
+	}
+	return model, nil
+}
@@ -0,0 +1,382 @@
+package index
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JackDrogon/aicodereader/pkgs/chunker"
+)
+
+const (
+	// DefaultChunkTokens is the size of the chunks code is embedded in.
+	// Small chunks keep search results focused.
+	DefaultChunkTokens = 512
+	// DefaultChunkOverlap is the overlap between consecutive line-split chunks.
+	DefaultChunkOverlap = 64
+	// DefaultBatchSize is the number of chunks embedded per request.
+	DefaultBatchSize = 32
+)
+
+// EmbedFunc embeds texts, returning one vector per text in order.
+type EmbedFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+// Builder fills an index from a repository's files.
+type Builder struct {
+	// Embed embeds chunk texts.
+	Embed EmbedFunc
+	// Model names the embedding model. It is recorded in the index so
+	// searches embed queries with the same model.
+	Model string
+	// Tokenizer measures chunk sizes.
+	Tokenizer chunker.Tokenizer
+	// ChunkTokens, ChunkOverlap and BatchSize default to DefaultChunkTokens,
+	// DefaultChunkOverlap and DefaultBatchSize when zero.
+	ChunkTokens  int
+	ChunkOverlap int
+	BatchSize    int
+	// Progress, if set, is called after each batch with the number of
+	// chunks embedded so far and in total.
+	Progress func(done, total int)
+	// OnSkip, if set, is called for each file left out of the index.
+	OnSkip func(path string, err error)
+	// Partial keeps the entries of indexed files missing from the files
+	// given to Build, for builds from a partial list such as a capped scan.
+	Partial bool
+	// Cache, if set, supplies the embeddings of chunks embedded before with
+	// Model and keeps the ones Build makes.
+	Cache *Cache
+}
+
+// Stats counts what a build did.
+type Stats struct {
+	Added     int
+	Updated   int
+	Removed   int
+	Unchanged int
+	Skipped   int
+	// Chunks is the number of chunks embedded.
+	Chunks int
+	// Cached is the number of those chunks whose embeddings came from Cache.
+	Cached int
+}
+
+// fileState identifies the indexed version of a file. Size and modification
+// time are checked first so unchanged files need not be read; the content
+// hash catches files that were touched without changing.
+type fileState struct {
+	hash  string
+	size  int64
+	mtime int64
+}
+
+// change is an added or modified file waiting to be embedded.
+type change struct {
+	path   string
+	state  fileState
+	chunks []entry
+}
+
+// entry is a chunk waiting to be embedded.
+type entry struct {
+	path  string
+	chunk chunker.Chunk
+}
+
+// Build brings ix up to date with files, given as paths under root, and
+// stores paths relative to root. Only added and modified files are embedded;
+// entries of files no longer listed are removed unless Partial is set. Files
+// that cannot be read, are not text or cannot be split are skipped and leave
+// the index. If ix was built with another embedding model, it is rebuilt from
+// scratch.
+//
+// Changes are committed in groups of about BatchSize chunks, so a failed
+// build keeps the files embedded so far.
+func (b *Builder) Build(ctx context.Context, ix *Index, root string, files []string) (Stats, error) {
+	var stats Stats
+	if err := ix.resetIfModelChanged(ctx, b.Model); err != nil {
+		return stats, err
+	}
+	indexed, err := ix.fileStates(ctx)
+	if err != nil {
+		return stats, err
+	}
+
+	current := make(map[string]bool, len(files))
+	touched := make(map[string]fileState)
+	var changes []change
+	for _, file := range files {
+		rel, err := relPath(root, file)
+		if err != nil {
+			return stats, err
+		}
+
+		old, known := indexed[rel]
+		state, c, err := b.examine(file, rel, old)
+		if err != nil {
+			stats.Skipped++
+			if b.OnSkip != nil {
+				b.OnSkip(file, err)
+			}
+			continue
+		}
+		current[rel] = true
+
+		switch {
+		case c != nil:
+			changes = append(changes, *c)
+			if known {
+				stats.Updated++
+			} else {
+				stats.Added++
+			}
+		case state != old:
+			touched[rel] = state
+			stats.Unchanged++
+		default:
+			stats.Unchanged++
+		}
+	}
+
+	if !b.Partial {
+		removed, err := ix.removeExcept(ctx, current)
+		if err != nil {
+			return stats, err
+		}
+		stats.Removed = removed
+	}
+	if err := ix.touch(ctx, touched); err != nil {
+		return stats, err
+	}
+
+	total := 0
+	for _, c := range changes {
+		total += len(c.chunks)
+	}
+	batchSize := b.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	for start := 0; start < len(changes); {
+		end, size := start, 0
+		for end < len(changes) && (end == start || size+len(changes[end].chunks) <= batchSize) {
+			size += len(changes[end].chunks)
+			end++
+		}
+		cached, err := b.commit(ctx, ix, changes[start:end], batchSize)
+		if err != nil {
+			return stats, err
+		}
+		stats.Chunks += size
+		stats.Cached += cached
+		if b.Progress != nil {
+			b.Progress(stats.Chunks, total)
+		}
+		start = end
+	}
+	return stats, nil
+}
+
+// examine compares file with its indexed state old, zero if not indexed.
+// It returns the file's current state, and a change holding its chunks if
+// the content differs from old.
+func (b *Builder) examine(file, rel string, old fileState) (fileState, *change, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fileState{}, nil, err
+	}
+	state := fileState{hash: old.hash, size: info.Size(), mtime: info.ModTime().UnixNano()}
+	if old.hash != "" && state == old {
+		return state, nil, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return fileState{}, nil, err
+	}
+	sum := sha256.Sum256(content)
+	state.hash = hex.EncodeToString(sum[:])
+	if state.hash == old.hash {
+		return state, nil, nil
+	}
+
+	chunks, err := b.split(file, content)
+	if err != nil {
+		return fileState{}, nil, err
+	}
+	c := &change{path: rel, state: state}
+	for _, chunk := range chunks {
+		if strings.TrimSpace(chunk.Content) != "" {
+			c.chunks = append(c.chunks, entry{path: rel, chunk: chunk})
+		}
+	}
+	return state, c, nil
+}
+
+// split splits the content of file into chunks.
+func (b *Builder) split(file string, content []byte) ([]chunker.Chunk, error) {
+	if !utf8.Valid(content) {
+		return nil, errors.New("not a text file")
+	}
+
+	maxTokens := b.ChunkTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultChunkTokens
+	}
+	overlap := b.ChunkOverlap
+	if overlap <= 0 {
+		overlap = DefaultChunkOverlap
+	}
+	return chunker.SplitFile(file, string(content), b.Tokenizer, chunker.Options{MaxTokens: maxTokens, Overlap: overlap})
+}
+
+// commit embeds the chunks of changes and replaces the files' entries in
+// one transaction. It returns the number of chunks embedded from Cache.
+func (b *Builder) commit(ctx context.Context, ix *Index, changes []change, batchSize int) (int, error) {
+	var entries []entry
+	for _, c := range changes {
+		entries = append(entries, c.chunks...)
+	}
+
+	tx, err := ix.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() //nolint:errcheck // a no-op after Commit
+
+	for _, c := range changes {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM chunks WHERE path = ?`, c.path); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR REPLACE INTO files (path, hash, size, mtime) VALUES (?, ?, ?, ?)`,
+			c.path, c.state.hash, c.state.size, c.state.mtime); err != nil {
+			return 0, err
+		}
+	}
+	total := 0
+	for start := 0; start < len(entries); start += batchSize {
+		cached, err := b.insertBatch(ctx, tx, entries[start:min(start+batchSize, len(entries))])
+		if err != nil {
+			return 0, err
+		}
+		total += cached
+	}
+	return total, tx.Commit()
+}
+
+// relPath returns file relative to root with forward slashes.
+func relPath(root, file string) (string, error) {
+	rel, err := filepath.Rel(root, file)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// insertBatch embeds a batch of chunks and stores them. It returns the
+// number of chunks embedded from Cache.
+func (b *Builder) insertBatch(ctx context.Context, tx *sql.Tx, batch []entry) (int, error) {
+	texts := make([]string, len(batch))
+	for i, e := range batch {
+		texts[i] = embeddingText(e.path, e.chunk.Content)
+	}
+
+	vectors, cached, err := b.embed(ctx, texts)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, e := range batch {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO chunks (path, start_line, end_line, content, embedding) VALUES (?, ?, ?, ?, ?)`,
+			e.path, e.chunk.StartLine, e.chunk.EndLine, e.chunk.Content, encodeVector(vectors[i])); err != nil {
+			return 0, err
+		}
+	}
+	return cached, nil
+}
+
+// embed returns the embeddings of texts, taking the ones in Cache from it
+// and embedding the rest in one request. It returns the number of texts
+// found in Cache.
+func (b *Builder) embed(ctx context.Context, texts []string) ([][]float32, int, error) {
+	var hashes []string
+	vectors := make([][]float32, len(texts))
+	if b.Cache != nil {
+		hashes = make([]string, len(texts))
+		for i, text := range texts {
+			hashes[i] = TextHash(text)
+		}
+		var err error
+		if vectors, err = b.Cache.Get(ctx, b.Model, hashes); err != nil {
+			return nil, 0, fmt.Errorf("failed to read embedding cache: %w", err)
+		}
+	}
+
+	var missing []int
+	var missingTexts, missingHashes []string
+	for i, v := range vectors {
+		if v == nil {
+			missing = append(missing, i)
+			missingTexts = append(missingTexts, texts[i])
+			if hashes != nil {
+				missingHashes = append(missingHashes, hashes[i])
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return vectors, len(texts), nil
+	}
+
+	embedded, err := b.Embed(ctx, missingTexts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to embed chunks: %w", err)
+	}
+	if len(embedded) != len(missing) {
+		return nil, 0, fmt.Errorf("expected %d embeddings, got %d", len(missing), len(embedded))
+	}
+	for j, i := range missing {
+		vectors[i] = embedded[j]
+	}
+	if b.Cache != nil {
+		if err := b.Cache.Put(ctx, b.Model, missingHashes, embedded); err != nil {
+			return nil, 0, fmt.Errorf("failed to write embedding cache: %w", err)
+		}
+	}
+	return vectors, len(texts) - len(missing), nil
+}
+
+// embeddingText is the text embedded for a chunk. The path carries meaning
+// the code alone may not, such as the package or feature it belongs to.
+func embeddingText(path, content string) string {
+	return path + "\n" + content
+}
+
+// encodeVector serializes v as little-endian float32 values.
+func encodeVector(v []float32) []byte {
+	data := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(f))
+	}
+	return data
+}
+
+// decodeVector reverses encodeVector.
+func decodeVector(data []byte) []float32 {
+	v := make([]float32, len(data)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return v
+}
@@ -0,0 +1,129 @@
+package index
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const cacheSchema = `
+CREATE TABLE IF NOT EXISTS embeddings (
+	model     TEXT NOT NULL,
+	hash      TEXT NOT NULL,
+	embedding BLOB NOT NULL,
+	PRIMARY KEY (model, hash)
+);
+`
+
+// Cache stores chunk embeddings by embedding model and the hash of the
+// embedded text, apart from the caches of answers, whose entries depend on
+// prompts. Rebuilt indexes, edited files and other checkouts of a repository
+// take the embeddings of chunks seen before from it instead of embedding
+// them again.
+type Cache struct {
+	db *sql.DB
+}
+
+// DefaultCachePath returns where the embedding cache is stored, e.g.
+// ~/.cache/aicodereader/embeddings.db on Linux.
+func DefaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "aicodereader", "embeddings.db"), nil
+}
+
+// OpenCache opens the embedding cache at path, creating the database and its
+// directory if needed.
+func OpenCache(path string) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(cacheSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open embedding cache %s: %w", path, err)
+	}
+	return &Cache{db: db}, nil
+}
+
+// Close closes the database.
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// TextHash is the key of an embedded text in the cache.
+func TextHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the embeddings stored for model under hashes, in order, with
+// nil for the hashes not in the cache. It looks them up in one query.
+func (c *Cache) Get(ctx context.Context, model string, hashes []string) ([][]float32, error) {
+	vectors := make([][]float32, len(hashes))
+	if len(hashes) == 0 {
+		return vectors, nil
+	}
+
+	args := make([]any, 0, len(hashes)+1)
+	args = append(args, model)
+	for _, hash := range hashes {
+		args = append(args, hash)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(hashes)), ", ")
+	rows, err := c.db.QueryContext(ctx,
+		`SELECT hash, embedding FROM embeddings WHERE model = ? AND hash IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[string][]float32, len(hashes))
+	for rows.Next() {
+		var hash string
+		var embedding []byte
+		if err := rows.Scan(&hash, &embedding); err != nil {
+			return nil, err
+		}
+		found[hash] = decodeVector(embedding)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, hash := range hashes {
+		vectors[i] = found[hash]
+	}
+	return vectors, nil
+}
+
+// Put stores vectors, the embeddings made by model of the texts hashed to
+// hashes, in one transaction.
+func (c *Cache) Put(ctx context.Context, model string, hashes []string, vectors [][]float32) error {
+	if len(hashes) != len(vectors) {
+		return fmt.Errorf("expected %d embeddings, got %d", len(hashes), len(vectors))
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // a no-op after Commit
+
+	for i, hash := range hashes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR REPLACE INTO embeddings (model, hash, embedding) VALUES (?, ?, ?)`,
+			model, hash, encodeVector(vectors[i])); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
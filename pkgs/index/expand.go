@@ -0,0 +1,164 @@
+package index
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxSynonyms is the number of identifiers a query is expanded with.
+const maxSynonyms = 8
+
+// minPrefix is the length from which a word and an identifier part, one the
+// start of the other, are taken for the same word, as in "auth" and
+// "authentication".
+const minPrefix = 4
+
+// abbreviations lists the abbreviations code commonly uses for words.
+var abbreviations = map[string][]string{
+	"argument":       {"arg", "args"},
+	"authentication": {"auth", "authn"},
+	"authorization":  {"auth", "authz"},
+	"buffer":         {"buf"},
+	"channel":        {"ch", "chan"},
+	"command":        {"cmd"},
+	"config":         {"cfg", "conf"},
+	"configuration":  {"config", "cfg", "conf"},
+	"connection":     {"conn"},
+	"context":        {"ctx"},
+	"database":       {"db"},
+	"directory":      {"dir"},
+	"document":       {"doc"},
+	"error":          {"err"},
+	"index":          {"idx"},
+	"initialize":     {"init"},
+	"length":         {"len"},
+	"manager":        {"mgr"},
+	"message":        {"msg"},
+	"number":         {"num"},
+	"package":        {"pkg"},
+	"parameter":      {"param", "params"},
+	"pointer":        {"ptr"},
+	"reference":      {"ref"},
+	"repository":     {"repo"},
+	"request":        {"req"},
+	"response":       {"resp", "res"},
+	"source":         {"src"},
+	"specification":  {"spec"},
+	"string":         {"str"},
+	"temporary":      {"tmp", "temp"},
+	"transaction":    {"tx", "txn"},
+	"value":          {"val"},
+}
+
+// wordForms returns the forms a query word takes in identifiers: the word,
+// its singular, and its abbreviations, or the words it abbreviates.
+func wordForms(word string) []string {
+	forms := []string{word}
+	if singular := strings.TrimSuffix(word, "s"); singular != word && len(singular) >= 3 {
+		forms = append(forms, singular)
+	}
+	for _, form := range forms {
+		forms = append(forms, abbreviations[form]...)
+	}
+	for full, abbrevs := range abbreviations {
+		for _, abbrev := range abbrevs {
+			if abbrev == word {
+				forms = append(forms, full)
+			}
+		}
+	}
+	return forms
+}
+
+// matchesForm reports whether part, a lowercase identifier part, is one of
+// forms or, for long enough words, starts one of them or starts with one.
+func matchesForm(part string, forms []string) bool {
+	for _, form := range forms {
+		if part == form {
+			return true
+		}
+		if len(part) >= minPrefix && len(form) >= minPrefix &&
+			(strings.HasPrefix(form, part) || strings.HasPrefix(part, form)) {
+			return true
+		}
+	}
+	return false
+}
+
+// synonyms returns up to limit identifiers in docs related to the words of
+// query, for keyword search to find code that names a concept differently
+// than the question: compound identifiers with a part matching a word, such
+// as ParseConfig for "configuration", and abbreviations of the words, such
+// as cfg. Identifiers in more documents come first; those in the query are
+// left out.
+func synonyms(query string, docs []string, limit int) []string {
+	inQuery := make(map[string]bool)
+	var wordsForms [][]string
+	for _, term := range terms(query) {
+		if inQuery[term] {
+			continue
+		}
+		inQuery[term] = true
+		if len(term) >= 3 {
+			wordsForms = append(wordsForms, wordForms(term))
+		}
+	}
+	if len(wordsForms) == 0 {
+		return nil
+	}
+
+	related := make(map[string]bool)
+	isRelated := func(identifier string) bool {
+		if known, ok := related[identifier]; ok {
+			return known
+		}
+		parts := splitIdentifier(identifier)
+		found := false
+		for _, forms := range wordsForms {
+			if len(parts) == 1 {
+				// Single words only count as abbreviations, not as the words
+				// themselves or words sharing a prefix with them
+				found = found || (parts[0] != forms[0] && slices.Contains(forms[1:], parts[0]))
+				continue
+			}
+			for _, part := range parts {
+				found = found || matchesForm(part, forms)
+			}
+		}
+		related[identifier] = found
+		return found
+	}
+
+	docFreq := make(map[string]int)
+	for _, doc := range docs {
+		seen := make(map[string]bool)
+		for _, word := range strings.FieldsFunc(doc, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+		}) {
+			if len(word) < 2 || seen[word] || inQuery[strings.ToLower(word)] || unicode.IsDigit(rune(word[0])) {
+				continue
+			}
+			seen[word] = true
+			if isRelated(word) {
+				docFreq[word]++
+			}
+		}
+	}
+
+	found := make([]string, 0, len(docFreq))
+	for identifier := range docFreq {
+		found = append(found, identifier)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if docFreq[found[i]] != docFreq[found[j]] {
+			return docFreq[found[i]] > docFreq[found[j]]
+		}
+		return found[i] < found[j]
+	})
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+	return found
+}
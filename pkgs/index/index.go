@@ -0,0 +1,241 @@
+// Package index stores embedded code chunks in a local SQLite database for
+// semantic search over a repository.
+package index
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	// Registers the pure Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// DefaultEmbeddingModel embeds code when no embedding model is configured.
+const DefaultEmbeddingModel = "text-embedding-3-small"
+
+// ErrNotFound is returned by Open when the index has not been built.
+var ErrNotFound = errors.New("index not found")
+
+const schema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS chunks (
+	id         INTEGER PRIMARY KEY,
+	path       TEXT NOT NULL,
+	start_line INTEGER NOT NULL,
+	end_line   INTEGER NOT NULL,
+	content    TEXT NOT NULL,
+	embedding  BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS chunks_path ON chunks (path);
+CREATE TABLE IF NOT EXISTS files (
+	path  TEXT PRIMARY KEY,
+	hash  TEXT NOT NULL,
+	size  INTEGER NOT NULL,
+	mtime INTEGER NOT NULL
+);
+`
+
+// Index is an open index database.
+type Index struct {
+	db *sql.DB
+}
+
+// DefaultPath returns where the index of the repository at root is stored:
+// a database in the per-user cache directory named after root's absolute
+// path, e.g. ~/.cache/aicodereader/index/<hash>.db on Linux, so indexing
+// never writes into the repository.
+func DefaultPath(root string) (string, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, "aicodereader", "index", hex.EncodeToString(sum[:8])+".db"), nil
+}
+
+// Create opens the index at path for building, creating the database and
+// its directory if needed.
+func Create(path string) (*Index, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return open(path)
+}
+
+// Open opens an existing index at path, returning ErrNotFound if there is none.
+func Open(path string) (*Index, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return open(path)
+}
+
+// open opens the database at path and ensures the schema exists.
+func open(path string) (*Index, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open index %s: %w", path, err)
+	}
+	return &Index{db: db}, nil
+}
+
+// Close closes the database.
+func (ix *Index) Close() error {
+	return ix.db.Close()
+}
+
+// meta returns the metadata value for key, empty if unset.
+func (ix *Index) meta(key string) (string, error) {
+	var value string
+	err := ix.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+// resetIfModelChanged empties the index if it was built with an embedding
+// model other than model, since vectors of different models cannot be
+// compared, and records model.
+func (ix *Index) resetIfModelChanged(ctx context.Context, model string) error {
+	indexed, err := ix.meta("model")
+	if err != nil || indexed == model {
+		return err
+	}
+
+	tx, err := ix.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // a no-op after Commit
+
+	for _, statement := range []string{`DELETE FROM chunks`, `DELETE FROM files`} {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO meta (key, value) VALUES ('model', ?)`, model); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// fileStates returns the indexed state of every file.
+func (ix *Index) fileStates(ctx context.Context) (map[string]fileState, error) {
+	rows, err := ix.db.QueryContext(ctx, `SELECT path, hash, size, mtime FROM files`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[string]fileState)
+	for rows.Next() {
+		var path string
+		var state fileState
+		if err := rows.Scan(&path, &state.hash, &state.size, &state.mtime); err != nil {
+			return nil, err
+		}
+		states[path] = state
+	}
+	return states, rows.Err()
+}
+
+// removeExcept removes the entries of every indexed path not in keep,
+// including chunks without a file record, and returns the number of paths
+// removed.
+func (ix *Index) removeExcept(ctx context.Context, keep map[string]bool) (int, error) {
+	rows, err := ix.db.QueryContext(ctx, `SELECT path FROM files UNION SELECT DISTINCT path FROM chunks`)
+	if err != nil {
+		return 0, err
+	}
+	var stale []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !keep[path] {
+			stale = append(stale, path)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := ix.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() //nolint:errcheck // a no-op after Commit
+
+	for _, path := range stale {
+		for _, statement := range []string{`DELETE FROM chunks WHERE path = ?`, `DELETE FROM files WHERE path = ?`} {
+			if _, err := tx.ExecContext(ctx, statement, path); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(stale), tx.Commit()
+}
+
+// touch records new sizes and modification times of files whose content
+// did not change.
+func (ix *Index) touch(ctx context.Context, states map[string]fileState) error {
+	if len(states) == 0 {
+		return nil
+	}
+
+	tx, err := ix.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // a no-op after Commit
+
+	for path, state := range states {
+		if _, err := tx.ExecContext(ctx, `UPDATE files SET size = ?, mtime = ? WHERE path = ?`,
+			state.size, state.mtime, path); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Prune removes the entries of indexed files that are not among files,
+// given as paths under root, and compacts the database. Unlike Build it
+// neither reads nor embeds anything. It returns the number of files removed.
+func (ix *Index) Prune(ctx context.Context, root string, files []string) (int, error) {
+	keep := make(map[string]bool, len(files))
+	for _, file := range files {
+		rel, err := relPath(root, file)
+		if err != nil {
+			return 0, err
+		}
+		keep[rel] = true
+	}
+
+	removed, err := ix.removeExcept(ctx, keep)
+	if err != nil {
+		return 0, err
+	}
+	_, err = ix.db.ExecContext(ctx, `VACUUM`)
+	return removed, err
+}
@@ -0,0 +1,653 @@
+// nolint:testpackage
+package index
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/JackDrogon/aicodereader/pkgs/chunker"
+)
+
+// bagOfWords embeds texts as hashed word counts, so texts sharing words are
+// similar.
+func bagOfWords(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, 64)
+		for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			vector[h.Sum32()%64]++
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// buildIndex indexes files written under a temporary root.
+func buildIndex(t *testing.T, files map[string]string) (*Index, Stats) {
+	t.Helper()
+	root := t.TempDir()
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	ix, err := Create(filepath.Join(t.TempDir(), "index", "test.db"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	t.Cleanup(func() { ix.Close() })
+
+	b := &Builder{Embed: bagOfWords, Model: "bag", Tokenizer: mustTokenizer(t)}
+	stats, err := b.Build(context.Background(), ix, root, paths)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	return ix, stats
+}
+
+func TestBuildAndSearch(t *testing.T) {
+	ix, stats := buildIndex(t, map[string]string{
+		"pkgs/config/load.go": "package config\n\n// Load reads the configuration file and environment variables.\nfunc Load() {}\n",
+		"pkgs/http/client.go": "package http\n\n// NewClient creates an HTTP client with timeouts and keep alive.\nfunc NewClient() {}\n",
+		"logo.png":            "\x89PNG\r\n\x1a\n\xff\xfe",
+	})
+	if stats.Added != 2 || stats.Chunks != 2 || stats.Skipped != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	results, err := ix.Search(context.Background(), bagOfWords, "bag", "http client timeouts", 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != "pkgs/http/client.go" {
+		t.Fatalf("Expected the HTTP client first, got %+v", results)
+	}
+	if r := results[0]; r.StartLine != 3 || r.EndLine != 4 || !strings.Contains(r.Content, "func NewClient") || r.Score <= 0 {
+		t.Errorf("Unexpected result %+v", r)
+	}
+	states, _ := ix.fileStates(context.Background())
+	if r := results[0]; r.ID == 0 || r.FileHash == "" || r.FileHash != states[r.Path].hash {
+		t.Errorf("Expected the chunk ID and the hash of its file, got %+v", r)
+	}
+
+	all, err := ix.Search(context.Background(), bagOfWords, "bag", "configuration file", 0)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(all) != 2 || all[0].Path != "pkgs/config/load.go" || all[0].Score < all[1].Score {
+		t.Errorf("Expected every chunk ranked by score, got %+v", all)
+	}
+}
+
+func TestSearchModelMismatch(t *testing.T) {
+	ix, _ := buildIndex(t, map[string]string{"a.go": "package a\n"})
+	if _, err := ix.Search(context.Background(), bagOfWords, "other", "query", 5); err == nil {
+		t.Errorf("Expected error when searching with a different embedding model")
+	}
+}
+
+// countingEmbedder embeds like bagOfWords and counts the texts it embeds.
+type countingEmbedder struct {
+	texts int
+	fail  bool
+}
+
+func (e *countingEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if e.fail {
+		return nil, errors.New("quota exceeded")
+	}
+	e.texts += len(texts)
+	return bagOfWords(ctx, texts)
+}
+
+// repo is a directory of files being indexed.
+type repo struct {
+	t    *testing.T
+	root string
+}
+
+// write creates or replaces a file in the repository.
+func (r repo) write(name, content string) {
+	r.t.Helper()
+	if err := os.WriteFile(filepath.Join(r.root, name), []byte(content), 0644); err != nil {
+		r.t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+// files lists the repository's files.
+func (r repo) files() []string {
+	r.t.Helper()
+	entries, err := os.ReadDir(r.root)
+	if err != nil {
+		r.t.Fatalf("Failed to list files: %v", err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, filepath.Join(r.root, entry.Name()))
+	}
+	return paths
+}
+
+// build updates ix from the repository with embedder.
+func (r repo) build(ix *Index, model string, embedder *countingEmbedder) (Stats, error) {
+	b := &Builder{Embed: embedder.embed, Model: model, Tokenizer: mustTokenizer(r.t)}
+	return b.Build(context.Background(), ix, r.root, r.files())
+}
+
+// indexedPaths returns the indexed paths, sorted.
+func indexedPaths(t *testing.T, ix *Index) []string {
+	t.Helper()
+	states, err := ix.fileStates(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read file states: %v", err)
+	}
+	var paths []string
+	for path := range states {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func newTestIndex(t *testing.T) *Index {
+	t.Helper()
+	ix, err := Create(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	t.Cleanup(func() { ix.Close() })
+	return ix
+}
+
+func TestBuildIncremental(t *testing.T) {
+	r := repo{t: t, root: t.TempDir()}
+	r.write("a.go", "package a\n")
+	r.write("b.go", "package b\n")
+	r.write("c.go", "package c\n")
+	ix := newTestIndex(t)
+
+	embedder := &countingEmbedder{}
+	if stats, err := r.build(ix, "bag", embedder); err != nil || stats.Added != 3 || embedder.texts != 3 {
+		t.Fatalf("Expected 3 files embedded, got %+v, %d texts, %v", stats, embedder.texts, err)
+	}
+
+	// Nothing changed, or only the modification time
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(r.root, "a.go"), later, later); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	embedder = &countingEmbedder{}
+	if stats, err := r.build(ix, "bag", embedder); err != nil || stats.Unchanged != 3 || embedder.texts != 0 {
+		t.Errorf("Expected nothing to be embedded, got %+v, %d texts, %v", stats, embedder.texts, err)
+	}
+	states, _ := ix.fileStates(context.Background())
+	if states["a.go"].mtime != later.UnixNano() {
+		t.Errorf("Expected the new modification time to be recorded")
+	}
+
+	// One modified, one deleted, one added
+	r.write("b.go", "package b\n\nfunc B() {}\n")
+	if err := os.Remove(filepath.Join(r.root, "c.go")); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	r.write("d.go", "package d\n")
+	embedder = &countingEmbedder{}
+	stats, err := r.build(ix, "bag", embedder)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if stats.Added != 1 || stats.Updated != 1 || stats.Removed != 1 || stats.Unchanged != 1 || embedder.texts != 2 {
+		t.Errorf("Expected only b.go and d.go to be embedded, got %+v, %d texts", stats, embedder.texts)
+	}
+	if paths := indexedPaths(t, ix); strings.Join(paths, ",") != "a.go,b.go,d.go" {
+		t.Errorf("Unexpected indexed files %v", paths)
+	}
+
+	results, err := ix.Search(context.Background(), bagOfWords, "bag", "func B", 0)
+	if err != nil || len(results) != 3 || results[0].Path != "b.go" || !strings.Contains(results[0].Content, "func B") {
+		t.Errorf("Expected the updated b.go first among 3 chunks, got %+v, %v", results, err)
+	}
+}
+
+func TestBuildModelChange(t *testing.T) {
+	r := repo{t: t, root: t.TempDir()}
+	r.write("a.go", "package a\n")
+	r.write("b.go", "package b\n")
+	ix := newTestIndex(t)
+
+	if _, err := r.build(ix, "bag", &countingEmbedder{}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	embedder := &countingEmbedder{}
+	if _, err := r.build(ix, "other", embedder); err != nil || embedder.texts != 2 {
+		t.Errorf("Expected a new model to re-embed everything, got %d texts, %v", embedder.texts, err)
+	}
+	if _, err := ix.Search(context.Background(), bagOfWords, "other", "package", 0); err != nil {
+		t.Errorf("Expected the index to use the new model, got %v", err)
+	}
+}
+
+func TestBuildCache(t *testing.T) {
+	r := repo{t: t, root: t.TempDir()}
+	r.write("a.go", "package a\n")
+	r.write("b.go", "package b\n")
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "cache", "embeddings.db"))
+	if err != nil {
+		t.Fatalf("OpenCache failed: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+
+	build := func(ix *Index, model string, embedder *countingEmbedder) Stats {
+		t.Helper()
+		b := &Builder{Embed: embedder.embed, Model: model, Tokenizer: mustTokenizer(t), Cache: cache}
+		stats, err := b.Build(context.Background(), ix, r.root, r.files())
+		if err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		return stats
+	}
+
+	embedder := &countingEmbedder{}
+	if stats := build(newTestIndex(t), "bag", embedder); embedder.texts != 2 || stats.Cached != 0 {
+		t.Fatalf("Expected both files embedded, got %+v, %d texts", stats, embedder.texts)
+	}
+
+	// A new index of the same files embeds only what changed
+	r.write("b.go", "package b\n\nfunc B() {}\n")
+	ix := newTestIndex(t)
+	embedder = &countingEmbedder{}
+	if stats := build(ix, "bag", embedder); embedder.texts != 1 || stats.Chunks != 2 || stats.Cached != 1 {
+		t.Errorf("Expected only b.go embedded, got %+v, %d texts", stats, embedder.texts)
+	}
+	results, err := ix.Search(context.Background(), bagOfWords, "bag", "package a", 1)
+	if err != nil || len(results) != 1 || results[0].Path != "a.go" || results[0].Similarity == 0 {
+		t.Errorf("Expected the cached embedding of a.go to be searchable, got %+v, %v", results, err)
+	}
+
+	// Embeddings of another model are not shared
+	embedder = &countingEmbedder{}
+	if build(newTestIndex(t), "other", embedder); embedder.texts != 2 {
+		t.Errorf("Expected another model to embed everything, got %d texts", embedder.texts)
+	}
+}
+
+func TestCacheGetPut(t *testing.T) {
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "embeddings.db"))
+	if err != nil {
+		t.Fatalf("OpenCache failed: %v", err)
+	}
+	defer cache.Close()
+	ctx := context.Background()
+
+	hashes := []string{TextHash("a"), TextHash("b")}
+	if err := cache.Put(ctx, "m", hashes, [][]float32{{1, 2}, {3}}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	vectors, err := cache.Get(ctx, "m", []string{TextHash("b"), TextHash("c"), TextHash("a")})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(vectors) != 3 || !slices.Equal(vectors[0], []float32{3}) || vectors[1] != nil || !slices.Equal(vectors[2], []float32{1, 2}) {
+		t.Errorf("Unexpected vectors %v", vectors)
+	}
+	if vectors, err = cache.Get(ctx, "other", hashes); err != nil || vectors[0] != nil || vectors[1] != nil {
+		t.Errorf("Expected no vectors for another model, got %v, %v", vectors, err)
+	}
+	if err := cache.Put(ctx, "m", hashes, [][]float32{{1}}); err == nil {
+		t.Errorf("Expected mismatched vectors to be rejected")
+	}
+}
+
+func TestBuildEmbedError(t *testing.T) {
+	r := repo{t: t, root: t.TempDir()}
+	r.write("a.go", "package a\n")
+	ix := newTestIndex(t)
+	if _, err := r.build(ix, "bag", &countingEmbedder{}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	r.write("a.go", "package a\n\nfunc A() {}\n")
+	if _, err := r.build(ix, "bag", &countingEmbedder{fail: true}); err == nil {
+		t.Fatalf("Expected embedding errors to fail the build")
+	}
+
+	// The previous version stays indexed and is retried next time
+	results, err := ix.Search(context.Background(), bagOfWords, "bag", "package", 0)
+	if err != nil || len(results) != 1 || strings.Contains(results[0].Content, "func A") {
+		t.Errorf("Expected the previous contents, got %+v, %v", results, err)
+	}
+	embedder := &countingEmbedder{}
+	if stats, err := r.build(ix, "bag", embedder); err != nil || stats.Updated != 1 || embedder.texts != 1 {
+		t.Errorf("Expected the failed file to be retried, got %+v, %v", stats, err)
+	}
+}
+
+func TestPartialBuild(t *testing.T) {
+	r := repo{t: t, root: t.TempDir()}
+	r.write("a.go", "package a\n")
+	r.write("b.go", "package b\n")
+	ix := newTestIndex(t)
+	if _, err := r.build(ix, "bag", &countingEmbedder{}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	r.write("a.go", "package a // changed\n")
+	b := &Builder{Embed: (&countingEmbedder{}).embed, Model: "bag", Tokenizer: mustTokenizer(t), Partial: true}
+	stats, err := b.Build(context.Background(), ix, r.root, r.files()[:1])
+	if err != nil || stats.Updated != 1 || stats.Removed != 0 {
+		t.Fatalf("Expected a.go updated and nothing removed, got %+v, %v", stats, err)
+	}
+	if paths := indexedPaths(t, ix); len(paths) != 2 {
+		t.Errorf("Expected the files left out of a partial build to stay indexed, got %v", paths)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	r := repo{t: t, root: t.TempDir()}
+	r.write("a.go", "package a\n")
+	r.write("b.go", "package b\n")
+	ix := newTestIndex(t)
+	if _, err := r.build(ix, "bag", &countingEmbedder{}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(r.root, "b.go")); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	removed, err := ix.Prune(context.Background(), r.root, r.files())
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected 1 file pruned, got %d, %v", removed, err)
+	}
+	results, err := ix.Search(context.Background(), bagOfWords, "bag", "package", 0)
+	if err != nil || len(results) != 1 || results[0].Path != "a.go" {
+		t.Errorf("Expected only a.go left, got %+v, %v", results, err)
+	}
+}
+
+func TestOpenMissing(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.db")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestVectorEncoding(t *testing.T) {
+	v := []float32{1.5, -2, 0, 3.25}
+	got := decodeVector(encodeVector(v))
+	for i := range v {
+		if got[i] != v[i] {
+			t.Fatalf("Expected %v, got %v", v, got)
+		}
+	}
+	if c := cosine([]float32{1, 0}, []float32{2, 0}); c != 1 {
+		t.Errorf("Expected parallel vectors to have similarity 1, got %v", c)
+	}
+	if c := cosine([]float32{1, 0}, []float32{1}); c != 0 {
+		t.Errorf("Expected mismatched lengths to score 0, got %v", c)
+	}
+}
+
+func mustTokenizer(t *testing.T) chunker.Tokenizer {
+	t.Helper()
+	tokenizer, err := chunker.TokenizerForEncoding(chunker.DefaultEncoding)
+	if err != nil {
+		t.Fatalf("Failed to load tokenizer: %v", err)
+	}
+	return tokenizer
+}
+
+func TestResultLines(t *testing.T) {
+	r := Result{StartLine: 5, EndLine: 6, Content: "package a\n\nimport \"fmt\"\n\nfunc A() {\n}\n"}
+	if got := r.Lines(); got != "func A() {\n}\n" {
+		t.Errorf("Expected the header dropped, got %q", got)
+	}
+
+	r = Result{StartLine: 1, EndLine: 2, Content: "a\nb"}
+	if got := r.Lines(); got != "a\nb\n" {
+		t.Errorf("Expected every line, got %q", got)
+	}
+}
+
+func TestSearchExactIdentifier(t *testing.T) {
+	// Every chunk embeds alike, so only keyword scoring can tell them apart
+	same := func(_ context.Context, texts []string) ([][]float32, error) {
+		vectors := make([][]float32, len(texts))
+		for i := range texts {
+			vectors[i] = []float32{1, 1}
+		}
+		return vectors, nil
+	}
+
+	ix := newTestIndex(t)
+	r := repo{t: t, root: t.TempDir()}
+	r.write("a.go", "package a\n\n// List lists the sources.\nfunc List() {}\n")
+	r.write("b.go", "package b\n\n// GetSourceListOptions configures the scan.\ntype GetSourceListOptions struct{}\n")
+	r.write("c.go", "package c\n\nfunc Options() {}\n")
+	builder := Builder{Embed: same, Model: "same", Tokenizer: mustTokenizer(t)}
+	if _, err := builder.Build(context.Background(), ix, r.root, r.files()); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	results, err := ix.Search(context.Background(), same, "same", "GetSourceListOptions", 0)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 3 || results[0].Path != "b.go" || results[0].Keyword <= results[1].Keyword {
+		t.Errorf("Expected the exact identifier match first, got %+v", results)
+	}
+	if results[1].Keyword <= 0 || results[2].Keyword <= 0 {
+		t.Errorf("Expected partial identifier matches to score, got %+v", results)
+	}
+}
+
+func TestTerms(t *testing.T) {
+	cases := map[string][]string{
+		"GetSourceListOptions": {"getsourcelistoptions", "get", "source", "list", "options"},
+		"parseHTTPRequest(x)":  {"parsehttprequest", "parse", "http", "request", "x"},
+		"max_file_size = 10":   {"max_file_size", "max", "file", "size", "10"},
+		"配置 load":              {"配置", "load"},
+	}
+	for input, expected := range cases {
+		if got := terms(input); !slices.Equal(got, expected) {
+			t.Errorf("terms(%q) = %v, expected %v", input, got, expected)
+		}
+	}
+}
+
+func TestBM25(t *testing.T) {
+	scores := bm25("timeout", []string{"set the timeout", "timeout timeout timeout in a much longer chunk of text", "nothing"})
+	if scores[0] <= 0 || scores[1] <= 0 || scores[2] != 0 {
+		t.Errorf("Expected only chunks with the term to score, got %v", scores)
+	}
+	if scores := bm25("", []string{"a"}); scores[0] != 0 {
+		t.Errorf("Expected an empty query to score zero, got %v", scores)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	ix, _ := buildIndex(t, map[string]string{"a.go": "package a\n\n// Parse reads the input.\nfunc Parse() {}\n"})
+	var archive bytes.Buffer
+	if err := ix.Export(context.Background(), &archive); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "imported", "index.db")
+	model, err := Import(context.Background(), bytes.NewReader(archive.Bytes()), path)
+	if err != nil || model != "bag" {
+		t.Fatalf("Expected the bag model to be imported, got %q, %v", model, err)
+	}
+	imported, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer imported.Close()
+	results, err := imported.Search(context.Background(), bagOfWords, "bag", "parse input", 1)
+	if err != nil || len(results) != 1 || results[0].Path != "a.go" {
+		t.Errorf("Expected the imported index to find a.go, got %+v, %v", results, err)
+	}
+
+	// A broken archive leaves the index alone
+	if _, err := Import(context.Background(), strings.NewReader("not an archive"), path); err == nil {
+		t.Errorf("Expected a broken archive to be rejected")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the imported index to remain, got %v", err)
+	}
+}
+
+func TestSynonyms(t *testing.T) {
+	docs := []string{
+		"package config\n\nfunc ParseConfig(cfg *Options) error { return loadCfg(cfg) }\n",
+		"package auth\n\ntype AuthenticationProvider struct{}\n",
+		"package server\n\nfunc Serve() {}\n",
+	}
+	for _, tt := range []struct {
+		query    string
+		expected []string
+	}{
+		{"where is the configuration parsed", []string{"ParseConfig", "cfg", "config", "loadCfg"}},
+		{"how does auth work", []string{"AuthenticationProvider"}},
+		{"Serve", nil},
+	} {
+		if got := synonyms(tt.query, docs, 0); !slices.Equal(got, tt.expected) {
+			t.Errorf("synonyms(%q) = %v, expected %v", tt.query, got, tt.expected)
+		}
+	}
+	if got := synonyms("configuration", docs, 1); len(got) != 1 {
+		t.Errorf("Expected the limit to apply, got %v", got)
+	}
+}
+
+func TestSearchExpanded(t *testing.T) {
+	ix, _ := buildIndex(t, map[string]string{
+		"load.go":  "package app\n\nfunc loadCfg(path string) (*Cfg, error) { return nil, nil }\n",
+		"serve.go": "package app\n\n// Serve answers the configuration endpoint of the server.\nfunc Serve() {}\n",
+	})
+	// Keyword scores only, so that the expansion decides the order
+	noVectors := func(_ context.Context, texts []string) ([][]float32, error) {
+		return make([][]float32, len(texts)), nil
+	}
+
+	results, err := ix.Search(context.Background(), noVectors, "bag", "configuration loading", 1)
+	if err != nil || len(results) != 1 || results[0].Path != "serve.go" {
+		t.Fatalf("Expected only serve.go to share the words of the query, got %+v, %v", results, err)
+	}
+	results, added, err := ix.SearchExpanded(context.Background(), noVectors, "bag", "configuration loading", 2)
+	if err != nil {
+		t.Fatalf("SearchExpanded failed: %v", err)
+	}
+	if !slices.Contains(added, "loadCfg") || !slices.Contains(added, "Cfg") {
+		t.Errorf("Expected the abbreviated identifiers to be added, got %v", added)
+	}
+	if len(results) != 2 || results[0].Keyword == 0 || results[1].Keyword == 0 {
+		t.Errorf("Expected both files to match the expanded keywords, got %+v", results)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b, c := Result{ID: 1, Path: "a.go"}, Result{ID: 2, Path: "b.go"}, Result{ID: 3, Path: "c.go"}
+	merged := Merge([][]Result{{a, b}, {c, b}, {b}}, 2)
+	if len(merged) != 2 || merged[0].ID != 2 || merged[1].ID != 1 {
+		t.Fatalf("Expected b.go, found by every search, then a.go, got %+v", merged)
+	}
+	if expected := 1.0/61 + 2.0/62; math.Abs(merged[0].Score-expected) > 1e-9 {
+		t.Errorf("Expected the fused score %f, got %f", expected, merged[0].Score)
+	}
+}
+
+func TestBlend(t *testing.T) {
+	results := []Result{
+		{ID: 1, Path: "a.go"}, {ID: 2, Path: "b.go"}, {ID: 3, Path: "c.go"}, {ID: 4, Path: "d.go"},
+		{ID: 5, Path: "README.md"}, {ID: 6, Path: "docs/design.md"},
+	}
+	ids := func(results []Result) []int64 {
+		var ids []int64
+		for _, r := range results {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	if blended := ids(Blend(results, 4)); !slices.Equal(blended, []int64{1, 2, 3, 5}) {
+		t.Errorf("Expected the best documentation in place of the worst code, got %v", blended)
+	}
+	if blended := ids(Blend(results, 8)); len(blended) != 6 {
+		t.Errorf("Expected every result under the limit, got %v", blended)
+	}
+	if blended := ids(Blend(results[:4], 2)); !slices.Equal(blended, []int64{1, 2}) {
+		t.Errorf("Expected code alone without documentation, got %v", blended)
+	}
+	if blended := ids(Blend(results, 1)); !slices.Equal(blended, []int64{1}) {
+		t.Errorf("Expected the best result alone, got %v", blended)
+	}
+}
+
+func TestIdentifiers(t *testing.T) {
+	known := func(name string) bool { return name == "Parse" }
+	got := Identifiers("How does Parse call parseHeader, `load` and Index.Search()? What is max_size, or a Parse?", known)
+	expected := []string{"Parse", "parseHeader", "load", "Index.Search", "max_size"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if got := Identifiers("How are files scanned?", nil); len(got) != 0 {
+		t.Errorf("Expected no identifiers in plain words, got %q", got)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	ix, _ := buildIndex(t, map[string]string{
+		"parse.go":  "package a\n\n// Parse parses.\nfunc Parse() {}\n",
+		"index.go":  "package a\n\ntype Index struct{}\n\nfunc (ix *Index) Search() {}\n",
+		"other.go":  "package a\n\ntype Other struct{}\n\nfunc (o *Other) Search() { Parse() }\n",
+		"main.go":   "package a\n\nfunc main() {\n\tParse()\n\tParse()\n\tvar ix Index\n\tix.Search()\n}\n",
+		"parser.py": "class Parser:\n    def parse(self):\n        pass\n",
+	})
+
+	definitions, references, err := ix.Lookup(context.Background(), "Parse", 0)
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if len(definitions) != 1 || definitions[0].Path != "parse.go" || !definitions[0].Definition || definitions[0].Symbol != "Parse" {
+		t.Errorf("Expected the definition in parse.go, got %+v", definitions)
+	}
+	if len(references) != 2 || references[0].Path != "main.go" || references[1].Path != "other.go" || references[0].Definition {
+		t.Errorf("Expected the references, most first, got %+v", references)
+	}
+	if _, references, _ := ix.Lookup(context.Background(), "Parse", 1); len(references) != 1 {
+		t.Errorf("Expected the references limited, got %+v", references)
+	}
+
+	definitions, references, err = ix.Lookup(context.Background(), "Index.Search", 0)
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if len(definitions) != 1 || definitions[0].Path != "index.go" {
+		t.Errorf("Expected the method of Index only, got %+v", definitions)
+	}
+	if len(references) != 1 || references[0].Path != "main.go" {
+		t.Errorf("Expected the call in main.go, got %+v", references)
+	}
+
+	if definitions, _, _ := ix.Lookup(context.Background(), "parse", 0); len(definitions) != 1 || definitions[0].Path != "parser.py" {
+		t.Errorf("Expected the Python method, got %+v", definitions)
+	}
+}
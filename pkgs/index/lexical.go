@@ -0,0 +1,102 @@
+package index
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters: bm25K1 saturates repeated terms and bm25B normalizes for
+// chunk length. These are the values commonly used for code and prose.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// rrfK damps the contribution of top ranks in reciprocal rank fusion, so
+// a chunk ranked well by both searches beats one ranked first by only one.
+const rrfK = 60
+
+// terms splits text into lowercase search terms. An identifier yields
+// itself and, if it is compound, its camelCase and snake_case parts, so
+// "GetSourceListOptions" matches both exactly and on "source" or "options".
+func terms(text string) []string {
+	var out []string
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		out = append(out, strings.ToLower(word))
+		if parts := splitIdentifier(word); len(parts) > 1 {
+			out = append(out, parts...)
+		}
+	}
+	return out
+}
+
+// splitIdentifier splits an identifier at underscores and case changes,
+// keeping acronyms together: "parseHTTPRequest" gives parse, http, request.
+func splitIdentifier(word string) []string {
+	var parts []string
+	for _, field := range strings.FieldsFunc(word, func(r rune) bool { return r == '_' }) {
+		runes := []rune(field)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			lowerToUpper := unicode.IsLower(runes[i-1]) && unicode.IsUpper(runes[i])
+			acronymEnd := i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i+1])
+			if lowerToUpper || acronymEnd {
+				parts = append(parts, strings.ToLower(string(runes[start:i])))
+				start = i
+			}
+		}
+		parts = append(parts, strings.ToLower(string(runes[start:])))
+	}
+	return parts
+}
+
+// bm25 scores every document in docs against query with Okapi BM25,
+// treating docs as the whole collection. Documents sharing no term with
+// the query score zero.
+func bm25(query string, docs []string) []float64 {
+	queryTerms := make(map[string]bool)
+	for _, term := range terms(query) {
+		queryTerms[term] = true
+	}
+
+	// Only the frequencies of query terms are needed
+	freqs := make([]map[string]int, len(docs))
+	lengths := make([]int, len(docs))
+	docFreq := make(map[string]int)
+	total := 0
+	for i, doc := range docs {
+		freqs[i] = make(map[string]int)
+		for _, term := range terms(doc) {
+			lengths[i]++
+			if queryTerms[term] {
+				freqs[i][term]++
+			}
+		}
+		for term := range freqs[i] {
+			docFreq[term]++
+		}
+		total += lengths[i]
+	}
+
+	scores := make([]float64, len(docs))
+	if total == 0 {
+		return scores
+	}
+	n := float64(len(docs))
+	avgLength := float64(total) / n
+	for term := range queryTerms {
+		idf := math.Log(1 + (n-float64(docFreq[term])+0.5)/(float64(docFreq[term])+0.5))
+		for i := range docs {
+			tf := float64(freqs[i][term])
+			if tf == 0 {
+				continue
+			}
+			norm := 1 - bm25B + bm25B*float64(lengths[i])/avgLength
+			scores[i] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+	return scores
+}
@@ -0,0 +1,255 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/JackDrogon/aicodereader/pkgs/lang"
+)
+
+// Result is a chunk matching a search query.
+type Result struct {
+	// ID identifies the chunk in the index. Chunks of a file get new IDs
+	// when it is indexed again.
+	ID int64
+	// Path is relative to the indexed root, with forward slashes.
+	Path string
+	// FileHash is the SHA-256 of the file content the chunk was indexed
+	// from, in hex, empty if the index holds no record of the file.
+	FileHash  string
+	StartLine int
+	EndLine   int
+	Content   string
+	// Score ranks results: the reciprocal rank fusion of the chunk's ranks
+	// by embedding similarity and by BM25 keyword relevance.
+	Score float64
+	// Similarity is the cosine similarity between the query and the chunk.
+	Similarity float32
+	// Keyword is the BM25 score of the chunk for the query, zero if they
+	// share no term.
+	Keyword float64
+	// Symbol is the identifier the chunk defines or refers to, for chunks
+	// found by Lookup rather than by search.
+	Symbol string
+	// Definition is set for chunks defining Symbol.
+	Definition bool
+}
+
+// Lines returns the lines StartLine to EndLine of the chunk. Chunks of Go
+// files repeat the package clause and imports before those lines, which
+// Content includes and Lines drops.
+func (r Result) Lines() string {
+	lines := strings.SplitAfter(strings.TrimRight(r.Content, "\n"), "\n")
+	if extra := len(lines) - (r.EndLine - r.StartLine + 1); extra > 0 {
+		lines = lines[extra:]
+	}
+	return strings.Join(lines, "") + "\n"
+}
+
+// Documentation reports whether the chunk comes from documentation, such as
+// a README or a file under docs/, rather than from code.
+func (r Result) Documentation() bool {
+	return lang.IsDocumentation(r.Path)
+}
+
+// Search returns the limit chunks most relevant to query, best first. It
+// combines embedding similarity, which finds code by meaning, with BM25
+// keyword scoring, which finds exact identifiers that embeddings blur, by
+// reciprocal rank fusion. embed must use the model the index was built
+// with, named by model.
+func (ix *Index) Search(ctx context.Context, embed EmbedFunc, model, query string, limit int) ([]Result, error) {
+	results, _, err := ix.search(ctx, embed, model, query, limit, false)
+	return results, err
+}
+
+// SearchExpanded is Search with the keywords of query expanded by indexed
+// identifiers related to its words, such as type names containing them and
+// their common abbreviations, so that keyword search finds code naming a
+// concept differently than the question does. The query is embedded as
+// given. It returns the identifiers added.
+func (ix *Index) SearchExpanded(ctx context.Context, embed EmbedFunc, model, query string, limit int) ([]Result, []string, error) {
+	return ix.search(ctx, embed, model, query, limit, true)
+}
+
+// search implements Search and SearchExpanded.
+func (ix *Index) search(ctx context.Context, embed EmbedFunc, model, query string, limit int, expand bool) ([]Result, []string, error) {
+	indexed, err := ix.meta("model")
+	if err != nil {
+		return nil, nil, err
+	}
+	if indexed != model {
+		return nil, nil, fmt.Errorf("index was built with embedding model %q, not %q; rebuild it or switch models", indexed, model)
+	}
+
+	vectors, err := embed(ctx, []string{query})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, nil, fmt.Errorf("expected 1 embedding, got %d", len(vectors))
+	}
+	queryVector := vectors[0]
+
+	rows, err := ix.db.QueryContext(ctx, `SELECT chunks.id, chunks.path, COALESCE(files.hash, ''), start_line, end_line, content, embedding
+		FROM chunks LEFT JOIN files ON files.path = chunks.path`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var results []Result
+	for rows.Next() {
+		var r Result
+		var embedding []byte
+		if err := rows.Scan(&r.ID, &r.Path, &r.FileHash, &r.StartLine, &r.EndLine, &r.Content, &embedding); err != nil {
+			return nil, nil, err
+		}
+		r.Similarity = cosine(queryVector, decodeVector(embedding))
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	docs := make([]string, len(results))
+	for i, r := range results {
+		docs[i] = embeddingText(r.Path, r.Content)
+	}
+	keywords := query
+	var added []string
+	if expand {
+		added = synonyms(query, docs, maxSynonyms)
+		keywords = strings.Join(append([]string{query}, added...), " ")
+	}
+	for i, score := range bm25(keywords, docs) {
+		results[i].Keyword = score
+	}
+
+	fuse(results)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, added, nil
+}
+
+// Merge combines the results of several searches, each best first, into
+// the limit chunks ranked best across them by reciprocal rank fusion, all if
+// limit is zero. A chunk found by several searches keeps the result of the
+// first search that found it, with Score replaced by the fused score.
+func Merge(lists [][]Result, limit int) []Result {
+	best := make(map[int64]Result)
+	scores := make(map[int64]float64)
+	var order []int64
+	for _, results := range lists {
+		for rank, r := range results {
+			if _, ok := best[r.ID]; !ok {
+				best[r.ID] = r
+				order = append(order, r.ID)
+			}
+			scores[r.ID] += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	merged := make([]Result, 0, len(order))
+	for _, id := range order {
+		r := best[id]
+		r.Score = scores[id]
+		merged = append(merged, r)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Score > merged[j].Score })
+	if limit > 0 && len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+// Blend returns the first limit of results, ranked best first, with at least
+// a quarter of them from documentation and a quarter from code when results
+// hold enough of both, so answers can draw on what the code does and on what
+// its documentation says. The best results of the type falling short replace
+// the worst of the other; the order of results is kept. All results are
+// returned if limit is zero.
+func Blend(results []Result, limit int) []Result {
+	if limit <= 0 || len(results) <= limit {
+		return results
+	}
+	reserve := max(1, limit/4)
+	picked := make([]bool, len(results))
+	counts := make(map[bool]int)
+	for i := range limit {
+		picked[i] = true
+		counts[results[i].Documentation()]++
+	}
+	for _, doc := range []bool{true, false} {
+		for i := limit; i < len(results) && counts[doc] < reserve && counts[!doc] > reserve; i++ {
+			if results[i].Documentation() != doc {
+				continue
+			}
+			for j := len(results) - 1; j >= 0; j-- {
+				if picked[j] && results[j].Documentation() != doc {
+					picked[j] = false
+					break
+				}
+			}
+			picked[i] = true
+			counts[doc]++
+			counts[!doc]--
+		}
+	}
+
+	blended := make([]Result, 0, limit)
+	for i, r := range results {
+		if picked[i] {
+			blended = append(blended, r)
+		}
+	}
+	return blended
+}
+
+// fuse sets the Score of results by reciprocal rank fusion of their ranks by
+// Similarity and, for results sharing a term with the query, by Keyword.
+func fuse(results []Result) {
+	addRanks := func(better func(a, b Result) bool, include func(r Result) bool) {
+		order := make([]int, 0, len(results))
+		for i, r := range results {
+			if include(r) {
+				order = append(order, i)
+			}
+		}
+		sort.SliceStable(order, func(i, j int) bool { return better(results[order[i]], results[order[j]]) })
+		// Ties share the best rank among them, so the order chunks were
+		// stored in does not break ties
+		rank := 0
+		for position, i := range order {
+			if position > 0 && better(results[order[position-1]], results[i]) {
+				rank = position
+			}
+			results[i].Score += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	addRanks(func(a, b Result) bool { return a.Similarity > b.Similarity }, func(Result) bool { return true })
+	addRanks(func(a, b Result) bool { return a.Keyword > b.Keyword }, func(r Result) bool { return r.Keyword > 0 })
+}
+
+// cosine returns the cosine similarity of a and b, zero if their lengths
+// differ or either is a zero vector.
+func cosine(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / math.Sqrt(normA*normB))
+}
@@ -0,0 +1,115 @@
+package index
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// definitionKeywords introduce the declarations of the indexed languages:
+// Go functions, methods and types, Python functions and classes, JavaScript
+// and TypeScript declarations, Rust items and JVM types.
+const definitionKeywords = `(?:export\s+(?:default\s+)?)?(?:pub(?:\([\w:]+\))?\s+)?(?:async\s+)?` +
+	`(?:func(?:\s*\([^)]*\))?|def|class|function\*?|fn|struct|enum|trait|interface|type|const|let|var|static|record|mod)`
+
+// identifierPattern matches the words of a question that may name code,
+// including qualified ones such as Index.Search.
+var identifierPattern = regexp.MustCompile("`?[A-Za-z_]\\w*(?:\\.[A-Za-z_]\\w*)?(?:`|\\(\\))?")
+
+// Identifiers returns the words of question naming code, in order: those
+// known is true for, such as the symbols of a repository map, and those
+// shaped like identifiers, quoted in backticks, called with "()", or
+// written in camelCase, PascalCase or snake_case. Lookup finds where they
+// are defined.
+func Identifiers(question string, known func(name string) bool) []string {
+	var identifiers []string
+	seen := make(map[string]bool)
+	for _, word := range identifierPattern.FindAllString(question, -1) {
+		quoted := strings.HasPrefix(word, "`") && strings.HasSuffix(word, "`") && len(word) > 2
+		called := strings.HasSuffix(word, "()")
+		name := strings.TrimSuffix(strings.Trim(word, "`"), "()")
+		if seen[name] {
+			continue
+		}
+		if quoted || called || (known != nil && known(name)) || identifierShaped(name) {
+			seen[name] = true
+			identifiers = append(identifiers, name)
+		}
+	}
+	return identifiers
+}
+
+// identifierShaped reports whether name looks like an identifier rather
+// than a word: qualified, with an underscore, or with an upper case letter
+// after a lower case one, as in parseConfig and ParseConfig.
+func identifierShaped(name string) bool {
+	if strings.Contains(name, ".") || strings.Contains(strings.Trim(name, "_"), "_") {
+		return true
+	}
+	runes := []rune(name)
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the chunks defining name, an identifier such as Parse or a
+// method qualified by its type such as Index.Search, and up to limit chunks
+// referring to it, those naming it most often first, all if limit is zero.
+// Results have Symbol set to name and, for definitions, Definition.
+func (ix *Index) Lookup(ctx context.Context, name string, limit int) ([]Result, []Result, error) {
+	owner, member, qualified := strings.Cut(name, ".")
+	if !qualified {
+		owner, member = "", name
+	}
+	definition := regexp.MustCompile(`(?m)^\s*` + definitionKeywords + `\s+` + regexp.QuoteMeta(member) + `\b`)
+	reference := regexp.MustCompile(`\b` + regexp.QuoteMeta(member) + `\b`)
+	if qualified {
+		reference = regexp.MustCompile(`\.` + regexp.QuoteMeta(member) + `\b`)
+	}
+	var ownerWord *regexp.Regexp
+	if qualified {
+		ownerWord = regexp.MustCompile(`\b` + regexp.QuoteMeta(owner) + `\b`)
+	}
+
+	rows, err := ix.db.QueryContext(ctx, `SELECT chunks.id, chunks.path, COALESCE(files.hash, ''), start_line, end_line, content
+		FROM chunks LEFT JOIN files ON files.path = chunks.path
+		WHERE instr(content, ?) > 0 ORDER BY chunks.path, start_line`, member)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var definitions, references []Result
+	counts := make(map[int64]int)
+	for rows.Next() {
+		r := Result{Symbol: name}
+		if err := rows.Scan(&r.ID, &r.Path, &r.FileHash, &r.StartLine, &r.EndLine, &r.Content); err != nil {
+			return nil, nil, err
+		}
+		lines := r.Lines()
+		// A method of another type is a reference to the member at most
+		if definition.MatchString(lines) && (!qualified || ownerWord.MatchString(lines)) {
+			r.Definition = true
+			definitions = append(definitions, r)
+			continue
+		}
+		if n := len(reference.FindAllStringIndex(lines, -1)); n > 0 {
+			counts[r.ID] = n
+			references = append(references, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	sort.SliceStable(references, func(i, j int) bool { return counts[references[i].ID] > counts[references[j].ID] })
+	if limit > 0 && len(references) > limit {
+		references = references[:limit]
+	}
+	return definitions, references, nil
+}
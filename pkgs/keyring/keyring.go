@@ -0,0 +1,99 @@
+// Package keyring stores secrets, such as API keys, in the system keyring
+// through the tools the system ships with: security on macOS and
+// secret-tool, from libsecret, on Linux and other Unix systems. No keyring
+// is available elsewhere, or where the tool is not installed.
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrUnavailable is returned when the system has no keyring tool.
+var ErrUnavailable = errors.New("no system keyring is available")
+
+// ErrNotFound is returned by Get when the keyring holds no such secret.
+var ErrNotFound = errors.New("secret not found in the system keyring")
+
+// run runs a keyring tool with stdin as its input and returns its output.
+// Tests replace it.
+var run = func(stdin, name string, args ...string) (string, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", fmt.Errorf("%w: %s is not installed", ErrUnavailable, name)
+	}
+	cmd := exec.Command(name, args...) // #nosec G204 -- fixed tools
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", &toolError{name: name, code: exitErr.ExitCode(), stderr: strings.TrimSpace(stderr.String())}
+		}
+		return "", err
+	}
+	return string(output), nil
+}
+
+// toolError is a keyring tool exiting with an error.
+type toolError struct {
+	name   string
+	code   int
+	stderr string
+}
+
+func (e *toolError) Error() string {
+	if e.stderr == "" {
+		return fmt.Sprintf("%s exited with status %d", e.name, e.code)
+	}
+	return fmt.Sprintf("%s: %s", e.name, e.stderr)
+}
+
+// Set stores secret for account under service, replacing the one stored
+// before. On macOS the secret is passed to security as an argument, which
+// other processes of the user can see while it runs.
+func Set(service, account, secret string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		_, err := run("", "security", "add-generic-password", "-U", "-s", service, "-a", account, "-w", secret)
+		return err
+	case "windows", "plan9", "js", "wasip1":
+		return ErrUnavailable
+	default:
+		_, err := run(secret, "secret-tool", "store", "--label="+service+" "+account, "service", service, "account", account)
+		return err
+	}
+}
+
+// Get returns the secret stored for account under service.
+func Get(service, account string) (string, error) {
+	var output string
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		output, err = run("", "security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "windows", "plan9", "js", "wasip1":
+		return "", ErrUnavailable
+	default:
+		output, err = run("", "secret-tool", "lookup", "service", service, "account", account)
+	}
+	// security exits with status 44 when nothing matches, secret-tool with
+	// status 1 and no message
+	var toolErr *toolError
+	if errors.As(err, &toolErr) && (toolErr.code == 44 || toolErr.code == 1 && toolErr.stderr == "") {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSuffix(output, "\n")
+	if secret == "" {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
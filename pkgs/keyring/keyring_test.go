@@ -0,0 +1,59 @@
+// nolint:testpackage
+package keyring
+
+import (
+	"errors"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// fakeKeyring replaces the keyring tools with an in-memory store of the
+// secrets passed to secret-tool, and restores them when the test ends.
+func fakeKeyring(t *testing.T) map[string]string {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("the fake keyring speaks secret-tool")
+	}
+	secrets := make(map[string]string)
+	saved := run
+	t.Cleanup(func() { run = saved })
+	run = func(stdin, name string, args ...string) (string, error) {
+		if name != "secret-tool" {
+			t.Fatalf("Unexpected tool %s", name)
+		}
+		key := args[len(args)-3] + "/" + args[len(args)-1]
+		switch args[0] {
+		case "store":
+			if !slices.Contains(args, "--label=aicodereader openai") {
+				t.Errorf("Expected a label, got %q", args)
+			}
+			secrets[key] = stdin
+			return "", nil
+		case "lookup":
+			if secret, ok := secrets[key]; ok {
+				return secret, nil
+			}
+			return "", &toolError{name: name, code: 1}
+		}
+		return "", &toolError{name: name, code: 2, stderr: "unknown command"}
+	}
+	return secrets
+}
+
+func TestSetGet(t *testing.T) {
+	secrets := fakeKeyring(t)
+	if _, err := Get("aicodereader", "openai"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := Set("aicodereader", "openai", "sk-test"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if secrets["aicodereader/openai"] != "sk-test" {
+		t.Errorf("Expected the secret passed on stdin, got %q", secrets)
+	}
+	secret, err := Get("aicodereader", "openai")
+	if err != nil || secret != "sk-test" {
+		t.Errorf("Expected the stored secret, got %q, %v", secret, err)
+	}
+}
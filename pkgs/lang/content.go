@@ -0,0 +1,83 @@
+package lang
+
+import (
+	"regexp"
+	"strings"
+)
+
+// contentSignature recognizes a language from characteristic source text.
+type contentSignature struct {
+	language string
+	pattern  *regexp.Regexp
+}
+
+// contentSignatures are checked in order; more specific languages come first
+// so that, for example, C++ is not reported as C.
+var contentSignatures = []contentSignature{
+	{"Go", regexp.MustCompile(`(?m)^package \w+\s*$|^func (\(\w+ \*?\w+\) )?\w+\(|:= `)},
+	{"Rust", regexp.MustCompile(`(?m)^\s*(pub )?fn \w+|let mut |impl\b.*\{|use \w+::`)},
+	{"C++", regexp.MustCompile(`(?m)^#include <(iostream|vector|string|memory)>|std::|template\s*<`)},
+	{"C", regexp.MustCompile(`(?m)^#include [<"]|^int main\(`)},
+	{"Java", regexp.MustCompile(`(?m)^\s*(public|private|protected) (static )?(final )?(class|void|interface)\b|System\.out\.`)},
+	{"Python", regexp.MustCompile(`(?m)^\s*def \w+\(.*\):|^\s*(from \w+ )?import \w+\s*$|^\s*class \w+(\(.*\))?:\s*$|print\(`)},
+	{"TypeScript", regexp.MustCompile(`(?m)^\s*(export )?(interface|type) \w+ *[={]|: (string|number|boolean)\b`)},
+	{"JavaScript", regexp.MustCompile(`(?m)\bfunction\s*\w*\(|=> |^\s*(const|let|var) \w+ =|console\.log\(|require\(`)},
+	{"SQL", regexp.MustCompile(`(?i)^\s*(select|insert|update|delete|create|alter|with)\b`)},
+	{"Shell", regexp.MustCompile(`(?m)^\s*(echo|export|if \[|for \w+ in) |\$\{?\w+\}?`)},
+}
+
+// diffPattern matches unified diff headers, including git diff output.
+var diffPattern = regexp.MustCompile(`(?m)^diff --git |^--- \S.*\n\+\+\+ \S|^@@ -\d+(,\d+)? \+\d+(,\d+)? @@`)
+
+// interpreters maps shebang interpreter names to languages.
+var interpreters = map[string]string{
+	"sh":      "Shell",
+	"bash":    "Shell",
+	"zsh":     "Shell",
+	"python":  "Python",
+	"python3": "Python",
+	"node":    "JavaScript",
+	"ruby":    "Ruby",
+	"lua":     "Lua",
+	"perl":    "Perl",
+	"php":     "PHP",
+}
+
+// FromContent guesses the language of an unnamed snippet from a shebang line or characteristic syntax. Unified diffs are
+// reported as "Diff" whatever language they change. It returns an empty
+// string if no language is recognized.
+func FromContent(content string) string {
+	if lang := shebangLanguage(content); lang != "" {
+		return lang
+	}
+	if diffPattern.MatchString(content) {
+		return "Diff"
+	}
+
+	for _, signature := range contentSignatures {
+		if signature.pattern.MatchString(content) {
+			return signature.language
+		}
+	}
+	return ""
+}
+
+// shebangLanguage returns the language named by a "#!" first line, if any.
+func shebangLanguage(content string) string {
+	line, ok := strings.CutPrefix(content, "#!")
+	if !ok {
+		return ""
+	}
+	line, _, _ = strings.Cut(line, "\n")
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	// "#!/usr/bin/env python3" names the interpreter in the second field
+	interpreter := fields[0][strings.LastIndex(fields[0], "/")+1:]
+	if interpreter == "env" && len(fields) > 1 {
+		interpreter = fields[1]
+	}
+	return interpreters[interpreter]
+}
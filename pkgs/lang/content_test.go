@@ -0,0 +1,34 @@
+// nolint:testpackage
+package lang
+
+import (
+	"testing"
+)
+
+func TestFromContent(t *testing.T) {
+	cases := map[string]string{
+		"package main\n\nfunc main() {}\n":                       "Go",
+		"x := compute()\n":                                       "Go",
+		"fn main() {\n    let mut v = Vec::new();\n}\n":          "Rust",
+		"#include <iostream>\nint main() { std::cout << 1; }\n":  "C++",
+		"#include <stdio.h>\nint main(void) { return 0; }\n":     "C",
+		"public class Foo {\n}\n":                                "Java",
+		"def add(a, b):\n    return a + b\n":                     "Python",
+		"interface User {\n  name: string\n}\n":                  "TypeScript",
+		"const add = (a, b) => a + b;\n":                         "JavaScript",
+		"SELECT id, name FROM users WHERE id = 1;":               "SQL",
+		"#!/usr/bin/env python3\nx = 1\n":                        "Python",
+		"#!/bin/bash\nset -e\n":                                  "Shell",
+		"echo $HOME\n":                                           "Shell",
+		"diff --git a/main.go b/main.go\n+x := 1\n":              "Diff",
+		"--- a/f.py\n+++ b/f.py\n@@ -1 +1 @@\n-a\n+b\n":          "Diff",
+		"just some prose without any code in it":                 "",
+		"#!/usr/bin/env unknown-interpreter\nplain words here\n": "",
+	}
+
+	for content, expected := range cases {
+		if got := FromContent(content); got != expected {
+			t.Errorf("FromContent(%q) = %q, expected %q", content, got, expected)
+		}
+	}
+}
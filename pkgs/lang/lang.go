@@ -0,0 +1,153 @@
+// Package lang detects the programming language of source files from their
+// names, shebang lines and, for unnamed snippets, characteristic syntax.
+// Languages are identified by display names such as "Go" or "TypeScript";
+// the empty string means unknown.
+package lang
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// languagesByExt maps file extensions to language names.
+var languagesByExt = map[string]string{
+	".c":      "C",
+	".h":      "C",
+	".cc":     "C++",
+	".cpp":    "C++",
+	".cxx":    "C++",
+	".hpp":    "C++",
+	".cs":     "C#",
+	".go":     "Go",
+	".java":   "Java",
+	".js":     "JavaScript",
+	".jsx":    "JavaScript",
+	".mjs":    "JavaScript",
+	".cjs":    "JavaScript",
+	".ts":     "TypeScript",
+	".tsx":    "TypeScript",
+	".py":     "Python",
+	".pyi":    "Python",
+	".rb":     "Ruby",
+	".rs":     "Rust",
+	".php":    "PHP",
+	".pl":     "Perl",
+	".swift":  "Swift",
+	".kt":     "Kotlin",
+	".kts":    "Kotlin",
+	".scala":  "Scala",
+	".lua":    "Lua",
+	".sh":     "Shell",
+	".bash":   "Shell",
+	".zsh":    "Shell",
+	".sql":    "SQL",
+	".md":     "Markdown",
+	".yaml":   "YAML",
+	".yml":    "YAML",
+	".json":   "JSON",
+	".toml":   "TOML",
+	".hcl":    "HCL",
+	".tf":     "HCL",
+	".tfvars": "HCL",
+	".html":   "HTML",
+	".css":    "CSS",
+	".proto":  "Protobuf",
+	".diff":   "Diff",
+	".patch":  "Diff",
+}
+
+// languagesByName maps well-known extensionless file names to language names.
+var languagesByName = map[string]string{
+	"Makefile":    "Makefile",
+	"GNUmakefile": "Makefile",
+	"Dockerfile":  "Dockerfile",
+	"Gemfile":     "Ruby",
+	"Rakefile":    "Ruby",
+}
+
+// FromPath guesses the language of path from its name. It returns an empty
+// string if the language is unknown.
+func FromPath(path string) string {
+	base := filepath.Base(path)
+	if lang, ok := languagesByName[base]; ok {
+		return lang
+	}
+	return languagesByExt[strings.ToLower(filepath.Ext(base))]
+}
+
+// Detect guesses the language of the file at path with the given content,
+// from its name or, failing that, its shebang line. Only the start of the
+// content is needed. Syntax is not considered, since a named file with an
+// unknown extension is more often data or prose than code.
+func Detect(path string, content []byte) string {
+	if lang := FromPath(path); lang != "" {
+		return lang
+	}
+	return shebangLanguage(string(content))
+}
+
+// Extension returns the alphabetically first extension of lang, such as
+// ".go", or an empty string if lang has none.
+func Extension(lang string) string {
+	ext := ""
+	for candidate, candidateLang := range languagesByExt {
+		if candidateLang == lang && lang != "" && (ext == "" || candidate < ext) {
+			ext = candidate
+		}
+	}
+	return ext
+}
+
+// documentationExts are the extensions of prose markup.
+var documentationExts = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".rst":      true,
+	".adoc":     true,
+}
+
+// documentationNames are the names, without extension, of the prose files
+// repositories keep at their top.
+var documentationNames = map[string]bool{
+	"README":          true,
+	"CHANGELOG":       true,
+	"CHANGES":         true,
+	"HISTORY":         true,
+	"CONTRIBUTING":    true,
+	"CODE_OF_CONDUCT": true,
+	"SECURITY":        true,
+	"AUTHORS":         true,
+	"NOTICE":          true,
+	"LICENSE":         true,
+}
+
+// documentationDirs are the directories holding documentation, such as
+// architecture decision records.
+var documentationDirs = map[string]bool{
+	"doc":       true,
+	"docs":      true,
+	"adr":       true,
+	"adrs":      true,
+	"decisions": true,
+}
+
+// IsDocumentation reports whether path is documentation rather than code or
+// data: prose markup such as Markdown, files such as README and CHANGELOG of
+// any extension, and files of no known language, such as plain text, under
+// a doc, docs or adr directory.
+func IsDocumentation(path string) bool {
+	base := filepath.Base(path)
+	ext := strings.ToLower(filepath.Ext(base))
+	if documentationExts[ext] || documentationNames[strings.ToUpper(strings.TrimSuffix(base, filepath.Ext(base)))] {
+		return true
+	}
+	if FromPath(path) != "" {
+		return false
+	}
+	for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		if documentationDirs[strings.ToLower(dir)] {
+			return true
+		}
+	}
+	return false
+}
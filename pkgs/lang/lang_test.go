@@ -0,0 +1,84 @@
+// nolint:testpackage
+package lang
+
+import (
+	"testing"
+)
+
+func TestFromPath(t *testing.T) {
+	cases := map[string]string{
+		"main.go":            "Go",
+		"src/app/index.TSX":  "TypeScript",
+		"scripts/run.sh":     "Shell",
+		"Makefile":           "Makefile",
+		"build/Dockerfile":   "Dockerfile",
+		"Gemfile":            "Ruby",
+		"infra/main.tf":      "HCL",
+		"LICENSE":            "",
+		"archive.unknownext": "",
+	}
+
+	for path, expected := range cases {
+		if got := FromPath(path); got != expected {
+			t.Errorf("FromPath(%q) = %q, expected %q", path, got, expected)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		path     string
+		content  string
+		expected string
+	}{
+		{"main.go", "#!/bin/sh\n", "Go"},
+		{"bin/deploy", "#!/usr/bin/env bash\nset -e\n", "Shell"},
+		{"tools/gen", "#!/usr/bin/python3\nimport sys\n", "Python"},
+		// Syntax alone does not make a named file code
+		{"NOTES", "echo $HOME\nx := 1\n", ""},
+	}
+
+	for _, c := range cases {
+		if got := Detect(c.path, []byte(c.content)); got != c.expected {
+			t.Errorf("Detect(%q, %q) = %q, expected %q", c.path, c.content, got, c.expected)
+		}
+	}
+}
+
+func TestExtension(t *testing.T) {
+	cases := map[string]string{
+		"Go":         ".go",
+		"Diff":       ".diff",
+		"TypeScript": ".ts",
+		"Makefile":   "",
+		"":           "",
+	}
+
+	for language, expected := range cases {
+		if got := Extension(language); got != expected {
+			t.Errorf("Extension(%q) = %q, expected %q", language, got, expected)
+		}
+	}
+}
+
+func TestIsDocumentation(t *testing.T) {
+	cases := map[string]bool{
+		"README":                          true,
+		"readme.rst":                      true,
+		"docs/guide/setup.markdown":       true,
+		"docs/adr/0001-use-sqlite.txt":    true,
+		"CHANGELOG.txt":                   true,
+		"LICENSE":                         true,
+		"requirements.txt":                false,
+		"docs/conf.py":                    false,
+		"docs/examples/config.yaml":       false,
+		"pkgs/chunker/golang.go":          false,
+		"cmd/aicodereader/testdata/a.txt": false,
+	}
+
+	for path, expected := range cases {
+		if got := IsDocumentation(path); got != expected {
+			t.Errorf("IsDocumentation(%q) = %v, expected %v", path, got, expected)
+		}
+	}
+}
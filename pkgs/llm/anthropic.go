@@ -0,0 +1,317 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultAnthropicBaseURL is the Anthropic API endpoint used when no base URL is configured.
+	DefaultAnthropicBaseURL = "https://api.anthropic.com"
+
+	// anthropicVersion is the API version sent with every request.
+	anthropicVersion = "2023-06-01"
+
+	// defaultAnthropicMaxTokens is used when a request does not set MaxTokens,
+	// since the Messages API requires an explicit limit.
+	defaultAnthropicMaxTokens = 4096
+)
+
+// AnthropicProvider talks to the Anthropic Messages API.
+type AnthropicProvider struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+// NewAnthropicProvider creates a provider for the Anthropic API at baseURL.
+// An empty baseURL uses DefaultAnthropicBaseURL.
+func NewAnthropicProvider(apiKey, baseURL string) *AnthropicProvider {
+	if baseURL == "" {
+		baseURL = DefaultAnthropicBaseURL
+	}
+
+	return &AnthropicProvider{
+		apiKey:  apiKey,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  http.DefaultClient,
+	}
+}
+
+// anthropicMessage is a message in the Messages API format.
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// anthropicRequest is the Messages API request body.
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	MaxTokens     int                `json:"max_tokens"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	Temperature   float32            `json:"temperature,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Thinking      *anthropicThinking `json:"thinking,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+}
+
+// anthropicThinking enables extended thinking with a token budget.
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// anthropicContentBlock is a content block in responses and stream events.
+type anthropicContentBlock struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Thinking string `json:"thinking"`
+}
+
+// anthropicUsage is the token usage reported by the Messages API.
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicResponse is the Messages API response body.
+type anthropicResponse struct {
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      anthropicUsage          `json:"usage"`
+}
+
+// anthropicStopRefusal is the stop reason reported when Claude declines to answer.
+const anthropicStopRefusal = "refusal"
+
+// anthropicError is the error body returned by the Messages API.
+type anthropicError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Complete implements Provider.
+func (p *AnthropicProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	httpResp, err := p.send(ctx, req, false)
+	if err != nil {
+		return Response{}, err
+	}
+	defer httpResp.Body.Close()
+
+	var resp anthropicResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("failed to decode anthropic response: %w", err)
+	}
+
+	var content, reasoning strings.Builder
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "thinking":
+			reasoning.WriteString(block.Thinking)
+		}
+	}
+
+	if resp.StopReason == anthropicStopRefusal {
+		return Response{}, &ContentFilterError{
+			Provider: ProviderAnthropic,
+			Stage:    FilterStageAnswer,
+			Message:  content.String(),
+		}
+	}
+
+	return Response{
+		Content:          content.String(),
+		ReasoningContent: reasoning.String(),
+		Usage: Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+// Stream implements Provider.
+func (p *AnthropicProvider) Stream(ctx context.Context, req Request) (Stream, error) {
+	httpResp, err := p.send(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &anthropicStream{events: newSSEReader(httpResp.Body)}, nil
+}
+
+// CountTokens implements Provider.
+func (p *AnthropicProvider) CountTokens(messages []Message) int {
+	return EstimateTokens(messages)
+}
+
+// send posts req to the Messages API and returns the successful HTTP response.
+func (p *AnthropicProvider) send(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	body, err := json.Marshal(toAnthropicRequest(req, stream))
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Api-Key", p.apiKey)
+	httpReq.Header.Set("Anthropic-Version", anthropicVersion)
+
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		defer httpResp.Body.Close()
+		return nil, decodeAnthropicError(httpResp)
+	}
+	return httpResp, nil
+}
+
+// decodeAnthropicError builds an error from a non-200 Messages API response.
+func decodeAnthropicError(resp *http.Response) error {
+	data, _ := io.ReadAll(resp.Body)
+
+	var apiErr anthropicError
+	if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+		return fmt.Errorf("anthropic API error (status %d, %s): %s",
+			resp.StatusCode, apiErr.Error.Type, apiErr.Error.Message)
+	}
+	return fmt.Errorf("anthropic API error (status %d): %s", resp.StatusCode, strings.TrimSpace(string(data)))
+}
+
+// toAnthropicRequest converts req to the Messages API format. System messages
+// are lifted into the top-level system field as the API requires.
+func toAnthropicRequest(req Request, stream bool) anthropicRequest {
+	out := anthropicRequest{
+		Model:         req.Model,
+		MaxTokens:     req.MaxTokens,
+		Temperature:   req.Temperature,
+		StopSequences: req.Stop,
+		Stream:        stream,
+	}
+	if out.MaxTokens == 0 {
+		out.MaxTokens = defaultAnthropicMaxTokens
+	}
+
+	// Extended thinking counts towards max_tokens, which must exceed the
+	// budget, and does not allow a custom temperature
+	if budget := req.thinkingBudget(); budget > 0 {
+		out.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: budget}
+		if out.MaxTokens <= budget {
+			out.MaxTokens = budget + defaultAnthropicMaxTokens
+		}
+		out.Temperature = 0
+	}
+
+	var system []string
+	for _, message := range req.Messages {
+		if message.Role == RoleSystem {
+			system = append(system, message.Content)
+			continue
+		}
+		out.Messages = append(out.Messages, anthropicMessage{
+			Role:    string(message.Role),
+			Content: message.Content,
+		})
+	}
+	out.System = strings.Join(system, "\n\n")
+
+	return out
+}
+
+// anthropicStreamEvent is a server-sent event payload from the Messages API.
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicStream adapts a Messages API event stream to Stream.
+type anthropicStream struct {
+	events *sseReader
+	// inputTokens is reported by message_start and output tokens by
+	// message_delta; they are combined into one UsageEvent.
+	inputTokens int
+}
+
+// Recv implements Stream.
+func (s *anthropicStream) Recv() (Event, error) {
+	for {
+		data, err := s.events.next()
+		if err != nil {
+			return Event{}, err
+		}
+
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return Event{}, fmt.Errorf("failed to decode anthropic stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			s.inputTokens = event.Message.Usage.InputTokens
+		case "content_block_start":
+			if block := event.ContentBlock; block.Type == "tool_use" {
+				return Event{Type: ToolCallDelta, ToolCall: ToolCall{Index: event.Index, ID: block.ID, Name: block.Name}}, nil
+			}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				return Event{Type: ContentDelta, Text: event.Delta.Text}, nil
+			case "thinking_delta":
+				return Event{Type: ReasoningDelta, Text: event.Delta.Thinking}, nil
+			case "input_json_delta":
+				return Event{Type: ToolCallDelta, ToolCall: ToolCall{Index: event.Index, Arguments: event.Delta.PartialJSON}}, nil
+			}
+		case "message_delta":
+			if event.Delta.StopReason == anthropicStopRefusal {
+				return Event{}, &ContentFilterError{Provider: ProviderAnthropic, Stage: FilterStageAnswer}
+			}
+			return Event{Type: UsageEvent, Usage: Usage{
+				PromptTokens:     s.inputTokens,
+				CompletionTokens: event.Usage.OutputTokens,
+			}}, nil
+		case "message_stop":
+			return Event{}, io.EOF
+		case "error":
+			return Event{}, fmt.Errorf("anthropic stream error (%s): %s", event.Error.Type, event.Error.Message)
+		}
+	}
+}
+
+// Close implements Stream.
+func (s *anthropicStream) Close() error {
+	return s.events.Close()
+}
@@ -0,0 +1,163 @@
+// nolint:testpackage
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newAnthropicTestServer serves handler under the Messages API path.
+func newAnthropicTestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/messages", handler)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAnthropicProviderComplete(t *testing.T) {
+	var got anthropicRequest
+	server := newAnthropicTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("X-Api-Key"); key != "test-key" {
+			t.Errorf("Expected x-api-key header, got %q", key)
+		}
+		if version := r.Header.Get("Anthropic-Version"); version != anthropicVersion {
+			t.Errorf("Expected anthropic-version header, got %q", version)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"content":[{"type":"thinking","thinking":"let me see"},{"type":"text","text":"answer"}],
+			"usage":{"input_tokens":20,"output_tokens":5}}`)
+	})
+
+	provider := NewAnthropicProvider("test-key", server.URL+"/")
+	resp, err := provider.Complete(context.Background(), Request{
+		Model: "claude-test",
+		Messages: []Message{
+			{Role: RoleSystem, Content: "system"},
+			{Role: RoleUser, Content: "question"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	if resp.Content != "answer" || resp.ReasoningContent != "let me see" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if resp.Usage.PromptTokens != 20 || resp.Usage.CompletionTokens != 5 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+	if got.System != "system" || len(got.Messages) != 1 || got.Messages[0].Role != "user" {
+		t.Errorf("System message should be lifted out of messages, got %+v", got)
+	}
+	if got.MaxTokens != defaultAnthropicMaxTokens {
+		t.Errorf("Expected default max_tokens, got %d", got.MaxTokens)
+	}
+}
+
+func TestAnthropicProviderError(t *testing.T) {
+	server := newAnthropicTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+	})
+
+	provider := NewAnthropicProvider("bad-key", server.URL)
+	_, err := provider.Complete(context.Background(), Request{Model: "m"})
+	if err == nil || !strings.Contains(err.Error(), "invalid x-api-key") {
+		t.Errorf("Expected authentication error, got %v", err)
+	}
+}
+
+func TestAnthropicProviderStream(t *testing.T) {
+	server := newAnthropicTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"usage":{"input_tokens":10}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking"}}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"hi"}}`,
+			`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"grep"}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{}"}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":7}}`,
+			`{"type":"message_stop"}`,
+		} {
+			var parsed struct{ Type string }
+			json.Unmarshal([]byte(event), &parsed)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", parsed.Type, event)
+		}
+	})
+
+	provider := NewAnthropicProvider("test-key", server.URL)
+	stream, err := provider.Stream(context.Background(), Request{Model: "m"})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	defer stream.Close()
+
+	events := collectEvents(t, stream)
+	expected := []Event{
+		{Type: ReasoningDelta, Text: "hmm"},
+		{Type: ContentDelta, Text: "hi"},
+		{Type: ToolCallDelta, ToolCall: ToolCall{Index: 2, ID: "toolu_1", Name: "grep"}},
+		{Type: ToolCallDelta, ToolCall: ToolCall{Index: 2, Arguments: "{}"}},
+		{Type: UsageEvent, Usage: Usage{PromptTokens: 10, CompletionTokens: 7}},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Event %d: expected %+v, got %+v", i, expected[i], events[i])
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{"", ProviderOpenAI, ProviderAnthropic, ProviderGemini, ProviderAzure} {
+		if _, err := New(Options{Provider: name, APIKey: "key"}); err != nil {
+			t.Errorf("New(%q) failed: %v", name, err)
+		}
+	}
+
+	provider, err := New(Options{Provider: ProviderGemini, GeminiSafetyThreshold: "BLOCK_NONE"})
+	if err != nil || provider.(*GeminiProvider).SafetyThreshold != "BLOCK_NONE" {
+		t.Errorf("Expected Gemini safety threshold to be applied, got %+v, %v", provider, err)
+	}
+
+	if _, err := New(Options{Provider: "unknown", APIKey: "key"}); err == nil {
+		t.Errorf("Expected error for unknown provider")
+	}
+}
+
+func TestToAnthropicRequestThinking(t *testing.T) {
+	out := toAnthropicRequest(Request{Model: "m", Temperature: 0.7, Stop: []string{"END"}, ReasoningEffort: "low"}, false)
+	if out.Thinking == nil || out.Thinking.Type != "enabled" || out.Thinking.BudgetTokens != 1024 {
+		t.Errorf("Expected effort mapped to a thinking budget, got %+v", out.Thinking)
+	}
+	if out.Temperature != 0 || out.MaxTokens != defaultAnthropicMaxTokens {
+		t.Errorf("Expected temperature dropped and default max tokens, got %+v", out)
+	}
+	if len(out.StopSequences) != 1 || out.StopSequences[0] != "END" {
+		t.Errorf("Expected stop sequences, got %v", out.StopSequences)
+	}
+
+	out = toAnthropicRequest(Request{Model: "m", MaxTokens: 2000, ThinkingBudget: 8000, ReasoningEffort: "high"}, false)
+	if out.Thinking.BudgetTokens != 8000 || out.MaxTokens <= 8000 {
+		t.Errorf("Expected explicit budget with max tokens above it, got %+v, %+v", out.Thinking, out)
+	}
+
+	if out := toAnthropicRequest(Request{Model: "m", Temperature: 0.7}, false); out.Thinking != nil || out.Temperature != 0.7 {
+		t.Errorf("Expected no thinking by default, got %+v", out)
+	}
+}
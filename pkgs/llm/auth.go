@@ -0,0 +1,334 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Built-in auth schemes.
+const (
+	// AuthHMAC signs each request with a shared secret; see newHMACAuth.
+	AuthHMAC = "hmac"
+	// AuthOAuth2 sends an access token obtained with the OAuth2 client
+	// credentials grant; see newOAuth2Auth.
+	AuthOAuth2 = "oauth2"
+)
+
+// AuthOptions configures how requests are authorized, for gateways that do
+// not accept the provider's API key.
+type AuthOptions struct {
+	// Type names a registered auth scheme. Empty keeps the provider's own
+	// API key authentication.
+	Type string
+	// Params configure the scheme, e.g. its credentials.
+	Params map[string]string
+}
+
+// Authenticator authorizes outgoing requests.
+type Authenticator interface {
+	// Authorize adds credentials to req, which it may modify, before it is
+	// sent.
+	Authorize(req *http.Request) error
+}
+
+// Refresher is an Authenticator whose credentials can go stale, such as an
+// expiring token. After a 401 response, Refresh drops them and the request
+// is sent once more.
+type Refresher interface {
+	Authenticator
+	Refresh()
+}
+
+// AuthFactory creates an Authenticator from the params of an AuthOptions.
+// client sends the scheme's own requests, such as those to a token endpoint.
+type AuthFactory func(params map[string]string, client *http.Client) (Authenticator, error)
+
+var (
+	authMu      sync.Mutex
+	authSchemes = map[string]AuthFactory{
+		AuthHMAC:   newHMACAuth,
+		AuthOAuth2: newOAuth2Auth,
+	}
+)
+
+// RegisterAuth makes an auth scheme available as name, so gateways with
+// their own scheme can be supported without changing the providers. It
+// panics if name is already registered.
+func RegisterAuth(name string, factory AuthFactory) {
+	authMu.Lock()
+	defer authMu.Unlock()
+	if _, ok := authSchemes[name]; ok {
+		panic(fmt.Sprintf("llm: auth scheme %q registered twice", name))
+	}
+	authSchemes[name] = factory
+}
+
+// AuthSchemes lists the registered auth schemes, sorted.
+func AuthSchemes() []string {
+	authMu.Lock()
+	defer authMu.Unlock()
+	names := make([]string, 0, len(authSchemes))
+	for name := range authSchemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withAuth returns a copy of client that authorizes its requests as opts
+// describe, or client itself if opts has no Type.
+func withAuth(client *http.Client, opts AuthOptions) (*http.Client, error) {
+	if opts.Type == "" {
+		return client, nil
+	}
+	authMu.Lock()
+	factory, ok := authSchemes[opts.Type]
+	authMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown auth type %q, expected one of %s", opts.Type, strings.Join(AuthSchemes(), ", "))
+	}
+
+	auth, err := factory(opts.Params, client)
+	if err != nil {
+		return nil, fmt.Errorf("%s auth: %w", opts.Type, err)
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	authorized := *client
+	authorized.Transport = &authTransport{base: base, auth: auth}
+	return &authorized, nil
+}
+
+// authTransport authorizes requests before sending them with base.
+type authTransport struct {
+	base http.RoundTripper
+	auth Authenticator
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Each attempt sends a fresh copy of a replayable body
+	if req.Body != nil && req.GetBody != nil {
+		req.Body.Close()
+	}
+	resp, err := t.send(req)
+	if err != nil {
+		return nil, err
+	}
+	refresher, ok := t.auth.(Refresher)
+	if resp.StatusCode != http.StatusUnauthorized || !ok || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil
+	}
+
+	// The credentials were revoked or expired early: retry once with new ones
+	resp.Body.Close()
+	refresher.Refresh()
+	return t.send(req)
+}
+
+// send authorizes a copy of req and sends it; a RoundTripper must not
+// modify its request.
+func (t *authTransport) send(req *http.Request) (*http.Response, error) {
+	authorized := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		authorized.Body = body
+	}
+	if err := t.auth.Authorize(authorized); err != nil {
+		if authorized.Body != nil {
+			authorized.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(authorized)
+}
+
+// requireParams returns an error naming the params that are missing.
+func requireParams(params map[string]string, names ...string) error {
+	var missing []string
+	for _, name := range names {
+		if params[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// hmacAuth signs requests with HMAC-SHA256.
+type hmacAuth struct {
+	keyID  string
+	secret []byte
+	header string
+	// now returns the current time; tests replace it.
+	now func() time.Time
+}
+
+// newHMACAuth creates the hmac scheme. Its params are key_id and secret,
+// and optionally header, the header carrying the signature (default
+// Authorization). Each request gets an X-Timestamp header with the Unix time
+// and a signature header
+//
+//	HMAC-SHA256 Credential=<key_id>, Signature=<hex>
+//
+// where the signature is the HMAC-SHA256, keyed with the secret, of the
+// method, the path with its query, the timestamp and the hex SHA-256 of the
+// body, joined by newlines.
+func newHMACAuth(params map[string]string, _ *http.Client) (Authenticator, error) {
+	if err := requireParams(params, "key_id", "secret"); err != nil {
+		return nil, err
+	}
+	header := params["header"]
+	if header == "" {
+		header = "Authorization"
+	}
+	return &hmacAuth{keyID: params["key_id"], secret: []byte(params["secret"]), header: header, now: time.Now}, nil
+}
+
+// Authorize implements Authenticator.
+func (a *hmacAuth) Authorize(req *http.Request) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	timestamp := strconv.FormatInt(a.now().Unix(), 10)
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, a.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", req.Method, req.URL.RequestURI(), timestamp, hex.EncodeToString(bodyHash[:]))
+
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set(a.header, fmt.Sprintf("HMAC-SHA256 Credential=%s, Signature=%s", a.keyID, hex.EncodeToString(mac.Sum(nil))))
+	return nil
+}
+
+// tokenExpiryMargin is how long before its expiry an access token is
+// replaced, so it does not expire in flight.
+const tokenExpiryMargin = time.Minute
+
+// oauth2Auth sends access tokens from the OAuth2 client credentials grant,
+// fetching a new one when the current one is about to expire.
+type oauth2Auth struct {
+	client *http.Client
+	params url.Values
+	// tokenURL is the token endpoint.
+	tokenURL string
+	// now returns the current time; tests replace it.
+	now func() time.Time
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// newOAuth2Auth creates the oauth2 scheme. Its params are token_url,
+// client_id and client_secret, and optionally scopes, separated by spaces,
+// and audience. The token replaces the provider's API key as a bearer token
+// in the Authorization header.
+func newOAuth2Auth(params map[string]string, client *http.Client) (Authenticator, error) {
+	if err := requireParams(params, "token_url", "client_id", "client_secret"); err != nil {
+		return nil, err
+	}
+	values := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {params["client_id"]},
+		"client_secret": {params["client_secret"]},
+	}
+	if scopes := params["scopes"]; scopes != "" {
+		values.Set("scope", strings.Join(strings.Fields(scopes), " "))
+	}
+	if audience := params["audience"]; audience != "" {
+		values.Set("audience", audience)
+	}
+	return &oauth2Auth{client: client, params: values, tokenURL: params["token_url"], now: time.Now}, nil
+}
+
+// Authorize implements Authenticator.
+func (a *oauth2Auth) Authorize(req *http.Request) error {
+	token, err := a.accessToken(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Refresh implements Refresher.
+func (a *oauth2Auth) Refresh() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token = ""
+}
+
+// accessToken returns the current token, fetching a new one if there is none
+// or it is about to expire.
+func (a *oauth2Auth) accessToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && (a.expires.IsZero() || a.now().Before(a.expires.Add(-tokenExpiryMargin))) {
+		return a.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.tokenURL, strings.NewReader(a.params.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch access token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch access token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to parse access token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("token endpoint returned no access token")
+	}
+
+	a.token = token.AccessToken
+	a.expires = time.Time{}
+	if token.ExpiresIn > 0 {
+		a.expires = a.now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return a.token, nil
+}
@@ -0,0 +1,183 @@
+// nolint:testpackage
+package llm
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACAuth(t *testing.T) {
+	var signature, timestamp string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp = r.Header.Get("X-Timestamp")
+		signature = r.Header.Get("X-Signature")
+
+		bodyHash := sha256.Sum256(body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		fmt.Fprintf(mac, "%s\n%s\n%s\n%s", r.Method, r.URL.RequestURI(), timestamp, hex.EncodeToString(bodyHash[:]))
+		if expected := "HMAC-SHA256 Credential=key, Signature=" + hex.EncodeToString(mac.Sum(nil)); signature != expected {
+			t.Errorf("Expected signature %q, got %q", expected, signature)
+		}
+		if !strings.Contains(string(body), `"model":"m"`) {
+			t.Errorf("Expected the body to reach the server intact, got %q", body)
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+
+	provider, err := New(Options{
+		BaseURL: server.URL,
+		Auth:    AuthOptions{Type: AuthHMAC, Params: map[string]string{"key_id": "key", "secret": "secret", "header": "X-Signature"}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := provider.Complete(context.Background(), Request{Model: "m"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if signature == "" || timestamp == "" {
+		t.Errorf("Expected signed request, got signature %q and timestamp %q", signature, timestamp)
+	}
+
+	if _, err := New(Options{Auth: AuthOptions{Type: AuthHMAC, Params: map[string]string{"key_id": "key"}}}); err == nil || !strings.Contains(err.Error(), "secret") {
+		t.Errorf("Expected the missing secret to be reported, got %v", err)
+	}
+}
+
+func TestOAuth2Auth(t *testing.T) {
+	tokens := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Failed to parse token request: %v", err)
+		}
+		if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("client_id") != "id" || r.Form.Get("scope") != "a b" {
+			t.Errorf("Unexpected token request %v", r.Form)
+		}
+		tokens++
+		fmt.Fprintf(w, `{"access_token":"token%d","token_type":"Bearer","expires_in":3600}`, tokens)
+	})
+	var authorizations []string
+	mux.HandleFunc("/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		authorizations = append(authorizations, authorization)
+		// The first token is revoked after two uses
+		if authorization == "Bearer token1" && len(authorizations) > 2 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider, err := New(Options{
+		APIKey:  "unused",
+		BaseURL: server.URL,
+		Auth: AuthOptions{Type: AuthOAuth2, Params: map[string]string{
+			"token_url": server.URL + "/token", "client_id": "id", "client_secret": "secret", "scopes": "a  b",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for range 3 {
+		if _, err := provider.Complete(context.Background(), Request{Model: "m"}); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+	}
+	expected := []string{"Bearer token1", "Bearer token1", "Bearer token1", "Bearer token2"}
+	if strings.Join(authorizations, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected a cached token refreshed after a 401, got %v", authorizations)
+	}
+	if tokens != 2 {
+		t.Errorf("Expected 2 token requests, got %d", tokens)
+	}
+}
+
+func TestOAuth2AuthExpiry(t *testing.T) {
+	tokens := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens++
+		fmt.Fprintf(w, `{"access_token":"token%d","expires_in":120}`, tokens)
+	}))
+	defer server.Close()
+
+	auth, err := newOAuth2Auth(map[string]string{"token_url": server.URL, "client_id": "id", "client_secret": "secret"}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	auth.(*oauth2Auth).now = func() time.Time { return now }
+
+	for _, step := range []struct {
+		elapsed time.Duration
+		token   string
+	}{
+		{0, "token1"},
+		{30 * time.Second, "token1"},
+		// Within a minute of expiring
+		{40 * time.Second, "token2"},
+	} {
+		now = now.Add(step.elapsed)
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		if err := auth.Authorize(req); err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer "+step.token {
+			t.Errorf("After %v: expected %s, got %q", step.elapsed, step.token, got)
+		}
+	}
+}
+
+func TestRegisterAuth(t *testing.T) {
+	RegisterAuth("test-header", func(params map[string]string, _ *http.Client) (Authenticator, error) {
+		return headerAuth(params["value"]), nil
+	})
+	t.Cleanup(func() {
+		authMu.Lock()
+		delete(authSchemes, "test-header")
+		authMu.Unlock()
+	})
+
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Gateway")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+
+	provider, err := New(Options{BaseURL: server.URL, Auth: AuthOptions{Type: "test-header", Params: map[string]string{"value": "v"}}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := provider.Complete(context.Background(), Request{Model: "m"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if got != "v" {
+		t.Errorf("Expected the registered scheme to authorize requests, got %q", got)
+	}
+
+	if _, err := New(Options{Auth: AuthOptions{Type: "missing"}}); err == nil || !strings.Contains(err.Error(), "hmac, oauth2") {
+		t.Errorf("Expected an unknown type to list the schemes, got %v", err)
+	}
+}
+
+// headerAuth sets a fixed X-Gateway header.
+type headerAuth string
+
+func (h headerAuth) Authorize(req *http.Request) error {
+	req.Header.Set("X-Gateway", string(h))
+	return nil
+}
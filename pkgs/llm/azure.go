@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// DefaultAzureAPIVersion is the Azure OpenAI REST API version used when none is configured.
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// AzureOptions configures an Azure OpenAI provider.
+type AzureOptions struct {
+	// Endpoint is the resource endpoint, e.g. "https://my-resource.openai.azure.com".
+	Endpoint string
+	// APIKey authenticates with the resource key, sent in the api-key header.
+	APIKey string
+	// ADToken is a Microsoft Entra ID (Azure AD) access token. When set it is
+	// sent as a bearer token instead of APIKey.
+	ADToken string
+	// APIVersion is the api-version query parameter. Empty uses DefaultAzureAPIVersion.
+	APIVersion string
+	// Deployment is the deployment every request is routed to. When empty the
+	// deployment is derived from the request model name.
+	Deployment string
+	// HTTPClient sends the requests. Nil uses http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// NewAzureProvider creates a provider for an Azure OpenAI resource. Azure
+// addresses models through per-resource deployments and an api-version query
+// parameter, which a plain base URL override cannot express.
+func NewAzureProvider(opts AzureOptions) *OpenAIProvider {
+	config := openai.DefaultAzureConfig(opts.APIKey, opts.Endpoint)
+	if opts.ADToken != "" {
+		config = openai.DefaultAzureConfig(opts.ADToken, opts.Endpoint)
+		config.APIType = openai.APITypeAzureAD
+	}
+
+	config.APIVersion = opts.APIVersion
+	if config.APIVersion == "" {
+		config.APIVersion = DefaultAzureAPIVersion
+	}
+
+	if opts.Deployment != "" {
+		deployment := opts.Deployment
+		config.AzureModelMapperFunc = func(string) string { return deployment }
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	config.HTTPClient = withCleanStreams(client)
+
+	return &OpenAIProvider{
+		name:   ProviderAzure,
+		client: openai.NewClientWithConfig(config),
+	}
+}
@@ -0,0 +1,78 @@
+// nolint:testpackage
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newAzureTestServer records the request and answers with a fixed completion.
+func newAzureTestServer(t *testing.T, got **http.Request) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*got = r
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"azure answer"}}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAzureProviderAPIKey(t *testing.T) {
+	var got *http.Request
+	server := newAzureTestServer(t, &got)
+
+	provider := NewAzureProvider(AzureOptions{
+		Endpoint:   server.URL,
+		APIKey:     "resource-key",
+		Deployment: "my-gpt4o",
+	})
+	resp, err := provider.Complete(context.Background(), Request{Model: "gpt-4o"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	if resp.Content != "azure answer" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if got.URL.Path != "/openai/deployments/my-gpt4o/chat/completions" {
+		t.Errorf("Expected deployment path, got %q", got.URL.Path)
+	}
+	if version := got.URL.Query().Get("api-version"); version != DefaultAzureAPIVersion {
+		t.Errorf("Expected default api-version, got %q", version)
+	}
+	if key := got.Header.Get("Api-Key"); key != "resource-key" {
+		t.Errorf("Expected api-key header, got %q", key)
+	}
+	if auth := got.Header.Get("Authorization"); auth != "" {
+		t.Errorf("Expected no Authorization header with key auth, got %q", auth)
+	}
+}
+
+func TestAzureProviderADToken(t *testing.T) {
+	var got *http.Request
+	server := newAzureTestServer(t, &got)
+
+	provider := NewAzureProvider(AzureOptions{
+		Endpoint:   server.URL,
+		APIKey:     "ignored-key",
+		ADToken:    "entra-token",
+		APIVersion: "2024-06-01",
+	})
+	if _, err := provider.Complete(context.Background(), Request{Model: "gpt-35-turbo"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	if got.URL.Path != "/openai/deployments/gpt-35-turbo/chat/completions" {
+		t.Errorf("Expected deployment derived from model, got %q", got.URL.Path)
+	}
+	if version := got.URL.Query().Get("api-version"); version != "2024-06-01" {
+		t.Errorf("Expected configured api-version, got %q", version)
+	}
+	if auth := got.Header.Get("Authorization"); auth != "Bearer entra-token" {
+		t.Errorf("Expected bearer AD token, got %q", auth)
+	}
+}
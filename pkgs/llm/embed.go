@@ -0,0 +1,38 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Embedder turns texts into embedding vectors for semantic search. Not every
+// provider offers embeddings; check with a type assertion.
+type Embedder interface {
+	// Embed returns one vector per text, in order.
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, error)
+}
+
+// Embed implements Embedder.
+func (p *OpenAIProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+		Input: texts,
+		Model: openai.EmbeddingModel(model),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, data := range resp.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+		}
+		vectors[data.Index] = data.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,68 @@
+package llm
+
+// EventType identifies what a stream Event carries.
+type EventType int
+
+const (
+	// ReasoningDelta carries a fragment of the model's reasoning in Text.
+	ReasoningDelta EventType = iota + 1
+	// ContentDelta carries a fragment of the final answer in Text.
+	ContentDelta
+	// ToolCallDelta carries a fragment of a tool call in ToolCall.
+	ToolCallDelta
+	// UsageEvent carries the token usage of the whole request in Usage. It is
+	// sent at most once, near the end of the stream, by providers that
+	// report usage.
+	UsageEvent
+)
+
+// Event is a provider-neutral piece of a streamed completion. Only the field
+// matching Type is set.
+type Event struct {
+	Type     EventType
+	Text     string
+	ToolCall ToolCall
+	Usage    Usage
+}
+
+// ToolCall is a fragment of a tool call requested by the model. Fragments
+// with the same Index belong to one call; ID and Name arrive with the first
+// fragment and Arguments is a piece of the JSON arguments.
+type ToolCall struct {
+	Index     int
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// eventQueue holds events decoded from one provider chunk until Recv
+// returns them, since a single chunk may carry several events.
+type eventQueue struct {
+	pending []Event
+}
+
+// pushText queues the text events for reasoning and content, skipping empty
+// fragments.
+func (q *eventQueue) pushText(reasoning, content string) {
+	if reasoning != "" {
+		q.pending = append(q.pending, Event{Type: ReasoningDelta, Text: reasoning})
+	}
+	if content != "" {
+		q.pending = append(q.pending, Event{Type: ContentDelta, Text: content})
+	}
+}
+
+// push queues events.
+func (q *eventQueue) push(events ...Event) {
+	q.pending = append(q.pending, events...)
+}
+
+// pop removes and returns the oldest queued event.
+func (q *eventQueue) pop() (Event, bool) {
+	if len(q.pending) == 0 {
+		return Event{}, false
+	}
+	event := q.pending[0]
+	q.pending = q.pending[1:]
+	return event, true
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Stages at which content can be filtered.
+const (
+	FilterStagePrompt = "prompt"
+	FilterStageAnswer = "answer"
+)
+
+// ContentFilterError reports that a provider's safety system blocked the
+// prompt or the answer, or that the model refused to answer. Providers return
+// it instead of an empty response so callers can tell a refusal from silence.
+type ContentFilterError struct {
+	// Provider is the name of the provider that filtered the content.
+	Provider string
+	// Stage is FilterStagePrompt if the request was rejected before generation,
+	// FilterStageAnswer otherwise.
+	Stage string
+	// Categories lists the flagged categories as reported by the provider.
+	Categories []string
+	// Message is the model's refusal text or the provider's explanation, if any.
+	Message string
+}
+
+// Error implements error.
+func (e *ContentFilterError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s blocked by content filter", e.Provider, e.Stage)
+	if len(e.Categories) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(e.Categories, ", "))
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	return b.String()
+}
+
+// openAIFilteredCategories lists the categories an OpenAI/Azure content
+// filter result flagged.
+func openAIFilteredCategories(results openai.ContentFilterResults) []string {
+	var categories []string
+	add := func(name string, filtered bool, severity string) {
+		if !filtered {
+			return
+		}
+		if severity != "" {
+			name += "=" + severity
+		}
+		categories = append(categories, name)
+	}
+
+	add("hate", results.Hate.Filtered, results.Hate.Severity)
+	add("self_harm", results.SelfHarm.Filtered, results.SelfHarm.Severity)
+	add("sexual", results.Sexual.Filtered, results.Sexual.Severity)
+	add("violence", results.Violence.Filtered, results.Violence.Severity)
+	add("jailbreak", results.JailBreak.Filtered, "")
+	add("profanity", results.Profanity.Filtered, "")
+	return categories
+}
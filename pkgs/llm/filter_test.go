@@ -0,0 +1,117 @@
+// nolint:testpackage
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestContentFilterErrorMessage(t *testing.T) {
+	err := &ContentFilterError{
+		Provider:   ProviderAzure,
+		Stage:      FilterStagePrompt,
+		Categories: []string{"violence=high", "jailbreak"},
+		Message:    "The prompt was filtered",
+	}
+
+	expected := "azure: prompt blocked by content filter (violence=high, jailbreak): The prompt was filtered"
+	if err.Error() != expected {
+		t.Errorf("Error() = %q, expected %q", err.Error(), expected)
+	}
+}
+
+// completeWithBody runs provider against a server answering with status and body.
+func completeWithBody(t *testing.T, newProvider func(url string) Provider, status int, body string) error {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	_, err := newProvider(server.URL).Complete(context.Background(), Request{Model: "m"})
+	return err
+}
+
+func TestOpenAIContentFilter(t *testing.T) {
+	newOpenAI := func(url string) Provider { return NewOpenAIProvider("key", url) }
+	newAzure := func(url string) Provider { return NewAzureProvider(AzureOptions{Endpoint: url, APIKey: "key"}) }
+
+	cases := []struct {
+		name        string
+		newProvider func(url string) Provider
+		status      int
+		body        string
+		expected    ContentFilterError
+	}{
+		{
+			name:        "answer filtered",
+			newProvider: newAzure,
+			status:      http.StatusOK,
+			body: `{"choices":[{"finish_reason":"content_filter","message":{"role":"assistant","content":""},
+				"content_filter_results":{"violence":{"filtered":true,"severity":"medium"}}}]}`,
+			expected: ContentFilterError{Provider: ProviderAzure, Stage: FilterStageAnswer,
+				Categories: []string{"violence=medium"}},
+		},
+		{
+			name:        "refusal",
+			newProvider: newOpenAI,
+			status:      http.StatusOK,
+			body:        `{"choices":[{"finish_reason":"stop","message":{"role":"assistant","refusal":"I can't help with that."}}]}`,
+			expected: ContentFilterError{Provider: ProviderOpenAI, Stage: FilterStageAnswer,
+				Message: "I can't help with that."},
+		},
+		{
+			name:        "prompt filtered",
+			newProvider: newAzure,
+			status:      http.StatusBadRequest,
+			body: `{"error":{"code":"content_filter","message":"The response was filtered",
+				"innererror":{"code":"ResponsibleAIPolicyViolation","content_filter_result":{"hate":{"filtered":true,"severity":"high"},
+				"jailbreak":{"filtered":true,"detected":true}}}}}`,
+			expected: ContentFilterError{Provider: ProviderAzure, Stage: FilterStagePrompt,
+				Categories: []string{"hate=high", "jailbreak"}, Message: "The response was filtered"},
+		},
+	}
+
+	for _, c := range cases {
+		err := completeWithBody(t, c.newProvider, c.status, c.body)
+
+		var filterErr *ContentFilterError
+		if !errors.As(err, &filterErr) {
+			t.Errorf("%s: expected ContentFilterError, got %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*filterErr, c.expected) {
+			t.Errorf("%s: got %+v, expected %+v", c.name, *filterErr, c.expected)
+		}
+	}
+}
+
+func TestOpenAIOtherErrorsUnchanged(t *testing.T) {
+	err := completeWithBody(t, func(url string) Provider { return NewOpenAIProvider("key", url) },
+		http.StatusTooManyRequests, `{"error":{"code":"rate_limit_exceeded","message":"slow down"}}`)
+
+	var filterErr *ContentFilterError
+	if err == nil || errors.As(err, &filterErr) {
+		t.Errorf("Expected a plain API error, got %v", err)
+	}
+}
+
+func TestAnthropicRefusal(t *testing.T) {
+	server := newAnthropicTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"I won't do that."}],"stop_reason":"refusal"}`)
+	})
+
+	_, err := NewAnthropicProvider("key", server.URL).Complete(context.Background(), Request{Model: "m"})
+
+	var filterErr *ContentFilterError
+	if !errors.As(err, &filterErr) || filterErr.Provider != ProviderAnthropic || filterErr.Message != "I won't do that." {
+		t.Errorf("Expected anthropic refusal, got %v", err)
+	}
+}
@@ -0,0 +1,343 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// DefaultGeminiBaseURL is the Gemini API endpoint used when no base URL is configured.
+	DefaultGeminiBaseURL = "https://generativelanguage.googleapis.com"
+
+	// DefaultGeminiSafetyThreshold only blocks content Gemini rates as highly
+	// likely harmful. Stricter defaults regularly block ordinary security code
+	// review as "dangerous content".
+	DefaultGeminiSafetyThreshold = "BLOCK_ONLY_HIGH"
+)
+
+// geminiHarmCategories are the adjustable Gemini safety categories.
+var geminiHarmCategories = []string{
+	"HARM_CATEGORY_HARASSMENT",
+	"HARM_CATEGORY_HATE_SPEECH",
+	"HARM_CATEGORY_SEXUALLY_EXPLICIT",
+	"HARM_CATEGORY_DANGEROUS_CONTENT",
+}
+
+// GeminiProvider talks to the Google Gemini generateContent API.
+type GeminiProvider struct {
+	// SafetyThreshold is applied to every harm category, e.g. "BLOCK_NONE",
+	// "BLOCK_ONLY_HIGH" or "BLOCK_MEDIUM_AND_ABOVE".
+	SafetyThreshold string
+
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+// NewGeminiProvider creates a provider for the Gemini API at baseURL.
+// An empty baseURL uses DefaultGeminiBaseURL.
+func NewGeminiProvider(apiKey, baseURL string) *GeminiProvider {
+	if baseURL == "" {
+		baseURL = DefaultGeminiBaseURL
+	}
+
+	return &GeminiProvider{
+		SafetyThreshold: DefaultGeminiSafetyThreshold,
+		apiKey:          apiKey,
+		baseURL:         strings.TrimSuffix(baseURL, "/"),
+		client:          http.DefaultClient,
+	}
+}
+
+// geminiPart is a piece of message content.
+type geminiPart struct {
+	Text    string `json:"text"`
+	Thought bool   `json:"thought,omitempty"`
+}
+
+// geminiContent is a message in the Gemini format.
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiSafetySetting sets the block threshold for one harm category.
+type geminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// geminiGenerationConfig holds sampling parameters.
+type geminiGenerationConfig struct {
+	Temperature     float32               `json:"temperature,omitempty"`
+	MaxOutputTokens int                   `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string              `json:"stopSequences,omitempty"`
+	ThinkingConfig  *geminiThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+// geminiThinkingConfig sets the token budget of thinking models.
+type geminiThinkingConfig struct {
+	ThinkingBudget  int  `json:"thinkingBudget"`
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
+}
+
+// geminiRequest is the generateContent request body.
+type geminiRequest struct {
+	Contents          []geminiContent        `json:"contents"`
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	SafetySettings    []geminiSafetySetting  `json:"safetySettings,omitempty"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+}
+
+// geminiSafetyRating is Gemini's assessment of one harm category.
+type geminiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked"`
+}
+
+// geminiResponse is the generateContent response body, also used for each
+// streamed chunk.
+type geminiResponse struct {
+	Candidates []struct {
+		Content       geminiContent        `json:"content"`
+		FinishReason  string               `json:"finishReason"`
+		SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason   string               `json:"blockReason"`
+		SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// geminiError is the error body returned by the Gemini API.
+type geminiError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// Complete implements Provider.
+func (p *GeminiProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	httpResp, err := p.send(ctx, req, "generateContent", nil)
+	if err != nil {
+		return Response{}, err
+	}
+	defer httpResp.Body.Close()
+
+	var resp geminiResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("failed to decode gemini response: %w", err)
+	}
+
+	reasoning, content, err := geminiText(resp)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return Response{
+		Content:          content,
+		ReasoningContent: reasoning,
+		Usage: Usage{
+			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+		},
+	}, nil
+}
+
+// Stream implements Provider.
+func (p *GeminiProvider) Stream(ctx context.Context, req Request) (Stream, error) {
+	httpResp, err := p.send(ctx, req, "streamGenerateContent", url.Values{"alt": {"sse"}})
+	if err != nil {
+		return nil, err
+	}
+	return &geminiStream{events: newSSEReader(httpResp.Body)}, nil
+}
+
+// CountTokens implements Provider.
+func (p *GeminiProvider) CountTokens(messages []Message) int {
+	return EstimateTokens(messages)
+}
+
+// send posts req to the model's method endpoint and returns the successful HTTP response.
+func (p *GeminiProvider) send(ctx context.Context, req Request, method string, query url.Values) (*http.Response, error) {
+	body, err := json.Marshal(p.toGeminiRequest(req))
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s:%s", p.baseURL, url.PathEscape(req.Model), method)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Goog-Api-Key", p.apiKey)
+
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		defer httpResp.Body.Close()
+		return nil, decodeGeminiError(httpResp)
+	}
+	return httpResp, nil
+}
+
+// decodeGeminiError builds an error from a non-200 Gemini API response.
+func decodeGeminiError(resp *http.Response) error {
+	data, _ := io.ReadAll(resp.Body)
+
+	var apiErr geminiError
+	if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+		return fmt.Errorf("gemini API error (status %d, %s): %s",
+			resp.StatusCode, apiErr.Error.Status, apiErr.Error.Message)
+	}
+	return fmt.Errorf("gemini API error (status %d): %s", resp.StatusCode, strings.TrimSpace(string(data)))
+}
+
+// toGeminiRequest converts req to the Gemini format. System messages become
+// the system instruction and assistant messages use Gemini's "model" role.
+func (p *GeminiProvider) toGeminiRequest(req Request) geminiRequest {
+	out := geminiRequest{
+		GenerationConfig: geminiGenerationConfig{
+			Temperature:     req.Temperature,
+			MaxOutputTokens: req.MaxTokens,
+			StopSequences:   req.Stop,
+		},
+	}
+	if budget := req.thinkingBudget(); budget > 0 {
+		out.GenerationConfig.ThinkingConfig = &geminiThinkingConfig{ThinkingBudget: budget, IncludeThoughts: true}
+	}
+
+	var system []geminiPart
+	for _, message := range req.Messages {
+		switch message.Role {
+		case RoleSystem:
+			system = append(system, geminiPart{Text: message.Content})
+		case RoleAssistant:
+			out.Contents = append(out.Contents, geminiContent{Role: "model", Parts: []geminiPart{{Text: message.Content}}})
+		case RoleUser:
+			out.Contents = append(out.Contents, geminiContent{Role: "user", Parts: []geminiPart{{Text: message.Content}}})
+		}
+	}
+	if len(system) > 0 {
+		out.SystemInstruction = &geminiContent{Parts: system}
+	}
+
+	if p.SafetyThreshold != "" {
+		for _, category := range geminiHarmCategories {
+			out.SafetySettings = append(out.SafetySettings, geminiSafetySetting{
+				Category:  category,
+				Threshold: p.SafetyThreshold,
+			})
+		}
+	}
+
+	return out
+}
+
+// geminiText extracts the thoughts and answer from a response, turning
+// safety blocks into a ContentFilterError naming the offending categories.
+func geminiText(resp geminiResponse) (reasoning, content string, err error) {
+	if reason := resp.PromptFeedback.BlockReason; reason != "" {
+		return "", "", &ContentFilterError{
+			Provider:   ProviderGemini,
+			Stage:      FilterStagePrompt,
+			Categories: blockedCategories(resp.PromptFeedback.SafetyRatings),
+			Message:    "block reason " + reason,
+		}
+	}
+	if len(resp.Candidates) == 0 {
+		return "", "", nil
+	}
+
+	candidate := resp.Candidates[0]
+	if candidate.FinishReason == "SAFETY" {
+		return "", "", &ContentFilterError{
+			Provider:   ProviderGemini,
+			Stage:      FilterStageAnswer,
+			Categories: blockedCategories(candidate.SafetyRatings),
+		}
+	}
+
+	for _, part := range candidate.Content.Parts {
+		if part.Thought {
+			reasoning += part.Text
+		} else {
+			content += part.Text
+		}
+	}
+	return reasoning, content, nil
+}
+
+// blockedCategories lists the categories flagged as blocked.
+func blockedCategories(ratings []geminiSafetyRating) []string {
+	var categories []string
+	for _, rating := range ratings {
+		if rating.Blocked {
+			categories = append(categories, fmt.Sprintf("%s=%s", rating.Category, rating.Probability))
+		}
+	}
+	return categories
+}
+
+// geminiStream adapts a streamGenerateContent event stream to Stream.
+type geminiStream struct {
+	events *sseReader
+	queue  eventQueue
+}
+
+// Recv implements Stream.
+func (s *geminiStream) Recv() (Event, error) {
+	for {
+		if event, ok := s.queue.pop(); ok {
+			return event, nil
+		}
+
+		data, err := s.events.next()
+		if err != nil {
+			return Event{}, err
+		}
+
+		var resp geminiResponse
+		if err := json.Unmarshal([]byte(data), &resp); err != nil {
+			return Event{}, fmt.Errorf("failed to decode gemini stream chunk: %w", err)
+		}
+		reasoning, content, err := geminiText(resp)
+		if err != nil {
+			return Event{}, err
+		}
+		s.queue.pushText(reasoning, content)
+
+		// Every chunk reports the usage so far; the last one has the total
+		if len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason != "" {
+			s.queue.push(Event{Type: UsageEvent, Usage: Usage{
+				PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+				CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+			}})
+		}
+	}
+}
+
+// Close implements Stream.
+func (s *geminiStream) Close() error {
+	return s.events.Close()
+}
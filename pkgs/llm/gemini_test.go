@@ -0,0 +1,166 @@
+// nolint:testpackage
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newGeminiTestServer serves handler for every model method.
+func newGeminiTestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGeminiProviderComplete(t *testing.T) {
+	var got geminiRequest
+	server := newGeminiTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-test:generateContent" {
+			t.Errorf("Unexpected path %q", r.URL.Path)
+		}
+		if key := r.Header.Get("X-Goog-Api-Key"); key != "test-key" {
+			t.Errorf("Expected x-goog-api-key header, got %q", key)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[
+			{"text":"considering","thought":true},{"text":"answer"}]},"finishReason":"STOP"}],
+			"usageMetadata":{"promptTokenCount":30,"candidatesTokenCount":7}}`)
+	})
+
+	provider := NewGeminiProvider("test-key", server.URL)
+	resp, err := provider.Complete(context.Background(), Request{
+		Model: "gemini-test",
+		Messages: []Message{
+			{Role: RoleSystem, Content: "system"},
+			{Role: RoleUser, Content: "question"},
+			{Role: RoleAssistant, Content: "earlier answer"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	if resp.Content != "answer" || resp.ReasoningContent != "considering" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if resp.Usage.PromptTokens != 30 || resp.Usage.CompletionTokens != 7 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+	if got.SystemInstruction == nil || got.SystemInstruction.Parts[0].Text != "system" {
+		t.Errorf("System message should become the system instruction, got %+v", got.SystemInstruction)
+	}
+	if len(got.Contents) != 2 || got.Contents[0].Role != "user" || got.Contents[1].Role != "model" {
+		t.Errorf("Unexpected contents: %+v", got.Contents)
+	}
+	if len(got.SafetySettings) != len(geminiHarmCategories) ||
+		got.SafetySettings[0].Threshold != DefaultGeminiSafetyThreshold {
+		t.Errorf("Expected default safety settings for every category, got %+v", got.SafetySettings)
+	}
+}
+
+func TestGeminiProviderSafetyBlocks(t *testing.T) {
+	responses := map[string]string{
+		"prompt": `{"promptFeedback":{"blockReason":"SAFETY","safetyRatings":[
+			{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"HIGH","blocked":true}]}}`,
+		"answer": `{"candidates":[{"finishReason":"SAFETY","safetyRatings":[
+			{"category":"HARM_CATEGORY_HARASSMENT","probability":"MEDIUM","blocked":true}]}]}`,
+	}
+
+	for name, body := range responses {
+		server := newGeminiTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprint(w, body)
+		})
+
+		provider := NewGeminiProvider("test-key", server.URL)
+		_, err := provider.Complete(context.Background(), Request{Model: "m"})
+
+		var filterErr *ContentFilterError
+		if !errors.As(err, &filterErr) || filterErr.Stage != name || len(filterErr.Categories) != 1 ||
+			!strings.HasPrefix(filterErr.Categories[0], "HARM_CATEGORY_") {
+			t.Errorf("Expected %s content filter error naming the category, got %v", name, err)
+		}
+	}
+}
+
+func TestGeminiProviderError(t *testing.T) {
+	server := newGeminiTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"code":400,"message":"API key not valid","status":"INVALID_ARGUMENT"}}`)
+	})
+
+	provider := NewGeminiProvider("bad-key", server.URL)
+	_, err := provider.Complete(context.Background(), Request{Model: "m"})
+	if err == nil || !strings.Contains(err.Error(), "API key not valid") {
+		t.Errorf("Expected API key error, got %v", err)
+	}
+}
+
+func TestGeminiProviderStream(t *testing.T) {
+	server := newGeminiTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/m:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("Unexpected stream URL %q", r.URL.String())
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"candidates":[{"content":{"parts":[{"text":"hmm","thought":true}]}}]}`,
+			`{"candidates":[{"content":{"parts":[{"text":"hel"}]}}]}`,
+			`{"candidates":[{"content":{"parts":[{"text":"lo"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":2}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\r\n\r\n", chunk)
+		}
+	})
+
+	provider := NewGeminiProvider("test-key", server.URL)
+	stream, err := provider.Stream(context.Background(), Request{Model: "m"})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	defer stream.Close()
+
+	var reasoning, content string
+	var usage Usage
+	for _, event := range collectEvents(t, stream) {
+		switch event.Type {
+		case ReasoningDelta:
+			reasoning += event.Text
+		case ContentDelta:
+			content += event.Text
+		case UsageEvent:
+			usage = event.Usage
+		}
+	}
+
+	if reasoning != "hmm" || content != "hello" {
+		t.Errorf("Unexpected stream result: reasoning=%q content=%q", reasoning, content)
+	}
+	if usage.PromptTokens != 5 || usage.CompletionTokens != 2 {
+		t.Errorf("Expected usage from the last chunk, got %+v", usage)
+	}
+}
+
+func TestToGeminiRequestThinking(t *testing.T) {
+	p := NewGeminiProvider("key", "")
+	out := p.toGeminiRequest(Request{Model: "m", Stop: []string{"END"}, ThinkingBudget: 2048})
+	config := out.GenerationConfig
+	if config.ThinkingConfig == nil || config.ThinkingConfig.ThinkingBudget != 2048 || !config.ThinkingConfig.IncludeThoughts {
+		t.Errorf("Expected a thinking budget, got %+v", config.ThinkingConfig)
+	}
+	if len(config.StopSequences) != 1 || config.StopSequences[0] != "END" {
+		t.Errorf("Expected stop sequences, got %v", config.StopSequences)
+	}
+
+	if out := p.toGeminiRequest(Request{Model: "m"}); out.GenerationConfig.ThinkingConfig != nil {
+		t.Errorf("Expected no thinking config by default")
+	}
+}
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTPOptions tunes the HTTP client a provider uses. Zero fields keep the
+// defaults of http.DefaultTransport.
+type HTTPOptions struct {
+	// ConnectTimeout bounds establishing a TCP connection.
+	ConnectTimeout time.Duration
+	// ReadTimeout bounds waiting for response headers after a request is
+	// sent. Streamed bodies are not limited, so long answers are not cut off.
+	ReadTimeout time.Duration
+	// KeepAlive is the TCP keep-alive probe interval. A negative value
+	// disables keep-alive probes and HTTP connection reuse.
+	KeepAlive time.Duration
+	// TLSMinVersion is the minimum TLS version, "1.2" or "1.3".
+	TLSMinVersion string
+	// MaxIdleConns limits idle connections kept open per host.
+	MaxIdleConns int
+}
+
+// tlsVersions maps TLSMinVersion values to crypto/tls constants.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewHTTPClient creates an HTTP client tuned by opts. Zero opts yield
+// http.DefaultClient.
+func NewHTTPClient(opts HTTPOptions) (*http.Client, error) {
+	if opts == (HTTPOptions{}) {
+		return http.DefaultClient, nil
+	}
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("unexpected default transport %T", http.DefaultTransport)
+	}
+	transport = transport.Clone()
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if opts.ConnectTimeout > 0 {
+		dialer.Timeout = opts.ConnectTimeout
+	}
+	if opts.KeepAlive != 0 {
+		dialer.KeepAlive = opts.KeepAlive
+		transport.DisableKeepAlives = opts.KeepAlive < 0
+	}
+	transport.DialContext = dialer.DialContext
+
+	if opts.ReadTimeout > 0 {
+		transport.ResponseHeaderTimeout = opts.ReadTimeout
+	}
+
+	if opts.TLSMinVersion != "" {
+		version, ok := tlsVersions[opts.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version %q, expected 1.2 or 1.3", opts.TLSMinVersion)
+		}
+		transport.TLSClientConfig = &tls.Config{MinVersion: version}
+	}
+
+	if opts.MaxIdleConns > 0 {
+		transport.MaxIdleConnsPerHost = opts.MaxIdleConns
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,79 @@
+// nolint:testpackage
+package llm
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClientDefaults(t *testing.T) {
+	client, err := NewHTTPClient(HTTPOptions{})
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	if client != http.DefaultClient {
+		t.Errorf("Expected http.DefaultClient for zero options")
+	}
+
+	if _, err := NewHTTPClient(HTTPOptions{TLSMinVersion: "1.0"}); err == nil {
+		t.Errorf("Expected error for unsupported TLS version")
+	}
+}
+
+func TestNewHTTPClientTuning(t *testing.T) {
+	client, err := NewHTTPClient(HTTPOptions{
+		ReadTimeout:   time.Second,
+		KeepAlive:     -1,
+		TLSMinVersion: "1.3",
+		MaxIdleConns:  4,
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected *http.Transport, got %T", client.Transport)
+	}
+	if transport.ResponseHeaderTimeout != time.Second {
+		t.Errorf("Expected ResponseHeaderTimeout 1s, got %v", transport.ResponseHeaderTimeout)
+	}
+	if !transport.DisableKeepAlives {
+		t.Errorf("Expected negative KeepAlive to disable keep-alives")
+	}
+	if transport.TLSClientConfig == nil || transport.TLSClientConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3 minimum, got %+v", transport.TLSClientConfig)
+	}
+	if transport.MaxIdleConnsPerHost != 4 {
+		t.Errorf("Expected MaxIdleConnsPerHost 4, got %d", transport.MaxIdleConnsPerHost)
+	}
+	if transport == http.DefaultTransport {
+		t.Errorf("Expected a copy of the default transport")
+	}
+}
+
+func TestNewHTTPReadTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(`{"choices":[{"message":{"content":"late"}}]}`))
+	}))
+	defer server.Close()
+
+	provider, err := New(Options{
+		APIKey:  "key",
+		BaseURL: server.URL,
+		HTTP:    HTTPOptions{ReadTimeout: 20 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	_, err = provider.Complete(context.Background(), Request{Model: "m", Messages: []Message{{Role: RoleUser, Content: "hi"}}})
+	if err == nil {
+		t.Errorf("Expected read timeout error")
+	}
+}
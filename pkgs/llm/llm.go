@@ -0,0 +1,180 @@
+// Package llm defines a provider-neutral interface for chat completion
+// backends so new providers can be added without touching the CLI.
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Role identifies the author of a chat message.
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// Message is a single chat message.
+type Message struct {
+	Role    Role
+	Content string
+}
+
+// Request describes a chat completion request.
+type Request struct {
+	// Model is the provider-specific model name.
+	Model string
+	// Messages is the conversation to complete.
+	Messages []Message
+	// Temperature controls sampling randomness. Zero uses the provider default.
+	Temperature float32
+	// MaxTokens caps the completion length. Zero uses the provider default.
+	MaxTokens int
+	// Stop lists sequences that end the completion when generated.
+	Stop []string
+	// ReasoningEffort asks reasoning models to think less or more: "low",
+	// "medium" or "high". Empty uses the provider default.
+	ReasoningEffort string
+	// ThinkingBudget caps the tokens spent on reasoning for providers with an
+	// explicit budget (Anthropic extended thinking, Gemini thinking). Zero
+	// derives the budget from ReasoningEffort, if set.
+	ThinkingBudget int
+}
+
+// reasoningEffortBudgets maps reasoning efforts to thinking budgets for
+// providers that only take a budget.
+var reasoningEffortBudgets = map[string]int{
+	"low":    1024,
+	"medium": 8192,
+	"high":   24576,
+}
+
+// thinkingBudget returns the thinking budget req asks for, zero for the
+// provider default.
+func (req Request) thinkingBudget() int {
+	if req.ThinkingBudget > 0 {
+		return req.ThinkingBudget
+	}
+	return reasoningEffortBudgets[req.ReasoningEffort]
+}
+
+// Usage reports token consumption for a request.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Response is the result of a non-streaming completion.
+type Response struct {
+	// Content is the final answer.
+	Content string
+	// ReasoningContent is the model's reasoning, for models that expose it.
+	ReasoningContent string
+	// Usage is the token usage reported by the provider, if any.
+	Usage Usage
+}
+
+// Stream is an in-progress streamed completion.
+type Stream interface {
+	// Recv returns the next event, or io.EOF when the stream is finished.
+	Recv() (Event, error)
+	// Close releases the underlying connection.
+	Close() error
+}
+
+// Provider is a chat completion backend.
+type Provider interface {
+	// Complete sends req and waits for the full response.
+	Complete(ctx context.Context, req Request) (Response, error)
+	// Stream sends req and returns a stream of incremental deltas.
+	Stream(ctx context.Context, req Request) (Stream, error)
+	// CountTokens returns the number of prompt tokens messages would consume.
+	CountTokens(messages []Message) int
+}
+
+// Supported provider names.
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderGemini    = "gemini"
+	ProviderAzure     = "azure"
+)
+
+// Options configures the provider created by New.
+type Options struct {
+	// Provider is the provider name. Empty selects ProviderOpenAI.
+	Provider string
+	// APIKey authenticates requests.
+	APIKey string
+	// BaseURL overrides the provider's default endpoint.
+	BaseURL string
+	// GeminiSafetyThreshold is the block threshold applied to every Gemini
+	// harm category, e.g. "BLOCK_ONLY_HIGH". Empty uses DefaultGeminiSafetyThreshold.
+	GeminiSafetyThreshold string
+	// AzureAPIVersion, AzureDeployment and AzureADToken configure the Azure
+	// provider; see AzureOptions. BaseURL is the resource endpoint.
+	AzureAPIVersion string
+	AzureDeployment string
+	AzureADToken    string
+	// HTTP tunes the HTTP client used to reach the provider.
+	HTTP HTTPOptions
+	// Auth replaces the API key with another way of authorizing requests,
+	// such as signing them for an internal gateway.
+	Auth AuthOptions
+}
+
+// New creates the provider described by opts.
+func New(opts Options) (Provider, error) {
+	client, err := NewHTTPClient(opts.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	if client, err = withAuth(client, opts.Auth); err != nil {
+		return nil, err
+	}
+
+	switch opts.Provider {
+	case "", ProviderOpenAI:
+		return newOpenAIProvider(opts.APIKey, opts.BaseURL, client), nil
+	case ProviderAnthropic:
+		provider := NewAnthropicProvider(opts.APIKey, opts.BaseURL)
+		provider.client = client
+		return provider, nil
+	case ProviderGemini:
+		provider := NewGeminiProvider(opts.APIKey, opts.BaseURL)
+		provider.client = client
+		if opts.GeminiSafetyThreshold != "" {
+			provider.SafetyThreshold = opts.GeminiSafetyThreshold
+		}
+		return provider, nil
+	case ProviderAzure:
+		return NewAzureProvider(AzureOptions{
+			Endpoint:   opts.BaseURL,
+			APIKey:     opts.APIKey,
+			ADToken:    opts.AzureADToken,
+			APIVersion: opts.AzureAPIVersion,
+			Deployment: opts.AzureDeployment,
+			HTTPClient: client,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q", opts.Provider)
+	}
+}
+
+// perMessageTokens approximates the framing overhead each chat message adds.
+const perMessageTokens = 4
+
+// charsPerToken is the rough average number of characters per token for
+// English text and code with BPE tokenizers.
+const charsPerToken = 4
+
+// EstimateTokens approximates the token count of messages without a tokenizer.
+func EstimateTokens(messages []Message) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += perMessageTokens + (len(message.Content)+charsPerToken-1)/charsPerToken
+	}
+	return tokens
+}
@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// MeterFunc receives the token usage of a request and the model it was sent
+// to.
+type MeterFunc func(model string, usage Usage)
+
+// Metered wraps p so that record receives the usage of each request it
+// completes: once a completion returns, and once a stream ends or is closed.
+// Usage the provider does not report is estimated from the length of the
+// messages and the answer. Failed requests are not recorded. The result is an
+// Embedder if p is, and embedding requests are recorded as prompt tokens.
+func Metered(p Provider, record MeterFunc) Provider {
+	m := &meteredProvider{Provider: p, record: record}
+	if embedder, ok := p.(Embedder); ok {
+		return &meteredEmbedder{meteredProvider: m, embedder: embedder}
+	}
+	return m
+}
+
+// meteredProvider is a Provider whose usage is recorded.
+type meteredProvider struct {
+	Provider
+	record MeterFunc
+}
+
+// Complete implements Provider.
+func (p *meteredProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	resp, err := p.Provider.Complete(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	usage := resp.Usage
+	if usage == (Usage{}) {
+		usage = Usage{
+			PromptTokens:     p.CountTokens(req.Messages),
+			CompletionTokens: estimateChars(len(resp.Content) + len(resp.ReasoningContent)),
+		}
+	}
+	p.record(req.Model, usage)
+	return resp, nil
+}
+
+// Stream implements Provider.
+func (p *meteredProvider) Stream(ctx context.Context, req Request) (Stream, error) {
+	stream, err := p.Provider.Stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &meteredStream{
+		Stream: stream,
+		prompt: p.CountTokens(req.Messages),
+		record: func(usage Usage) { p.record(req.Model, usage) },
+	}, nil
+}
+
+// meteredEmbedder is a meteredProvider that also embeds.
+type meteredEmbedder struct {
+	*meteredProvider
+	embedder Embedder
+}
+
+// Embed implements Embedder.
+func (p *meteredEmbedder) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	vectors, err := p.embedder.Embed(ctx, model, texts)
+	if err != nil {
+		return nil, err
+	}
+	chars := 0
+	for _, text := range texts {
+		chars += len(text)
+	}
+	p.record(model, Usage{PromptTokens: estimateChars(chars)})
+	return vectors, nil
+}
+
+// meteredStream tallies a stream's usage and records it when the stream is
+// done.
+type meteredStream struct {
+	Stream
+	// prompt is the estimated prompt tokens, in case the provider reports
+	// no usage.
+	prompt int
+	record func(Usage)
+
+	usage Usage
+	// chars is the length of the answer so far.
+	chars int
+	once  sync.Once
+}
+
+// Recv implements Stream.
+func (s *meteredStream) Recv() (Event, error) {
+	event, err := s.Stream.Recv()
+	if err == io.EOF {
+		s.finish()
+	}
+	if err != nil {
+		return event, err
+	}
+	switch event.Type {
+	case UsageEvent:
+		s.usage = event.Usage
+	case ReasoningDelta, ContentDelta:
+		s.chars += len(event.Text)
+	case ToolCallDelta:
+		s.chars += len(event.ToolCall.Name) + len(event.ToolCall.Arguments)
+	}
+	return event, nil
+}
+
+// Close implements Stream. A stream closed early is recorded with what it
+// answered so far.
+func (s *meteredStream) Close() error {
+	s.finish()
+	return s.Stream.Close()
+}
+
+// finish records the stream's usage, once.
+func (s *meteredStream) finish() {
+	s.once.Do(func() {
+		usage := s.usage
+		if usage == (Usage{}) {
+			usage = Usage{PromptTokens: s.prompt, CompletionTokens: estimateChars(s.chars)}
+		}
+		s.record(usage)
+	})
+}
+
+// estimateChars approximates the tokens of chars characters of text.
+func estimateChars(chars int) int {
+	return (chars + charsPerToken - 1) / charsPerToken
+}
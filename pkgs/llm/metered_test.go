@@ -0,0 +1,97 @@
+// nolint:testpackage
+package llm
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+// streamingProvider streams events.
+type streamingProvider struct {
+	countingProvider
+	events []Event
+}
+
+func (p *streamingProvider) Stream(context.Context, Request) (Stream, error) {
+	return &fakeStream{events: append([]Event(nil), p.events...)}, nil
+}
+
+// usageRecorder is a MeterFunc recording what it receives.
+type usageRecorder struct {
+	models []string
+	usages []Usage
+}
+
+func (r *usageRecorder) record(model string, usage Usage) {
+	r.models = append(r.models, model)
+	r.usages = append(r.usages, usage)
+}
+
+func TestMeteredComplete(t *testing.T) {
+	recorder := &usageRecorder{}
+	p := Metered(&countingProvider{usage: Usage{PromptTokens: 12, CompletionTokens: 3}}, recorder.record)
+	if _, err := p.Complete(context.Background(), Request{Model: "m"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without reported usage, tokens are estimated
+	unreported := Metered(&countingProvider{}, recorder.record)
+	req := Request{Model: "n", Messages: []Message{{Role: RoleUser, Content: "12345678"}}}
+	if _, err := unreported.Complete(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Usage{{PromptTokens: 12, CompletionTokens: 3}, {PromptTokens: perMessageTokens + 2, CompletionTokens: 1}}
+	if len(recorder.usages) != 2 || recorder.usages[0] != expected[0] || recorder.usages[1] != expected[1] {
+		t.Errorf("Expected %+v, got %+v", expected, recorder.usages)
+	}
+	if recorder.models[0] != "m" || recorder.models[1] != "n" {
+		t.Errorf("Expected the models of the requests, got %v", recorder.models)
+	}
+}
+
+func TestMeteredStream(t *testing.T) {
+	recorder := &usageRecorder{}
+	p := Metered(&streamingProvider{events: []Event{
+		{Type: ContentDelta, Text: "answer"},
+		{Type: UsageEvent, Usage: Usage{PromptTokens: 20, CompletionTokens: 2}},
+	}}, recorder.record)
+
+	stream, err := p.Stream(context.Background(), Request{Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		}
+	}
+	stream.Close()
+	if len(recorder.usages) != 1 || recorder.usages[0] != (Usage{PromptTokens: 20, CompletionTokens: 2}) {
+		t.Errorf("Expected the reported usage recorded once, got %+v", recorder.usages)
+	}
+
+	// A stream closed early without usage is estimated from what it answered
+	p = Metered(&streamingProvider{events: []Event{{Type: ContentDelta, Text: "12345678"}, {Type: ContentDelta, Text: "unread"}}}, recorder.record)
+	stream, err = p.Stream(context.Background(), Request{Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	stream.Close()
+	if len(recorder.usages) != 2 || recorder.usages[1] != (Usage{CompletionTokens: 2}) {
+		t.Errorf("Expected an estimate of the answer so far, got %+v", recorder.usages)
+	}
+}
+
+func TestMeteredEmbedder(t *testing.T) {
+	if _, ok := Metered(&countingProvider{}, nil).(Embedder); ok {
+		t.Errorf("Expected no Embedder for a provider without embeddings")
+	}
+	if _, ok := Metered(NewOpenAIProvider("", ""), nil).(Embedder); !ok {
+		t.Errorf("Expected an Embedder for a provider with embeddings")
+	}
+}
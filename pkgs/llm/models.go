@@ -0,0 +1,130 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ModelLister lists the models a provider serves. Not every provider can,
+// Azure OpenAI serving deployments instead; check with a type assertion.
+type ModelLister interface {
+	// ListModels returns the names of the models, sorted.
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// ListModels implements ModelLister.
+func (p *OpenAIProvider) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := p.client.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]string, 0, len(resp.Models))
+	for _, model := range resp.Models {
+		models = append(models, model.ID)
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+// ListModels implements ModelLister.
+func (p *AnthropicProvider) ListModels(ctx context.Context) ([]string, error) {
+	var models []string
+	query := url.Values{"limit": {"1000"}}
+	for {
+		var page struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+			HasMore bool   `json:"has_more"`
+			LastID  string `json:"last_id"`
+		}
+		if err := p.get(ctx, "/v1/models?"+query.Encode(), &page); err != nil {
+			return nil, err
+		}
+		for _, model := range page.Data {
+			models = append(models, model.ID)
+		}
+		if !page.HasMore || page.LastID == "" {
+			break
+		}
+		query.Set("after_id", page.LastID)
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+// get fetches path from the API and decodes the JSON response into v.
+func (p *AnthropicProvider) get(ctx context.Context, path string, v any) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("X-Api-Key", p.apiKey)
+	httpReq.Header.Set("Anthropic-Version", anthropicVersion)
+
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return decodeAnthropicError(httpResp)
+	}
+	return json.NewDecoder(httpResp.Body).Decode(v)
+}
+
+// ListModels implements ModelLister. Only models that generate content are
+// listed, leaving out embedding models.
+func (p *GeminiProvider) ListModels(ctx context.Context) ([]string, error) {
+	var models []string
+	query := url.Values{"pageSize": {"1000"}}
+	for {
+		var page struct {
+			Models []struct {
+				Name    string   `json:"name"`
+				Methods []string `json:"supportedGenerationMethods"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := p.get(ctx, "/v1beta/models?"+query.Encode(), &page); err != nil {
+			return nil, err
+		}
+		for _, model := range page.Models {
+			for _, method := range model.Methods {
+				if method == "generateContent" {
+					models = append(models, strings.TrimPrefix(model.Name, "models/"))
+					break
+				}
+			}
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+// get fetches path from the API and decodes the JSON response into v.
+func (p *GeminiProvider) get(ctx context.Context, path string, v any) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("X-Goog-Api-Key", p.apiKey)
+
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return decodeGeminiError(httpResp)
+	}
+	return json.NewDecoder(httpResp.Body).Decode(v)
+}
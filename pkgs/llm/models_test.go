@@ -0,0 +1,55 @@
+// nolint:testpackage
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestListModels(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/models", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"}]}`)
+	})
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("after_id") == "" {
+			fmt.Fprint(w, `{"data":[{"id":"claude-sonnet-4-5"}],"has_more":true,"last_id":"claude-sonnet-4-5"}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"claude-haiku-4-5"}],"has_more":false}`)
+	})
+	mux.HandleFunc("/v1beta/models", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pageToken") == "" {
+			fmt.Fprint(w, `{"models":[{"name":"models/gemini-2.5-pro","supportedGenerationMethods":["generateContent"]}],"nextPageToken":"next"}`)
+			return
+		}
+		fmt.Fprint(w, `{"models":[{"name":"models/text-embedding-004","supportedGenerationMethods":["embedContent"]}]}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		lister   ModelLister
+		expected []string
+	}{
+		{"openai", NewOpenAIProvider("key", server.URL), []string{"gpt-4o", "gpt-4o-mini"}},
+		{"anthropic", NewAnthropicProvider("key", server.URL), []string{"claude-haiku-4-5", "claude-sonnet-4-5"}},
+		{"gemini", NewGeminiProvider("key", server.URL), []string{"gemini-2.5-pro"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			models, err := tt.lister.ListModels(context.Background())
+			if err != nil {
+				t.Fatalf("ListModels failed: %v", err)
+			}
+			if !slices.Equal(models, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, models)
+			}
+		})
+	}
+}
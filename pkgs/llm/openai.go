@@ -0,0 +1,205 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenAIProvider talks to OpenAI-compatible chat completion APIs.
+type OpenAIProvider struct {
+	name   string
+	client *openai.Client
+}
+
+// NewOpenAIProvider creates a provider for the API at baseURL.
+// An empty baseURL uses the official OpenAI endpoint.
+func NewOpenAIProvider(apiKey, baseURL string) *OpenAIProvider {
+	return newOpenAIProvider(apiKey, baseURL, http.DefaultClient)
+}
+
+// newOpenAIProvider creates an OpenAI provider that sends requests with client.
+func newOpenAIProvider(apiKey, baseURL string, client *http.Client) *OpenAIProvider {
+	config := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		config.BaseURL = baseURL
+	}
+	config.HTTPClient = withCleanStreams(client)
+
+	return &OpenAIProvider{
+		name:   ProviderOpenAI,
+		client: openai.NewClientWithConfig(config),
+	}
+}
+
+// Complete implements Provider.
+func (p *OpenAIProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	resp, err := p.client.CreateChatCompletion(ctx, toOpenAIRequest(req))
+	if err != nil {
+		return Response{}, openAIPromptFilterError(p.name, err)
+	}
+	if len(resp.Choices) == 0 {
+		return Response{}, errors.New("response contains no choices")
+	}
+
+	choice := resp.Choices[0]
+	if err := openAIAnswerFilterError(p.name, choice.FinishReason, choice.ContentFilterResults,
+		choice.Message.Refusal); err != nil {
+		return Response{}, err
+	}
+
+	return Response{
+		Content:          choice.Message.Content,
+		ReasoningContent: choice.Message.ReasoningContent,
+		Usage: Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+// Stream implements Provider.
+func (p *OpenAIProvider) Stream(ctx context.Context, req Request) (Stream, error) {
+	openaiReq := toOpenAIRequest(req)
+	openaiReq.Stream = true
+	openaiReq.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+
+	stream, err := p.client.CreateChatCompletionStream(ctx, openaiReq)
+	if err != nil {
+		return nil, openAIPromptFilterError(p.name, err)
+	}
+	return &openAIStream{name: p.name, stream: stream}, nil
+}
+
+// CountTokens implements Provider.
+func (p *OpenAIProvider) CountTokens(messages []Message) int {
+	return EstimateTokens(messages)
+}
+
+// openAIStream adapts an OpenAI chat completion stream to Stream.
+type openAIStream struct {
+	name   string
+	stream *openai.ChatCompletionStream
+	queue  eventQueue
+}
+
+// Recv implements Stream.
+func (s *openAIStream) Recv() (Event, error) {
+	for {
+		if event, ok := s.queue.pop(); ok {
+			return event, nil
+		}
+
+		recv, err := s.stream.Recv()
+		if err != nil {
+			return Event{}, err
+		}
+		if err := s.decode(recv); err != nil {
+			return Event{}, err
+		}
+	}
+}
+
+// decode queues the events carried by one chunk.
+func (s *openAIStream) decode(recv openai.ChatCompletionStreamResponse) error {
+	if len(recv.Choices) > 0 {
+		choice := recv.Choices[0]
+		if err := openAIAnswerFilterError(s.name, choice.FinishReason, choice.ContentFilterResults,
+			choice.Delta.Refusal); err != nil {
+			return err
+		}
+
+		delta := choice.Delta
+		s.queue.pushText(delta.ReasoningContent, delta.Content)
+		for i, toolCall := range delta.ToolCalls {
+			index := i
+			if toolCall.Index != nil {
+				index = *toolCall.Index
+			}
+			s.queue.push(Event{Type: ToolCallDelta, ToolCall: ToolCall{
+				Index:     index,
+				ID:        toolCall.ID,
+				Name:      toolCall.Function.Name,
+				Arguments: toolCall.Function.Arguments,
+			}})
+		}
+	}
+
+	if recv.Usage != nil {
+		s.queue.push(Event{Type: UsageEvent, Usage: Usage{
+			PromptTokens:     recv.Usage.PromptTokens,
+			CompletionTokens: recv.Usage.CompletionTokens,
+		}})
+	}
+	return nil
+}
+
+// Close implements Stream.
+func (s *openAIStream) Close() error {
+	return s.stream.Close()
+}
+
+// toOpenAIRequest converts req to the go-openai request type.
+func toOpenAIRequest(req Request) openai.ChatCompletionRequest {
+	messages := make([]openai.ChatCompletionMessage, 0, len(req.Messages))
+	for _, message := range req.Messages {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    string(message.Role),
+			Content: message.Content,
+		})
+	}
+
+	out := openai.ChatCompletionRequest{
+		Model:           req.Model,
+		Messages:        messages,
+		Temperature:     req.Temperature,
+		MaxTokens:       req.MaxTokens,
+		Stop:            req.Stop,
+		ReasoningEffort: req.ReasoningEffort,
+	}
+
+	// Reasoning models reject max_tokens; their limit also covers reasoning
+	if req.ReasoningEffort != "" {
+		out.MaxTokens, out.MaxCompletionTokens = 0, req.MaxTokens
+	}
+	return out
+}
+
+// openAIPromptFilterError converts an API error raised because the prompt
+// tripped the content filter (as Azure OpenAI does) into a ContentFilterError.
+// Other errors are returned unchanged.
+func openAIPromptFilterError(provider string, err error) error {
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "content_filter" {
+		return err
+	}
+
+	filterErr := &ContentFilterError{
+		Provider: provider,
+		Stage:    FilterStagePrompt,
+		Message:  apiErr.Message,
+	}
+	if apiErr.InnerError != nil {
+		filterErr.Categories = openAIFilteredCategories(apiErr.InnerError.ContentFilterResults)
+	}
+	return filterErr
+}
+
+// openAIAnswerFilterError returns a ContentFilterError if the answer was
+// filtered or the model refused, and nil otherwise.
+func openAIAnswerFilterError(provider string, reason openai.FinishReason, results openai.ContentFilterResults,
+	refusal string,
+) error {
+	if reason != openai.FinishReasonContentFilter && refusal == "" {
+		return nil
+	}
+
+	return &ContentFilterError{
+		Provider:   provider,
+		Stage:      FilterStageAnswer,
+		Categories: openAIFilteredCategories(results),
+		Message:    refusal,
+	}
+}
@@ -0,0 +1,203 @@
+// nolint:testpackage
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestServer serves handler under the chat completions path.
+func newTestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat/completions", handler)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenAIProviderComplete(t *testing.T) {
+	var got map[string]any
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer test-key" {
+			t.Errorf("Expected bearer auth header, got %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"answer","reasoning_content":"thoughts"}}],
+			"usage":{"prompt_tokens":12,"completion_tokens":3}}`)
+	})
+
+	provider := NewOpenAIProvider("test-key", server.URL)
+	resp, err := provider.Complete(context.Background(), Request{
+		Model: "test-model",
+		Messages: []Message{
+			{Role: RoleSystem, Content: "system"},
+			{Role: RoleUser, Content: "question"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	if resp.Content != "answer" || resp.ReasoningContent != "thoughts" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 3 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+	if got["model"] != "test-model" {
+		t.Errorf("Expected model test-model, got %v", got["model"])
+	}
+	if messages, _ := got["messages"].([]any); len(messages) != 2 {
+		t.Errorf("Expected 2 messages, got %v", got["messages"])
+	}
+}
+
+func TestOpenAIProviderCompleteNoChoices(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[]}`)
+	})
+
+	provider := NewOpenAIProvider("test-key", server.URL)
+	if _, err := provider.Complete(context.Background(), Request{Model: "m"}); err == nil {
+		t.Errorf("Expected error for response without choices")
+	}
+}
+
+func TestOpenAIProviderStream(t *testing.T) {
+	var got map[string]any
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"choices":[{"delta":{"role":"assistant","reasoning_content":"think"}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"grep","arguments":"{\"a\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"1}"}}]}}]}`,
+			`{"choices":[{"delta":{"content":"hello","reasoning_content":"done"}}]}`,
+			`{"choices":[]}`,
+			`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":3}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	provider := NewOpenAIProvider("test-key", server.URL)
+	stream, err := provider.Stream(context.Background(), Request{Model: "m"})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	defer stream.Close()
+
+	events := collectEvents(t, stream)
+	expected := []Event{
+		{Type: ReasoningDelta, Text: "think"},
+		{Type: ToolCallDelta, ToolCall: ToolCall{ID: "call_1", Name: "grep", Arguments: `{"a":`}},
+		{Type: ToolCallDelta, ToolCall: ToolCall{Arguments: "1}"}},
+		{Type: ReasoningDelta, Text: "done"},
+		{Type: ContentDelta, Text: "hello"},
+		{Type: UsageEvent, Usage: Usage{PromptTokens: 12, CompletionTokens: 3}},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Event %d: expected %+v, got %+v", i, expected[i], events[i])
+		}
+	}
+
+	if options, _ := got["stream_options"].(map[string]any); options["include_usage"] != true {
+		t.Errorf("Expected usage to be requested, got %v", got["stream_options"])
+	}
+}
+
+// collectEvents reads stream until io.EOF.
+func collectEvents(t *testing.T, stream Stream) []Event {
+	t.Helper()
+	var events []Event
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		events = append(events, event)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	messages := []Message{
+		{Role: RoleSystem, Content: ""},
+		{Role: RoleUser, Content: "12345678"},
+		{Role: RoleUser, Content: "123456789"},
+	}
+	// 3 messages of framing, plus 0 + 2 + 3 content tokens
+	if got := EstimateTokens(messages); got != 3*perMessageTokens+5 {
+		t.Errorf("EstimateTokens = %d, expected %d", got, 3*perMessageTokens+5)
+	}
+
+	provider := NewOpenAIProvider("", "")
+	if got := provider.CountTokens(messages); got != EstimateTokens(messages) {
+		t.Errorf("CountTokens = %d, expected %d", got, EstimateTokens(messages))
+	}
+}
+
+func TestOpenAIProviderEmbed(t *testing.T) {
+	var got map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("/embeddings", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		// Out of order, as the API does not promise ordering
+		fmt.Fprint(w, `{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := NewOpenAIProvider("test-key", server.URL)
+	vectors, err := provider.Embed(context.Background(), "embed-model", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Errorf("Expected vectors in input order, got %v", vectors)
+	}
+	if got["model"] != "embed-model" {
+		t.Errorf("Expected model embed-model, got %v", got["model"])
+	}
+
+	if _, err := provider.Embed(context.Background(), "embed-model", []string{"a"}); err == nil {
+		t.Errorf("Expected error when the number of embeddings does not match")
+	}
+}
+
+func TestToOpenAIRequestReasoning(t *testing.T) {
+	out := toOpenAIRequest(Request{Model: "o3", MaxTokens: 1000, ReasoningEffort: "high", Stop: []string{"END"}})
+	if out.ReasoningEffort != "high" || out.MaxTokens != 0 || out.MaxCompletionTokens != 1000 {
+		t.Errorf("Expected reasoning effort with max_completion_tokens, got %+v", out)
+	}
+	if len(out.Stop) != 1 || out.Stop[0] != "END" {
+		t.Errorf("Expected stop sequences, got %v", out.Stop)
+	}
+
+	if out := toOpenAIRequest(Request{Model: "gpt-4o", MaxTokens: 1000}); out.MaxTokens != 1000 || out.MaxCompletionTokens != 0 {
+		t.Errorf("Expected max_tokens without reasoning effort, got %+v", out)
+	}
+}
@@ -0,0 +1,170 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit is the rate a provider accepts requests at, such as the limits of
+// a free tier. Zero fields are not limited.
+type RateLimit struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// WaitFunc waits d before a request, returning early with ctx's error if ctx
+// is cancelled.
+type WaitFunc func(ctx context.Context, d time.Duration) error
+
+// Sleep is a WaitFunc that waits silently.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RateLimited wraps p so its requests stay under limit instead of failing
+// with 429 errors: requests are spaced evenly over the minute, and a request
+// whose tokens would take the last minute over the token limit waits until
+// enough earlier ones fall out of it. wait does the waiting, nil selects
+// Sleep. The result is an Embedder if p is, and embedding requests count
+// against the same limit.
+func RateLimited(p Provider, limit RateLimit, wait WaitFunc) Provider {
+	if wait == nil {
+		wait = Sleep
+	}
+	r := &rateLimitedProvider{Provider: p, limiter: &rateLimiter{limit: limit, wait: wait, now: time.Now}}
+	if embedder, ok := p.(Embedder); ok {
+		return &rateLimitedEmbedder{rateLimitedProvider: r, embedder: embedder}
+	}
+	return r
+}
+
+// rateLimitedProvider is a Provider whose requests go through a rateLimiter.
+type rateLimitedProvider struct {
+	Provider
+	limiter *rateLimiter
+}
+
+// Complete implements Provider.
+func (p *rateLimitedProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	sent, err := p.limiter.reserve(ctx, p.CountTokens(req.Messages)+req.MaxTokens)
+	if err != nil {
+		return Response{}, err
+	}
+	resp, err := p.Provider.Complete(ctx, req)
+	if used := resp.Usage.PromptTokens + resp.Usage.CompletionTokens; used > 0 {
+		p.limiter.settle(sent, used)
+	}
+	return resp, err
+}
+
+// Stream implements Provider.
+func (p *rateLimitedProvider) Stream(ctx context.Context, req Request) (Stream, error) {
+	if _, err := p.limiter.reserve(ctx, p.CountTokens(req.Messages)+req.MaxTokens); err != nil {
+		return nil, err
+	}
+	return p.Provider.Stream(ctx, req)
+}
+
+// rateLimitedEmbedder is a rateLimitedProvider that also embeds.
+type rateLimitedEmbedder struct {
+	*rateLimitedProvider
+	embedder Embedder
+}
+
+// Embed implements Embedder.
+func (p *rateLimitedEmbedder) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	messages := make([]Message, len(texts))
+	for i, text := range texts {
+		messages[i] = Message{Role: RoleUser, Content: text}
+	}
+	if _, err := p.limiter.reserve(ctx, EstimateTokens(messages)); err != nil {
+		return nil, err
+	}
+	return p.embedder.Embed(ctx, model, texts)
+}
+
+// sentRequest is a request counted against the token limit.
+type sentRequest struct {
+	at     time.Time
+	tokens int
+}
+
+// rateLimiter tracks the requests of the last minute.
+type rateLimiter struct {
+	limit RateLimit
+	wait  WaitFunc
+	// now returns the current time; tests replace it.
+	now func() time.Time
+
+	mu   sync.Mutex
+	last time.Time
+	sent []*sentRequest
+}
+
+// reserve waits until a request of tokens fits under the limit and records
+// it as sent.
+func (l *rateLimiter) reserve(ctx context.Context, tokens int) (*sentRequest, error) {
+	for {
+		l.mu.Lock()
+		now := l.now()
+		delay := l.delay(now, tokens)
+		if delay <= 0 {
+			sent := &sentRequest{at: now, tokens: tokens}
+			l.last = now
+			l.sent = append(l.sent, sent)
+			l.mu.Unlock()
+			return sent, nil
+		}
+		l.mu.Unlock()
+
+		if err := l.wait(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// delay returns how long a request of tokens has to wait at now. It drops
+// the requests older than a minute.
+func (l *rateLimiter) delay(now time.Time, tokens int) time.Duration {
+	kept := l.sent[:0]
+	used := 0
+	for _, sent := range l.sent {
+		if now.Sub(sent.at) < time.Minute {
+			kept = append(kept, sent)
+			used += sent.tokens
+		}
+	}
+	l.sent = kept
+
+	var delay time.Duration
+	if l.limit.RequestsPerMinute > 0 && !l.last.IsZero() {
+		delay = l.last.Add(time.Minute / time.Duration(l.limit.RequestsPerMinute)).Sub(now)
+	}
+	if l.limit.TokensPerMinute > 0 {
+		// A request over the whole limit goes once the minute is clear
+		for _, sent := range l.sent {
+			if used+tokens <= l.limit.TokensPerMinute {
+				break
+			}
+			used -= sent.tokens
+			delay = max(delay, sent.at.Add(time.Minute).Sub(now))
+		}
+	}
+	return delay
+}
+
+// settle replaces the estimated tokens of sent with the tokens the provider
+// reported using.
+func (l *rateLimiter) settle(sent *sentRequest, tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sent.tokens = tokens
+}
@@ -0,0 +1,118 @@
+// nolint:testpackage
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingProvider answers every request, reporting usage tokens.
+type countingProvider struct {
+	requests int
+	usage    Usage
+}
+
+func (p *countingProvider) Complete(context.Context, Request) (Response, error) {
+	p.requests++
+	return Response{Content: "ok", Usage: p.usage}, nil
+}
+
+func (p *countingProvider) Stream(context.Context, Request) (Stream, error) {
+	p.requests++
+	return &fakeStream{}, nil
+}
+
+func (p *countingProvider) CountTokens(messages []Message) int {
+	return EstimateTokens(messages)
+}
+
+// fakeClock is a WaitFunc that advances time instead of sleeping.
+type fakeClock struct {
+	now   time.Time
+	waits []time.Duration
+}
+
+func (c *fakeClock) wait(_ context.Context, d time.Duration) error {
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func newTestRateLimited(p Provider, limit RateLimit) (*rateLimitedProvider, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r := RateLimited(p, limit, clock.wait).(*rateLimitedProvider)
+	r.limiter.now = func() time.Time { return clock.now }
+	return r, clock
+}
+
+func TestRateLimitedRequests(t *testing.T) {
+	inner := &countingProvider{}
+	r, clock := newTestRateLimited(inner, RateLimit{RequestsPerMinute: 3})
+
+	for range 3 {
+		if _, err := r.Complete(context.Background(), Request{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if inner.requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", inner.requests)
+	}
+	expected := []time.Duration{20 * time.Second, 20 * time.Second}
+	if len(clock.waits) != len(expected) || clock.waits[0] != expected[0] || clock.waits[1] != expected[1] {
+		t.Errorf("Expected requests spaced by %v, got waits %v", expected, clock.waits)
+	}
+
+	// Time spent elsewhere counts towards the spacing
+	clock.now = clock.now.Add(15 * time.Second)
+	if _, err := r.Stream(context.Background(), Request{}); err != nil {
+		t.Fatal(err)
+	}
+	if last := clock.waits[len(clock.waits)-1]; last != 5*time.Second {
+		t.Errorf("Expected to wait the rest of the interval, got %v", last)
+	}
+}
+
+func TestRateLimitedTokens(t *testing.T) {
+	inner := &countingProvider{usage: Usage{PromptTokens: 700, CompletionTokens: 298}}
+	r, clock := newTestRateLimited(inner, RateLimit{TokensPerMinute: 1000})
+	req := Request{Messages: []Message{{Role: RoleUser, Content: "hello"}}}
+
+	if _, err := r.Complete(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = clock.now.Add(10 * time.Second)
+	if _, err := r.Complete(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.waits) != 1 || clock.waits[0] != 50*time.Second {
+		t.Errorf("Expected to wait for the first request's 998 tokens to leave the minute, got %v", clock.waits)
+	}
+}
+
+func TestRateLimitedCancel(t *testing.T) {
+	inner := &countingProvider{}
+	r := RateLimited(inner, RateLimit{RequestsPerMinute: 1}, nil)
+	if _, err := r.Complete(context.Background(), Request{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.Complete(ctx, Request{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the wait to end with the context, got %v", err)
+	}
+	if inner.requests != 1 {
+		t.Errorf("Expected the cancelled request not to be sent, got %d requests", inner.requests)
+	}
+}
+
+func TestRateLimitedEmbedder(t *testing.T) {
+	if _, ok := RateLimited(&countingProvider{}, RateLimit{}, nil).(Embedder); ok {
+		t.Errorf("Expected no Embedder for a provider without embeddings")
+	}
+	if _, ok := RateLimited(NewOpenAIProvider("", ""), RateLimit{}, nil).(Embedder); !ok {
+		t.Errorf("Expected an Embedder for a provider with embeddings")
+	}
+}
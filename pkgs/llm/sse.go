@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxStreamLineSize bounds a single server-sent event line.
+const maxStreamLineSize = 1 << 20
+
+// sseReader extracts data payloads from a server-sent event stream.
+type sseReader struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+// newSSEReader reads events from body.
+func newSSEReader(body io.ReadCloser) *sseReader {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+
+	return &sseReader{
+		body:    body,
+		scanner: scanner,
+	}
+}
+
+// next returns the payload of the next "data:" line, or io.EOF at the end of
+// the stream. Event names, comments, blank lines and heartbeats are skipped.
+func (r *sseReader) next() (string, error) {
+	for r.scanner.Scan() {
+		data, ok := strings.CutPrefix(r.scanner.Text(), "data:")
+		if data = strings.TrimSpace(data); ok && !isHeartbeat(data) {
+			return data, nil
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+// Close closes the underlying body.
+func (r *sseReader) Close() error {
+	return r.body.Close()
+}
+
+// isHeartbeat reports whether an event payload is a keep-alive rather than
+// data. Providers and proxies keep idle streams open with payloads such as
+// "ping" or empty data lines; every real payload is a JSON value, or the
+// "[DONE]" marker ending OpenAI streams.
+func isHeartbeat(data string) bool {
+	if data == openAIStreamDone {
+		return false
+	}
+	return !strings.HasPrefix(data, "{") && !strings.HasPrefix(data, "[")
+}
+
+// openAIStreamDone is the payload ending an OpenAI chat completion stream.
+const openAIStreamDone = "[DONE]"
+
+// cleanStreamTransport rewrites successful event stream responses into the
+// strict form go-openai parses: one "data: " line per payload, with
+// comments, other fields and heartbeats removed. go-openai fails on
+// non-JSON payloads and counts every other line towards a limit of empty
+// messages, so long keep-alive periods would otherwise end the stream.
+type cleanStreamTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *cleanStreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK ||
+		!strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return resp, err
+	}
+
+	events := newSSEReader(resp.Body)
+	pr, pw := io.Pipe()
+	go func() {
+		for {
+			data, err := events.next()
+			if err != nil {
+				events.Close()
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := fmt.Fprintf(pw, "data: %s\n\n", data); err != nil {
+				events.Close()
+				return
+			}
+		}
+	}()
+
+	resp.Body = pr
+	resp.ContentLength = -1
+	return resp, nil
+}
+
+// withCleanStreams returns a copy of client whose event stream responses are
+// cleaned by cleanStreamTransport.
+func withCleanStreams(client *http.Client) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	cleaned := *client
+	cleaned.Transport = &cleanStreamTransport{base: base}
+	return &cleaned
+}
@@ -0,0 +1,109 @@
+// nolint:testpackage
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// streamFromBody streams a completion from a provider of kind whose server
+// answers every request with body as an event stream.
+func streamFromBody(t *testing.T, kind, body string) []Event {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	var provider Provider
+	switch kind {
+	case ProviderAnthropic:
+		provider = NewAnthropicProvider("test-key", server.URL)
+	case ProviderGemini:
+		provider = NewGeminiProvider("test-key", server.URL)
+	default:
+		provider = NewOpenAIProvider("test-key", server.URL)
+	}
+
+	stream, err := provider.Stream(context.Background(), Request{Model: "m"})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	defer stream.Close()
+	return collectEvents(t, stream)
+}
+
+// joinText concatenates the reasoning and content fragments of events.
+func joinText(events []Event) (reasoning, content string) {
+	for _, event := range events {
+		switch event.Type {
+		case ReasoningDelta:
+			reasoning += event.Text
+		case ContentDelta:
+			content += event.Text
+		}
+	}
+	return reasoning, content
+}
+
+func TestStreamFixtures(t *testing.T) {
+	cases := []struct {
+		fixture   string
+		kind      string
+		reasoning string
+	}{
+		{"azure_openai.sse", ProviderOpenAI, ""},
+		{"openrouter.sse", ProviderOpenAI, ""},
+		{"deepseek_reasoner.sse", ProviderOpenAI, "Greet the user."},
+		{"volcengine_ark.sse", ProviderOpenAI, "Greet the user."},
+		{"proxy_heartbeat.sse", ProviderOpenAI, "Greet the user."},
+		{"anthropic.sse", ProviderAnthropic, "Greet the user."},
+		{"gemini.sse", ProviderGemini, "Greet the user."},
+	}
+
+	for _, c := range cases {
+		t.Run(c.fixture, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", "streams", c.fixture))
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+
+			reasoning, content := joinText(streamFromBody(t, c.kind, string(body)))
+			if reasoning != c.reasoning || content != "Hello world" {
+				t.Errorf("Expected reasoning %q and content %q, got %q and %q", c.reasoning, "Hello world", reasoning, content)
+			}
+		})
+	}
+}
+
+func TestStreamLongKeepAlive(t *testing.T) {
+	// go-openai gives up after 300 non-data lines in a row
+	body := strings.Repeat(": keep-alive\n\n", 500) +
+		`data: {"choices":[{"index":0,"delta":{"content":"Hello world"}}]}` + "\n\n" +
+		"data: [DONE]\n\n"
+
+	if _, content := joinText(streamFromBody(t, ProviderOpenAI, body)); content != "Hello world" {
+		t.Errorf("Expected the answer after keep-alives, got %q", content)
+	}
+}
+
+func TestIsHeartbeat(t *testing.T) {
+	for data, expected := range map[string]bool{
+		"":           true,
+		"ping":       true,
+		"keep-alive": true,
+		"[DONE]":     false,
+		`{"a":1}`:    false,
+		"[1]":        false,
+	} {
+		if got := isHeartbeat(data); got != expected {
+			t.Errorf("isHeartbeat(%q): expected %v, got %v", data, expected, got)
+		}
+	}
+}
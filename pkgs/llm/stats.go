@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"time"
+)
+
+// Stats are the latency figures of one request, for comparing providers and
+// models on real workloads.
+type Stats struct {
+	// TTFT is the time from sending the request to the first reasoning,
+	// answer or tool call token. It is zero for non-streamed requests.
+	TTFT time.Duration
+	// Duration is the time from sending the request to the end of the answer.
+	Duration time.Duration
+	// Usage is the token usage of the request.
+	Usage Usage
+	// Estimated is set when the provider did not report completion tokens
+	// and Usage.CompletionTokens was estimated from the output length.
+	Estimated bool
+}
+
+// TokensPerSecond returns the output rate. For streamed requests it covers
+// the time after the first token, so prompt processing is not counted
+// against generation speed.
+func (s Stats) TokensPerSecond() float64 {
+	generation := s.Duration - s.TTFT
+	if generation <= 0 || s.Usage.CompletionTokens == 0 {
+		return 0
+	}
+	return float64(s.Usage.CompletionTokens) / generation.Seconds()
+}
+
+// ResponseStats returns the stats of a non-streamed request that was sent at
+// start and answered with resp.
+func ResponseStats(resp Response, start time.Time) Stats {
+	stats := Stats{Duration: time.Since(start), Usage: resp.Usage}
+	stats.estimate(len(resp.ReasoningContent) + len(resp.Content))
+	return stats
+}
+
+// estimate fills in the completion tokens from the number of characters
+// generated when the provider did not report them.
+func (s *Stats) estimate(chars int) {
+	if s.Usage.CompletionTokens == 0 && chars > 0 {
+		s.Usage.CompletionTokens = (chars + charsPerToken - 1) / charsPerToken
+		s.Estimated = true
+	}
+}
+
+// MeasuredStream wraps a Stream and records its Stats as events are received.
+type MeasuredStream struct {
+	Stream
+
+	start time.Time
+	stats Stats
+	chars int
+	// now returns the current time; tests replace it.
+	now func() time.Time
+}
+
+// Measure starts recording the stats of stream, whose request was sent at
+// start.
+func Measure(stream Stream, start time.Time) *MeasuredStream {
+	return &MeasuredStream{Stream: stream, start: start, now: time.Now}
+}
+
+// Recv implements Stream.
+func (s *MeasuredStream) Recv() (Event, error) {
+	event, err := s.Stream.Recv()
+	if err != nil {
+		s.stats.Duration = s.now().Sub(s.start)
+		return event, err
+	}
+
+	switch event.Type {
+	case UsageEvent:
+		s.stats.Usage = event.Usage
+	default:
+		if s.stats.TTFT == 0 {
+			s.stats.TTFT = s.now().Sub(s.start)
+		}
+		s.chars += len(event.Text) + len(event.ToolCall.Arguments)
+	}
+	return event, nil
+}
+
+// Stats returns the stats recorded so far; they are complete once Recv has
+// returned an error.
+func (s *MeasuredStream) Stats() Stats {
+	stats := s.stats
+	stats.estimate(s.chars)
+	return stats
+}
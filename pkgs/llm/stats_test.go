@@ -0,0 +1,83 @@
+// nolint:testpackage
+package llm
+
+import (
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+// fakeStream returns events one by one, then io.EOF.
+type fakeStream struct {
+	events []Event
+}
+
+func (s *fakeStream) Recv() (Event, error) {
+	if len(s.events) == 0 {
+		return Event{}, io.EOF
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+func (s *fakeStream) Close() error { return nil }
+
+func TestMeasuredStream(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := start
+	stream := Measure(&fakeStream{events: []Event{
+		{Type: ReasoningDelta, Text: "hmm"},
+		{Type: ContentDelta, Text: "answer"},
+		{Type: UsageEvent, Usage: Usage{PromptTokens: 10, CompletionTokens: 40}},
+	}}, start)
+	stream.now = func() time.Time { return clock }
+
+	// Each event arrives 500ms after the previous one
+	for {
+		clock = clock.Add(500 * time.Millisecond)
+		if _, err := stream.Recv(); err != nil {
+			break
+		}
+	}
+
+	stats := stream.Stats()
+	if stats.TTFT != 500*time.Millisecond || stats.Duration != 2*time.Second {
+		t.Errorf("Expected 500ms TTFT and 2s duration, got %+v", stats)
+	}
+	if stats.Usage.CompletionTokens != 40 || stats.Estimated {
+		t.Errorf("Expected reported usage, got %+v", stats)
+	}
+	// 40 tokens in the 1.5s after the first token
+	if rate := stats.TokensPerSecond(); math.Abs(rate-40/1.5) > 1e-9 {
+		t.Errorf("Expected %.2f tokens/s, got %.2f", 40/1.5, rate)
+	}
+}
+
+func TestMeasuredStreamEstimatesTokens(t *testing.T) {
+	stream := Measure(&fakeStream{events: []Event{{Type: ContentDelta, Text: "12345678"}}}, time.Now())
+	for {
+		if _, err := stream.Recv(); err != nil {
+			break
+		}
+	}
+
+	if stats := stream.Stats(); stats.Usage.CompletionTokens != 2 || !stats.Estimated {
+		t.Errorf("Expected 2 estimated tokens, got %+v", stats)
+	}
+}
+
+func TestResponseStats(t *testing.T) {
+	stats := ResponseStats(Response{Content: "answer", Usage: Usage{CompletionTokens: 7}}, time.Now().Add(-time.Second))
+	if stats.TTFT != 0 || stats.Duration < time.Second || stats.Usage.CompletionTokens != 7 || stats.Estimated {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	if rate := (Stats{Duration: 2 * time.Second, Usage: Usage{CompletionTokens: 10}}).TokensPerSecond(); rate != 5 {
+		t.Errorf("Expected 5 tokens/s, got %v", rate)
+	}
+	if rate := (Stats{}).TokensPerSecond(); rate != 0 {
+		t.Errorf("Expected no rate without tokens, got %v", rate)
+	}
+}
@@ -0,0 +1,146 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AggregateTitle heads the report written by Aggregate.
+const AggregateTitle = "汇总报告"
+
+// Part is the report of one run, such as the analysis of one package, to be
+// merged with others by Aggregate.
+type Part struct {
+	// Name identifies the run, such as the path of its report.
+	Name string
+	// Body is the report without its title.
+	Body string
+	// Sections are the report's "## " sections, their heading as Source.
+	Sections []Answer
+}
+
+// ParsePart reads a Markdown report such as those of report sinks. Its "# "
+// title is dropped, since runs of the same command share it. Headings inside
+// code blocks are not taken for sections.
+func ParsePart(name, content string) Part {
+	part := Part{Name: name}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "# ") {
+			lines = lines[i+1:]
+		}
+		break
+	}
+	part.Body = strings.TrimSpace(strings.Join(lines, "\n"))
+
+	var section *Answer
+	var body []string
+	flush := func() {
+		if section != nil {
+			section.Content = strings.TrimSpace(strings.Join(body, "\n"))
+			part.Sections = append(part.Sections, *section)
+		}
+		body = nil
+	}
+	fence := ""
+	for _, line := range lines {
+		fence = nextFence(fence, line)
+		if fence == "" && strings.HasPrefix(line, "## ") {
+			flush()
+			section = &Answer{Source: strings.TrimSpace(strings.TrimPrefix(line, "## "))}
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	return part
+}
+
+// Digest returns the first paragraph of each section of p, cut to limit
+// runes, for when the whole report is too long to pass on.
+func (p Part) Digest(limit int) string {
+	if len(p.Sections) == 0 {
+		return Summarize(p.Body, limit)
+	}
+	lines := make([]string, 0, len(p.Sections))
+	for _, section := range p.Sections {
+		lines = append(lines, fmt.Sprintf("- %s: %s", section.Source, Summarize(section.Content, limit)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Aggregate merges parts into one Markdown report: summary, an executive
+// summary of the whole, if not empty, then an index linking to each part and
+// listing its sections, then the parts in order under their names, with
+// their headings one level down.
+func Aggregate(summary string, parts []Part) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", AggregateTitle)
+	if summary = strings.TrimSpace(summary); summary != "" {
+		fmt.Fprintf(&b, "\n## 概要\n\n%s\n", summary)
+	}
+
+	b.WriteString("\n## 目录\n\n")
+	for i, part := range parts {
+		fmt.Fprintf(&b, "%d. [%s](#%s)\n", i+1, part.Name, partAnchor(i))
+		for _, section := range part.Sections {
+			fmt.Fprintf(&b, "   - %s\n", section.Source)
+		}
+	}
+
+	for i, part := range parts {
+		fmt.Fprintf(&b, "\n<a id=\"%s\"></a>\n\n## %s\n", partAnchor(i), part.Name)
+		if body := demoteHeadings(part.Body); body != "" {
+			fmt.Fprintf(&b, "\n%s\n", body)
+		}
+	}
+	return b.String()
+}
+
+// partAnchor returns the anchor of the i-th part. Explicit anchors keep the
+// index working whatever characters the names hold.
+func partAnchor(i int) string {
+	return fmt.Sprintf("part-%d", i+1)
+}
+
+// demoteHeadings moves the headings of markdown outside code blocks one
+// level down, as far as the sixth.
+func demoteHeadings(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	fence := ""
+	for i, line := range lines {
+		fence = nextFence(fence, line)
+		if fence != "" {
+			continue
+		}
+		level := len(line) - len(strings.TrimLeft(line, "#"))
+		if level >= 1 && level < 6 && strings.HasPrefix(line[level:], " ") {
+			lines[i] = "#" + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// nextFence tracks code blocks line by line: given the fence of the block
+// line is in, "" outside blocks, it returns the fence of the block the next
+// line is in. Lines that open a block stay inside it until a fence of the
+// same character, at least as long, closes it.
+func nextFence(fence, line string) string {
+	trimmed := strings.TrimSpace(line)
+	if fence != "" {
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			return ""
+		}
+		return fence
+	}
+	for _, c := range []string{"`", "~"} {
+		run := len(trimmed) - len(strings.TrimLeft(trimmed, c))
+		if run >= 3 {
+			return trimmed[:run]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+)
+
+// docsSink writes each answer as a Markdown page.
+type docsSink struct {
+	dir string
+	// now returns the date of the pages; tests replace it.
+	now func() time.Time
+	// written holds the pages of the run, so answers about the same source
+	// do not overwrite each other.
+	written map[string]bool
+}
+
+// NewDocsSink returns a Sink that writes each answer it receives as a
+// Markdown page under dir, headed by YAML front matter (see Page), so static
+// site generators such as MkDocs and Hugo can publish them as they are. An
+// answer about one file is written to the file's path with ".md" appended,
+// e.g. dir/pkgs/config/file.go.md.
+func NewDocsSink(dir string) Sink {
+	return &docsSink{dir: dir, now: time.Now, written: make(map[string]bool)}
+}
+
+// Delta implements Sink; pages are written from finished answers.
+func (s *docsSink) Delta(llm.Event) error { return nil }
+
+// Done implements Sink.
+func (s *docsSink) Done(answer Answer) error {
+	page, err := Page(answer, s.now())
+	if err != nil {
+		return err
+	}
+
+	name := pageName(answer)
+	base := strings.TrimSuffix(name, ".md")
+	for i := 2; s.written[name]; i++ {
+		name = fmt.Sprintf("%s-%d.md", base, i)
+	}
+	s.written[name] = true
+
+	file := filepath.Join(s.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, []byte(page), 0644); err != nil {
+		return fmt.Errorf("failed to write page: %w", err)
+	}
+	return nil
+}
+
+// Close implements Sink; pages are written as answers come in.
+func (s *docsSink) Close(context.Context) error { return nil }
+
+// pageName returns the path of answer's page relative to the docs
+// directory. Paths cannot climb out of it, and absolute paths are taken
+// relative to the working directory where possible.
+func pageName(answer Answer) string {
+	name := answer.Source
+	if len(answer.Files) == 1 {
+		name = answer.Files[0].Path
+		if filepath.IsAbs(name) {
+			if wd, err := os.Getwd(); err == nil {
+				if rel, err := filepath.Rel(wd, name); err == nil {
+					name = rel
+				}
+			}
+		}
+	} else if len(answer.Files) > 1 {
+		paths := make([]string, len(answer.Files))
+		for i, file := range answer.Files {
+			paths[i] = strings.ReplaceAll(filepath.ToSlash(file.Path), "/", "_")
+		}
+		name = strings.Join(paths, "+")
+	}
+	name = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+	if name == "" {
+		name = "answer"
+	}
+	return name + ".md"
+}
+
+// frontMatter is the YAML front matter of a page.
+type frontMatter struct {
+	Title string `yaml:"title"`
+	// Path and Hash describe the file of an answer about one file, and Files
+	// the files of an answer about several.
+	Path     string            `yaml:"path,omitempty"`
+	Hash     string            `yaml:"hash,omitempty"`
+	Files    []File            `yaml:"files,omitempty"`
+	Sources  []Source          `yaml:"sources,omitempty"`
+	Provider string            `yaml:"provider"`
+	Model    string            `yaml:"model"`
+	Date     time.Time         `yaml:"date"`
+	Tokens   frontMatterTokens `yaml:"tokens"`
+}
+
+// frontMatterTokens is the token usage of a page's answer.
+type frontMatterTokens struct {
+	Input  int `yaml:"input"`
+	Output int `yaml:"output"`
+}
+
+// Page renders answer as a Markdown page answered at date, headed by YAML
+// front matter:
+//
+//	---
+//	title: pkgs/config/file.go
+//	path: pkgs/config/file.go
+//	hash: sha256:…
+//	provider: openai
+//	model: gpt-4o
+//	date: 2024-01-31T12:00:00Z
+//	tokens:
+//	  input: 1200
+//	  output: 350
+//	---
+//
+// The title is the answer's source, and the body its content. Reasoning is
+// left out.
+func Page(answer Answer, date time.Time) (string, error) {
+	meta := frontMatter{
+		Title:    answer.Source,
+		Sources:  answer.Sources,
+		Provider: answer.Provider,
+		Model:    answer.Model,
+		Date:     date.Truncate(time.Second),
+		Tokens: frontMatterTokens{
+			Input:  answer.Stats.Usage.PromptTokens,
+			Output: answer.Stats.Usage.CompletionTokens,
+		},
+	}
+	if len(answer.Files) == 1 {
+		meta.Path, meta.Hash = answer.Files[0].Path, answer.Files[0].Hash
+	} else {
+		meta.Files = answer.Files
+	}
+
+	var header strings.Builder
+	encoder := yaml.NewEncoder(&header)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(meta); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("---\n%s---\n\n%s\n", header.String(), strings.TrimSpace(answer.Content)), nil
+}
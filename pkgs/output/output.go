@@ -0,0 +1,301 @@
+// Package output delivers answers to several destinations at once, such as
+// streaming the live answer to the terminal while writing a report file and
+// posting a summary to a webhook once the run is over.
+package output
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+)
+
+// Answer is a finished answer.
+type Answer struct {
+	// Source names what the answer is about, such as the files of the prompt.
+	Source string
+	// Files are the files the answer is about, if any.
+	Files []File
+	// Sources are the retrieved chunks the answer was grounded in, if any.
+	Sources   []Source
+	Provider  string
+	Model     string
+	Reasoning string
+	Content   string
+	Stats     llm.Stats
+}
+
+// File is a file an answer is about.
+type File struct {
+	Path string `yaml:"path"`
+	// Hash identifies the content the answer was given for, as
+	// "sha256:<hex>", so readers can tell whether the answer is current.
+	Hash string `yaml:"hash"`
+}
+
+// Source is a retrieved chunk of a file an answer was grounded in.
+type Source struct {
+	// ID identifies the chunk in the search index it was retrieved from.
+	ID        int64  `yaml:"id"`
+	Path      string `yaml:"path"`
+	StartLine int    `yaml:"start_line"`
+	EndLine   int    `yaml:"end_line"`
+	// FileHash identifies the version of the file the chunk was indexed
+	// from, as "sha256:<hex>".
+	FileHash string `yaml:"file_hash,omitempty"`
+	// Type is "documentation" or "code".
+	Type string `yaml:"type,omitempty"`
+}
+
+// NewFile returns the File at path with content.
+func NewFile(path, content string) File {
+	sum := sha256.Sum256([]byte(content))
+	return File{Path: path, Hash: "sha256:" + hex.EncodeToString(sum[:])}
+}
+
+// Sink is a destination for answers. A run delivers the events of each
+// streamed answer as they arrive, then the finished answer, and closes its
+// sinks once all answers are in. Answers that are not streamed only reach
+// Done.
+type Sink interface {
+	// Delta receives an event of the answer being streamed.
+	Delta(event llm.Event) error
+	// Done receives a finished answer.
+	Done(answer Answer) error
+	// Close completes the sink's output, such as by writing a report of
+	// the answers it received.
+	Close(ctx context.Context) error
+}
+
+// multi delivers to several sinks.
+type multi []Sink
+
+// Multi returns a Sink delivering to each of sinks, in order; nil sinks are
+// left out. A failing sink does not keep the others from receiving an
+// answer: every sink is called and their errors are joined.
+func Multi(sinks ...Sink) Sink {
+	var m multi
+	for _, sink := range sinks {
+		if sink != nil {
+			m = append(m, sink)
+		}
+	}
+	return m
+}
+
+// Delta implements Sink.
+func (m multi) Delta(event llm.Event) error {
+	var errs []error
+	for _, sink := range m {
+		errs = append(errs, sink.Delta(event))
+	}
+	return errors.Join(errs...)
+}
+
+// Done implements Sink.
+func (m multi) Done(answer Answer) error {
+	var errs []error
+	for _, sink := range m {
+		errs = append(errs, sink.Done(answer))
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements Sink.
+func (m multi) Close(ctx context.Context) error {
+	var errs []error
+	for _, sink := range m {
+		errs = append(errs, sink.Close(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// collector keeps the finished answers for sinks that write them all on
+// Close.
+type collector struct {
+	answers []Answer
+}
+
+// Delta implements Sink; collectors only need finished answers.
+func (c *collector) Delta(llm.Event) error { return nil }
+
+// Done implements Sink.
+func (c *collector) Done(answer Answer) error {
+	c.answers = append(c.answers, answer)
+	return nil
+}
+
+// ReportTitle heads the report written by a report sink.
+const ReportTitle = "分析报告"
+
+// reportSink writes the answers as a Markdown report.
+type reportSink struct {
+	collector
+	path string
+	// append adds the answers to an existing report instead of replacing it.
+	append bool
+}
+
+// NewReportSink returns a Sink that writes the answers it receives as a
+// Markdown report (see Report) to the file at path when closed.
+func NewReportSink(path string) Sink {
+	return &reportSink{path: path}
+}
+
+// NewAppendSink returns a Sink that adds the answers it receives to the
+// Markdown report at path when closed, one section per answer after those of
+// earlier runs. A missing report is created as by NewReportSink.
+func NewAppendSink(path string) Sink {
+	return &reportSink{path: path, append: true}
+}
+
+// Close implements Sink.
+func (s *reportSink) Close(context.Context) error {
+	if len(s.answers) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	content := Report(s.answers)
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if s.append {
+		if info, err := os.Stat(s.path); err == nil && info.Size() > 0 {
+			content = sections(s.answers)
+			flag = os.O_WRONLY | os.O_APPEND
+		}
+	}
+	f, err := os.OpenFile(s.path, flag, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// Report renders answers as a Markdown document with one section per
+// answer, headed by its source. Reasoning is left out.
+func Report(answers []Answer) string {
+	return fmt.Sprintf("# %s\n", ReportTitle) + sections(answers)
+}
+
+// sections renders the sections of answers in a report.
+func sections(answers []Answer) string {
+	var b strings.Builder
+	for _, answer := range answers {
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n", answer.Source, strings.TrimSpace(answer.Content))
+	}
+	return b.String()
+}
+
+// summaryRunes bounds the excerpt of each answer a webhook receives.
+const summaryRunes = 300
+
+// webhookSink posts a summary of the answers to a URL.
+type webhookSink struct {
+	collector
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink returns a Sink that posts a summary of the answers it
+// receives to url as JSON when closed. client sends the request; nil uses
+// http.DefaultClient. The payload's text field holds the whole summary, as
+// chat incoming webhooks expect, and answers holds an excerpt per answer:
+//
+//	{"text": "...", "answers": [{"source": "...", "provider": "...", "model": "...", "summary": "..."}]}
+func NewWebhookSink(url string, client *http.Client) Sink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &webhookSink{url: url, client: client}
+}
+
+// webhookPayload is the JSON body posted to a webhook.
+type webhookPayload struct {
+	Text    string          `json:"text"`
+	Answers []answerSummary `json:"answers"`
+}
+
+// answerSummary is the excerpt of an answer posted to a webhook.
+type answerSummary struct {
+	Source   string `json:"source"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Summary  string `json:"summary"`
+}
+
+// Close implements Sink.
+func (s *webhookSink) Close(ctx context.Context) error {
+	if len(s.answers) == 0 {
+		return nil
+	}
+
+	payload := webhookPayload{Answers: make([]answerSummary, 0, len(s.answers))}
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d answer(s)", len(s.answers))
+	for _, answer := range s.answers {
+		summary := Summarize(answer.Content, summaryRunes)
+		payload.Answers = append(payload.Answers, answerSummary{
+			Source:   answer.Source,
+			Provider: answer.Provider,
+			Model:    answer.Model,
+			Summary:  summary,
+		})
+		fmt.Fprintf(&text, "\n\n%s: %s", answer.Source, summary)
+	}
+	payload.Text = text.String()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to post to webhook: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Summarize returns the first paragraph of content, cut to at most limit
+// runes with an ellipsis.
+func Summarize(content string, limit int) string {
+	content = strings.TrimSpace(content)
+	if paragraph, _, found := strings.Cut(content, "\n\n"); found {
+		content = paragraph
+	}
+	content = strings.Join(strings.Fields(content), " ")
+	if utf8.RuneCountInString(content) <= limit {
+		return content
+	}
+	runes := []rune(content)
+	return strings.TrimSpace(string(runes[:limit-1])) + "…"
+}
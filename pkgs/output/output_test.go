@@ -0,0 +1,258 @@
+// nolint:testpackage
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JackDrogon/aicodereader/pkgs/llm"
+)
+
+// recordingSink records what it receives.
+type recordingSink struct {
+	deltas  []string
+	answers []string
+	closed  bool
+	err     error
+}
+
+func (s *recordingSink) Delta(event llm.Event) error {
+	s.deltas = append(s.deltas, event.Text)
+	return s.err
+}
+
+func (s *recordingSink) Done(answer Answer) error {
+	s.answers = append(s.answers, answer.Content)
+	return s.err
+}
+
+func (s *recordingSink) Close(context.Context) error {
+	s.closed = true
+	return s.err
+}
+
+func TestMulti(t *testing.T) {
+	failing := &recordingSink{err: errors.New("disk full")}
+	working := &recordingSink{}
+	sink := Multi(failing, nil, working)
+
+	if err := sink.Delta(llm.Event{Type: llm.ContentDelta, Text: "a"}); err == nil {
+		t.Errorf("Expected the failing sink's error")
+	}
+	if err := sink.Done(Answer{Content: "a"}); err == nil {
+		t.Errorf("Expected the failing sink's error")
+	}
+	if err := sink.Close(context.Background()); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Expected the failing sink's error, got %v", err)
+	}
+
+	if len(working.deltas) != 1 || len(working.answers) != 1 || !working.closed {
+		t.Errorf("Expected every sink to receive everything despite a failing one, got %+v", working)
+	}
+}
+
+func TestReportSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "report.md")
+	sink := NewReportSink(path)
+	for _, answer := range []Answer{{Source: "a.go", Content: "first\n"}, {Source: "b.go", Reasoning: "hidden", Content: "second"}} {
+		if err := sink.Done(answer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# 分析报告\n\n## a.go\n\nfirst\n\n## b.go\n\nsecond\n"
+	if string(content) != expected {
+		t.Errorf("Expected %q, got %q", expected, content)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.md")
+	if err := NewReportSink(empty).Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(empty); !os.IsNotExist(err) {
+		t.Errorf("Expected no report without answers")
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var payload webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected JSON, got %q", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, nil)
+	if err := sink.Done(Answer{Source: "a.go", Provider: "openai", Model: "m", Content: "Parses flags.\n\nDetails follow."}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	expected := []answerSummary{{Source: "a.go", Provider: "openai", Model: "m", Summary: "Parses flags."}}
+	if len(payload.Answers) != 1 || payload.Answers[0] != expected[0] {
+		t.Errorf("Expected %+v, got %+v", expected, payload.Answers)
+	}
+	if payload.Text != "1 answer(s)\n\na.go: Parses flags." {
+		t.Errorf("Unexpected text %q", payload.Text)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such hook", http.StatusNotFound)
+	}))
+	defer failing.Close()
+	sink = NewWebhookSink(failing.URL, nil)
+	_ = sink.Done(Answer{Content: "x"})
+	if err := sink.Close(context.Background()); err == nil || !strings.Contains(err.Error(), "no such hook") {
+		t.Errorf("Expected the webhook's error, got %v", err)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		content  string
+		limit    int
+		expected string
+	}{
+		{"short", 10, "short"},
+		{"  first\nline\n\nsecond paragraph", 50, "first line"},
+		{"这是一个很长的回答", 5, "这是一个…"},
+	}
+	for _, test := range tests {
+		if got := Summarize(test.content, test.limit); got != test.expected {
+			t.Errorf("Summarize(%q, %d) = %q, expected %q", test.content, test.limit, got, test.expected)
+		}
+	}
+}
+
+func TestAppendSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.md")
+	for _, source := range []string{"a.go", "b.go"} {
+		sink := NewAppendSink(path)
+		if err := sink.Done(Answer{Source: source, Content: "about " + source}); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Close(context.Background()); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# 分析报告\n\n## a.go\n\nabout a.go\n\n## b.go\n\nabout b.go\n"
+	if string(content) != expected {
+		t.Errorf("Expected %q, got %q", expected, content)
+	}
+}
+
+func TestParsePart(t *testing.T) {
+	report := "# 分析报告\n\n## a.go\n\nParses flags.\n\n```md\n## not a section\n```\n\n## b.go\n\n### Details\n\nMore.\n"
+	part := ParsePart("pkgs/cli", report)
+
+	if strings.Contains(part.Body, "分析报告") || !strings.HasPrefix(part.Body, "## a.go") {
+		t.Errorf("Expected the title to be dropped, got %q", part.Body)
+	}
+	if len(part.Sections) != 2 || part.Sections[0].Source != "a.go" || part.Sections[1].Source != "b.go" {
+		t.Fatalf("Expected sections a.go and b.go, got %+v", part.Sections)
+	}
+	if !strings.Contains(part.Sections[0].Content, "## not a section") {
+		t.Errorf("Expected headings in code blocks to stay in their section, got %q", part.Sections[0].Content)
+	}
+	if digest := part.Digest(20); digest != "- a.go: Parses flags.\n- b.go: ### Details" {
+		t.Errorf("Unexpected digest %q", digest)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	parts := []Part{
+		ParsePart("pkgs/cli", "# 分析报告\n\n## main.go\n\nEntry point.\n\n```\n# comment\n```\n"),
+		ParsePart("pkgs/llm", "Clients for the providers.\n"),
+	}
+	report := Aggregate("Both packages are small.", parts)
+
+	expected := "# 汇总报告\n\n## 概要\n\nBoth packages are small.\n\n## 目录\n\n" +
+		"1. [pkgs/cli](#part-1)\n   - main.go\n2. [pkgs/llm](#part-2)\n" +
+		"\n<a id=\"part-1\"></a>\n\n## pkgs/cli\n\n### main.go\n\nEntry point.\n\n```\n# comment\n```\n" +
+		"\n<a id=\"part-2\"></a>\n\n## pkgs/llm\n\nClients for the providers.\n"
+	if report != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, report)
+	}
+
+	if strings.Contains(Aggregate("", parts), "概要") {
+		t.Errorf("Expected no executive summary section without a summary")
+	}
+}
+
+func TestDocsSink(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewDocsSink(dir).(*docsSink)
+	sink.now = func() time.Time { return time.Date(2024, 1, 31, 12, 0, 0, 500, time.UTC) }
+
+	answer := Answer{
+		Source:   "pkgs/config/file.go",
+		Files:    []File{NewFile("pkgs/config/file.go", "package config\n")},
+		Provider: "openai",
+		Model:    "gpt-4o",
+		Content:  "Loads config files.\n",
+		Stats:    llm.Stats{Usage: llm.Usage{PromptTokens: 1200, CompletionTokens: 350}},
+	}
+	for _, a := range []Answer{answer, answer, {Source: "a.go, b.go", Files: []File{{Path: "x/a.go"}, {Path: "b.go"}}, Content: "both"}} {
+		if err := sink.Done(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "pkgs", "config", "file.go.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "---\ntitle: pkgs/config/file.go\npath: pkgs/config/file.go\n" +
+		"hash: sha256:" + answer.Files[0].Hash[len("sha256:"):] + "\n" +
+		"provider: openai\nmodel: gpt-4o\ndate: 2024-01-31T12:00:00Z\ntokens:\n  input: 1200\n  output: 350\n---\n\nLoads config files.\n"
+	if string(content) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, content)
+	}
+	for _, name := range []string{"pkgs/config/file.go-2.md", "x_a.go+b.go.md"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("Expected page %s: %v", name, err)
+		}
+	}
+}
+
+func TestPageName(t *testing.T) {
+	tests := []struct {
+		answer   Answer
+		expected string
+	}{
+		{Answer{Files: []File{{Path: "../../etc/passwd"}}}, "etc/passwd.md"},
+		{Answer{Source: "snippet"}, "snippet.md"},
+		{Answer{}, "answer.md"},
+	}
+	for _, test := range tests {
+		if got := pageName(test.answer); got != test.expected {
+			t.Errorf("pageName(%+v) = %q, expected %q", test.answer, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// binarySizeInstruction asks for ways to shrink a binary in prompts built by
+// BuildBinarySize.
+const binarySizeInstruction = "下面是一个 Go 二进制文件按包汇总的体积和最大的符号。请给出减小它体积的具体建议：" +
+	"每条建议以加粗的包名开头（如 **github.com/x/y**，构建参数等全局建议写 **构建**），说明这个包为什么占用这么多空间、" +
+	"可以怎样处理（如换用更轻量的依赖、去掉不需要的功能、用构建标签排除、改用 embed 压缩数据、构建时加 `-ldflags=\"-s -w\"` 或 `-trimpath`），" +
+	"并估计能节省多少。按节省的体积从大到小排列，只根据给出的数据分析，不确定的地方说明需要如何确认。"
+
+// SizeEntry is a package or symbol of a binary with its size in bytes.
+type SizeEntry struct {
+	Name string
+	Size int64
+}
+
+// BuildBinarySize creates a Prompt asking for concrete ways to shrink the
+// binary named binary, whose symbols add up to total bytes, from the sizes
+// of its largest packages and symbols.
+func BuildBinarySize(binary string, total int64, packages, symbols []SizeEntry) Prompt {
+	var user strings.Builder
+	user.WriteString(binarySizeInstruction)
+	fmt.Fprintf(&user, "\n\n二进制文件: %s\n符号总大小: %d 字节\n\n最大的包（字节，占比）：\n", binary, total)
+	for _, p := range packages {
+		fmt.Fprintf(&user, "%d\t%.1f%%\t%s\n", p.Size, percent(p.Size, total), p.Name)
+	}
+	user.WriteString("\n最大的符号（字节）：\n")
+	for _, s := range symbols {
+		fmt.Fprintf(&user, "%d\t%s\n", s.Size, s.Name)
+	}
+
+	return Prompt{
+		System:   DefaultSystemPrompt,
+		User:     user.String(),
+		Question: binarySizeInstruction,
+	}
+}
+
+// percent returns part as a percentage of total, zero if total is.
+func percent(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
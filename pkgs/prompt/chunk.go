@@ -0,0 +1,54 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Part is one chunk of a file too large to send whole.
+type Part struct {
+	// Index and Total give the part's 1-based position and the part count.
+	Index int
+	Total int
+	// StartLine and EndLine are the 1-based, inclusive lines the part covers.
+	StartLine int
+	EndLine   int
+}
+
+// BuildPart creates a prompt asking question about one part of file, whose
+// Content holds only that part.
+func BuildPart(question string, file File, part Part) Prompt {
+	if question == "" {
+		question = DefaultQuestion
+	}
+
+	note := fmt.Sprintf("下面只是文件的第 %d/%d 段（第 %d-%d 行），其余各段会单独分析，最后再合并。请只根据这一段回答，不要猜测其他段的内容。",
+		part.Index, part.Total, part.StartLine, part.EndLine)
+	return Build(note+"\n\n"+question, file)
+}
+
+// BuildMerge creates a prompt that combines the answers given for each part
+// of file into one answer to question. file is the whole file, kept in the
+// prompt's Files so the answer is attributed to it, but not sent.
+func BuildMerge(question string, file File, parts []Part, answers []string) Prompt {
+	if question == "" {
+		question = DefaultQuestion
+	}
+
+	var user strings.Builder
+	fmt.Fprintf(&user, "文件 %s 太大，已分成 %d 段分别分析。请把下面各段的分析结果整合成对整个文件的一份完整回答，去掉重复内容，"+
+		"并补充跨段的联系。\n\n原始问题：%s", file.Path, len(parts), question)
+	for i, part := range parts {
+		fmt.Fprintf(&user, "\n\n----- 第 %d/%d 段（第 %d-%d 行）的分析 -----\n%s", part.Index, part.Total, part.StartLine, part.EndLine, answers[i])
+	}
+
+	// The partial answers are the bulk of the request, so they count
+	// towards the question in Contributions
+	return Prompt{
+		System:   DefaultSystemPrompt,
+		User:     user.String(),
+		Question: user.String(),
+		Files:    []File{file},
+		Merged:   true,
+	}
+}
@@ -0,0 +1,47 @@
+// nolint:testpackage
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildPart(t *testing.T) {
+	file := File{Path: "big.go", Language: "Go", Content: "func a() {}\n"}
+	p := BuildPart("", file, Part{Index: 2, Total: 3, StartLine: 40, EndLine: 80})
+
+	for _, expected := range []string{"第 2/3 段", "第 40-80 行", DefaultQuestion, "文件: big.go", "func a() {}"} {
+		if !strings.Contains(p.User, expected) {
+			t.Errorf("Expected user message to contain %q, got %q", expected, p.User)
+		}
+	}
+	if len(p.Files) != 1 || p.Files[0] != file {
+		t.Errorf("Expected the part to be the prompt's only file, got %+v", p.Files)
+	}
+}
+
+func TestBuildMerge(t *testing.T) {
+	parts := []Part{{Index: 1, Total: 2, StartLine: 1, EndLine: 50}, {Index: 2, Total: 2, StartLine: 45, EndLine: 90}}
+	file := NewFile("big.go", []byte("package big\n"))
+	p := BuildMerge("what does it do?", file, parts, []string{"first answer", "second answer"})
+
+	for _, expected := range []string{"big.go", "what does it do?", "第 2/2 段（第 45-90 行）", "first answer", "second answer"} {
+		if !strings.Contains(p.User, expected) {
+			t.Errorf("Expected merge prompt to contain %q, got %q", expected, p.User)
+		}
+	}
+	if strings.Index(p.User, "first answer") > strings.Index(p.User, "second answer") {
+		t.Errorf("Expected answers in part order")
+	}
+	if len(p.Files) != 1 || p.Files[0] != file {
+		t.Errorf("Expected the whole file in merge prompt, got %+v", p.Files)
+	}
+	if strings.Contains(p.User, "package big") {
+		t.Errorf("Expected the file's content not to be sent, got %q", p.User)
+	}
+	for _, c := range p.Contributions(func(text string) int { return len(text) }) {
+		if c.Label == "big.go" {
+			t.Errorf("Expected the unsent file not to count towards the prompt, got %+v", c)
+		}
+	}
+}
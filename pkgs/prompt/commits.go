@@ -0,0 +1,83 @@
+package prompt
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// commitRewriteInstruction asks for conventional commit messages in prompts
+// built by BuildCommitRewrite; %s is the list of allowed types.
+const commitRewriteInstruction = "下面的提交信息不符合 Conventional Commits 规范，每条附有发现的问题和提交改动的文件统计。" +
+	"请根据原信息和改动为每个提交重写提交信息：首行格式为 `type(scope): description`，type 只能是 %s 之一，" +
+	"scope 可省略，description 用小写字母开头、结尾不加句号，首行不超过 72 个字符；原信息的正文中有用的内容保留在空一行后的正文里。" +
+	"沿用原信息的语言，不要编造改动中没有的内容。每条以单独一行的 `### <编号>` 开头，后面直接写提交信息，不要加代码块或其他说明。"
+
+// CommitMessage is a commit whose message BuildCommitRewrite asks to
+// rewrite.
+type CommitMessage struct {
+	// Message is the full commit message.
+	Message string
+	// Problems are the ways Message breaks the rules.
+	Problems []string
+	// Stat is the diffstat of the commit, empty if unknown.
+	Stat string
+}
+
+// rewriteHeading matches the "### <n>" line starting each message in the
+// answer to a prompt built by BuildCommitRewrite.
+var rewriteHeading = regexp.MustCompile(`^#{2,4}\s*(\d+)\.?\s*$`)
+
+// BuildCommitRewrite creates a Prompt asking for Conventional Commits
+// messages of the given types replacing those of commits.
+// ParseCommitRewrite reads the answer.
+func BuildCommitRewrite(commits []CommitMessage, types []string) Prompt {
+	instruction := fmt.Sprintf(commitRewriteInstruction, strings.Join(types, "、"))
+
+	var user strings.Builder
+	user.WriteString(instruction)
+	for i, c := range commits {
+		fmt.Fprintf(&user, "\n\n### %d\n", i+1)
+		writeFile(&user, File{Content: c.Message})
+		fmt.Fprintf(&user, "\n问题：%s", strings.Join(c.Problems, "; "))
+		if c.Stat != "" {
+			user.WriteString("\n改动：\n")
+			writeFile(&user, File{Content: c.Stat})
+		}
+	}
+	return Prompt{
+		System:   DefaultSystemPrompt,
+		User:     user.String(),
+		Question: instruction,
+	}
+}
+
+// ParseCommitRewrite returns the n messages in answer, the answer to a
+// prompt built by BuildCommitRewrite, in order, with "" for commits the
+// answer skipped.
+func ParseCommitRewrite(answer string, n int) []string {
+	messages := make([]string, n)
+	current := -1
+	var message strings.Builder
+	flush := func() {
+		if current >= 0 {
+			messages[current] = strings.TrimSpace(strings.Trim(strings.TrimSpace(message.String()), "`"))
+		}
+		message.Reset()
+	}
+	for _, line := range strings.Split(answer, "\n") {
+		if match := rewriteHeading.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			flush()
+			current = -1
+			if i, err := strconv.Atoi(match[1]); err == nil && i >= 1 && i <= n {
+				current = i - 1
+			}
+			continue
+		}
+		message.WriteString(line)
+		message.WriteString("\n")
+	}
+	flush()
+	return messages
+}
@@ -0,0 +1,65 @@
+package prompt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// endpointNotesInstruction asks for the auth and validation of endpoints in
+// prompts built by BuildEndpointNotes.
+const endpointNotesInstruction = "下面是一个服务注册的 HTTP 接口，每个接口后面附有处理函数的代码（找不到时省略）。" +
+	"请为每个接口写一行说明，指出它如何鉴权（或者没有鉴权）、如何校验输入，从代码看不出时写“未知”。" +
+	"每行以接口的编号开头，格式为 `<编号>. 鉴权: ...；校验: ...`，按编号顺序列出，不要输出其他内容。"
+
+// EndpointHandler is an endpoint described to the model by
+// BuildEndpointNotes.
+type EndpointHandler struct {
+	// Route is the method and route, such as "GET /users".
+	Route string
+	// Handler names the handler.
+	Handler string
+	// Code is the source of the handler, empty if it was not found.
+	Code File
+}
+
+// BuildEndpointNotes creates a Prompt asking for a note on the auth and
+// input validation of each of endpoints. ParseEndpointNotes reads the
+// answer.
+func BuildEndpointNotes(endpoints []EndpointHandler) Prompt {
+	var user strings.Builder
+	user.WriteString(endpointNotesInstruction)
+	var files []File
+	for i, e := range endpoints {
+		fmt.Fprintf(&user, "\n\n%d. %s → %s\n", i+1, e.Route, e.Handler)
+		if e.Code.Content != "" {
+			writeFile(&user, e.Code)
+			files = append(files, e.Code)
+		}
+	}
+	return Prompt{
+		System:   DefaultSystemPrompt,
+		User:     user.String(),
+		Question: endpointNotesInstruction,
+		Files:    files,
+	}
+}
+
+// ParseEndpointNotes returns the notes on n endpoints in answer, the answer
+// to a prompt built by BuildEndpointNotes, in order, with "" for endpoints
+// the answer skipped.
+func ParseEndpointNotes(answer string, n int) []string {
+	notes := make([]string, n)
+	for _, line := range strings.Split(answer, "\n") {
+		number, note, ok := strings.Cut(strings.TrimSpace(line), ".")
+		if !ok {
+			continue
+		}
+		i, err := strconv.Atoi(strings.TrimSpace(number))
+		if err != nil || i < 1 || i > n {
+			continue
+		}
+		notes[i-1] = strings.TrimSpace(note)
+	}
+	return notes
+}
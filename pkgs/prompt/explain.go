@@ -0,0 +1,43 @@
+package prompt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// explainQuestions holds the specialized question for each snippet kind
+// supported by BuildExplain.
+var explainQuestions = map[string]string{
+	"regex": "请解释下面的正则表达式：逐段说明每个部分匹配什么，给出能匹配和不能匹配的示例，并指出可能的陷阱（如回溯爆炸、转义问题、不同方言的差异）。",
+	"sql":   "请解释下面的 SQL 语句：说明它查询或修改了什么数据、各子句的作用和执行顺序，并指出潜在的性能问题（如缺失索引、全表扫描）和正确性风险。",
+	"shell": "请解释下面的 shell 命令：逐个说明命令、参数和管道的作用，并指出可能带来的风险（如误删文件、未加引号的变量）。",
+	"cron":  "请解释下面的 cron 表达式：说明它在什么时间触发，列出接下来的几次触发时间示例，并指出不同 cron 实现之间的差异。",
+}
+
+// explainLanguages maps snippet kinds to the language used to fence them.
+var explainLanguages = map[string]string{
+	"sql":   "SQL",
+	"shell": "Shell",
+}
+
+// ExplainKinds returns the snippet kinds supported by BuildExplain, sorted.
+func ExplainKinds() []string {
+	kinds := make([]string, 0, len(explainQuestions))
+	for kind := range explainQuestions {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// BuildExplain creates a Prompt asking for an explanation of a standalone
+// snippet of the given kind, such as a regular expression or SQL query.
+func BuildExplain(kind, snippet string) (Prompt, error) {
+	question, ok := explainQuestions[kind]
+	if !ok {
+		return Prompt{}, fmt.Errorf("unknown kind %q, expected one of: %s", kind, strings.Join(ExplainKinds(), ", "))
+	}
+
+	return Build(question, File{Language: explainLanguages[kind], Content: snippet}), nil
+}
@@ -0,0 +1,38 @@
+// nolint:testpackage
+package prompt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExplainKinds(t *testing.T) {
+	expected := []string{"cron", "regex", "shell", "sql"}
+	if got := ExplainKinds(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("ExplainKinds() = %v, expected %v", got, expected)
+	}
+}
+
+func TestBuildExplain(t *testing.T) {
+	p, err := BuildExplain("regex", `^\d{3}-\d{4}$`)
+	if err != nil {
+		t.Fatalf("BuildExplain failed: %v", err)
+	}
+
+	if !strings.HasPrefix(p.User, explainQuestions["regex"]) {
+		t.Errorf("User message should start with the regex question, got %q", p.User)
+	}
+	if !strings.HasSuffix(p.User, "\n\n```\n^\\d{3}-\\d{4}$\n```") {
+		t.Errorf("User message should embed the snippet, got %q", p.User)
+	}
+
+	p, err = BuildExplain("sql", "SELECT 1")
+	if err != nil || !strings.HasSuffix(p.User, "\n\n语言: SQL\n```sql\nSELECT 1\n```") {
+		t.Errorf("Expected SQL fenced snippet, got %q, %v", p.User, err)
+	}
+
+	if _, err := BuildExplain("brainfuck", "+-"); err == nil {
+		t.Errorf("Expected error for unknown kind")
+	}
+}
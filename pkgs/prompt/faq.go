@@ -0,0 +1,11 @@
+package prompt
+
+// faqInstruction follows the question in prompts built by BuildFAQ.
+const faqInstruction = "这是一个关于当前仓库的常见问题，读者是第一次接触这个仓库的开发者。请结合仓库地图回答，" +
+	"给出具体的文件、目录和操作步骤；仓库地图中看不出来的内容请明确说明，不要臆测。"
+
+// BuildFAQ creates a Prompt answering a frequently asked question about the
+// repository from its repo map, which the caller adds with WithRepoMap.
+func BuildFAQ(question string) Prompt {
+	return Build(question + "\n\n" + faqInstruction)
+}
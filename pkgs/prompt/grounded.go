@@ -0,0 +1,57 @@
+package prompt
+
+import (
+	"fmt"
+
+	"github.com/JackDrogon/aicodereader/pkgs/lang"
+)
+
+// groundedInstruction follows the question in prompts built by BuildGrounded.
+const groundedInstruction = "下面是从仓库中检索到的与问题最相关的片段，每个片段都标明了文件、行号和类型（代码或文档）。请只依据这些片段回答问题，" +
+	"并用 `文件:起始行-结束行（类型）` 的形式注明每个结论的出处，如 `main.go:10-20（代码）`、`README.md:5-9（文档）`；" +
+	"文档与代码不一致时以代码为准并指出差异。如果这些片段不足以回答，请直接说明还缺少哪些信息，不要臆测。"
+
+// Snippet is a retrieved range of lines from a file.
+type Snippet struct {
+	// ID identifies the snippet where it was retrieved from, such as a
+	// search index.
+	ID        int64
+	Path      string
+	StartLine int
+	EndLine   int
+	Content   string
+	// FileHash identifies the version of the file the snippet was taken
+	// from, as "sha256:<hex>", empty if unknown.
+	FileHash string
+	// Documentation is set for snippets of documentation, such as a README,
+	// rather than code.
+	Documentation bool
+}
+
+// Kind is the type of the snippet cited in answers: 文档 for documentation
+// and 代码 for code.
+func (s Snippet) Kind() string {
+	if s.Documentation {
+		return "文档"
+	}
+	return "代码"
+}
+
+// BuildGrounded creates a Prompt answering question from retrieved snippets
+// only. Each snippet is labeled "path:start-end" and with its Kind so the
+// answer can cite it and tell documentation from code, and kept in Snippets
+// so the answer can list them.
+func BuildGrounded(question string, snippets []Snippet) Prompt {
+	files := make([]File, 0, len(snippets))
+	for _, snippet := range snippets {
+		files = append(files, File{
+			Path:     fmt.Sprintf("%s:%d-%d", snippet.Path, snippet.StartLine, snippet.EndLine),
+			Language: lang.FromPath(snippet.Path),
+			Content:  snippet.Content,
+			Kind:     snippet.Kind(),
+		})
+	}
+	p := Build(question+"\n\n"+groundedInstruction, files...)
+	p.Snippets = snippets
+	return p
+}
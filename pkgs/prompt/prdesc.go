@@ -0,0 +1,58 @@
+package prompt
+
+import "strings"
+
+// prDescriptionInstruction asks for a pull request description in prompts
+// built by BuildPRDescription without a template.
+const prDescriptionInstruction = "下面是一个分支相对目标分支的全部提交和改动。请据此写一份 Pull Request 说明：" +
+	"第一行是以 `# ` 开头的简洁标题（不超过 72 个字符），然后依次是“## 描述”（改了什么、为什么改）、" +
+	"“## 风险”（可能破坏的行为、兼容性和迁移问题、需要重点审查的地方，没有就写“无”）和" +
+	"“## 测试计划”（审查者如何验证这些改动，列出具体的命令或步骤）。只根据给出的提交和改动来写，不要编造其中没有的内容。"
+
+// prTemplateInstruction asks for a pull request description filling a
+// template in prompts built by BuildPRDescription.
+const prTemplateInstruction = "下面是一个分支相对目标分支的全部提交和改动，以及项目的 Pull Request 模板。请据此写一份 Pull Request 说明：" +
+	"第一行是以 `# ` 开头的简洁标题（不超过 72 个字符），然后按模板填写，保留模板的标题和结构，删去模板里的注释和占位说明。" +
+	"模板中没有风险和测试计划的位置时，在末尾补上“## 风险”（可能破坏的行为、兼容性和迁移问题，没有就写“无”）和" +
+	"“## 测试计划”（审查者如何验证这些改动）。只根据给出的提交和改动来写，不要编造其中没有的内容。"
+
+// BuildPRDescription creates a Prompt asking for the title, description,
+// risk notes and test plan of a pull request from the messages of its
+// commits and the diffs of the files it changes, each a File with its
+// patch. omitted names the changed files whose diffs were left out for
+// size. A template with content is filled in rather than the default
+// layout.
+func BuildPRDescription(commits []string, diffs []File, omitted []string, template File) Prompt {
+	instruction := prDescriptionInstruction
+	if template.Content != "" {
+		instruction = prTemplateInstruction
+	}
+
+	var user strings.Builder
+	user.WriteString(instruction)
+	if template.Content != "" {
+		user.WriteString("\n\nPR 模板：\n")
+		writeFile(&user, template)
+	}
+	user.WriteString("\n\n提交：\n")
+	for _, message := range commits {
+		user.WriteString("\n- ")
+		user.WriteString(strings.ReplaceAll(strings.TrimSpace(message), "\n", "\n  "))
+	}
+	user.WriteString("\n\n改动：")
+	for _, diff := range diffs {
+		user.WriteString("\n\n")
+		writeFile(&user, diff)
+	}
+	if len(omitted) > 0 {
+		user.WriteString("\n\n以下文件的改动因篇幅省略，只知道它们被修改了：")
+		user.WriteString(strings.Join(omitted, ", "))
+	}
+
+	return Prompt{
+		System:   DefaultSystemPrompt,
+		User:     user.String(),
+		Question: instruction,
+		Files:    diffs,
+	}
+}
@@ -0,0 +1,249 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JackDrogon/aicodereader/pkgs/lang"
+)
+
+// DefaultSystemPrompt sets the assistant's role for code reading requests.
+const DefaultSystemPrompt = "你是一个资深的软件工程师和代码阅读助手，擅长阅读、解释和审查各种编程语言的代码。"
+
+// DefaultQuestion is asked about the supplied code when the user gives no question.
+const DefaultQuestion = "请阅读下面的代码，说明它的用途、主要结构和关键逻辑，并指出其中值得注意的问题。"
+
+// SummarizeQuestion asks for a short overview of the supplied code.
+const SummarizeQuestion = "请用简洁的几段话总结下面代码的用途、对外接口和主要依赖，不需要逐行解释。"
+
+// ReviewQuestion asks for a code review of the supplied code.
+const ReviewQuestion = "请以代码审查者的身份审查下面的代码，按严重程度列出缺陷、安全隐患、性能问题和可维护性问题，并给出修改建议。"
+
+// File is a source file to be embedded into a prompt.
+type File struct {
+	// Path is the file name shown to the model, empty for standalone snippets.
+	Path string
+	// Language is the detected programming language, empty if unknown.
+	Language string
+	// Content is the file's text.
+	Content string
+	// Kind says what the file is, such as documentation or code for
+	// retrieved snippets, empty if it goes without saying.
+	Kind string
+}
+
+// NewFile creates a File and detects its language from the path or, for
+// scripts without an extension, the shebang line.
+func NewFile(path string, content []byte) File {
+	return File{
+		Path:     path,
+		Language: lang.Detect(path, content),
+		Content:  string(content),
+	}
+}
+
+// Prompt is the pair of messages sent for a code reading request.
+type Prompt struct {
+	System string
+	User   string
+
+	// Question and Files are the inputs User was built from.
+	Question string
+	Files    []File
+	// Merged is set by BuildMerge, whose User holds the answers about the
+	// parts of Files rather than their content.
+	Merged bool
+	// Snippets are the retrieved snippets of a prompt built by
+	// BuildGrounded, which Files holds the content of.
+	Snippets []Snippet
+
+	// RepoMap is the repository map prepended to User by WithRepoMap.
+	RepoMap string
+	// Tree is the directory tree prepended to User by WithTree.
+	Tree string
+	// EntryPoints lists the entry points prepended to User by
+	// WithEntryPoints.
+	EntryPoints string
+}
+
+// Contribution is the number of tokens one part of a prompt accounts for.
+type Contribution struct {
+	// Label names the part: a file path, or a description such as "system prompt".
+	Label  string
+	Tokens int
+}
+
+// Contributions breaks the prompt down into the system prompt, the entry
+// points, directory tree and repository map if any, the question and each
+// embedded file, counting tokens with count. Parts are returned in prompt
+// order.
+func (p Prompt) Contributions(count func(text string) int) []Contribution {
+	contributions := make([]Contribution, 0, len(p.Files)+3)
+	contributions = append(contributions, Contribution{Label: "system prompt", Tokens: count(p.System)})
+	if p.EntryPoints != "" {
+		contributions = append(contributions, Contribution{Label: "entry points", Tokens: count(entryPointsBlock(p.EntryPoints))})
+	}
+	if p.Tree != "" {
+		contributions = append(contributions, Contribution{Label: "directory tree", Tokens: count(treeBlock(p.Tree))})
+	}
+	if p.RepoMap != "" {
+		contributions = append(contributions, Contribution{Label: "repo map", Tokens: count(repoMapBlock(p.RepoMap))})
+	}
+	contributions = append(contributions, Contribution{Label: "question", Tokens: count(p.Question)})
+
+	if p.Merged {
+		return contributions
+	}
+	for _, file := range p.Files {
+		var b strings.Builder
+		writeFile(&b, file)
+
+		label := file.Path
+		if label == "" {
+			label = "snippet"
+		}
+		contributions = append(contributions, Contribution{Label: label, Tokens: count(b.String())})
+	}
+	return contributions
+}
+
+// Build creates a Prompt asking question about files. If question is empty,
+// DefaultQuestion is used.
+func Build(question string, files ...File) Prompt {
+	if question == "" {
+		question = DefaultQuestion
+	}
+
+	var user strings.Builder
+	user.WriteString(question)
+	for _, file := range files {
+		user.WriteString("\n\n")
+		writeFile(&user, file)
+	}
+
+	return Prompt{
+		System:   DefaultSystemPrompt,
+		User:     user.String(),
+		Question: question,
+		Files:    files,
+	}
+}
+
+// WithRepoMap returns a copy of p whose user message starts with repoMap, a
+// listing of the repository's files and symbols, so the model can place the
+// embedded files in the project. An empty repoMap leaves p unchanged.
+func WithRepoMap(p Prompt, repoMap string) Prompt {
+	if repoMap == "" {
+		return p
+	}
+	p.RepoMap = repoMap
+	p.User = repoMapBlock(repoMap) + "\n\n" + p.User
+	return p
+}
+
+// WithTree returns a copy of p whose user message starts with tree, a
+// compact drawing of the repository's directories, so the model knows the
+// layout of a repository-level question even when only parts of it are
+// included. An empty tree leaves p unchanged.
+func WithTree(p Prompt, tree string) Prompt {
+	if tree == "" {
+		return p
+	}
+	p.Tree = tree
+	p.User = treeBlock(tree) + "\n\n" + p.User
+	return p
+}
+
+// treeBlock renders a directory tree as a labeled, fenced block.
+func treeBlock(tree string) string {
+	fence := strings.Repeat("`", max(3, longestRun(tree, '`')+1))
+	return "目录结构:\n" + fence + "\n" + strings.TrimRight(tree, "\n") + "\n" + fence
+}
+
+// WithEntryPoints returns a copy of p whose user message starts with
+// entryPoints, a list of where execution of the repository likely starts, so
+// the model can follow the code from there. An empty list leaves p
+// unchanged.
+func WithEntryPoints(p Prompt, entryPoints string) Prompt {
+	if entryPoints == "" {
+		return p
+	}
+	p.EntryPoints = entryPoints
+	p.User = entryPointsBlock(entryPoints) + "\n\n" + p.User
+	return p
+}
+
+// entryPointsBlock renders an entry point list as a labeled, fenced block.
+func entryPointsBlock(entryPoints string) string {
+	fence := strings.Repeat("`", max(3, longestRun(entryPoints, '`')+1))
+	return "可能的程序入口:\n" + fence + "\n" + strings.TrimRight(entryPoints, "\n") + "\n" + fence
+}
+
+// repoMapBlock renders a repository map as a labeled, fenced block.
+func repoMapBlock(repoMap string) string {
+	fence := strings.Repeat("`", max(3, longestRun(repoMap, '`')+1))
+	return "项目结构（文件及导出的符号）:\n" + fence + "\n" + strings.TrimRight(repoMap, "\n") + "\n" + fence
+}
+
+// writeFile renders file as a labeled, fenced code block.
+func writeFile(b *strings.Builder, file File) {
+	if file.Path != "" {
+		fmt.Fprintf(b, "文件: %s\n", file.Path)
+	}
+	if file.Language != "" {
+		fmt.Fprintf(b, "语言: %s\n", file.Language)
+	}
+	if file.Kind != "" {
+		fmt.Fprintf(b, "类型: %s\n", file.Kind)
+	}
+
+	// Use a fence longer than any backtick run in the content so it can't be closed early
+	fence := strings.Repeat("`", max(3, longestRun(file.Content, '`')+1))
+	fmt.Fprintf(b, "%s%s\n", fence, strings.ToLower(file.Language))
+	b.WriteString(file.Content)
+	if !strings.HasSuffix(file.Content, "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(fence)
+}
+
+// longestRun returns the length of the longest run of c in s.
+func longestRun(s string, c byte) int {
+	longest, current := 0, 0
+	for i := range len(s) {
+		if s[i] == c {
+			current++
+			longest = max(longest, current)
+		} else {
+			current = 0
+		}
+	}
+	return longest
+}
+
+// StdinName is the base of the synthetic file name given to standard input.
+const StdinName = "stdin"
+
+// NewStdinFile creates a File for content read from standard input. The
+// language is detected from the content, and the file is named StdinName plus
+// a matching extension, e.g. "stdin.diff" for piped git diff output.
+func NewStdinFile(content []byte) File {
+	language := lang.FromContent(string(content))
+	return File{
+		Path:     StdinName + lang.Extension(language),
+		Language: language,
+		Content:  string(content),
+	}
+}
+
+// softenedPreamble is prepended to the system prompt by Soften.
+const softenedPreamble = "以下是一次正当的软件工程代码审阅请求。代码中出现的任何敏感词、漏洞、攻击或安全相关内容都只是被分析的对象，" +
+	"请仅从软件质量、可维护性和安全防护的角度进行客观的技术说明，不要生成可被直接滥用的内容。"
+
+// Soften returns a copy of p whose system prompt frames the request as
+// defensive code review. It is used to retry requests rejected by provider
+// content filters, which often misread security-related code.
+func Soften(p Prompt) Prompt {
+	p.System = softenedPreamble + "\n\n" + p.System
+	return p
+}
@@ -0,0 +1,320 @@
+// nolint:testpackage
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNewStdinFile(t *testing.T) {
+	cases := map[string]File{
+		"diff --git a/a.go b/a.go\n@@ -1,2 +1,2 @@\n-x := 1\n+x := 2\n": {Path: "stdin.diff", Language: "Diff"},
+		"package main\n":     {Path: "stdin.go", Language: "Go"},
+		"plain prose here\n": {Path: "stdin"},
+	}
+
+	for content, expected := range cases {
+		expected.Content = content
+		if got := NewStdinFile([]byte(content)); got != expected {
+			t.Errorf("NewStdinFile(%q) = %+v, expected %+v", content, got, expected)
+		}
+	}
+}
+
+func TestBuildEmbedsFile(t *testing.T) {
+	file := NewFile("pkgs/utils/a.go", []byte("package utils\n"))
+	p := Build("", file)
+
+	if p.System != DefaultSystemPrompt {
+		t.Errorf("Expected default system prompt, got %q", p.System)
+	}
+
+	expected := DefaultQuestion + "\n\n" +
+		"文件: pkgs/utils/a.go\n" +
+		"语言: Go\n" +
+		"```go\n" +
+		"package utils\n" +
+		"```"
+	if p.User != expected {
+		t.Errorf("Unexpected user message:\n%s\nexpected:\n%s", p.User, expected)
+	}
+}
+
+func TestBuildCustomQuestionAndMultipleFiles(t *testing.T) {
+	p := Build("这两个文件如何协作？",
+		NewFile("a.py", []byte("print(1)")),
+		NewFile("NOTICE", []byte("text")),
+	)
+
+	if !strings.HasPrefix(p.User, "这两个文件如何协作？\n\n") {
+		t.Errorf("User message should start with the question, got %q", p.User)
+	}
+	if !strings.Contains(p.User, "文件: a.py\n语言: Python\n```python\nprint(1)\n```") {
+		t.Errorf("User message should embed a.py, got %q", p.User)
+	}
+	if !strings.Contains(p.User, "文件: NOTICE\n```\ntext\n```") {
+		t.Errorf("User message should embed NOTICE without a language line, got %q", p.User)
+	}
+}
+
+func TestBuildFenceLongerThanContent(t *testing.T) {
+	p := Build("q", NewFile("README.md", []byte("```go\nx := 1\n```\n")))
+
+	if !strings.Contains(p.User, "````markdown\n```go\nx := 1\n```\n````") {
+		t.Errorf("Fence should be longer than backtick runs in content, got %q", p.User)
+	}
+}
+
+func TestContributions(t *testing.T) {
+	p := Build("q", NewFile("a.go", []byte("package a\n")), File{Content: "x"})
+
+	contributions := p.Contributions(func(text string) int { return len(text) })
+
+	labels := make([]string, 0, len(contributions))
+	for _, c := range contributions {
+		labels = append(labels, c.Label)
+	}
+	if strings.Join(labels, ",") != "system prompt,question,a.go,snippet" {
+		t.Errorf("Unexpected contribution labels: %v", labels)
+	}
+
+	if contributions[0].Tokens != len(DefaultSystemPrompt) || contributions[1].Tokens != 1 {
+		t.Errorf("Unexpected system/question tokens: %+v", contributions[:2])
+	}
+	if want := len("文件: a.go\n语言: Go\n```go\npackage a\n```"); contributions[2].Tokens != want {
+		t.Errorf("Expected a.go to count its rendered block (%d), got %d", want, contributions[2].Tokens)
+	}
+}
+
+func TestSoften(t *testing.T) {
+	p := Build("q", NewFile("a.go", []byte("package a")))
+	softened := Soften(p)
+
+	if softened.User != p.User {
+		t.Errorf("Soften should not change the user message")
+	}
+	if !strings.HasPrefix(softened.System, softenedPreamble) || !strings.HasSuffix(softened.System, p.System) {
+		t.Errorf("Soften should prepend the preamble to the system prompt, got %q", softened.System)
+	}
+}
+
+func TestWithRepoMap(t *testing.T) {
+	p := Build("q", File{Path: "a.go", Content: "package a\n"})
+	if got := WithRepoMap(p, ""); got.User != p.User || got.RepoMap != "" {
+		t.Errorf("Expected empty repo map to leave the prompt unchanged")
+	}
+
+	repoMap := "pkgs/a/\n  a.go: A\n"
+	mapped := WithRepoMap(p, repoMap)
+	if !strings.HasPrefix(mapped.User, "项目结构") || !strings.HasSuffix(mapped.User, p.User) {
+		t.Errorf("Expected repo map before the original message, got %q", mapped.User)
+	}
+	if !strings.Contains(mapped.User, "```\npkgs/a/\n  a.go: A\n```") {
+		t.Errorf("Expected fenced repo map, got %q", mapped.User)
+	}
+
+	contributions := mapped.Contributions(func(text string) int { return len(text) })
+	if len(contributions) != 4 || contributions[1].Label != "repo map" || contributions[2].Label != "question" {
+		t.Errorf("Expected repo map between system prompt and question, got %+v", contributions)
+	}
+}
+
+func TestWithTree(t *testing.T) {
+	p := Build("q", File{Path: "a.go", Content: "package a\n"})
+	if got := WithTree(p, ""); got.User != p.User || got.Tree != "" {
+		t.Errorf("Expected empty tree to leave the prompt unchanged")
+	}
+
+	tree := WithTree(WithRepoMap(p, "a.go: A\n"), "pkgs/\n  a.go\n")
+	if !strings.HasPrefix(tree.User, "目录结构:\n```\npkgs/\n  a.go\n```\n\n项目结构") {
+		t.Errorf("Expected fenced tree before the repo map, got %q", tree.User)
+	}
+
+	contributions := tree.Contributions(func(text string) int { return len(text) })
+	if len(contributions) != 5 || contributions[1].Label != "directory tree" || contributions[2].Label != "repo map" {
+		t.Errorf("Expected directory tree after the system prompt, got %+v", contributions)
+	}
+}
+
+func TestWithEntryPoints(t *testing.T) {
+	p := Build("q", File{Path: "a.go", Content: "package a\n"})
+	if got := WithEntryPoints(p, ""); got.User != p.User || got.EntryPoints != "" {
+		t.Errorf("Expected empty entry points to leave the prompt unchanged")
+	}
+
+	seeded := WithEntryPoints(WithTree(p, "a.go\n"), "main.go:5 (go-main) func main\n")
+	if !strings.HasPrefix(seeded.User, "可能的程序入口:\n```\nmain.go:5 (go-main) func main\n```\n\n目录结构") {
+		t.Errorf("Expected fenced entry points before the tree, got %q", seeded.User)
+	}
+	contributions := seeded.Contributions(func(text string) int { return len(text) })
+	if len(contributions) != 5 || contributions[1].Label != "entry points" || contributions[2].Label != "directory tree" {
+		t.Errorf("Expected entry points after the system prompt, got %+v", contributions)
+	}
+}
+
+func TestBuildDirectorySummary(t *testing.T) {
+	p := BuildDirectorySummary("pkgs/", []Summary{{Name: "a.go", Text: "Parses input.\n"}, {Name: "sub/", Text: "Helpers."}})
+	if !strings.HasPrefix(p.User, DirectorySummaryQuestion+"\n\n目录: pkgs/") {
+		t.Errorf("Expected question and directory heading first, got %q", p.User)
+	}
+	if !strings.HasSuffix(p.User, "----- a.go -----\nParses input.\n\n----- sub/ -----\nHelpers.") {
+		t.Errorf("Expected entry summaries in order, got %q", p.User)
+	}
+
+	repo := BuildRepoSummary([]Summary{{Name: "main.go", Text: "Entry point."}})
+	if repo.User != RepoSummaryQuestion+"\n\n----- main.go -----\nEntry point." {
+		t.Errorf("Unexpected repository prompt %q", repo.User)
+	}
+
+	summary := BuildExecutiveSummary([]Summary{{Name: "pkgs/cli", Text: "## main.go\n\nEntry point."}})
+	if summary.User != ExecutiveSummaryQuestion+"\n\n----- pkgs/cli -----\n## main.go\n\nEntry point." {
+		t.Errorf("Unexpected executive summary prompt %q", summary.User)
+	}
+}
+
+func TestBuildGrounded(t *testing.T) {
+	p := BuildGrounded("How is config loaded?", []Snippet{
+		{Path: "pkgs/config/file.go", StartLine: 120, EndLine: 131, Content: "func Load() {}\n"},
+		{Path: "README.md", StartLine: 5, EndLine: 6, Content: "## Config\n", Documentation: true},
+	})
+
+	if !strings.HasPrefix(p.User, "How is config loaded?\n\n"+groundedInstruction) {
+		t.Errorf("Expected the question followed by the grounding instruction, got %q", p.User)
+	}
+	if len(p.Files) != 2 || p.Files[0].Path != "pkgs/config/file.go:120-131" || p.Files[0].Language != "Go" {
+		t.Errorf("Expected a snippet labeled with its location, got %+v", p.Files)
+	}
+	if !strings.Contains(p.User, "文件: pkgs/config/file.go:120-131\n语言: Go\n类型: 代码\n```go\nfunc Load() {}\n```") {
+		t.Errorf("Expected the snippet rendered as a file, got %q", p.User)
+	}
+	if !strings.Contains(p.User, "文件: README.md:5-6\n语言: Markdown\n类型: 文档\n") {
+		t.Errorf("Expected documentation labeled as such, got %q", p.User)
+	}
+}
+
+func TestParseRewrites(t *testing.T) {
+	answer := "1. 配置文件在哪里加载\n\n- ParseConfig 的实现\n2、配置文件在哪里加载\n配置如何合并？\n\"多余的一行\"\n"
+	got := ParseRewrites(answer, "配置如何合并？", 3)
+	expected := []string{"配置文件在哪里加载", "ParseConfig 的实现", "多余的一行"}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if p := BuildRewrite("q", 3); !strings.Contains(p.User, "3 个") || !strings.HasSuffix(p.User, "\n\nq") {
+		t.Errorf("Unexpected rewrite prompt %q", p.User)
+	}
+}
+
+func TestParseSelfCheck(t *testing.T) {
+	score, unverified, ok := ParseSelfCheck("置信度：85%\n- Parse 会校验输入\n- 无\n")
+	if !ok || score != 0.85 || strings.Join(unverified, "|") != "Parse 会校验输入" {
+		t.Errorf("Unexpected self-check %v %q %v", score, unverified, ok)
+	}
+	if _, _, ok := ParseSelfCheck("- 无"); ok {
+		t.Error("Expected a self-check without a score to be rejected")
+	}
+}
+
+func TestEndpointNotes(t *testing.T) {
+	p := BuildEndpointNotes([]EndpointHandler{
+		{Route: "GET /users", Handler: "h.ListUsers", Code: File{Path: "api/handler.go:5-9", Content: "func (h *Handler) ListUsers() {}"}},
+		{Route: "* /health", Handler: "health"},
+	})
+	if !strings.Contains(p.User, "1. GET /users → h.ListUsers\n文件: api/handler.go:5-9") || !strings.HasSuffix(p.User, "2. * /health → health\n") {
+		t.Errorf("Unexpected endpoint prompt %q", p.User)
+	}
+
+	notes := ParseEndpointNotes("2. 鉴权: 无；校验: 无\n1. 鉴权: JWT；校验: 未知\n9. extra\n", 3)
+	expected := []string{"鉴权: JWT；校验: 未知", "鉴权: 无；校验: 无", ""}
+	if strings.Join(notes, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %q, got %q", expected, notes)
+	}
+}
+
+func TestPRDescription(t *testing.T) {
+	diff := File{Path: "a.go", Language: "Diff", Content: "diff --git a/a.go b/a.go\n+func A() {}\n"}
+	p := BuildPRDescription([]string{"feat: add A\n\nA does nothing yet."}, []File{diff}, []string{"go.sum"}, File{})
+	for _, expected := range []string{
+		"## 测试计划",
+		"提交：\n\n- feat: add A\n  \n  A does nothing yet.",
+		"文件: a.go\n语言: Diff\n```diff\n",
+		"篇幅省略，只知道它们被修改了：go.sum",
+	} {
+		if !strings.Contains(p.User, expected) {
+			t.Errorf("Expected %q in the prompt, got %q", expected, p.User)
+		}
+	}
+	if len(p.Files) != 1 || strings.Contains(p.User, "PR 模板") {
+		t.Errorf("Expected the diff as the only file and no template, got %+v", p)
+	}
+
+	template := File{Path: ".github/pull_request_template.md", Language: "Markdown", Content: "## Summary\n"}
+	p = BuildPRDescription(nil, []File{diff}, nil, template)
+	if !strings.Contains(p.User, "按模板填写") || !strings.Contains(p.User, "PR 模板：\n文件: .github/pull_request_template.md") {
+		t.Errorf("Expected the template to fill in, got %q", p.User)
+	}
+}
+
+func TestCommitRewrite(t *testing.T) {
+	p := BuildCommitRewrite([]CommitMessage{
+		{Message: "update stuff", Problems: []string{"no type"}, Stat: " a.go | 2 ++\n"},
+		{Message: "Fix: typo.", Problems: []string{"upper case", "period"}},
+	}, []string{"feat", "fix"})
+	for _, expected := range []string{"feat、fix", "### 1\n```\nupdate stuff\n```\n问题：no type\n改动：\n```\n a.go | 2 ++\n```", "问题：upper case; period"} {
+		if !strings.Contains(p.User, expected) {
+			t.Errorf("Expected %q in the prompt, got %q", expected, p.User)
+		}
+	}
+
+	answer := "### 2\nfix: correct a typo\n\n### 1\n```\nfeat(a): add A\n\nA does nothing yet.\n```\n### 7\nextra\n"
+	messages := ParseCommitRewrite(answer, 3)
+	expected := []string{"feat(a): add A\n\nA does nothing yet.", "fix: correct a typo", ""}
+	if strings.Join(messages, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %q, got %q", expected, messages)
+	}
+}
+
+func TestBinarySize(t *testing.T) {
+	p := BuildBinarySize("bin/app", 1000,
+		[]SizeEntry{{Name: "modernc.org/sqlite/lib", Size: 750}, {Name: "runtime", Size: 250}},
+		[]SizeEntry{{Name: "modernc.org/sqlite/lib._sqlite3VdbeExec", Size: 700}})
+	for _, expected := range []string{
+		"二进制文件: bin/app\n符号总大小: 1000 字节",
+		"750\t75.0%\tmodernc.org/sqlite/lib\n250\t25.0%\truntime\n",
+		"最大的符号（字节）：\n700\tmodernc.org/sqlite/lib._sqlite3VdbeExec\n",
+	} {
+		if !strings.Contains(p.User, expected) {
+			t.Errorf("Expected %q in the prompt, got %q", expected, p.User)
+		}
+	}
+}
+
+func TestWithDepth(t *testing.T) {
+	p := Build("q", NewFile("a.go", []byte("package a\n")))
+
+	if got := WithDepth(p, ""); got.System != DefaultSystemPrompt {
+		t.Errorf("Expected no depth to leave the system prompt unchanged, got %q", got.System)
+	}
+	got := WithDepth(p, "beginner")
+	if !strings.HasPrefix(got.System, DefaultSystemPrompt+"\n\n") || !strings.Contains(got.System, "新手") || got.User != p.User {
+		t.Errorf("Expected the beginner instruction appended to the system prompt, got %+v", got)
+	}
+
+	for _, depth := range append(Depths(), "") {
+		if err := ValidateDepth(depth); err != nil {
+			t.Errorf("ValidateDepth(%q) failed: %v", depth, err)
+		}
+	}
+	if err := ValidateDepth("guru"); err == nil || !strings.Contains(err.Error(), "beginner, expert, intermediate") {
+		t.Errorf("Expected an error listing the depths, got %v", err)
+	}
+}
+
+func TestQuizQuestion(t *testing.T) {
+	if q := QuizQuestion(3); !strings.Contains(q, "3 道理解题") {
+		t.Errorf("Expected 3 questions, got %q", q)
+	}
+	if q := QuizQuestion(0); !strings.Contains(q, fmt.Sprintf("%d 道理解题", DefaultQuizQuestions)) {
+		t.Errorf("Expected the default number of questions, got %q", q)
+	}
+}
@@ -0,0 +1,44 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rewriteInstruction asks for reformulations of a question in prompts built
+// by BuildRewrite.
+const rewriteInstruction = "请把下面这个关于代码仓库的问题改写成 %d 个用于检索代码的查询，从不同角度描述同一个需求，" +
+	"可以换用同义词、可能出现在代码中的类型名和函数名，或者拆出问题涉及的子问题。每行一个查询，不要编号、解释或其他内容。"
+
+// BuildRewrite creates a Prompt asking for n reformulations of question, to
+// retrieve code for each and merge the results. ParseRewrites reads the
+// answer.
+func BuildRewrite(question string, n int) Prompt {
+	return Prompt{
+		System:   DefaultSystemPrompt,
+		User:     fmt.Sprintf(rewriteInstruction, n) + "\n\n" + question,
+		Question: question,
+	}
+}
+
+// ParseRewrites returns up to n distinct queries from answer, the answer to
+// a prompt built by BuildRewrite, dropping list markers the model added
+// anyway and the question itself.
+func ParseRewrites(answer, question string, n int) []string {
+	seen := map[string]bool{strings.TrimSpace(question): true}
+	var queries []string
+	for _, line := range strings.Split(answer, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimLeft(line, "-*•0123456789.、)） ")
+		line = strings.Trim(line, "\"'`“”")
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		queries = append(queries, line)
+		if len(queries) == n {
+			break
+		}
+	}
+	return queries
+}
@@ -0,0 +1,57 @@
+package prompt
+
+import (
+	"strconv"
+	"strings"
+)
+
+// selfCheckInstruction asks the model to check an answer in prompts built by
+// BuildSelfCheck.
+const selfCheckInstruction = "下面是一个关于代码仓库的问题、针对它给出的回答，以及回答所依据的代码片段。请逐条核对回答中的说法能否由这些片段证实。" +
+	"第一行只写 `置信度: <0 到 100 的整数>`，表示回答整体可信的程度；之后每行以 `- ` 开头，列出一条无法由片段证实的说法，" +
+	"全部都能证实时只写 `- 无`。不要输出其他内容。"
+
+// selfCheckNone marks a self-check that found nothing unverified.
+const selfCheckNone = "无"
+
+// BuildSelfCheck creates a Prompt asking the model to check answer, the
+// answer to question, against snippets, the code it was grounded in.
+// ParseSelfCheck reads the answer.
+func BuildSelfCheck(question, answer string, snippets []Snippet) Prompt {
+	return Build(selfCheckInstruction+"\n\n问题: "+question+"\n\n回答:\n"+answer, BuildGrounded(question, snippets).Files...)
+}
+
+// ParseSelfCheck reads the answer to a prompt built by BuildSelfCheck: a
+// score from 0 to 1 and the claims that could not be verified. It reports
+// false if the answer holds no score.
+func ParseSelfCheck(answer string) (float64, []string, bool) {
+	score, found := 0.0, false
+	var unverified []string
+	for _, line := range strings.Split(answer, "\n") {
+		line = strings.TrimSpace(line)
+		if value, ok := cutAnyPrefix(line, "置信度:", "置信度："); ok && !found {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+			if err != nil {
+				continue
+			}
+			score, found = min(max(n, 0), 100)/100, true
+			continue
+		}
+		if claim, ok := strings.CutPrefix(line, "- "); ok {
+			if claim = strings.TrimSpace(claim); claim != "" && claim != selfCheckNone {
+				unverified = append(unverified, claim)
+			}
+		}
+	}
+	return score, unverified, found
+}
+
+// cutAnyPrefix returns s without the first of prefixes it starts with.
+func cutAnyPrefix(s string, prefixes ...string) (string, bool) {
+	for _, prefix := range prefixes {
+		if rest, ok := strings.CutPrefix(s, prefix); ok {
+			return rest, true
+		}
+	}
+	return s, false
+}
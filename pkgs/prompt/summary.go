@@ -0,0 +1,67 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Summary is a summary of one file or directory, used to fold summaries
+// upwards through a repository.
+type Summary struct {
+	// Name is the file or directory name, directories ending in "/".
+	Name string
+	// Text is the summary.
+	Text string
+}
+
+// DirectorySummaryQuestion asks for a directory summary built from the
+// summaries of its entries.
+const DirectorySummaryQuestion = "下面是目录中各文件和子目录的摘要。请据此用一两段话总结这个目录的职责、主要组成部分以及它们之间的关系。"
+
+// RepoSummaryQuestion asks for an architecture summary of the whole
+// repository built from the summaries of its top-level entries.
+const RepoSummaryQuestion = "下面是仓库顶层各文件和目录的摘要。请据此写一份仓库的架构总结：项目的用途、主要模块及其职责、模块之间的依赖和数据流，" +
+	"以及阅读代码时建议的入口。"
+
+// ExecutiveSummaryQuestion asks for an executive summary of the reports of
+// several runs, such as the analyses of each package of a repository.
+const ExecutiveSummaryQuestion = "下面是多次分析得到的报告。请据此写一份执行摘要：用几段话概括整体情况，列出最重要的发现和风险，" +
+	"并给出优先处理的建议。不要逐份复述报告。"
+
+// BuildDirectorySummary creates a prompt folding the summaries of a
+// directory's entries into one summary of dir.
+func BuildDirectorySummary(dir string, entries []Summary) Prompt {
+	return buildFold(DirectorySummaryQuestion, "目录: "+dir, entries)
+}
+
+// BuildRepoSummary creates a prompt folding the summaries of the top-level
+// entries into an architecture summary of the repository.
+func BuildRepoSummary(entries []Summary) Prompt {
+	return buildFold(RepoSummaryQuestion, "", entries)
+}
+
+// BuildExecutiveSummary creates a prompt folding the reports of several runs
+// into an executive summary.
+func BuildExecutiveSummary(reports []Summary) Prompt {
+	return buildFold(ExecutiveSummaryQuestion, "", reports)
+}
+
+// buildFold creates a prompt asking question about a list of summaries.
+func buildFold(question, heading string, entries []Summary) Prompt {
+	var user strings.Builder
+	user.WriteString(question)
+	if heading != "" {
+		user.WriteString("\n\n" + heading)
+	}
+	for _, entry := range entries {
+		fmt.Fprintf(&user, "\n\n----- %s -----\n%s", entry.Name, strings.TrimSpace(entry.Text))
+	}
+
+	// The summaries are the bulk of the request, so they count towards the
+	// question in Contributions
+	return Prompt{
+		System:   DefaultSystemPrompt,
+		User:     user.String(),
+		Question: user.String(),
+	}
+}
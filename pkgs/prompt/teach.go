@@ -0,0 +1,60 @@
+package prompt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// depthInstructions holds the audience instruction appended to the system
+// prompt for each explanation depth supported by WithDepth.
+var depthInstructions = map[string]string{
+	"beginner": "读者是刚开始学习编程的新手。请用通俗的语言解释，遇到术语、语言特性和标准库时先简单说明它是什么，" +
+		"多用类比和小例子，按代码执行的顺序一步步讲解，不要默认读者了解项目背景。",
+	"intermediate": "读者是有一定经验的开发者，熟悉这门语言的常见用法。请重点解释代码的设计思路、模块之间的协作和不太直观的写法，" +
+		"常见语法和标准库无需赘述。",
+	"expert": "读者是经验丰富的工程师。请直接讨论设计取舍、边界条件、并发与性能特征以及潜在风险，" +
+		"省略基础概念和显而易见的细节，回答尽量精炼。",
+}
+
+// Depths returns the explanation depths supported by WithDepth, sorted.
+func Depths() []string {
+	depths := make([]string, 0, len(depthInstructions))
+	for depth := range depthInstructions {
+		depths = append(depths, depth)
+	}
+	sort.Strings(depths)
+	return depths
+}
+
+// ValidateDepth returns an error if depth is neither empty nor one of Depths.
+func ValidateDepth(depth string) error {
+	if _, ok := depthInstructions[depth]; depth != "" && !ok {
+		return fmt.Errorf("unknown depth %q, expected one of: %s", depth, strings.Join(Depths(), ", "))
+	}
+	return nil
+}
+
+// WithDepth returns a copy of p whose system prompt asks for explanations
+// pitched at depth. An empty or unknown depth leaves p unchanged.
+func WithDepth(p Prompt, depth string) Prompt {
+	if instruction, ok := depthInstructions[depth]; ok {
+		p.System += "\n\n" + instruction
+	}
+	return p
+}
+
+// DefaultQuizQuestions is the number of questions QuizQuestion asks for when
+// given zero.
+const DefaultQuizQuestions = 5
+
+// QuizQuestion asks for n comprehension questions about the supplied code,
+// with answers, for learners checking their understanding.
+func QuizQuestion(n int) string {
+	if n <= 0 {
+		n = DefaultQuizQuestions
+	}
+	return fmt.Sprintf("请根据下面的代码出 %d 道理解题，帮助学习者检验自己是否读懂了代码。题目应覆盖代码的用途、关键流程、"+
+		"边界情况和设计原因，可以是选择题或简答题，由浅入深排列，不要只考查记忆细节。先列出全部题目，"+
+		"再在“参考答案”部分逐题给出答案，并注明依据的代码位置。", n)
+}
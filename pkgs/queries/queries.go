@@ -0,0 +1,204 @@
+// Package queries saves named questions about a repository, so they can be
+// asked again later or in CI, and keeps the history of their answers.
+package queries
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the file the queries of a repository are saved in, at its
+// root. Like the project config file it is meant to be committed.
+const FileName = ".aicodereader-queries.yaml"
+
+// Query is a saved question.
+type Query struct {
+	// Question may contain parameters written as {{name}}, filled in by
+	// Expand each time the query is run.
+	Question string `yaml:"question"`
+}
+
+// validName matches query names: they double as history file names.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// paramPattern matches a {{name}} parameter; the first group is the name.
+var paramPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// Path returns the query file of the repository at root.
+func Path(root string) string {
+	return filepath.Join(root, FileName)
+}
+
+// Load reads the queries saved in the file at path, by name. A missing file
+// has no queries.
+func Load(path string) (map[string]Query, error) {
+	queries := make(map[string]Query)
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return queries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queries: %w", err)
+	}
+	if err := yaml.Unmarshal(content, &queries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return queries, nil
+}
+
+// Save adds or replaces the query name in the file at path.
+func Save(path, name string, query Query) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid query name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	if strings.TrimSpace(query.Question) == "" {
+		return errors.New("a saved query needs a question")
+	}
+
+	queries, err := Load(path)
+	if err != nil {
+		return err
+	}
+	queries[name] = query
+	// Map keys are written sorted, so saving keeps the file stable
+	content, err := yaml.Marshal(queries)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, content)
+}
+
+// Params returns the names of the parameters of question, in order of first
+// appearance.
+func Params(question string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range paramPattern.FindAllStringSubmatch(question, -1) {
+		if name := match[1]; !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Expand fills in the parameters of question from values. It fails if a
+// parameter has no value.
+func Expand(question string, values map[string]string) (string, error) {
+	var missing []string
+	for _, name := range Params(question) {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing value for parameter %s", strings.Join(missing, ", "))
+	}
+	return paramPattern.ReplaceAllStringFunc(question, func(param string) string {
+		return values[paramPattern.FindStringSubmatch(param)[1]]
+	}), nil
+}
+
+// Run is an answer to a saved query.
+type Run struct {
+	Time time.Time `json:"time"`
+	// Question is the question asked, with its parameters filled in.
+	Question string            `json:"question"`
+	Params   map[string]string `json:"params,omitempty"`
+	Source   string            `json:"source"`
+	Provider string            `json:"provider"`
+	Model    string            `json:"model"`
+	Content  string            `json:"content"`
+}
+
+// DefaultHistoryDir returns where the answers to the queries of the
+// repository at root are kept: a directory in the per-user cache directory
+// named after root's absolute path, e.g.
+// ~/.cache/aicodereader/history/<hash>/ on Linux. CI jobs can keep it
+// between runs by caching XDG_CACHE_HOME.
+func DefaultHistoryDir(root string) (string, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, "aicodereader", "history", hex.EncodeToString(sum[:8])), nil
+}
+
+// Record appends run to the history of the query name in dir, one JSON
+// object per line.
+func Record(dir, name string, run Run) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	line, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(historyPath(dir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, writeErr := f.Write(append(line, '\n'))
+	return errors.Join(writeErr, f.Close())
+}
+
+// History returns the runs of the query name recorded in dir, oldest first.
+func History(dir, name string) ([]Run, error) {
+	f, err := os.Open(historyPath(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []Run
+	scanner := bufio.NewScanner(f)
+	// Answers are often longer than the default 64 KiB token limit
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var run Run
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			return nil, fmt.Errorf("corrupt history of %s: %w", name, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, scanner.Err()
+}
+
+// historyPath returns the history file of the query name.
+func historyPath(dir, name string) string {
+	return filepath.Join(dir, name+".jsonl")
+}
+
+// writeFileAtomic replaces the file at path with content, so an interrupted
+// write never leaves a truncated file behind.
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, writeErr := tmp.Write(content)
+	if err := errors.Join(writeErr, tmp.Close()); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,89 @@
+// nolint:testpackage
+package queries
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	path := Path(t.TempDir())
+
+	if queries, err := Load(path); err != nil || len(queries) != 0 {
+		t.Fatalf("Expected no queries without a file, got %v, %v", queries, err)
+	}
+
+	if err := Save(path, "find-auth", Query{Question: "where is authentication enforced?"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := Save(path, "callers", Query{Question: "who calls {{func}}?"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := Save(path, "find-auth", Query{Question: "where are permissions checked?"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	queries, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(queries) != 2 || queries["find-auth"].Question != "where are permissions checked?" {
+		t.Errorf("Expected the second save to replace the first, got %v", queries)
+	}
+	content, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(content), "callers:\n") {
+		t.Errorf("Expected queries sorted by name, got %q", content)
+	}
+
+	for _, name := range []string{"", "../escape", "with space"} {
+		if err := Save(path, name, Query{Question: "q"}); err == nil {
+			t.Errorf("Expected name %q to be rejected", name)
+		}
+	}
+	if err := Save(path, "empty", Query{Question: " "}); err == nil {
+		t.Errorf("Expected an empty question to be rejected")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	question := "who calls {{func}} in {{ pkg }}, and why {{func}}?"
+	if params := Params(question); !slices.Equal(params, []string{"func", "pkg"}) {
+		t.Errorf("Expected parameters in order, got %v", params)
+	}
+
+	expanded, err := Expand(question, map[string]string{"func": "Load", "pkg": "config"})
+	if err != nil || expanded != "who calls Load in config, and why Load?" {
+		t.Errorf("Unexpected expansion %q, %v", expanded, err)
+	}
+
+	if _, err := Expand(question, map[string]string{"func": "Load"}); err == nil || !strings.Contains(err.Error(), "pkg") {
+		t.Errorf("Expected missing parameter error naming pkg, got %v", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "history")
+	if runs, err := History(dir, "find-auth"); err != nil || runs != nil {
+		t.Fatalf("Expected no history, got %v, %v", runs, err)
+	}
+
+	first := Run{Time: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), Question: "q", Model: "m", Content: strings.Repeat("long answer ", 10000)}
+	second := Run{Time: first.Time.Add(time.Hour), Question: "q", Params: map[string]string{"a": "b"}, Content: "short"}
+	for _, run := range []Run{first, second} {
+		if err := Record(dir, "find-auth", run); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	runs, err := History(dir, "find-auth")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(runs) != 2 || runs[0].Content != first.Content || !runs[1].Time.Equal(second.Time) || runs[1].Params["a"] != "b" {
+		t.Errorf("Expected both runs in order, got %d runs", len(runs))
+	}
+}
@@ -0,0 +1,142 @@
+// Package repomap builds a compact map of a repository, listing its files and
+// the exported symbols they declare, so a model sees the project's structure
+// even when only a few files are sent.
+package repomap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/JackDrogon/aicodereader/pkgs/utils"
+)
+
+// DefaultMaxSymbols is the number of symbols listed per file when
+// Options.MaxSymbols is zero.
+const DefaultMaxSymbols = 10
+
+// Options configures Generate.
+type Options struct {
+	// MaxSymbols limits the symbols listed per file; the rest are counted.
+	MaxSymbols int
+}
+
+// File is a file in the map.
+type File struct {
+	// Path is relative to the map's root, with forward slashes.
+	Path string
+	// Symbols are the file's exported top-level symbols in source order.
+	Symbols []string
+}
+
+// Map lists a repository's files, sorted by path.
+type Map struct {
+	Files      []File
+	maxSymbols int
+}
+
+// Generate scans root, honoring .gitignore and skipping hidden, generated and
+// test files, and collects each file's exported symbols.
+func Generate(root string, opts Options) (Map, error) {
+	paths, err := utils.GetSourceList(root, &utils.GetSourceListOptions{
+		RespectGitignore: true,
+		MaxFileSizeBytes: utils.DefaultMaxFileSizeBytes,
+		SkipBinary:       true,
+		SkipGenerated:    true,
+	})
+	if err != nil {
+		return Map{}, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+
+	m := Map{maxSymbols: opts.MaxSymbols}
+	if m.maxSymbols <= 0 {
+		m.maxSymbols = DefaultMaxSymbols
+	}
+
+	for _, path := range paths {
+		if isTestFile(path) {
+			continue
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return Map{}, err
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			// An unreadable file is still part of the layout
+			content = nil
+		}
+		m.Files = append(m.Files, File{Path: filepath.ToSlash(rel), Symbols: Symbols(path, content)})
+	}
+
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return m, nil
+}
+
+// isTestFile reports whether path is a test file by common naming conventions.
+func isTestFile(path string) bool {
+	base := filepath.Base(path)
+	return strings.HasSuffix(base, "_test.go") ||
+		strings.HasPrefix(base, "test_") && strings.HasSuffix(base, ".py") ||
+		strings.Contains(base, ".test.") || strings.Contains(base, ".spec.")
+}
+
+// String renders the map as an indented tree: one line per directory, then
+// one line per file with its symbols.
+//
+//	pkgs/config/
+//	  config.go: Config, LoadConfig
+func (m Map) String() string {
+	var b strings.Builder
+	dir := ""
+	for _, file := range m.Files {
+		fileDir, name := "", file.Path
+		if i := strings.LastIndex(file.Path, "/"); i >= 0 {
+			fileDir, name = file.Path[:i+1], file.Path[i+1:]
+		}
+
+		indent := ""
+		if fileDir != "" {
+			indent = "  "
+			if fileDir != dir {
+				b.WriteString(fileDir + "\n")
+			}
+		}
+		dir = fileDir
+
+		b.WriteString(indent + name)
+		if len(file.Symbols) > 0 {
+			b.WriteString(": " + m.symbolList(file.Symbols))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// symbolList joins symbols, eliding those past the per-file limit.
+func (m Map) symbolList(symbols []string) string {
+	limit := m.maxSymbols
+	if limit <= 0 {
+		limit = DefaultMaxSymbols
+	}
+	if len(symbols) <= limit {
+		return strings.Join(symbols, ", ")
+	}
+	return fmt.Sprintf("%s, … (%d more)", strings.Join(symbols[:limit], ", "), len(symbols)-limit)
+}
+
+// FindRoot returns the work tree of the repository containing dir (see
+// utils.FindRepository), or dir itself if there is none.
+func FindRoot(dir string) string {
+	if repo, found := utils.FindRepository(dir); found && repo.WorkTree != "" {
+		return repo.WorkTree
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
@@ -0,0 +1,132 @@
+// nolint:testpackage
+package repomap
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSymbols(t *testing.T) {
+	cases := []struct {
+		path     string
+		content  string
+		expected []string
+	}{
+		{"a.go", "package a\n\nconst Max, min = 1, 2\n\ntype Store struct{}\n\ntype list[T any] []T\n\nfunc New() *Store { return nil }\n\n" +
+			"func (s *Store) Get() {}\n\nfunc (s *Store) put() {}\n\nfunc (l list[T]) Len() int { return 0 }\n\nfunc helper() {}\n",
+			[]string{"Max", "Store", "New", "Store.Get"}},
+		{"broken.go", "package a\nfunc {", nil},
+		{"a.py", "import os\n\nclass Parser:\n    def parse(self):\n        pass\n\ndef run():\n    pass\n\ndef _private():\n    pass\n",
+			[]string{"Parser", "run"}},
+		{"a.ts", "export interface User {}\nexport default async function load() {}\nconst hidden = 1\nexport const VERSION = 1\n",
+			[]string{"User", "load", "VERSION"}},
+		{"lib.rs", "pub struct Config;\npub(crate) fn build() {}\nfn private() {}\n", []string{"Config", "build"}},
+		{"App.java", "public final class App {\n    public static void main(String[] args) {}\n}\n", []string{"App"}},
+		{"notes.txt", "def not_code():\n", nil},
+	}
+
+	for _, c := range cases {
+		if got := Symbols(c.path, []byte(c.content)); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("Symbols(%s) = %v, expected %v", c.path, got, c.expected)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":                  "package main\n\nfunc main() {}\n",
+		"pkgs/store/store.go":      "package store\n\ntype Store struct{}\n\nfunc Open() {}\n",
+		"pkgs/store/store_test.go": "package store\n\nfunc TestOpen() {}\n",
+		"scripts/run.py":           "def run():\n    pass\n",
+		"build/out.bin":            "ignored",
+		".gitignore":               "build/\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	m, err := Generate(root, Options{})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	expected := "main.go\npkgs/store/\n  store.go: Store, Open\nscripts/\n  run.py: run\n"
+	if got := m.String(); got != expected {
+		t.Errorf("Expected map:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestMaxSymbols(t *testing.T) {
+	m := Map{Files: []File{{Path: "a.go", Symbols: []string{"A", "B", "C", "D"}}}, maxSymbols: 2}
+	if got, expected := m.String(), "a.go: A, B, … (2 more)\n"; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestFindRoot(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	if got := FindRoot(sub); got != sub {
+		t.Errorf("Expected %s without a repository, got %s", sub, got)
+	}
+
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git: %v", err)
+	}
+	if got := FindRoot(sub); got != root {
+		t.Errorf("Expected repository root %s, got %s", root, got)
+	}
+}
+
+func TestTree(t *testing.T) {
+	paths := []string{"main.go", "cmd/app/main.go", "pkgs/a/a.go", "pkgs/a/b.go", "pkgs/b/deep/c.go", "README.md"}
+
+	expected := "cmd/\n" +
+		"  app/ (1 file)\n" +
+		"pkgs/\n" +
+		"  a/ (2 files)\n" +
+		"  b/ (1 file)\n" +
+		"README.md\n" +
+		"main.go\n"
+	if got := Tree(paths, TreeOptions{MaxDepth: 2}); got != expected {
+		t.Errorf("Expected indented tree:\n%s\ngot:\n%s", expected, got)
+	}
+
+	expected = "├── cmd/\n" +
+		"│   └── app/\n" +
+		"│       └── main.go\n" +
+		"└── main.go\n"
+	if got := Tree([]string{"main.go", "cmd/app/main.go"}, TreeOptions{Format: TreeASCII}); got != expected {
+		t.Errorf("Expected ASCII tree:\n%s\ngot:\n%s", expected, got)
+	}
+
+	// One token per line: the budget forces the top level only, then cuts
+	lines := func(text string) int { return strings.Count(text, "\n") + 1 }
+	if got := Tree(paths, TreeOptions{MaxTokens: 4, Count: lines}); got != "cmd/ (1 file)\npkgs/ (3 files)\nREADME.md\nmain.go\n" {
+		t.Errorf("Expected the tree reduced to one level, got:\n%s", got)
+	}
+	if got := Tree(paths, TreeOptions{MaxTokens: 3, Count: lines}); got != "cmd/ (1 file)\npkgs/ (3 files)\n… (2 more)\n" {
+		t.Errorf("Expected the tree cut, got:\n%s", got)
+	}
+
+	if got := Tree(paths, TreeOptions{Format: TreeNone}); got != "" {
+		t.Errorf("Expected no tree, got %q", got)
+	}
+	if _, err := ParseTreeFormat("fancy"); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+}
@@ -0,0 +1,104 @@
+package repomap
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+
+	"github.com/JackDrogon/aicodereader/pkgs/lang"
+)
+
+// symbolPatterns recognize exported top-level declarations of languages
+// without a parser in the standard library. The first group is the name.
+var symbolPatterns = map[string]*regexp.Regexp{
+	"Python":     regexp.MustCompile(`(?m)^(?:async\s+)?(?:def|class)\s+([A-Za-z]\w*)`),
+	"JavaScript": jsExports,
+	"TypeScript": jsExports,
+	"Rust":       regexp.MustCompile(`(?m)^pub(?:\(crate\))?\s+(?:async\s+)?(?:fn|struct|enum|trait|type|const|static|mod)\s+(\w+)`),
+	"Java":       jvmTypes,
+	"Kotlin":     jvmTypes,
+	"C#":         jvmTypes,
+}
+
+var (
+	jsExports = regexp.MustCompile(`(?m)^export\s+(?:default\s+)?(?:async\s+)?(?:function\*?|class|const|let|var|interface|type|enum)\s+([A-Za-z_$][\w$]*)`)
+	jvmTypes  = regexp.MustCompile(`(?m)^\s*public\s+(?:(?:static|final|abstract|sealed)\s+)*(?:class|interface|enum|record)\s+(\w+)`)
+)
+
+// Symbols returns the exported top-level symbols declared in content, in
+// source order. The language is detected by lang.Detect. Go is parsed,
+// methods are reported as "Type.Method"; other languages are matched with
+// patterns. Unknown languages have no symbols.
+func Symbols(path string, content []byte) []string {
+	language := lang.Detect(path, content)
+	if language == "Go" {
+		return goSymbols(content)
+	}
+
+	pattern, ok := symbolPatterns[language]
+	if !ok {
+		return nil
+	}
+
+	var symbols []string
+	for _, match := range pattern.FindAllSubmatch(content, -1) {
+		symbols = append(symbols, string(match[1]))
+	}
+	return symbols
+}
+
+// goSymbols returns the exported functions, methods, types, constants and
+// variables of a Go file, or nil if it does not parse.
+func goSymbols(content []byte) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), "", content, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+
+	var symbols []string
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if !decl.Name.IsExported() {
+				continue
+			}
+			if decl.Recv == nil {
+				symbols = append(symbols, decl.Name.Name)
+			} else if recv := receiverType(decl.Recv.List[0].Type); ast.IsExported(recv) {
+				symbols = append(symbols, recv+"."+decl.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.IsExported() {
+						symbols = append(symbols, spec.Name.Name)
+					}
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if name.IsExported() {
+							symbols = append(symbols, name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// receiverType returns the type name of a method receiver such as "*T" or "T[K]".
+func receiverType(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(expr.X)
+	case *ast.IndexExpr:
+		return receiverType(expr.X)
+	case *ast.IndexListExpr:
+		return receiverType(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
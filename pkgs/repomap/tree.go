@@ -0,0 +1,160 @@
+package repomap
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// TreeFormat selects how Tree draws a directory tree.
+type TreeFormat string
+
+// Tree formats.
+const (
+	// TreeIndent indents each level by two spaces; directories end in "/".
+	TreeIndent TreeFormat = "indent"
+	// TreeASCII draws tree(1) style connectors.
+	TreeASCII TreeFormat = "ascii"
+	// TreeNone disables the tree.
+	TreeNone TreeFormat = "none"
+)
+
+// TreeFormats lists the accepted tree formats.
+func TreeFormats() []string {
+	return []string{string(TreeIndent), string(TreeASCII), string(TreeNone)}
+}
+
+// ParseTreeFormat validates a tree format name; "" selects TreeIndent.
+func ParseTreeFormat(name string) (TreeFormat, error) {
+	switch format := TreeFormat(name); format {
+	case "":
+		return TreeIndent, nil
+	case TreeIndent, TreeASCII, TreeNone:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown tree format %q, expected one of %s", name, strings.Join(TreeFormats(), ", "))
+	}
+}
+
+// DefaultTreeDepth is the number of directory levels Tree shows when
+// TreeOptions.MaxDepth is zero.
+const DefaultTreeDepth = 3
+
+// DefaultTreeTokens is a budget small enough to prepend to every
+// repository-level prompt.
+const DefaultTreeTokens = 1000
+
+// TreeOptions configures Tree.
+type TreeOptions struct {
+	Format TreeFormat
+	// MaxDepth limits the directory levels shown. Directories at the limit
+	// are collapsed into one line with their file count.
+	MaxDepth int
+	// MaxTokens bounds the size of the tree. Tree shows fewer levels until
+	// it fits, then cuts lines. Zero means no budget.
+	MaxTokens int
+	// Count returns the number of tokens in text; nil estimates four
+	// characters per token.
+	Count func(text string) int
+}
+
+// treeNode is a directory in the tree.
+type treeNode struct {
+	dirs  map[string]*treeNode
+	files []string
+	// total counts the files in the directory and below.
+	total int
+}
+
+// Tree draws the directory layout of paths, relative and slash-separated,
+// as a compact text block. It returns "" for TreeNone or no paths.
+func Tree(paths []string, opts TreeOptions) string {
+	if opts.Format == TreeNone || len(paths) == 0 {
+		return ""
+	}
+	count := opts.Count
+	if count == nil {
+		count = func(text string) int { return (len(text) + 3) / 4 }
+	}
+
+	root := &treeNode{dirs: make(map[string]*treeNode)}
+	for _, p := range paths {
+		root.add(strings.Split(path.Clean(p), "/"))
+	}
+
+	depth := opts.MaxDepth
+	if depth <= 0 {
+		depth = DefaultTreeDepth
+	}
+	var lines []string
+	for ; depth >= 1; depth-- {
+		lines = root.render(opts.Format, depth, "", nil)
+		if opts.MaxTokens <= 0 || count(strings.Join(lines, "\n")) <= opts.MaxTokens {
+			return strings.Join(lines, "\n") + "\n"
+		}
+	}
+
+	// Even the top level is over budget: keep as many lines as fit
+	for n := len(lines) - 1; n > 0; n-- {
+		cut := append(lines[:n:n], fmt.Sprintf("… (%d more)", len(lines)-n))
+		if count(strings.Join(cut, "\n")) <= opts.MaxTokens {
+			return strings.Join(cut, "\n") + "\n"
+		}
+	}
+	return ""
+}
+
+// add records the file at the path elements parts.
+func (n *treeNode) add(parts []string) {
+	n.total++
+	if len(parts) == 1 {
+		n.files = append(n.files, parts[0])
+		return
+	}
+	child, ok := n.dirs[parts[0]]
+	if !ok {
+		child = &treeNode{dirs: make(map[string]*treeNode)}
+		n.dirs[parts[0]] = child
+	}
+	child.add(parts[1:])
+}
+
+// render appends the lines of n's entries to lines, directories first, down
+// to depth more levels. prefix is the indentation of n's entries.
+func (n *treeNode) render(format TreeFormat, depth int, prefix string, lines []string) []string {
+	names := make([]string, 0, len(n.dirs))
+	for name := range n.dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	files := append([]string(nil), n.files...)
+	sort.Strings(files)
+
+	entries := len(names) + len(files)
+	for i, name := range append(names, files...) {
+		connector, childPrefix := "", prefix+"  "
+		if format == TreeASCII {
+			connector, childPrefix = "├── ", prefix+"│   "
+			if i == entries-1 {
+				connector, childPrefix = "└── ", prefix+"    "
+			}
+		}
+
+		isDir := i < len(names)
+		switch child := n.dirs[name]; {
+		case !isDir:
+			lines = append(lines, prefix+connector+name)
+		case depth <= 1:
+			unit := "files"
+			if child.total == 1 {
+				unit = "file"
+			}
+			lines = append(lines, fmt.Sprintf("%s%s%s/ (%d %s)", prefix, connector, name, child.total, unit))
+		default:
+			lines = append(lines, prefix+connector+name+"/")
+			lines = child.render(format, depth-1, childPrefix, lines)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,75 @@
+package summary
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Cache stores summaries on disk, one file per key, so re-runs only
+// summarize what changed.
+type Cache struct {
+	dir string
+}
+
+// NewCache creates a cache in dir. The directory is created on first Put.
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// DefaultCacheDir returns the per-user summary cache directory,
+// e.g. ~/.cache/aicodereader/summaries on Linux.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "aicodereader", "summaries"), nil
+}
+
+// Key derives a cache key from parts, which should include everything the
+// summary depends on: model, prompt and content.
+func Key(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Get returns the summary stored under key.
+func (c *Cache) Get(key string) (string, bool) {
+	content, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	return string(content), true
+}
+
+// Put stores summary under key. The file is written atomically so an
+// interrupted run never leaves a truncated summary behind.
+func (c *Cache) Put(key, summary string) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, writeErr := tmp.WriteString(summary)
+	if err := errors.Join(writeErr, tmp.Close()); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// path shards entries by the first two hex digits of the key.
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
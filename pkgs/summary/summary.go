@@ -0,0 +1,230 @@
+// Package summary summarizes a whole repository map-reduce style: every file
+// is summarized, file summaries are folded into directory summaries bottom
+// up, and the top-level summaries into an architecture summary. Intermediate
+// results are cached so re-runs only process what changed.
+package summary
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
+
+// cacheVersion is mixed into every cache key; bump it when the way
+// summaries are produced changes.
+const cacheVersion = "1"
+
+// Summarizer runs the pipeline.
+type Summarizer struct {
+	// Complete sends a prompt and returns the answer text.
+	Complete func(ctx context.Context, p prompt.Prompt) (string, error)
+	// Model identifies the model in cache keys, so switching models does
+	// not reuse another model's summaries.
+	Model string
+	// Cache stores intermediate summaries. Nil disables caching.
+	Cache *Cache
+	// Progress, if set, is called before each step with the file or
+	// directory being summarized and whether the result came from the cache.
+	Progress func(name string, cached bool)
+	// OnSkip, if set, is called for each file left out because it could not
+	// be read or summarized.
+	OnSkip func(name string, err error)
+	// Tree, if set, is a directory tree prepended to the repository summary
+	// prompt; see prompt.WithTree.
+	Tree string
+	// EntryPoints, if set, lists the repository's entry points, prepended
+	// to the repository summary prompt; see prompt.WithEntryPoints.
+	EntryPoints string
+}
+
+// Stats counts the work a run did.
+type Stats struct {
+	Files   int
+	Dirs    int
+	Cached  int
+	Skipped int
+}
+
+// Summarize summarizes the files under root, given as paths from
+// utils.GetSourceList, and returns the repository summary. Files that cannot
+// be read or summarized are skipped and counted in Stats.
+func (s *Summarizer) Summarize(ctx context.Context, root string, files []string) (string, Stats, error) {
+	var stats Stats
+
+	// children maps a directory, relative to root with "." for root itself,
+	// to the summaries of its entries
+	children := make(map[string][]prompt.Summary)
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return "", stats, err
+		}
+		rel = filepath.ToSlash(rel)
+
+		text, err := s.summarizeFile(ctx, file, rel, &stats)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", stats, ctx.Err()
+			}
+			stats.Skipped++
+			if s.OnSkip != nil {
+				s.OnSkip(rel, err)
+			}
+			continue
+		}
+
+		dir := path.Dir(rel)
+		children[dir] = append(children[dir], prompt.Summary{Name: path.Base(rel), Text: text})
+	}
+	if len(children) == 0 {
+		return "", stats, fmt.Errorf("no files under %s could be summarized", root)
+	}
+
+	// Every ancestor of a directory with files takes part in the fold
+	for _, dir := range mapKeys(children) {
+		for dir != "." {
+			dir = path.Dir(dir)
+			if _, ok := children[dir]; !ok {
+				children[dir] = nil
+			}
+		}
+	}
+
+	// Fold deepest directories first, so subdirectory summaries are ready
+	// before their parent's
+	dirs := mapKeys(children)
+	sort.SliceStable(dirs, func(i, j int) bool { return depth(dirs[i]) > depth(dirs[j]) })
+
+	for _, dir := range dirs {
+		if dir == "." {
+			break
+		}
+		text, err := s.summarizeDir(ctx, dir, children[dir], &stats)
+		if err != nil {
+			return "", stats, fmt.Errorf("failed to summarize %s: %w", dir, err)
+		}
+		parent := path.Dir(dir)
+		children[parent] = append(children[parent], prompt.Summary{Name: path.Base(dir) + "/", Text: text})
+	}
+
+	repo := prompt.BuildRepoSummary(sortedSummaries(children["."]))
+	repo = prompt.WithEntryPoints(prompt.WithTree(repo, s.Tree), s.EntryPoints)
+	text, err := s.cached(ctx, "./", repo, &stats)
+	return text, stats, err
+}
+
+// Plan returns the prompts of the files under root that Summarize would
+// send, leaving out cached ones and files that cannot be read, and the number
+// of directory and repository summaries that follow them. Those prompts are
+// built from the file summaries, so they are only known during the run; if
+// every file is cached, so are they, and folds is 0.
+func (s *Summarizer) Plan(root string, files []string) (prompts []prompt.Prompt, folds int, err error) {
+	dirs := map[string]bool{".": true}
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return nil, 0, err
+		}
+		rel = filepath.ToSlash(rel)
+		for dir := path.Dir(rel); !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		p := prompt.Build(prompt.SummarizeQuestion, prompt.NewFile(rel, content))
+		if s.Cache != nil {
+			if _, ok := s.Cache.Get(Key(cacheVersion, s.Model, p.System, p.User)); ok {
+				continue
+			}
+		}
+		prompts = append(prompts, p)
+	}
+	if len(prompts) == 0 {
+		return nil, 0, nil
+	}
+	return prompts, len(dirs), nil
+}
+
+// summarizeFile summarizes one file.
+func (s *Summarizer) summarizeFile(ctx context.Context, file, rel string, stats *Stats) (string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	stats.Files++
+	return s.cached(ctx, rel, prompt.Build(prompt.SummarizeQuestion, prompt.NewFile(rel, content)), stats)
+}
+
+// summarizeDir folds the summaries of a directory's entries.
+func (s *Summarizer) summarizeDir(ctx context.Context, dir string, entries []prompt.Summary, stats *Stats) (string, error) {
+	stats.Dirs++
+	return s.cached(ctx, dir+"/", prompt.BuildDirectorySummary(dir+"/", sortedSummaries(entries)), stats)
+}
+
+// cached returns the answer to p from the cache, or asks the model and
+// caches the answer. The key covers the whole prompt, so a directory is
+// re-summarized exactly when one of its entries' summaries changed.
+func (s *Summarizer) cached(ctx context.Context, name string, p prompt.Prompt, stats *Stats) (string, error) {
+	key := Key(cacheVersion, s.Model, p.System, p.User)
+	if s.Cache != nil {
+		if text, ok := s.Cache.Get(key); ok {
+			stats.Cached++
+			s.progress(name, true)
+			return text, nil
+		}
+	}
+
+	s.progress(name, false)
+	text, err := s.Complete(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	if s.Cache != nil && strings.TrimSpace(text) != "" {
+		// A failed write only costs a recomputation next time
+		_ = s.Cache.Put(key, text)
+	}
+	return text, nil
+}
+
+// progress reports a step if a Progress callback is set.
+func (s *Summarizer) progress(name string, cached bool) {
+	if s.Progress != nil {
+		s.Progress(name, cached)
+	}
+}
+
+// sortedSummaries returns entries sorted by name, so prompts and therefore
+// cache keys do not depend on traversal order.
+func sortedSummaries(entries []prompt.Summary) []prompt.Summary {
+	sorted := slices.Clone(entries)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+// depth returns the number of path elements in dir, "." having none.
+func depth(dir string) int {
+	if dir == "." {
+		return 0
+	}
+	return strings.Count(dir, "/") + 1
+}
+
+// mapKeys returns the keys of m in sorted order.
+func mapKeys(m map[string][]prompt.Summary) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,229 @@
+// nolint:testpackage
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JackDrogon/aicodereader/pkgs/prompt"
+)
+
+// fakeModel answers every prompt with a summary derived from its content and
+// records what it was asked.
+type fakeModel struct {
+	prompts []string
+	fail    string
+}
+
+func (m *fakeModel) complete(_ context.Context, p prompt.Prompt) (string, error) {
+	if m.fail != "" && strings.Contains(p.User, m.fail) {
+		return "", errors.New("rejected")
+	}
+	m.prompts = append(m.prompts, p.User)
+	return fmt.Sprintf("summary %s", Key(p.User)[:8]), nil
+}
+
+// writeRepo creates files under a temporary root and returns the root and
+// the file paths.
+func writeRepo(t *testing.T, files map[string]string) (string, []string) {
+	t.Helper()
+	root := t.TempDir()
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+	return root, paths
+}
+
+func TestSummarize(t *testing.T) {
+	root, paths := writeRepo(t, map[string]string{
+		"main.go":         "package main\n",
+		"pkg/b.go":        "package pkg\n",
+		"pkg/sub/c.go":    "package sub\n",
+		"docs/guide/d.md": "# Guide\n",
+	})
+
+	model := &fakeModel{}
+	s := &Summarizer{Complete: model.complete, Model: "m", Cache: NewCache(t.TempDir())}
+
+	summary, stats, err := s.Summarize(context.Background(), root, paths)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if !strings.HasPrefix(summary, "summary ") {
+		t.Errorf("Expected the repository summary, got %q", summary)
+	}
+
+	// 4 files; pkg/sub, pkg, docs/guide and docs; the repository
+	if len(model.prompts) != 9 || stats.Files != 4 || stats.Dirs != 4 || stats.Cached != 0 {
+		t.Errorf("Expected 9 model calls, got %d with stats %+v", len(model.prompts), stats)
+	}
+
+	repo := model.prompts[len(model.prompts)-1]
+	if !strings.HasPrefix(repo, prompt.RepoSummaryQuestion) {
+		t.Errorf("Expected the last prompt to be the repository summary, got %q", repo)
+	}
+	for _, entry := range []string{"----- docs/ -----", "----- main.go -----", "----- pkg/ -----"} {
+		if !strings.Contains(repo, entry) {
+			t.Errorf("Expected %q in the repository prompt", entry)
+		}
+	}
+	if strings.Contains(repo, "sub/") {
+		t.Errorf("Expected nested directories to be folded into their parent, got %q", repo)
+	}
+}
+
+func TestSummarizeSeeded(t *testing.T) {
+	root, paths := writeRepo(t, map[string]string{"main.go": "package main\n"})
+
+	model := &fakeModel{}
+	s := &Summarizer{Complete: model.complete, Model: "m", Tree: "main.go\n", EntryPoints: "main.go:1 (go-main) func main\n"}
+	if _, _, err := s.Summarize(context.Background(), root, paths); err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+
+	if strings.Contains(model.prompts[0], "func main") {
+		t.Errorf("Expected file prompts without entry points, got %q", model.prompts[0])
+	}
+	repo := model.prompts[len(model.prompts)-1]
+	if !strings.HasPrefix(repo, "可能的程序入口:") || !strings.Contains(repo, "目录结构:") {
+		t.Errorf("Expected entry points and tree to seed the repository prompt, got %q", repo)
+	}
+}
+
+func TestSummarizeCache(t *testing.T) {
+	root, paths := writeRepo(t, map[string]string{
+		"main.go":      "package main\n",
+		"pkg/b.go":     "package pkg\n",
+		"pkg/sub/c.go": "package sub\n",
+		"tools/t.go":   "package tools\n",
+	})
+	cache := NewCache(t.TempDir())
+
+	first := &fakeModel{}
+	s := &Summarizer{Complete: first.complete, Model: "m", Cache: cache}
+	expected, _, err := s.Summarize(context.Background(), root, paths)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+
+	rerun := &fakeModel{}
+	s.Complete = rerun.complete
+	summary, stats, err := s.Summarize(context.Background(), root, paths)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if len(rerun.prompts) != 0 || summary != expected || stats.Cached != len(first.prompts) {
+		t.Errorf("Expected a fully cached re-run, got %d calls, stats %+v", len(rerun.prompts), stats)
+	}
+
+	// Changing one file re-summarizes it and its ancestors only
+	if err := os.WriteFile(filepath.Join(root, "pkg", "sub", "c.go"), []byte("package sub\n\nfunc C() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to update file: %v", err)
+	}
+	changed := &fakeModel{}
+	s.Complete = changed.complete
+	if _, _, err := s.Summarize(context.Background(), root, paths); err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if len(changed.prompts) != 4 {
+		t.Errorf("Expected c.go, pkg/sub, pkg and the repository to be redone, got %d calls", len(changed.prompts))
+	}
+
+	// Another model does not reuse the cache
+	other := &fakeModel{}
+	s.Complete, s.Model = other.complete, "other"
+	if _, _, err := s.Summarize(context.Background(), root, paths); err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if len(other.prompts) != len(first.prompts) {
+		t.Errorf("Expected a different model to start from scratch, got %d calls", len(other.prompts))
+	}
+}
+
+func TestSummarizeSkipsFailedFiles(t *testing.T) {
+	root, paths := writeRepo(t, map[string]string{
+		"good.go": "package good\n",
+		"bad.go":  "package bad\n",
+	})
+
+	var skipped []string
+	model := &fakeModel{fail: "package bad"}
+	s := &Summarizer{
+		Complete: model.complete,
+		OnSkip:   func(name string, _ error) { skipped = append(skipped, name) },
+	}
+
+	_, stats, err := s.Summarize(context.Background(), root, paths)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if stats.Skipped != 1 || len(skipped) != 1 || skipped[0] != "bad.go" {
+		t.Errorf("Expected bad.go to be skipped, got %v, stats %+v", skipped, stats)
+	}
+
+	model.fail = "package"
+	if _, _, err := s.Summarize(context.Background(), root, paths); err == nil {
+		t.Errorf("Expected error when no file can be summarized")
+	}
+}
+
+func TestCache(t *testing.T) {
+	cache := NewCache(t.TempDir())
+	key := Key("a", "b")
+	if key == Key("ab") {
+		t.Errorf("Expected key parts to be separated")
+	}
+
+	if _, ok := cache.Get(key); ok {
+		t.Errorf("Expected a miss on an empty cache")
+	}
+	if err := cache.Put(key, "text"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if text, ok := cache.Get(key); !ok || text != "text" {
+		t.Errorf("Expected cached text, got %q, %v", text, ok)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	root, paths := writeRepo(t, map[string]string{
+		"main.go":      "package main\n",
+		"pkg/b.go":     "package pkg\n",
+		"pkg/sub/c.go": "package sub\n",
+		"tools/t.go":   "package tools\n",
+	})
+	model := &fakeModel{}
+	s := &Summarizer{Complete: model.complete, Model: "m", Cache: NewCache(t.TempDir())}
+
+	prompts, folds, err := s.Plan(root, paths)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if _, _, err := s.Summarize(context.Background(), root, paths); err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if len(prompts) != 4 || len(prompts)+folds != len(model.prompts) {
+		t.Errorf("Expected the plan to cover the %d requests sent, got %d prompts and %d folds", len(model.prompts), len(prompts), folds)
+	}
+
+	prompts, folds, err = s.Plan(root, paths)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(prompts) != 0 || folds != 0 {
+		t.Errorf("Expected nothing left to send once cached, got %d prompts and %d folds", len(prompts), folds)
+	}
+}
@@ -0,0 +1,151 @@
+package tokens
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// contextWindows lists the context windows of common models, in tokens, by
+// model name prefix like prices.
+var contextWindows = map[string]int{
+	"gpt-5":         400_000,
+	"gpt-5-mini":    400_000,
+	"gpt-5-nano":    400_000,
+	"gpt-4.1":       1_047_576,
+	"gpt-4.1-mini":  1_047_576,
+	"gpt-4.1-nano":  1_047_576,
+	"gpt-4o":        128_000,
+	"gpt-4o-mini":   128_000,
+	"gpt-4-turbo":   128_000,
+	"gpt-4":         8_192,
+	"gpt-3.5-turbo": 16_385,
+	"o1":            200_000,
+	"o1-mini":       128_000,
+	"o3":            200_000,
+	"o3-mini":       200_000,
+	"o4-mini":       200_000,
+
+	"claude-opus-4":     200_000,
+	"claude-opus-4-5":   200_000,
+	"claude-sonnet-4":   200_000,
+	"claude-sonnet-4-5": 200_000,
+	"claude-haiku-4-5":  200_000,
+	"claude-3-opus":     200_000,
+	"claude-3-7-sonnet": 200_000,
+	"claude-3-5-sonnet": 200_000,
+	"claude-3-5-haiku":  200_000,
+	"claude-3-haiku":    200_000,
+
+	"gemini-2.5-pro":        1_048_576,
+	"gemini-2.5-flash":      1_048_576,
+	"gemini-2.5-flash-lite": 1_048_576,
+	"gemini-2.0-flash":      1_048_576,
+	"gemini-1.5-pro":        2_097_152,
+	"gemini-1.5-flash":      1_048_576,
+
+	"deepseek-chat":     128_000,
+	"deepseek-reasoner": 128_000,
+}
+
+// ContextWindowFor returns the context window of model in tokens, and false
+// if the model is not in the table.
+func ContextWindowFor(model string) (int, bool) {
+	_, window, ok := lookup(contextWindows, model)
+	return window, ok
+}
+
+// Models returns the models of the bundled price and context tables, sorted.
+func Models() []string {
+	models := make([]string, 0, len(prices))
+	for model := range prices {
+		models = append(models, model)
+	}
+	for model := range contextWindows {
+		if _, ok := prices[model]; !ok {
+			models = append(models, model)
+		}
+	}
+	sort.Strings(models)
+	return models
+}
+
+// maxSuggestions is the number of models MatchModel suggests.
+const maxSuggestions = 3
+
+// MatchModel resolves name, a model as typed by a user, against the bundled
+// tables. Names the tables know, such as "gpt-4o" or the dated
+// "gpt-4o-2024-08-06", are returned as they are, and a part of one model
+// name, such as "4o-mini", is completed to it. Otherwise it returns no model
+// but the models name may have been meant for: those it is part of, or
+// those it is a typo of. Names close to no model, such as those of
+// self-hosted models, get neither.
+func MatchModel(name string) (string, []string) {
+	lower := strings.ToLower(name)
+	if _, _, ok := lookup(prices, lower); ok {
+		return name, nil
+	}
+	if _, _, ok := lookup(contextWindows, lower); ok {
+		return name, nil
+	}
+
+	models := Models()
+	if len(lower) >= 3 {
+		var containing []string
+		for _, model := range models {
+			if strings.Contains(model, lower) {
+				containing = append(containing, model)
+			}
+		}
+		if len(containing) == 1 {
+			return containing[0], nil
+		}
+		if len(containing) > 0 {
+			return "", containing[:min(len(containing), maxSuggestions)]
+		}
+	}
+
+	// Typos are one edit away in short names, two in longer ones
+	maxDistance := 1
+	if len(lower) >= 6 {
+		maxDistance = 2
+	}
+	distances := make(map[string]int)
+	var typos []string
+	for _, model := range models {
+		if d := editDistance(lower, model); d <= maxDistance {
+			distances[model] = d
+			typos = append(typos, model)
+		}
+	}
+	slices.SortStableFunc(typos, func(a, b string) int { return distances[a] - distances[b] })
+	return "", typos[:min(len(typos), maxSuggestions)]
+}
+
+// editDistance returns the number of single-character insertions,
+// deletions, substitutions and swaps of adjacent characters turning a into
+// b.
+func editDistance(a, b string) int {
+	// rows[i][j] is the distance between a[:i] and b[:j]
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)][len(b)]
+}
@@ -0,0 +1,63 @@
+package tokens
+
+// Price is what a model charges, in dollars per million tokens.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Cost returns the cost in dollars of input prompt tokens and output answer
+// tokens.
+func (p Price) Cost(input, output int) float64 {
+	return (float64(input)*p.Input + float64(output)*p.Output) / 1e6
+}
+
+// prices lists the published list prices of common models by model name
+// prefix. Batch, cached-input and long-context rates are left out, so costs
+// err on the high side. Models missing here can be priced in config files.
+var prices = map[string]Price{
+	"gpt-5":         {1.25, 10},
+	"gpt-5-mini":    {0.25, 2},
+	"gpt-5-nano":    {0.05, 0.40},
+	"gpt-4.1":       {2, 8},
+	"gpt-4.1-mini":  {0.40, 1.60},
+	"gpt-4.1-nano":  {0.10, 0.40},
+	"gpt-4o":        {2.50, 10},
+	"gpt-4o-mini":   {0.15, 0.60},
+	"gpt-4-turbo":   {10, 30},
+	"gpt-4":         {30, 60},
+	"gpt-3.5-turbo": {0.50, 1.50},
+	"o1":            {15, 60},
+	"o1-mini":       {1.10, 4.40},
+	"o3":            {2, 8},
+	"o3-mini":       {1.10, 4.40},
+	"o4-mini":       {1.10, 4.40},
+
+	"claude-opus-4":     {15, 75},
+	"claude-opus-4-5":   {5, 25},
+	"claude-sonnet-4":   {3, 15},
+	"claude-sonnet-4-5": {3, 15},
+	"claude-haiku-4-5":  {1, 5},
+	"claude-3-opus":     {15, 75},
+	"claude-3-7-sonnet": {3, 15},
+	"claude-3-5-sonnet": {3, 15},
+	"claude-3-5-haiku":  {0.80, 4},
+	"claude-3-haiku":    {0.25, 1.25},
+
+	"gemini-2.5-pro":        {1.25, 10},
+	"gemini-2.5-flash":      {0.30, 2.50},
+	"gemini-2.5-flash-lite": {0.10, 0.40},
+	"gemini-2.0-flash":      {0.10, 0.40},
+	"gemini-1.5-pro":        {1.25, 5},
+	"gemini-1.5-flash":      {0.075, 0.30},
+
+	"deepseek-chat":     {0.27, 1.10},
+	"deepseek-reasoner": {0.55, 2.19},
+}
+
+// PriceFor returns the price of model, and false if the model is not in the
+// price table.
+func PriceFor(model string) (Price, bool) {
+	_, price, ok := lookup(prices, model)
+	return price, ok
+}
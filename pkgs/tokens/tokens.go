@@ -0,0 +1,115 @@
+// Package tokens estimates what a run will cost before it starts: it counts
+// the tokens of prompts with the tokenizer of each model and prices them with
+// a table of published per-token prices.
+package tokens
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/JackDrogon/aicodereader/pkgs/chunker"
+)
+
+// charsPerToken approximates tokens when no tokenizer is available.
+const charsPerToken = 4
+
+// scales corrects tiktoken counts for model families with tokenizers of
+// their own, by model name prefix. Anthropic's tokenizer splits code into
+// noticeably more tokens than cl100k_base does.
+var scales = map[string]float64{
+	"claude": 1.2,
+}
+
+// Counter returns a function counting the tokens text takes up for model.
+// OpenAI models are counted exactly with their tiktoken encoding; other
+// models are approximated with DefaultEncoding, corrected for families known
+// to tokenize differently.
+func Counter(model string) func(text string) int {
+	scale := 1.0
+	if _, s, ok := lookup(scales, model); ok {
+		scale = s
+	}
+
+	count := func(text string) int { return (len(text) + charsPerToken - 1) / charsPerToken }
+	if tokenizer, err := chunker.TokenizerForModel(model); err == nil {
+		count = func(text string) int { return chunker.CountTokens(tokenizer, text) }
+	}
+	if scale == 1 {
+		return count
+	}
+	return func(text string) int { return int(math.Ceil(float64(count(text)) * scale)) }
+}
+
+// lookup returns the entry of table whose key is the longest prefix of
+// model, so that "gpt-4o-mini" is not priced as "gpt-4o".
+func lookup[V any](table map[string]V, model string) (string, V, bool) {
+	model = strings.ToLower(model)
+	var (
+		match string
+		value V
+		found bool
+	)
+	for prefix, v := range table {
+		if strings.HasPrefix(model, prefix) && (!found || len(prefix) > len(match)) {
+			match, value, found = prefix, v, true
+		}
+	}
+	return match, value, found
+}
+
+// OutputTokensPerRequest is the answer length assumed for each request, since
+// it is only known once the answer is in.
+const OutputTokensPerRequest = 1000
+
+// RequestLatency and OutputTokensPerSecond approximate how long a request
+// takes: the wait for the first token, then the answer at a typical rate.
+const (
+	RequestLatency        = 2 * time.Second
+	OutputTokensPerSecond = 50
+)
+
+// Estimate is the expected size of a run.
+type Estimate struct {
+	// Files is the number of files the run reads, and Parts the number of
+	// parts the files too large for one request are split into.
+	Files        int
+	Parts        int
+	Requests     int
+	InputTokens  int
+	OutputTokens int
+}
+
+// Add counts a request whose prompt takes up input tokens.
+func (e *Estimate) Add(input int) {
+	e.Requests++
+	e.InputTokens += input
+	e.OutputTokens += OutputTokensPerRequest
+}
+
+// Combine adds the requests, files and parts of other to e.
+func (e *Estimate) Combine(other Estimate) {
+	e.Files += other.Files
+	e.Parts += other.Parts
+	e.Requests += other.Requests
+	e.InputTokens += other.InputTokens
+	e.OutputTokens += other.OutputTokens
+}
+
+// Duration returns how long the run takes sending its requests one after
+// another.
+func (e Estimate) Duration() time.Duration {
+	return time.Duration(e.Requests)*RequestLatency + time.Duration(e.OutputTokens)*time.Second/OutputTokensPerSecond
+}
+
+// Cost returns the estimated cost of the run in dollars at price.
+func (e Estimate) Cost(price Price) float64 {
+	return price.Cost(e.InputTokens, e.OutputTokens)
+}
+
+// String describes the estimate, e.g. "3 requests, ~1200 input and ~3000
+// output tokens".
+func (e Estimate) String() string {
+	return fmt.Sprintf("%d requests, ~%d input and ~%d output tokens", e.Requests, e.InputTokens, e.OutputTokens)
+}
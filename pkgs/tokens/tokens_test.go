@@ -0,0 +1,100 @@
+// nolint:testpackage
+package tokens
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCounter(t *testing.T) {
+	text := "func main() { fmt.Println(\"hello, world\") }\n"
+
+	gpt := Counter("gpt-4o")(text)
+	if gpt == 0 {
+		t.Fatalf("Expected tokens for %q", text)
+	}
+	base := Counter("some-local-model")(text)
+	if base == 0 {
+		t.Errorf("Expected unknown models to be counted with the default encoding")
+	}
+	if claude := Counter("claude-sonnet-4-5")(text); claude != int(math.Ceil(float64(base)*1.2)) {
+		t.Errorf("Expected Claude counts scaled from %d, got %d", base, claude)
+	}
+}
+
+func TestPriceFor(t *testing.T) {
+	tests := []struct {
+		model    string
+		expected Price
+		ok       bool
+	}{
+		{"gpt-4o", Price{2.50, 10}, true},
+		{"gpt-4o-mini-2024-07-18", Price{0.15, 0.60}, true},
+		{"gpt-4.1-nano", Price{0.10, 0.40}, true},
+		{"Claude-Sonnet-4-5", Price{3, 15}, true},
+		{"claude-opus-4-5-20251101", Price{5, 25}, true},
+		{"doubao-seed-1.6", Price{}, false},
+	}
+	for _, test := range tests {
+		price, ok := PriceFor(test.model)
+		if ok != test.ok || price != test.expected {
+			t.Errorf("PriceFor(%q) = %+v, %v, expected %+v, %v", test.model, price, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	var e Estimate
+	e.Add(500_000)
+	e.Add(500_000)
+	if e.Requests != 2 || e.InputTokens != 1_000_000 || e.OutputTokens != 2*OutputTokensPerRequest {
+		t.Fatalf("Unexpected estimate %+v", e)
+	}
+	if cost := e.Cost(Price{Input: 2, Output: 8}); math.Abs(cost-2.016) > 1e-9 {
+		t.Errorf("Expected $2.016, got $%v", cost)
+	}
+	if s := e.String(); s != "2 requests, ~1000000 input and ~2000 output tokens" {
+		t.Errorf("Unexpected description %q", s)
+	}
+	if d := e.Duration(); d != 2*RequestLatency+40*time.Second {
+		t.Errorf("Expected two requests of 1000 output tokens to take %v, got %v", 2*RequestLatency+40*time.Second, d)
+	}
+
+	e.Combine(Estimate{Files: 1, Parts: 2, Requests: 3, InputTokens: 10, OutputTokens: 20})
+	if expected := (Estimate{Files: 1, Parts: 2, Requests: 5, InputTokens: 1_000_010, OutputTokens: 2020}); e != expected {
+		t.Errorf("Expected %+v, got %+v", expected, e)
+	}
+}
+
+func TestContextWindowFor(t *testing.T) {
+	if window, ok := ContextWindowFor("gpt-4o-mini-2024-07-18"); !ok || window != 128_000 {
+		t.Errorf("Expected the window of gpt-4o-mini, got %d, %v", window, ok)
+	}
+	if _, ok := ContextWindowFor("llama3"); ok {
+		t.Error("Expected no window for an unknown model")
+	}
+}
+
+func TestMatchModel(t *testing.T) {
+	tests := []struct {
+		name        string
+		model       string
+		suggestions []string
+	}{
+		{"gpt-4o-2024-08-06", "gpt-4o-2024-08-06", nil},
+		{"4o-mini", "gpt-4o-mini", nil},
+		{"haiku", "", []string{"claude-3-5-haiku", "claude-3-haiku", "claude-haiku-4-5"}},
+		{"gtp-4o", "", []string{"gpt-4o", "gpt-4"}},
+		{"claude-sonet-4-5", "", []string{"claude-sonnet-4-5"}},
+		{"llama3", "", nil},
+		{"gpt-oss-20b", "", nil},
+	}
+	for _, tt := range tests {
+		model, suggestions := MatchModel(tt.name)
+		if model != tt.model || fmt.Sprint(suggestions) != fmt.Sprint(tt.suggestions) {
+			t.Errorf("MatchModel(%q): expected %q, %q, got %q, %q", tt.name, tt.model, tt.suggestions, model, suggestions)
+		}
+	}
+}
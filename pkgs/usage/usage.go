@@ -0,0 +1,176 @@
+// Package usage keeps a local ledger of the tokens and cost of each run, so
+// spend can be reported per model, project or command.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is the usage of one model in one run.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Command is the subcommand of the run, such as "read".
+	Command string `json:"command"`
+	// Project is the root of the repository the run was in.
+	Project      string `json:"project"`
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	Requests     int    `json:"requests"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	// Cost is in dollars, zero if the model's price is unknown.
+	Cost float64 `json:"cost"`
+}
+
+// Ledger is a JSON Lines file of entries. Appending a line at a time keeps
+// concurrent runs from corrupting it.
+type Ledger struct {
+	path string
+}
+
+// NewLedger returns the ledger in the file at path.
+func NewLedger(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// DefaultPath returns the ledger's path in the user cache directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "aicodereader", "usage.jsonl"), nil
+}
+
+// Append adds entries to the ledger.
+func (l *Ledger) Append(entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	var lines []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(lines); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Read returns the entries recorded at or after since, oldest first. A
+// missing ledger has no entries, and lines that cannot be parsed, such as
+// one cut short by a crash, are skipped.
+func (l *Ledger) Read(since time.Time) ([]Entry, error) {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !entry.Time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", l.path, err)
+	}
+	return entries, nil
+}
+
+// Total is the usage of a group of entries.
+type Total struct {
+	// Key names the group, such as a model.
+	Key          string
+	Runs         int
+	Requests     int
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+}
+
+// Totals groups entries by key and adds up each group, most expensive
+// first. The entries of a run share their Time, which tells runs apart.
+func Totals(entries []Entry, key func(Entry) string) []Total {
+	groups := make(map[string]*Total)
+	runs := make(map[string]map[int64]bool)
+	for _, entry := range entries {
+		k := key(entry)
+		total, ok := groups[k]
+		if !ok {
+			total = &Total{Key: k}
+			groups[k] = total
+			runs[k] = make(map[int64]bool)
+		}
+		if run := entry.Time.UnixNano(); !runs[k][run] {
+			runs[k][run] = true
+			total.Runs++
+		}
+		total.Requests += entry.Requests
+		total.InputTokens += entry.InputTokens
+		total.OutputTokens += entry.OutputTokens
+		total.Cost += entry.Cost
+	}
+
+	totals := make([]Total, 0, len(groups))
+	for _, total := range groups {
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Cost != totals[j].Cost {
+			return totals[i].Cost > totals[j].Cost
+		}
+		return totals[i].Key < totals[j].Key
+	})
+	return totals
+}
+
+// ParseSince parses the start of a report period relative to now: a
+// duration such as "7d", "2w" or "12h", or a date such as "2024-01-31".
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	if date, err := time.ParseInLocation(time.DateOnly, value, now.Location()); err == nil {
+		return date, nil
+	}
+
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if n, err := strconv.Atoi(strings.TrimSuffix(value, suffix)); err == nil && strings.HasSuffix(value, suffix) && n >= 0 {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid period %q, expected a duration such as 7d, 2w or 12h, or a date such as 2024-01-31", value)
+}
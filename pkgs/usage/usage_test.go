@@ -0,0 +1,91 @@
+// nolint:testpackage
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "usage.jsonl")
+	ledger := NewLedger(path)
+
+	if entries, err := ledger.Read(time.Time{}); err != nil || len(entries) != 0 {
+		t.Fatalf("Expected an empty ledger before the first run, got %v, %v", entries, err)
+	}
+
+	day := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	old := Entry{Time: day.AddDate(0, 0, -30), Command: "read", Model: "gpt-4o", Requests: 1}
+	recent := Entry{Time: day, Command: "summarize", Project: "/src/app", Provider: "openai", Model: "gpt-4o", Requests: 3, InputTokens: 900, OutputTokens: 300, Cost: 0.01}
+	if err := ledger.Append(old); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Append(recent); err != nil {
+		t.Fatal(err)
+	}
+
+	// A line cut short is skipped
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2024-01-`)
+	f.Close()
+
+	entries, err := ledger.Read(day.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0] != recent {
+		t.Errorf("Expected only the recent entry, got %+v", entries)
+	}
+}
+
+func TestTotals(t *testing.T) {
+	run := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: run, Command: "index", Model: "text-embedding-3-small", Requests: 10, InputTokens: 5000, Cost: 0.0001},
+		{Time: run, Command: "index", Model: "gpt-4o", Requests: 1, InputTokens: 100, OutputTokens: 50, Cost: 0.001},
+		{Time: run.Add(time.Hour), Command: "read", Model: "gpt-4o", Requests: 2, InputTokens: 200, OutputTokens: 100, Cost: 0.002},
+	}
+
+	byModel := Totals(entries, func(e Entry) string { return e.Model })
+	expected := []Total{
+		{Key: "gpt-4o", Runs: 2, Requests: 3, InputTokens: 300, OutputTokens: 150, Cost: 0.003},
+		{Key: "text-embedding-3-small", Runs: 1, Requests: 10, InputTokens: 5000, Cost: 0.0001},
+	}
+	if len(byModel) != 2 || byModel[0] != expected[0] || byModel[1] != expected[1] {
+		t.Errorf("Expected %+v, got %+v", expected, byModel)
+	}
+
+	byCommand := Totals(entries, func(e Entry) string { return e.Command })
+	if len(byCommand) != 2 || byCommand[0].Key != "read" || byCommand[1].Runs != 1 {
+		t.Errorf("Expected the entries of one run counted as one run, got %+v", byCommand)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"7d", now.AddDate(0, 0, -7)},
+		{"2w", now.AddDate(0, 0, -14)},
+		{"12h", now.Add(-12 * time.Hour)},
+		{"2024-01-01", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		got, err := ParseSince(test.value, now)
+		if err != nil || !got.Equal(test.expected) {
+			t.Errorf("ParseSince(%q) = %v, %v, expected %v", test.value, got, err, test.expected)
+		}
+	}
+	for _, value := range []string{"", "soon", "-3d"} {
+		if _, err := ParseSince(value, now); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
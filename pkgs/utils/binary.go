@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// binarySniffLimit is how much of a file is inspected to tell binary from
+// text, the same amount git looks at.
+const binarySniffLimit = 8000
+
+// IsBinary reports whether the file at path looks binary: its first block
+// contains a NUL byte or is not valid UTF-8.
+func IsBinary(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	block := make([]byte, binarySniffLimit)
+	n, err := io.ReadFull(f, block)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return isBinary(block[:n], n == binarySniffLimit), nil
+}
+
+// isBinary reports whether block looks binary. If truncated, block was cut
+// from a longer file and may end in the middle of a UTF-8 sequence.
+func isBinary(block []byte, truncated bool) bool {
+	for _, b := range block {
+		if b == 0 {
+			return true
+		}
+	}
+
+	if truncated {
+		// Drop a trailing incomplete sequence, at most UTFMax-1 bytes
+		for i := 0; i < utf8.UTFMax-1 && len(block) > 0; i++ {
+			if r, _ := utf8.DecodeLastRune(block); r != utf8.RuneError {
+				break
+			}
+			block = block[:len(block)-1]
+		}
+	}
+	return !utf8.Valid(block)
+}
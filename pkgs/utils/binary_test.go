@@ -0,0 +1,92 @@
+// nolint:testpackage
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// BinaryTestSuite defines the test suite for binary detection and the size
+// and binary filters of GetSourceList.
+type BinaryTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+// SetupTest creates a fresh temporary directory for each test.
+func (suite *BinaryTestSuite) SetupTest() {
+	tempDir, err := os.MkdirTemp("", "test_binary")
+	suite.Require().NoError(err, "Failed to create temp dir")
+	suite.tempDir = tempDir
+}
+
+// TearDownTest removes the temporary directory.
+func (suite *BinaryTestSuite) TearDownTest() {
+	if suite.tempDir != "" {
+		os.RemoveAll(suite.tempDir)
+	}
+}
+
+// writeFile creates a file with the given content inside the temp directory.
+func (suite *BinaryTestSuite) writeFile(name, content string) string {
+	path := filepath.Join(suite.tempDir, name)
+	suite.Require().NoError(os.WriteFile(path, []byte(content), 0644), "Failed to create file %s", name)
+	return path
+}
+
+// TestIsBinary tests detection on text, binary and truncated UTF-8 content.
+func (suite *BinaryTestSuite) TestIsBinary() {
+	cases := map[string]bool{
+		"package main\n":            false,
+		"":                          false,
+		"中文注释\n":                    false,
+		"\x89PNG\r\n\x1a\n\x00\x00": true,
+		"text\x00more":              true,
+		"latin-1 caf\xe9\n":         true,
+	}
+	for content, expected := range cases {
+		suite.Equal(expected, isBinary([]byte(content), false), "content: %q", content)
+	}
+
+	// A block cut in the middle of a multi-byte character is still text
+	cut := []byte("中文")[:4]
+	suite.False(isBinary(cut, true), "Truncated block ending mid-character should be text")
+	suite.True(isBinary(cut, false), "Complete file ending mid-character should be binary")
+
+	// Only the first block is inspected
+	path := suite.writeFile("long.txt", strings.Repeat("中", binarySniffLimit)+"\x00")
+	binary, err := IsBinary(path)
+	suite.Require().NoError(err)
+	suite.False(binary, "Content after the first block should not be inspected")
+
+	_, err = IsBinary(filepath.Join(suite.tempDir, "missing"))
+	suite.Error(err, "Should return error for missing file")
+}
+
+// TestGetSourceListFilters tests the MaxFileSizeBytes and SkipBinary options.
+func (suite *BinaryTestSuite) TestGetSourceListFilters() {
+	small := suite.writeFile("main.go", "package main\n")
+	big := suite.writeFile("bundle.min.js", strings.Repeat("a", 2048))
+	image := suite.writeFile("logo.png", "\x89PNG\r\n\x1a\n\x00\x00")
+
+	files, err := GetSourceList(suite.tempDir, &GetSourceListOptions{})
+	suite.Require().NoError(err)
+	suite.ElementsMatch([]string{small, big, image}, files, "No filters should return every file")
+
+	files, err = GetSourceList(suite.tempDir, &GetSourceListOptions{MaxFileSizeBytes: 1024})
+	suite.Require().NoError(err)
+	suite.ElementsMatch([]string{small, image}, files, "Files over the size limit should be excluded")
+
+	files, err = GetSourceList(suite.tempDir, &GetSourceListOptions{SkipBinary: true})
+	suite.Require().NoError(err)
+	suite.ElementsMatch([]string{small, big}, files, "Binary files should be excluded")
+}
+
+// TestBinary runs the binary detection test suite.
+func TestBinary(t *testing.T) {
+	suite.Run(t, new(BinaryTestSuite))
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package utils
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// fileID identifies the file described by info, found at path, by its path
+// with symbolic links resolved, as inode numbers are not available.
+func fileID(path string, _ fs.FileInfo) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
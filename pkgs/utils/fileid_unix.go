@@ -0,0 +1,19 @@
+//go:build unix
+
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall"
+)
+
+// fileID identifies the file described by info, found at path, by its
+// device and inode numbers, so a directory reached through several paths
+// is recognized.
+func fileID(path string, info fs.FileInfo) string {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
+	}
+	return path
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxNameBytes is the longest file name most file systems accept.
+const maxNameBytes = 255
+
+// windowsReservedNames are device names Windows refuses as file names, with
+// or without an extension.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// IsWindowsReservedName reports whether name, a single path element, is a
+// Windows device name such as "CON" or "aux.go".
+func IsWindowsReservedName(name string) bool {
+	base, _, _ := strings.Cut(name, ".")
+	return windowsReservedNames[strings.ToUpper(strings.TrimRight(base, " "))]
+}
+
+// PortablePath rewrites rel, a slash-separated relative path found while
+// scanning one file system, into one that can be created on any supported
+// platform. Each element is made portable by portableName; paths that are
+// already portable, including ones with spaces and non-ASCII characters,
+// are returned unchanged.
+func PortablePath(rel string) string {
+	elements := strings.Split(path.Clean(rel), "/")
+	for i, element := range elements {
+		elements[i] = portableName(element)
+	}
+	return strings.Join(elements, "/")
+}
+
+// portableName replaces characters Windows forbids and control characters
+// with "_", appends "_" to reserved device names and to names ending in a
+// dot or space, and shortens names over maxNameBytes, keeping a hash of the
+// original so distinct long names stay distinct.
+func portableName(name string) string {
+	if name == "." || name == ".." {
+		return name
+	}
+
+	portable := strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	if IsWindowsReservedName(portable) {
+		base, ext, _ := strings.Cut(portable, ".")
+		portable = base + "_"
+		if ext != "" {
+			portable += "." + ext
+		}
+	}
+	if strings.HasSuffix(portable, ".") || strings.HasSuffix(portable, " ") {
+		portable += "_"
+	}
+
+	if len(portable) > maxNameBytes {
+		sum := sha256.Sum256([]byte(name))
+		suffix := "-" + hex.EncodeToString(sum[:4]) + path.Ext(portable)
+		if len(suffix) > maxNameBytes/2 {
+			suffix = "-" + hex.EncodeToString(sum[:4])
+		}
+		prefix := portable[:maxNameBytes-len(suffix)]
+		// Do not cut a multi-byte character in half
+		for !utf8.ValidString(prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+		portable = prefix + suffix
+	}
+	return portable
+}
@@ -0,0 +1,128 @@
+// nolint:testpackage
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// specialNames are file paths derived from the seeds of
+// FuzzGetSourceListWithSpecialChars that a file system can hold.
+var specialNames = []string{
+	"dir with spaces/file name.py",
+	"dir-with-unicode-测试/文件.go",
+	"dir\twith\ttabs/a.js",
+	"dir\nwith\nnewlines/a.txt",
+	"dir\\with\\backslashes/a.go",
+	"notes [draft].md",
+	"CON.go",
+	"nul.txt",
+	strings.Repeat("very", 60) + ".go",
+	filepath.Join(strings.Repeat("long/", 70), "deep.go"),
+}
+
+// FilenameTestSuite defines the test suite for portable file names and the
+// discovery of files with unusual names.
+type FilenameTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+// SetupTest creates a fresh temporary directory for each test.
+func (suite *FilenameTestSuite) SetupTest() {
+	tempDir, err := os.MkdirTemp("", "test_filename")
+	suite.Require().NoError(err, "Failed to create temp dir")
+	suite.tempDir = tempDir
+}
+
+// TearDownTest removes the temporary directory.
+func (suite *FilenameTestSuite) TearDownTest() {
+	if suite.tempDir != "" {
+		os.RemoveAll(suite.tempDir)
+	}
+}
+
+// TestIsWindowsReservedName tests device names with and without extensions.
+func (suite *FilenameTestSuite) TestIsWindowsReservedName() {
+	for _, name := range []string{"CON", "con.go", "Aux.tar.gz", "COM1.txt", "lpt9", "NUL .txt"} {
+		suite.True(IsWindowsReservedName(name), "name: %q", name)
+	}
+	for _, name := range []string{"console.go", "COM10", "aux_test.go", "connect", ""} {
+		suite.False(IsWindowsReservedName(name), "name: %q", name)
+	}
+}
+
+// TestPortablePath tests that only names unusable on some platform change.
+func (suite *FilenameTestSuite) TestPortablePath() {
+	cases := map[string]string{
+		"dir with spaces/file name.py": "dir with spaces/file name.py",
+		"测试/文件.go":                     "测试/文件.go",
+		"notes [draft].md":             "notes [draft].md",
+		"./pkg//a.go":                  "pkg/a.go",
+		"CON.go":                       "CON_.go",
+		"src/aux":                      "src/aux_",
+		"lib/nul.tar.gz":               "lib/nul_.tar.gz",
+		"a:b/c?d*.go":                  "a_b/c_d_.go",
+		"dir\twith\ttabs/a.js":         "dir_with_tabs/a.js",
+		"dir\\with\\backslashes/a.go":  "dir_with_backslashes/a.go",
+		"trailing./dot":                "trailing._/dot",
+		"trailing /space":              "trailing _/space",
+	}
+	for rel, expected := range cases {
+		suite.Equal(expected, PortablePath(rel), "path: %q", rel)
+	}
+}
+
+// TestPortablePathLongNames tests that long names are shortened, keep their
+// extension and stay distinct.
+func (suite *FilenameTestSuite) TestPortablePathLongNames() {
+	first := PortablePath("dir/" + strings.Repeat("长", 100) + "a.go")
+	second := PortablePath("dir/" + strings.Repeat("长", 100) + "b.go")
+
+	suite.NotEqual(first, second, "Distinct long names should stay distinct")
+	for _, portable := range []string{first, second} {
+		name := strings.TrimPrefix(portable, "dir/")
+		suite.LessOrEqual(len(name), maxNameBytes, "Name should fit: %q", name)
+		suite.True(utf8.ValidString(name), "Name should be valid UTF-8: %q", name)
+		suite.True(strings.HasSuffix(name, ".go"), "Name should keep its extension: %q", name)
+	}
+	suite.Equal(first, PortablePath("dir/"+strings.Repeat("长", 100)+"a.go"), "Shortening should be stable")
+}
+
+// TestGetSourceListSpecialNames tests that files with spaces, unicode,
+// control characters, reserved names and long paths are all discovered.
+func (suite *FilenameTestSuite) TestGetSourceListSpecialNames() {
+	for _, name := range specialNames {
+		path := filepath.Join(suite.tempDir, name)
+		suite.Require().NoError(os.MkdirAll(filepath.Dir(path), 0755), "Failed to create directory for %q", name)
+		suite.Require().NoError(os.WriteFile(path, []byte("content\n"), 0644), "Failed to create %q", name)
+	}
+	gitignore := "ignored dir/\n"
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.tempDir, ".gitignore"), []byte(gitignore), 0644))
+	suite.Require().NoError(os.MkdirAll(filepath.Join(suite.tempDir, "ignored dir"), 0755))
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.tempDir, "ignored dir", "a.go"), []byte("x\n"), 0644))
+
+	files, err := GetSourceList(suite.tempDir, nil)
+	suite.Require().NoError(err)
+
+	found := make(map[string]bool)
+	for _, file := range files {
+		rel, err := filepath.Rel(suite.tempDir, file)
+		suite.Require().NoError(err)
+		found[rel] = true
+	}
+	for _, name := range specialNames {
+		suite.True(found[name], "Expected %q to be found", name)
+	}
+	suite.False(found[filepath.Join("ignored dir", "a.go")], "Directory with a space in .gitignore should be skipped")
+}
+
+// TestFilename runs the filename test suite.
+func TestFilename(t *testing.T) {
+	suite.Run(t, new(FilenameTestSuite))
+}
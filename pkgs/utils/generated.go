@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// minifiedLineLength is the average line length above which a JavaScript or
+// CSS file is taken to be minified. Hand-written code rarely averages even a
+// hundred characters a line; minifiers put whole files on a few lines.
+const minifiedLineLength = 300
+
+// minifiableExtensions are the extensions of files minifiers produce.
+var minifiableExtensions = map[string]bool{
+	".js": true, ".mjs": true, ".cjs": true, ".css": true,
+}
+
+// IsGenerated reports whether the file at path looks generated rather than
+// written by hand: its header comment carries a generated-code marker, such
+// as Go's "// Code generated by stringer; DO NOT EDIT." or "@generated", or
+// it is minified JavaScript or CSS.
+func IsGenerated(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	head := make([]byte, binarySniffLimit)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	head = head[:n]
+
+	if hasGeneratedMarker(bytes.NewReader(head[:min(n, skipMarkerScanLimit)])) {
+		return true, nil
+	}
+	return isMinified(filepath.Base(path), head), nil
+}
+
+// hasGeneratedMarker scans the header comment block of r for a line marking
+// the file generated: "@generated", or a line saying it was generated and is
+// not to be edited, which covers the markers of most code generators.
+func hasGeneratedMarker(r io.Reader) bool {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		comment, ok := stripCommentPrefix(line)
+		if !ok {
+			// First non-comment line ends the header
+			return false
+		}
+
+		comment = strings.ToLower(comment)
+		if strings.Contains(comment, "@generated") ||
+			strings.Contains(comment, "generated") && strings.Contains(comment, "do not edit") {
+			return true
+		}
+	}
+	return false
+}
+
+// isMinified reports whether the file name with content head, the start of
+// the file, is minified JavaScript or CSS.
+func isMinified(name string, head []byte) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	if !minifiableExtensions[ext] {
+		return false
+	}
+	if strings.HasSuffix(strings.ToLower(name), ".min"+ext) {
+		return true
+	}
+	if len(head) == 0 {
+		return false
+	}
+	lines := bytes.Count(head, []byte{'\n'}) + 1
+	return len(head)/lines > minifiedLineLength
+}
+
+// generatedAttributes holds the linguist-generated attributes of a
+// repository's .gitattributes, with which GitHub hides generated files in
+// diffs and language statistics.
+type generatedAttributes struct {
+	// root is the absolute path of the directory of the .gitattributes file.
+	root  string
+	rules []generatedRule
+}
+
+// generatedRule is a .gitattributes line setting or unsetting
+// linguist-generated.
+type generatedRule struct {
+	pattern   *ignore.GitIgnore
+	generated bool
+}
+
+// loadGeneratedAttributes reads the linguist-generated rules of the
+// .gitattributes file at the root of the work tree containing dir, or of dir
+// outside a repository. It returns nil if there are none.
+func loadGeneratedAttributes(dir string) *generatedAttributes {
+	root := dir
+	if repo, found := FindRepository(dir); found && repo.WorkTree != "" {
+		root = repo.WorkTree
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil
+	}
+	content, err := os.ReadFile(filepath.Join(root, ".gitattributes"))
+	if err != nil {
+		return nil
+	}
+
+	attrs := &generatedAttributes{root: root}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			var generated bool
+			switch attr {
+			case "linguist-generated", "linguist-generated=true":
+				generated = true
+			case "-linguist-generated", "!linguist-generated", "linguist-generated=false":
+				generated = false
+			default:
+				continue
+			}
+			attrs.rules = append(attrs.rules, generatedRule{
+				pattern:   ignore.CompileIgnoreLines(fields[0]),
+				generated: generated,
+			})
+		}
+	}
+	if len(attrs.rules) == 0 {
+		return nil
+	}
+	return attrs
+}
+
+// Generated reports whether the file at path, absolute, is marked
+// linguist-generated. As in git, the last matching line wins.
+func (a *generatedAttributes) Generated(path string) bool {
+	rel, err := filepath.Rel(a.root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for i := len(a.rules) - 1; i >= 0; i-- {
+		if a.rules[i].pattern.MatchesPath(rel) {
+			return a.rules[i].generated
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+// nolint:testpackage
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// GeneratedTestSuite defines the test suite for generated file detection.
+type GeneratedTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+// SetupTest creates a fresh temporary directory for each test.
+func (suite *GeneratedTestSuite) SetupTest() {
+	tempDir, err := os.MkdirTemp("", "test_generated")
+	suite.Require().NoError(err, "Failed to create temp dir")
+	suite.tempDir = tempDir
+	suite.T().Setenv("GIT_DIR", "")
+	suite.T().Setenv("GIT_WORK_TREE", "")
+}
+
+// TearDownTest removes the temporary directory.
+func (suite *GeneratedTestSuite) TearDownTest() {
+	if suite.tempDir != "" {
+		os.RemoveAll(suite.tempDir)
+	}
+}
+
+// writeFile creates a file with the given content inside the temp directory.
+func (suite *GeneratedTestSuite) writeFile(name, content string) string {
+	path := filepath.Join(suite.tempDir, name)
+	suite.Require().NoError(os.MkdirAll(filepath.Dir(path), 0755))
+	suite.Require().NoError(os.WriteFile(path, []byte(content), 0644), "Failed to create file %s", name)
+	return path
+}
+
+// TestGeneratedMarkers tests detection of generator markers in headers.
+func (suite *GeneratedTestSuite) TestGeneratedMarkers() {
+	cases := map[string]bool{
+		"// Code generated by stringer -type=Kind; DO NOT EDIT.\n\npackage main\n":       true,
+		"//go:build linux\n\n// Code generated by mockgen. DO NOT EDIT.\npackage main\n": true,
+		"# Generated by the protocol buffer compiler.  DO NOT EDIT!\nimport sys\n":       true,
+		"/**\n * @generated SignedSource<<abc>>\n */\nexport {}\n":                       true,
+		"package main\n\n// Code generated by hand; DO NOT EDIT.\n":                      false,
+		"// Do not edit without review\npackage main\n":                                  false,
+		"": false,
+	}
+
+	for content, expected := range cases {
+		suite.Equal(expected, hasGeneratedMarker(strings.NewReader(content)), "content: %q", content)
+	}
+}
+
+// TestMinified tests detection of minified JavaScript and CSS.
+func (suite *GeneratedTestSuite) TestMinified() {
+	minified := []byte("!function(){" + strings.Repeat("var a=1;", 200) + "}();\n")
+	readable := []byte(strings.Repeat("const a = 1;\n", 200))
+
+	suite.True(isMinified("app.js", minified))
+	suite.True(isMinified("style.min.css", []byte("a { color: red; }\n")), "A .min name is enough")
+	suite.False(isMinified("app.js", readable))
+	suite.False(isMinified("data.json", minified), "Only JavaScript and CSS are minified")
+	suite.False(isMinified("empty.js", nil))
+
+	generated, err := IsGenerated(suite.writeFile("dist/app.js", string(minified)))
+	suite.Require().NoError(err)
+	suite.True(generated)
+}
+
+// TestGetSourceListSkipsGenerated tests that generated files are excluded
+// only when requested, including files marked in .gitattributes.
+func (suite *GeneratedTestSuite) TestGetSourceListSkipsGenerated() {
+	suite.Require().NoError(os.Mkdir(filepath.Join(suite.tempDir, ".git"), 0755))
+	suite.writeFile(".gitattributes", "# generated code\n*.pb.go linguist-generated\napi/** linguist-generated=true\napi/handwritten.go -linguist-generated\n*.go text eol=lf\n")
+	suite.writeFile("main.go", "package main\n")
+	suite.writeFile("kind_string.go", "// Code generated by stringer; DO NOT EDIT.\n\npackage main\n")
+	suite.writeFile("proto/user.pb.go", "package proto\n")
+	suite.writeFile("api/client.go", "package api\n")
+	suite.writeFile("api/handwritten.go", "package api\n")
+	suite.writeFile("web/vendor.min.js", "var a=1;\n")
+
+	// Scanning a subdirectory uses the .gitattributes of the work tree
+	files, err := GetSourceList(filepath.Join(suite.tempDir, "api"), &GetSourceListOptions{SkipGenerated: true})
+	suite.Require().NoError(err)
+	suite.Equal([]string{filepath.Join(suite.tempDir, "api", "handwritten.go")}, files)
+
+	files, err = GetSourceList(suite.tempDir, &GetSourceListOptions{SkipGenerated: true})
+	suite.Require().NoError(err)
+	suite.Equal([]string{
+		filepath.Join(suite.tempDir, "api", "handwritten.go"),
+		filepath.Join(suite.tempDir, "main.go"),
+	}, files)
+
+	files, err = GetSourceList(suite.tempDir, &GetSourceListOptions{})
+	suite.Require().NoError(err)
+	suite.Len(files, 6, "Generated files should be listed by default")
+}
+
+// TestGenerated runs the generated file test suite.
+func TestGenerated(t *testing.T) {
+	suite.Run(t, new(GeneratedTestSuite))
+}
@@ -1,14 +1,22 @@
 package utils
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io/fs"
 	"log"
+	"os"
 	"path/filepath"
 	"strings"
 
 	ignore "github.com/sabhiram/go-gitignore"
 )
 
+// DefaultMaxFileSizeBytes is a MaxFileSizeBytes suited to source code: far
+// above hand-written files, below most bundles, dumps and build outputs.
+const DefaultMaxFileSizeBytes = 1 << 20
+
 // GetSourceListOptions represents configuration options for the GetSourceList function.
 // It provides fine-grained control over file discovery behavior.
 type GetSourceListOptions struct {
@@ -40,8 +48,89 @@ type GetSourceListOptions struct {
 	//   - If the specified file doesn't exist: silently continues without gitignore rules
 	// When RespectGitignore is false: this field is ignored.
 	GitignoreFilePath string
+
+	// MaxFileSizeBytes excludes files larger than this many bytes, such as
+	// minified bundles, data dumps and build artifacts. Zero means no limit.
+	MaxFileSizeBytes int64
+
+	// SkipBinary excludes binary files, detected by sniffing the start of each
+	// file for NUL bytes or invalid UTF-8 (see IsBinary).
+	SkipBinary bool
+
+	// RespectSparseCheckout excludes files outside the sparse-checkout
+	// patterns of the git repository containing the directory, such as
+	// stale build outputs left in directories outside the cone of a
+	// partially checked-out monorepo. It has no effect outside a repository
+	// or when the repository is fully checked out.
+	RespectSparseCheckout bool
+
+	// IncludeSubmodules traverses git submodules below the directory. By
+	// default they are skipped: their files belong to another repository,
+	// and the .git entry marking them is a file, not a directory.
+	IncludeSubmodules bool
+
+	// FetchLFS replaces Git LFS pointer files with their content by running
+	// "git lfs pull" for them. By default pointer files are skipped, since
+	// their text is a stub, not the file. Fetching needs git-lfs and, for
+	// objects missing from the local LFS cache, access to the LFS server;
+	// pointers that cannot be fetched are skipped with a warning.
+	FetchLFS bool
+
+	// FollowSymlinks includes the targets of symbolic links: linked files
+	// are listed under the link's path and linked directories are walked.
+	// Each directory is walked once, so links back to an ancestor or several
+	// links to one directory do not repeat or loop the scan. By default
+	// symbolic links are skipped; dir itself is always followed.
+	FollowSymlinks bool
+
+	// UseGitLsFiles enumerates the files of a git work tree with "git
+	// ls-files" instead of walking it: tracked files plus untracked files
+	// git does not ignore. Git applies every ignore source exactly (nested
+	// .gitignore files, .git/info/exclude, core.excludesFile) and lists a
+	// large repository much faster than a walk. As in git, tracked files are
+	// listed even if they match an ignore pattern. With RespectGitignore
+	// false ignored files are listed too, and GitignoreFilePath is passed to
+	// git as an extra exclude file. The other filters apply as usual. Outside
+	// a work tree, or when git fails, the directory is walked instead.
+	UseGitLsFiles bool
+
+	// SkipGenerated excludes generated files, which describe the generator
+	// rather than the design: files whose header comment marks them
+	// generated ("Code generated ... DO NOT EDIT", "@generated"), files
+	// marked linguist-generated in the repository's .gitattributes, and
+	// minified JavaScript and CSS (see IsGenerated).
+	SkipGenerated bool
+
+	// MaxDepth limits how many directory levels below the directory are
+	// scanned: 1 lists only the files directly inside it. Zero means no
+	// limit.
+	MaxDepth int
+
+	// MaxFiles stops the scan once this many files are found, returning
+	// them with a *TruncatedError if more would have been listed. Zero
+	// means no limit.
+	MaxFiles int
+
+	// Sample makes a scan capped by MaxFiles list every file and keep
+	// MaxFiles of them picked by the strategy, instead of stopping at the
+	// first MaxFiles found. No *TruncatedError is returned then.
+	Sample Sample
 }
 
+// TruncatedError reports a scan stopped at GetSourceListOptions.MaxFiles.
+// The files found up to the limit are returned with it, so callers can go on
+// with a partial result after warning about it.
+type TruncatedError struct {
+	MaxFiles int
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("scan stopped after %d files", e.MaxFiles)
+}
+
+// errMaxFiles ends the walk when MaxFiles is reached.
+var errMaxFiles = errors.New("maximum number of files reached")
+
 // GetSourceList recursively scans a directory and returns a list of file paths
 // that match the specified criteria. It provides flexible filtering options
 // including gitignore support, glob pattern filtering, and hidden file handling.
@@ -59,9 +148,21 @@ type GetSourceListOptions struct {
 //
 // Behavior:
 //   - Always excludes .git directories from traversal for performance
+//   - Skips symbolic links unless FollowSymlinks=true
+//   - Skips git submodules unless IncludeSubmodules=true
 //   - Respects gitignore rules when RespectGitignore=true
 //   - Filters by glob patterns when IncludePatterns is specified
 //   - Filters hidden files when IncludeHidden=false
+//   - Filters files over MaxFileSizeBytes and binary files when SkipBinary=true
+//   - Filters files outside the sparse checkout when RespectSparseCheckout=true
+//   - Filters generated files when SkipGenerated=true
+//   - Skips Git LFS pointer files, or fetches their content when FetchLFS=true
+//   - Always excludes files whose header carries the SkipFileMarker directive
+//   - Skips unreadable files and directories below dir with a logged warning
+//   - Lists files with git ls-files instead of walking when UseGitLsFiles=true
+//   - Stops below MaxDepth directory levels, and after MaxFiles files with a
+//     *TruncatedError, when they are set; with Sample, keeps a sample of
+//     MaxFiles files instead
 //   - Returns empty slice (not nil) when no files match criteria
 //
 // Example usage:
@@ -88,6 +189,13 @@ type GetSourceListOptions struct {
 //	}
 //	files, err := GetSourceList("./project", options)
 func GetSourceList(dir string, options *GetSourceListOptions) ([]string, error) {
+	return GetSourceListContext(context.Background(), dir, options)
+}
+
+// GetSourceListContext is like GetSourceList but stops walking when ctx is
+// cancelled, returning the files found so far and ctx's error. Use it for
+// scans of large trees that should end promptly on Ctrl-C.
+func GetSourceListContext(ctx context.Context, dir string, options *GetSourceListOptions) ([]string, error) {
 	if options == nil {
 		options = &GetSourceListOptions{
 			RespectGitignore: true,
@@ -105,24 +213,116 @@ func GetSourceList(dir string, options *GetSourceListOptions) ([]string, error)
 		includePatterns = []string{"*"}
 	}
 
-	// Load .gitignore rules if requested
-	if options.RespectGitignore {
+	// Load .gitignore rules if requested; git applies them itself
+	useGit := options.UseGitLsFiles && inWorkTree(dir)
+	if options.RespectGitignore && !useGit {
 		gitIgnore = loadGitignore(dir, options.GitignoreFilePath)
 	}
 
+	var sparse *sparseCheckout
+	if options.RespectSparseCheckout {
+		sparse = loadSparseCheckout(dir)
+	}
+
+	var generated *generatedAttributes
+	if options.SkipGenerated {
+		generated = loadGeneratedAttributes(dir)
+	}
+
 	files := make([]string, 0, 512) // Preallocate larger initial capacity
+	pointers := make(map[string]bool)
+	skippedPointers := 0
+
+	// WalkDir does not descend into a symbolic link, even as its root; a
+	// trailing separator makes it walk the target
+	root := dir
+	if info, err := os.Lstat(dir); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		root = dir + string(filepath.Separator)
+	}
 
-	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
+	// visited holds the directories walked, when following symbolic links
+	var visited map[string]bool
+	if options.FollowSymlinks {
+		visited = make(map[string]bool)
+	}
+
+	// add lists path, unless MaxFiles are listed already
+	sampling := options.Sample != "" && options.MaxFiles > 0
+	add := func(path string) error {
+		if options.MaxFiles > 0 && len(files) >= options.MaxFiles && !sampling {
+			return errMaxFiles
+		}
+		files = append(files, path)
+		return nil
+	}
+
+	var walk fs.WalkDirFunc
+	walk = func(path string, d fs.DirEntry, walkErr error) error {
 		if walkErr != nil {
+			// One unreadable directory should not end the scan of the rest
+			if path != root && errors.Is(walkErr, fs.ErrPermission) {
+				log.Printf("WARNING: Skipping unreadable %q: %v", path, walkErr)
+				if d != nil && d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 			return walkErr
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
+		depth := options.depth(root, path)
 		// Skip if it's a directory
 		if d.IsDir() {
+			if path != root && options.MaxDepth > 0 && depth >= options.MaxDepth {
+				return filepath.SkipDir
+			}
 			// Skip .git directory
 			if d.Name() == ".git" {
 				return filepath.SkipDir
 			}
+			if path != root && !options.IncludeSubmodules && isSubmodule(path) {
+				return filepath.SkipDir
+			}
+			if visited != nil {
+				if info, err := d.Info(); err == nil {
+					id := fileID(path, info)
+					if visited[id] {
+						return filepath.SkipDir
+					}
+					visited[id] = true
+				}
+			}
+			return nil
+		}
+
+		// Files from git ls-files are not pruned with their directories
+		if options.MaxDepth > 0 && depth > options.MaxDepth {
+			return nil
+		}
+
+		info, infoErr := d.Info()
+		if d.Type()&fs.ModeSymlink != 0 {
+			if !options.FollowSymlinks {
+				return nil
+			}
+			if info, infoErr = os.Stat(path); infoErr != nil {
+				log.Printf("WARNING: Skipping broken symbolic link %q: %v", path, infoErr)
+				return nil
+			}
+			if info.IsDir() {
+				return filepath.WalkDir(path+string(filepath.Separator), walk)
+			}
+		}
+		// Reading a FIFO, socket or device would block or fail
+		if infoErr == nil && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		// A submodule's .git file only points to its git directory
+		if d.Name() == ".git" {
 			return nil
 		}
 
@@ -155,13 +355,137 @@ func GetSourceList(dir string, options *GetSourceListOptions) ([]string, error)
 			}
 		}
 
-		files = append(files, path)
+		if sparse != nil || generated != nil {
+			if abs, err := filepath.Abs(path); err == nil {
+				if sparse != nil && !sparse.Includes(abs) {
+					return nil
+				}
+				if generated != nil && generated.Generated(abs) {
+					return nil
+				}
+			}
+		}
+
+		var size int64
+		if infoErr == nil {
+			size = info.Size()
+		}
+		if size <= lfsPointerMaxSize {
+			if pointer, err := IsLFSPointer(path); err == nil && pointer {
+				if options.FetchLFS {
+					// Kept in place for now, checked again once fetched
+					pointers[path] = true
+					return add(path)
+				} else {
+					skippedPointers++
+				}
+				return nil
+			}
+		}
+
+		if options.acceptsContent(path, size) {
+			return add(path)
+		}
 		return nil
-	})
+	}
+	var err error
+	if useGit {
+		err = options.listGitFiles(ctx, root, walk)
+	}
+	if !useGit || errors.Is(err, errGitUnavailable) {
+		if err != nil {
+			log.Printf("WARNING: %v; walking %q instead", err, dir)
+			if options.RespectGitignore {
+				gitIgnore = loadGitignore(dir, options.GitignoreFilePath)
+			}
+		}
+		err = filepath.WalkDir(root, walk)
+	}
+
+	if errors.Is(err, errMaxFiles) {
+		err = &TruncatedError{MaxFiles: options.MaxFiles}
+	}
+	if sampling && err == nil && len(files) > options.MaxFiles {
+		log.Printf("sampled %d of %d files (%s)", options.MaxFiles, len(files), options.Sample)
+		files = sampleFiles(files, options.MaxFiles, options.Sample)
+	}
 
+	if skippedPointers > 0 {
+		log.Printf("skipped %d Git LFS pointer files whose content is not checked out", skippedPointers)
+	}
+	var truncated *TruncatedError
+	if (err == nil || errors.As(err, &truncated)) && len(pointers) > 0 {
+		files = options.fetchLFS(ctx, dir, files, pointers)
+	}
 	return files, err
 }
 
+// depth returns the number of directory levels from root down to path: 0 for
+// root, 1 for its entries, and so on.
+func (o *GetSourceListOptions) depth(root, path string) int {
+	if o.MaxDepth <= 0 {
+		return 0
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// acceptsContent reports whether the file at path, of the given size,
+// passes the size, binary, generated and skip marker filters of o.
+func (o *GetSourceListOptions) acceptsContent(path string, size int64) bool {
+	if o.MaxFileSizeBytes > 0 && size > o.MaxFileSizeBytes {
+		return false
+	}
+	if o.SkipBinary {
+		if binary, err := IsBinary(path); err == nil && binary {
+			return false
+		}
+	}
+	if o.SkipGenerated {
+		if generated, err := IsGenerated(path); err == nil && generated {
+			return false
+		}
+	}
+
+	// Honor in-file opt-out markers regardless of the options above.
+	// Reading the header also catches files that cannot be opened, which
+	// would otherwise fail later, during analysis
+	skip, err := HasSkipMarker(path)
+	if err != nil {
+		log.Printf("WARNING: Skipping unreadable file %q: %v", path, err)
+		return false
+	}
+	return !skip
+}
+
+// fetchLFS pulls the content of the LFS pointer files among files, then
+// returns files without the pointers that are still stubs or whose content
+// fails the other filters of o.
+func (o *GetSourceListOptions) fetchLFS(ctx context.Context, dir string, files []string, pointers map[string]bool) []string {
+	if err := pullLFS(ctx, dir, pointers); err != nil {
+		log.Printf("WARNING: Could not fetch Git LFS files: %v", err)
+	}
+
+	kept := files[:0]
+	for _, path := range files {
+		if pointers[path] {
+			if pointer, err := IsLFSPointer(path); err != nil || pointer {
+				log.Printf("WARNING: Skipping Git LFS pointer %q: content not fetched", path)
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil || !o.acceptsContent(path, info.Size()) {
+				continue
+			}
+		}
+		kept = append(kept, path)
+	}
+	return kept
+}
+
 // loadGitignore handles gitignore file loading with error logging.
 func loadGitignore(dir, customPath string) *ignore.GitIgnore {
 	gitignorePath := customPath
@@ -177,3 +501,11 @@ func loadGitignore(dir, customPath string) *ignore.GitIgnore {
 	}
 	return gitIgnore
 }
+
+// isSubmodule reports whether the directory at path is the work tree of a
+// git submodule, which has a .git file pointing to the superproject's
+// .git/modules instead of a .git directory.
+func isSubmodule(path string) bool {
+	info, err := os.Lstat(filepath.Join(path, ".git"))
+	return err == nil && info.Mode().IsRegular()
+}
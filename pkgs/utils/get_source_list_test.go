@@ -2,11 +2,14 @@
 package utils
 
 import (
+	"context"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -372,6 +375,299 @@ func (suite *GetSourceListTestSuite) TestWithGitDirectory() {
 	suite.False(hasGitFiles, "Should not contain any files from .git directory")
 }
 
+// TestWithCancelledContext tests that a cancelled context stops the walk.
+func (suite *GetSourceListTestSuite) TestWithCancelledContext() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	files, err := GetSourceListContext(ctx, suite.tempDir, &GetSourceListOptions{RespectGitignore: false})
+	suite.ErrorIs(err, context.Canceled, "Should return the context's error")
+	suite.Empty(files, "Should not return files after cancellation")
+
+	files, err = GetSourceListContext(context.Background(), suite.tempDir, &GetSourceListOptions{RespectGitignore: false})
+	suite.Require().NoError(err)
+	suite.NotEmpty(files, "Should walk normally with a live context")
+}
+
+// TestWithUnreadableFiles tests that files and directories that cannot be
+// read are skipped instead of failing the walk.
+func (suite *GetSourceListTestSuite) TestWithUnreadableFiles() {
+	// A dangling symlink cannot be opened, even by root
+	suite.Require().NoError(os.Symlink(filepath.Join(suite.tempDir, "missing.go"), filepath.Join(suite.tempDir, "dangling.go")))
+
+	if os.Geteuid() != 0 {
+		locked := filepath.Join(suite.tempDir, "locked")
+		suite.Require().NoError(os.Mkdir(locked, 0755))
+		suite.Require().NoError(os.WriteFile(filepath.Join(locked, "a.go"), []byte("x"), 0644))
+		suite.Require().NoError(os.Chmod(locked, 0))
+		defer os.Chmod(locked, 0755)
+
+		suite.Require().NoError(os.Chmod(filepath.Join(suite.tempDir, "file1.go"), 0))
+	}
+
+	files, err := GetSourceList(suite.tempDir, &GetSourceListOptions{RespectGitignore: false})
+	suite.Require().NoError(err, "Unreadable entries should not fail the walk")
+
+	relativeFiles := suite.getRelativeFiles(files, true)
+	suite.NotContains(relativeFiles, "dangling.go")
+	suite.Contains(relativeFiles, "dir1/file3.go", "Readable files should still be found")
+	if os.Geteuid() != 0 {
+		suite.NotContains(relativeFiles, "file1.go")
+		suite.NotContains(relativeFiles, "locked/a.go")
+	}
+}
+
+// TestWithSparseCheckout tests that files outside the sparse-checkout cone
+// are skipped only when requested.
+func (suite *GetSourceListTestSuite) TestWithSparseCheckout() {
+	info := filepath.Join(suite.tempDir, ".git", "info")
+	suite.Require().NoError(os.MkdirAll(info, 0755))
+	cone := "/*\n!/*/\n/dir1/\n"
+	suite.Require().NoError(os.WriteFile(filepath.Join(info, "sparse-checkout"), []byte(cone), 0644))
+
+	options := &GetSourceListOptions{RespectGitignore: true, RespectSparseCheckout: true}
+	files, err := GetSourceList(suite.tempDir, options)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"dir1/file3.go", "dir1/file4.txt", "file1.go", "file2.txt"}, suite.getRelativeFiles(files, true))
+
+	// Scanning a subdirectory uses the patterns of the enclosing repository
+	files, err = GetSourceList(filepath.Join(suite.tempDir, "dir2"), options)
+	suite.Require().NoError(err)
+	suite.Empty(files, "dir2 is outside the cone")
+
+	options.RespectSparseCheckout = false
+	files, err = GetSourceList(suite.tempDir, options)
+	suite.Require().NoError(err)
+	suite.Contains(suite.getRelativeFiles(files, true), "dir2/file5.js")
+}
+
+// TestWithSparseCheckoutWorktree tests that a .git file pointing to the git
+// directory, as in worktrees, is followed.
+func (suite *GetSourceListTestSuite) TestWithSparseCheckoutWorktree() {
+	gitDir := filepath.Join(suite.tempDir, "gitdir")
+	suite.Require().NoError(os.MkdirAll(filepath.Join(gitDir, "info"), 0755))
+	suite.Require().NoError(os.WriteFile(filepath.Join(gitDir, "info", "sparse-checkout"), []byte("/dir2/\n"), 0644))
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.tempDir, ".git"), []byte("gitdir: gitdir\n"), 0644))
+
+	files, err := GetSourceList(suite.tempDir, &GetSourceListOptions{RespectGitignore: true, RespectSparseCheckout: true})
+	suite.Require().NoError(err)
+	suite.Equal([]string{"dir2/file5.js"}, suite.getRelativeFiles(files, true))
+}
+
+// TestWithSubmodules tests that submodules are skipped unless requested.
+func (suite *GetSourceListTestSuite) TestWithSubmodules() {
+	submodule := filepath.Join(suite.tempDir, "third_party", "lib")
+	suite.Require().NoError(os.MkdirAll(submodule, 0755))
+	suite.Require().NoError(os.WriteFile(filepath.Join(submodule, ".git"), []byte("gitdir: ../../.git/modules/lib\n"), 0644))
+	suite.Require().NoError(os.WriteFile(filepath.Join(submodule, "lib.go"), []byte("package lib\n"), 0644))
+
+	options := &GetSourceListOptions{RespectGitignore: true, IncludeHidden: true}
+	files, err := GetSourceList(suite.tempDir, options)
+	suite.Require().NoError(err)
+	suite.NotContains(suite.getRelativeFiles(files, true), "third_party/lib/lib.go", "Submodules should be skipped by default")
+
+	options.IncludeSubmodules = true
+	files, err = GetSourceList(suite.tempDir, options)
+	suite.Require().NoError(err)
+	relativeFiles := suite.getRelativeFiles(files, true)
+	suite.Contains(relativeFiles, "third_party/lib/lib.go")
+	suite.NotContains(relativeFiles, "third_party/lib/.git", "The .git file should never be listed")
+
+	// Scanning the submodule itself lists its files
+	files, err = GetSourceList(submodule, &GetSourceListOptions{RespectGitignore: false})
+	suite.Require().NoError(err)
+	suite.Len(files, 1)
+}
+
+// TestWithGitLsFiles tests listing the files of a work tree with git.
+func (suite *GetSourceListTestSuite) TestWithGitLsFiles() {
+	if _, err := exec.LookPath("git"); err != nil {
+		suite.T().Skip("git is not installed")
+	}
+	// git rejects an empty GIT_DIR, so unset them; Setenv restores them
+	for _, name := range []string{"GIT_DIR", "GIT_WORK_TREE"} {
+		suite.T().Setenv(name, "")
+		suite.Require().NoError(os.Unsetenv(name))
+	}
+
+	options := &GetSourceListOptions{RespectGitignore: true, UseGitLsFiles: true}
+	files, err := GetSourceList(suite.tempDir, options)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"dir1/file3.go", "dir1/file4.txt", "dir2/file5.js", "file1.go", "file2.txt"},
+		suite.getRelativeFiles(files, true), "Outside a repository the directory should be walked")
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = suite.tempDir
+		output, err := cmd.CombinedOutput()
+		suite.Require().NoError(err, "git %v: %s", args, output)
+	}
+	// A nested .gitignore, a tracked file matching an ignore pattern and a
+	// tracked file deleted from the work tree
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.tempDir, "dir2", ".gitignore"), []byte("*.js\n"), 0644))
+	git("init", "-q")
+	git("add", "file1.go", "file2.txt")
+	git("add", "-f", "build/output.bin")
+	suite.Require().NoError(os.Remove(filepath.Join(suite.tempDir, "file2.txt")))
+
+	files, err = GetSourceList(suite.tempDir, options)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"build/output.bin", "dir1/file3.go", "dir1/file4.txt", "file1.go"}, suite.getRelativeFiles(files, true))
+
+	options.MaxDepth = 1
+	files, err = GetSourceList(suite.tempDir, options)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"file1.go"}, suite.getRelativeFiles(files, true), "Listed files below MaxDepth should be left out")
+	options.MaxDepth = 0
+
+	options.RespectGitignore = false
+	files, err = GetSourceList(suite.tempDir, options)
+	suite.Require().NoError(err)
+	suite.Contains(suite.getRelativeFiles(files, true), "node_modules/package.json", "Ignored files should be listed without gitignore rules")
+
+	// Without git the directory is walked
+	suite.T().Setenv("PATH", suite.T().TempDir())
+	options.RespectGitignore = true
+	files, err = GetSourceList(suite.tempDir, options)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"dir1/file3.go", "dir1/file4.txt", "dir2/file5.js", "file1.go"}, suite.getRelativeFiles(files, true))
+}
+
+// TestWithSymlinks tests that symbolic links are skipped by default and
+// followed, without loops or repeats, when requested.
+func (suite *GetSourceListTestSuite) TestWithSymlinks() {
+	link := func(target, name string) {
+		suite.Require().NoError(os.Symlink(target, filepath.Join(suite.tempDir, name)), "Failed to create link %s", name)
+	}
+	link("file1.go", "alias.go")
+	link("dir1", "linked")
+	link("dir1", "linked_again")
+	link("..", "dir2/parent") // loops back to the root
+	link("missing", "broken.go")
+
+	options := &GetSourceListOptions{RespectGitignore: true}
+	files, err := GetSourceList(suite.tempDir, options)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"dir1/file3.go", "dir1/file4.txt", "dir2/file5.js", "file1.go", "file2.txt"},
+		suite.getRelativeFiles(files, true), "Symbolic links should be skipped by default")
+
+	options.FollowSymlinks = true
+	files, err = GetSourceList(suite.tempDir, options)
+	suite.Require().NoError(err)
+	relativeFiles := suite.getRelativeFiles(files, true)
+	suite.Contains(relativeFiles, "alias.go", "Linked files should be listed under the link")
+	suite.Contains(relativeFiles, "dir1/file3.go")
+	suite.NotContains(relativeFiles, "broken.go")
+	// dir1 is walked once, whichever path reaches it first, and the loop
+	// through dir2/parent ends at the root
+	suite.Len(relativeFiles, 6, "Got %v", relativeFiles)
+	for _, file := range relativeFiles {
+		suite.False(strings.HasPrefix(file, "linked"), "Directory walked twice through %s", file)
+		suite.False(strings.HasPrefix(file, "dir2/parent/"), "Loop followed through %s", file)
+	}
+
+	// A symbolic link given as the directory is always walked
+	files, err = GetSourceList(filepath.Join(suite.tempDir, "linked"), &GetSourceListOptions{})
+	suite.Require().NoError(err)
+	suite.Len(files, 2)
+}
+
+// TestWithMaxDepth tests that directories below MaxDepth are not scanned.
+func (suite *GetSourceListTestSuite) TestWithMaxDepth() {
+	deep := filepath.Join(suite.tempDir, "dir1", "sub", "file6.go")
+	suite.Require().NoError(os.MkdirAll(filepath.Dir(deep), 0755))
+	suite.Require().NoError(os.WriteFile(deep, []byte("test content"), 0644))
+
+	for depth, expected := range map[int][]string{
+		0: {"dir1/file3.go", "dir1/file4.txt", "dir1/sub/file6.go", "dir2/file5.js", "file1.go", "file2.txt"},
+		1: {"file1.go", "file2.txt"},
+		2: {"dir1/file3.go", "dir1/file4.txt", "dir2/file5.js", "file1.go", "file2.txt"},
+	} {
+		files, err := GetSourceList(suite.tempDir, &GetSourceListOptions{RespectGitignore: true, MaxDepth: depth})
+		suite.Require().NoError(err)
+		suite.Equal(expected, suite.getRelativeFiles(files, true), "MaxDepth %d", depth)
+	}
+}
+
+// TestWithMaxFiles tests that the scan stops at MaxFiles, returning the files
+// found with a *TruncatedError.
+func (suite *GetSourceListTestSuite) TestWithMaxFiles() {
+	options := &GetSourceListOptions{RespectGitignore: true, MaxFiles: 3}
+	files, err := GetSourceList(suite.tempDir, options)
+	var truncated *TruncatedError
+	suite.Require().ErrorAs(err, &truncated)
+	suite.Equal(3, truncated.MaxFiles)
+	suite.Len(files, 3, "The files found before the limit should be returned")
+
+	entries, err := GetSourceEntries(suite.tempDir, options)
+	suite.ErrorAs(err, &truncated)
+	suite.Len(entries, 3)
+
+	// Reaching the limit with the last file is not a truncation
+	options.MaxFiles = 5
+	files, err = GetSourceList(suite.tempDir, options)
+	suite.Require().NoError(err)
+	suite.Len(files, 5)
+}
+
+// TestWithSample tests that a sampled scan keeps MaxFiles files picked by the
+// strategy, in their original order.
+func (suite *GetSourceListTestSuite) TestWithSample() {
+	dir := suite.T().TempDir()
+	for i, name := range []string{"a.go", "b.go", "c.go", "d.go"} {
+		path := filepath.Join(dir, name)
+		suite.Require().NoError(os.WriteFile(path, []byte(strings.Repeat("x", 10*(4-i))), 0644))
+		modTime := time.Date(2024, 5, 1+i, 0, 0, 0, 0, time.UTC)
+		suite.Require().NoError(os.Chtimes(path, modTime, modTime))
+	}
+
+	expected := map[Sample][]string{
+		SampleTopBySize: {filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")},
+		SampleRecent:    {filepath.Join(dir, "c.go"), filepath.Join(dir, "d.go")},
+	}
+	for sample, paths := range expected {
+		files, err := GetSourceList(dir, &GetSourceListOptions{MaxFiles: 2, Sample: sample})
+		suite.Require().NoError(err, "A sample is not a truncated scan")
+		suite.Equal(paths, files, "Sample %s", sample)
+	}
+
+	files, err := GetSourceList(dir, &GetSourceListOptions{MaxFiles: 3, Sample: SampleRandom})
+	suite.Require().NoError(err)
+	suite.Len(files, 3)
+	suite.True(sort.StringsAreSorted(files), "Sampled files should keep their order")
+
+	_, err = ParseSample("largest")
+	suite.Error(err)
+}
+
+// TestGetSourceEntries tests the metadata returned for each file.
+func (suite *GetSourceListTestSuite) TestGetSourceEntries() {
+	script := filepath.Join(suite.tempDir, "dir1", "run")
+	suite.Require().NoError(os.WriteFile(script, []byte("#!/usr/bin/env python3\nprint(1)\n\nprint(2)\n"), 0644))
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	suite.Require().NoError(os.Chtimes(script, modTime, modTime))
+
+	entries, err := GetSourceEntries(suite.tempDir, nil)
+	suite.Require().NoError(err)
+
+	byPath := make(map[string]SourceEntry)
+	for _, entry := range entries {
+		rel, err := filepath.Rel(suite.tempDir, entry.Path)
+		suite.Require().NoError(err)
+		byPath[filepath.ToSlash(rel)] = entry
+	}
+
+	run := byPath["dir1/run"]
+	suite.True(run.ModTime.Equal(modTime), "Expected mod time %v, got %v", modTime, run.ModTime)
+	run.ModTime = time.Time{}
+	suite.Equal(SourceEntry{Path: script, Size: 42, Language: "Python", Lines: 4}, run, "Language should come from the shebang")
+	goFile := byPath["file1.go"]
+	suite.Equal("Go", goFile.Language)
+	suite.Equal(1, goFile.Lines, "A last line without a newline should be counted")
+	suite.Equal(int64(len("test content")), goFile.Size)
+	suite.Len(entries, 6, "Entries should cover the same files as GetSourceList")
+}
+
 // TestGetSourceList runs all the test suites.
 func TestGetSourceList(t *testing.T) {
 	suite.Run(t, new(GetSourceListTestSuite))
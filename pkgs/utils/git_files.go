@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// errGitUnavailable marks a failure to run git ls-files, after which the
+// directory is walked instead.
+var errGitUnavailable = errors.New("git ls-files failed")
+
+// inWorkTree reports whether dir is inside the work tree of a git repository.
+func inWorkTree(dir string) bool {
+	repo, found := FindRepository(dir)
+	return found && repo.WorkTree != ""
+}
+
+// listGitFiles calls walk for each file under dir listed by git ls-files,
+// the way filepath.WalkDir would for a file found by walking.
+func (o *GetSourceListOptions) listGitFiles(ctx context.Context, dir string, walk fs.WalkDirFunc) error {
+	paths, err := o.gitLsFiles(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if err != nil {
+			// Deleted since the last commit, or outside the sparse checkout
+			continue
+		}
+		// A submodule, listed as a single entry when not recursed into
+		if info.IsDir() {
+			continue
+		}
+		if err := walk(path, fs.FileInfoToDirEntry(info), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gitLsFiles returns the sorted paths of the tracked and untracked files
+// under dir, joined to dir, leaving out untracked files git ignores when o
+// respects gitignore rules. Submodules are listed recursively when o
+// includes them.
+func (o *GetSourceListOptions) gitLsFiles(ctx context.Context, dir string) ([]string, error) {
+	args := []string{"ls-files", "--cached", "--others", "-z"}
+	if o.RespectGitignore {
+		args = append(args, "--exclude-standard")
+		if o.GitignoreFilePath != "" {
+			// git fails on a missing exclude file, where a walk goes on
+			// without its rules
+			if abs, err := filepath.Abs(o.GitignoreFilePath); err == nil && fileExists(abs) {
+				args = append(args, "--exclude-from="+abs)
+			} else {
+				log.Printf("WARNING: Could not load gitignore file at %q", o.GitignoreFilePath)
+			}
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("%w: %s", errGitUnavailable, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("%w: %v", errGitUnavailable, err)
+	}
+
+	var paths []string
+	for _, name := range strings.Split(string(output), "\x00") {
+		if name == "" {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if o.IncludeSubmodules && isSubmodule(path) {
+			files, err := o.gitLsFiles(ctx, path)
+			if err != nil {
+				log.Printf("WARNING: Skipping submodule %q: %v", path, err)
+				continue
+			}
+			paths = append(paths, files...)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	// git lists untracked files after the tracked ones
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// fileExists reports whether path names an existing file.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// lfsPointerMaxSize is the size limit of a Git LFS pointer file set by the
+// LFS specification; larger files are never pointers.
+const lfsPointerMaxSize = 1024
+
+// lfsPointerVersion is the first line of every Git LFS pointer file.
+const lfsPointerVersion = "version https://git-lfs.github.com/spec/v1\n"
+
+// IsLFSPointer reports whether the file at path is a Git LFS pointer, the
+// small stub committed in place of a file tracked by LFS, rather than the
+// file's content.
+func IsLFSPointer(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(io.LimitReader(f, lfsPointerMaxSize+1))
+	if err != nil {
+		return false, err
+	}
+	return isLFSPointer(content), nil
+}
+
+// isLFSPointer reports whether content is a Git LFS pointer: the version
+// line followed by at least an oid and a size.
+func isLFSPointer(content []byte) bool {
+	if len(content) > lfsPointerMaxSize || !bytes.HasPrefix(content, []byte(lfsPointerVersion)) {
+		return false
+	}
+	return bytes.Contains(content, []byte("\noid sha256:")) && bytes.Contains(content, []byte("\nsize "))
+}
+
+// pullLFS downloads and checks out the content of the pointer files in
+// paths with "git lfs pull", run in the work tree of the repository
+// containing dir.
+func pullLFS(ctx context.Context, dir string, paths map[string]bool) error {
+	repo, found := FindRepository(dir)
+	if !found || repo.WorkTree == "" {
+		return errors.New("not in a git work tree")
+	}
+
+	include := make([]string, 0, len(paths))
+	for path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(repo.WorkTree, abs)
+		if err != nil {
+			return err
+		}
+		include = append(include, lfsIncludePattern(filepath.ToSlash(rel)))
+	}
+	sort.Strings(include)
+
+	cmd := exec.CommandContext(ctx, "git", "lfs", "pull", "--include", strings.Join(include, ","))
+	cmd.Dir = repo.WorkTree
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git lfs pull: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// lfsIncludePattern returns the "git lfs pull --include" pattern matching
+// path. Glob characters are escaped, and commas, which separate patterns and
+// cannot be escaped, match any character instead.
+func lfsIncludePattern(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		switch r {
+		case '\\', '*', '?', '[':
+			b.WriteByte('\\')
+		case ',':
+			r = '?'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
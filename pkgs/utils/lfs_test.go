@@ -0,0 +1,132 @@
+// nolint:testpackage
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// lfsPointer is a pointer file as written by git-lfs.
+const lfsPointer = "version https://git-lfs.github.com/spec/v1\n" +
+	"oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\n" +
+	"size 12345\n"
+
+// LFSTestSuite defines the test suite for Git LFS pointer handling.
+type LFSTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+// SetupTest creates a repository with a source file and two LFS pointers.
+func (suite *LFSTestSuite) SetupTest() {
+	tempDir, err := os.MkdirTemp("", "test_lfs")
+	suite.Require().NoError(err, "Failed to create temp dir")
+	suite.tempDir = tempDir
+	suite.T().Setenv("GIT_DIR", "")
+	suite.T().Setenv("GIT_WORK_TREE", "")
+
+	suite.Require().NoError(os.Mkdir(filepath.Join(tempDir, ".git"), 0755))
+	for name, content := range map[string]string{
+		"main.go":          "package main\n",
+		"data/model.json":  lfsPointer,
+		"assets/logo.png":  lfsPointer,
+		"docs/pointers.md": "Pointers start with " + lfsPointer,
+	} {
+		path := filepath.Join(tempDir, name)
+		suite.Require().NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		suite.Require().NoError(os.WriteFile(path, []byte(content), 0644), "Failed to create %s", name)
+	}
+}
+
+// TearDownTest removes the temporary directory.
+func (suite *LFSTestSuite) TearDownTest() {
+	if suite.tempDir != "" {
+		os.RemoveAll(suite.tempDir)
+	}
+}
+
+// relativeFiles lists files relative to the temp directory.
+func (suite *LFSTestSuite) relativeFiles(files []string) []string {
+	rel := make([]string, 0, len(files))
+	for _, file := range files {
+		path, err := filepath.Rel(suite.tempDir, file)
+		suite.Require().NoError(err)
+		rel = append(rel, filepath.ToSlash(path))
+	}
+	return rel
+}
+
+// TestIsLFSPointer tests detection of pointer content.
+func (suite *LFSTestSuite) TestIsLFSPointer() {
+	suite.True(isLFSPointer([]byte(lfsPointer)))
+	suite.False(isLFSPointer([]byte("package main\n")))
+	suite.False(isLFSPointer([]byte("version https://git-lfs.github.com/spec/v1\n")), "A version line alone is not a pointer")
+	suite.False(isLFSPointer([]byte(lfsPointer+strings.Repeat("x", lfsPointerMaxSize))), "Pointers are small")
+
+	pointer, err := IsLFSPointer(filepath.Join(suite.tempDir, "data", "model.json"))
+	suite.Require().NoError(err)
+	suite.True(pointer)
+}
+
+// TestLFSIncludePattern tests that paths are quoted for --include.
+func (suite *LFSTestSuite) TestLFSIncludePattern() {
+	suite.Equal("data/model.json", lfsIncludePattern("data/model.json"))
+	suite.Equal("data/a?b.bin", lfsIncludePattern("data/a,b.bin"), "Commas would split the pattern")
+	suite.Equal(`data/\[draft]\*.bin`, lfsIncludePattern("data/[draft]*.bin"))
+}
+
+// TestSkipsPointers tests that pointers are skipped by default.
+func (suite *LFSTestSuite) TestSkipsPointers() {
+	files, err := GetSourceList(suite.tempDir, &GetSourceListOptions{SkipBinary: true})
+	suite.Require().NoError(err)
+	suite.Equal([]string{"docs/pointers.md", "main.go"}, suite.relativeFiles(files))
+}
+
+// TestFetchLFS tests that fetched pointers take their place in the list and
+// are filtered on their real content.
+func (suite *LFSTestSuite) TestFetchLFS() {
+	if runtime.GOOS == "windows" {
+		suite.T().Skip("uses a shell script in place of git")
+	}
+
+	// A stand-in for "git lfs pull --include a,b" that writes each file's
+	// content: text for the JSON file and a binary image for the PNG
+	bin := suite.T().TempDir()
+	script := `#!/bin/sh
+for path in $(echo "$4" | tr ',' ' '); do
+	case "$path" in
+	*.png) printf '\211PNG\r\n\032\n\000\000' > "$path" ;;
+	*) printf '{"weights": []}\n' > "$path" ;;
+	esac
+done
+`
+	suite.Require().NoError(os.WriteFile(filepath.Join(bin, "git"), []byte(script), 0755))
+	suite.T().Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	files, err := GetSourceList(suite.tempDir, &GetSourceListOptions{SkipBinary: true, FetchLFS: true})
+	suite.Require().NoError(err)
+	suite.Equal([]string{"data/model.json", "docs/pointers.md", "main.go"}, suite.relativeFiles(files))
+
+	content, err := os.ReadFile(filepath.Join(suite.tempDir, "data", "model.json"))
+	suite.Require().NoError(err)
+	suite.Equal("{\"weights\": []}\n", string(content))
+}
+
+// TestFetchLFSFailure tests that pointers are skipped when fetching fails.
+func (suite *LFSTestSuite) TestFetchLFSFailure() {
+	suite.T().Setenv("PATH", suite.T().TempDir())
+
+	files, err := GetSourceList(suite.tempDir, &GetSourceListOptions{FetchLFS: true})
+	suite.Require().NoError(err, "A failed fetch should not fail the scan")
+	suite.Equal([]string{"docs/pointers.md", "main.go"}, suite.relativeFiles(files))
+}
+
+// TestLFS runs the LFS test suite.
+func TestLFS(t *testing.T) {
+	suite.Run(t, new(LFSTestSuite))
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Repository locates the files of a git repository.
+type Repository struct {
+	// WorkTree is the absolute path of the checked-out files, empty for a
+	// bare repository.
+	WorkTree string
+	// GitDir is the absolute path of the git directory. For linked worktrees
+	// and submodules it is outside WorkTree, named by a "gitdir:" line in
+	// WorkTree/.git.
+	GitDir string
+}
+
+// FindRepository returns the repository containing dir, looking for a .git
+// directory or file at or above dir, and reports whether there is one. A
+// bare repository is found when dir is its git directory.
+//
+// As with git, GIT_DIR names the git directory instead, with the current
+// directory, not dir, as the work tree unless GIT_WORK_TREE is set, and
+// GIT_WORK_TREE overrides the work tree of a discovered repository.
+func FindRepository(dir string) (Repository, bool) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return Repository{}, false
+	}
+
+	var repo Repository
+	if gitDir := os.Getenv("GIT_DIR"); gitDir != "" {
+		gitDir, err = filepath.Abs(gitDir)
+		if err != nil {
+			return Repository{}, false
+		}
+		wd, err := os.Getwd()
+		if err != nil {
+			return Repository{}, false
+		}
+		repo = Repository{WorkTree: wd, GitDir: gitDir}
+	} else if repo, err = discoverRepository(abs); err != nil {
+		return Repository{}, false
+	}
+
+	if workTree := os.Getenv("GIT_WORK_TREE"); workTree != "" {
+		if workTree, err = filepath.Abs(workTree); err != nil {
+			return Repository{}, false
+		}
+		repo.WorkTree = workTree
+	}
+	return repo, true
+}
+
+// discoverRepository walks up from dir, absolute, to the nearest repository.
+func discoverRepository(dir string) (Repository, error) {
+	for current := dir; ; {
+		dotGit := filepath.Join(current, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() {
+				return Repository{WorkTree: current, GitDir: dotGit}, nil
+			}
+			gitDir, err := readGitFile(dotGit)
+			if err != nil {
+				return Repository{}, err
+			}
+			return Repository{WorkTree: current, GitDir: gitDir}, nil
+		}
+		if current == dir && isBareRepository(current) {
+			return Repository{GitDir: current}, nil
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return Repository{}, os.ErrNotExist
+		}
+		current = parent
+	}
+}
+
+// readGitFile returns the absolute git directory named by the "gitdir:" line
+// of a .git file, as written for linked worktrees and submodules.
+func readGitFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	gitDir, found := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir:")
+	if !found {
+		return "", os.ErrInvalid
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	return gitDir, nil
+}
+
+// isBareRepository reports whether dir has the layout of a git directory.
+func isBareRepository(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+// nolint:testpackage
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// RepositoryTestSuite defines the test suite for FindRepository.
+type RepositoryTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+// SetupTest creates a fresh temporary directory for each test and clears
+// the git environment variables.
+func (suite *RepositoryTestSuite) SetupTest() {
+	tempDir, err := os.MkdirTemp("", "test_repository")
+	suite.Require().NoError(err, "Failed to create temp dir")
+	// Resolve symlinked temp directories, such as /tmp on macOS
+	suite.tempDir, err = filepath.EvalSymlinks(tempDir)
+	suite.Require().NoError(err)
+
+	suite.T().Setenv("GIT_DIR", "")
+	suite.T().Setenv("GIT_WORK_TREE", "")
+}
+
+// TearDownTest removes the temporary directory.
+func (suite *RepositoryTestSuite) TearDownTest() {
+	if suite.tempDir != "" {
+		os.RemoveAll(suite.tempDir)
+	}
+}
+
+// mkdir creates a directory inside the temp directory and returns its path.
+func (suite *RepositoryTestSuite) mkdir(name string) string {
+	path := filepath.Join(suite.tempDir, name)
+	suite.Require().NoError(os.MkdirAll(path, 0755), "Failed to create %s", name)
+	return path
+}
+
+// TestConventional tests a .git directory found from a subdirectory.
+func (suite *RepositoryTestSuite) TestConventional() {
+	gitDir := suite.mkdir("repo/.git")
+	sub := suite.mkdir("repo/a/b")
+
+	repo, found := FindRepository(sub)
+	suite.True(found)
+	suite.Equal(Repository{WorkTree: filepath.Join(suite.tempDir, "repo"), GitDir: gitDir}, repo)
+
+	_, found = FindRepository(suite.mkdir("elsewhere"))
+	suite.False(found, "A directory outside any repository has none")
+}
+
+// TestLinkedWorktree tests a .git file pointing into the main repository.
+func (suite *RepositoryTestSuite) TestLinkedWorktree() {
+	gitDir := suite.mkdir("main/.git/worktrees/feature")
+	worktree := suite.mkdir("feature")
+	suite.Require().NoError(os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+gitDir+"\n"), 0644))
+
+	repo, found := FindRepository(worktree)
+	suite.True(found)
+	suite.Equal(Repository{WorkTree: worktree, GitDir: gitDir}, repo)
+
+	// Submodules use a relative path
+	submodule := suite.mkdir("main/lib")
+	suite.Require().NoError(os.WriteFile(filepath.Join(submodule, ".git"), []byte("gitdir: ../.git/modules/lib\n"), 0644))
+	repo, found = FindRepository(submodule)
+	suite.True(found)
+	suite.Equal(filepath.Join(suite.tempDir, "main", ".git", "modules", "lib"), repo.GitDir)
+}
+
+// TestBare tests a bare repository, which has no work tree.
+func (suite *RepositoryTestSuite) TestBare() {
+	bare := suite.mkdir("project.git")
+	suite.mkdir("project.git/objects")
+	suite.mkdir("project.git/refs")
+	suite.Require().NoError(os.WriteFile(filepath.Join(bare, "HEAD"), []byte("ref: refs/heads/main\n"), 0644))
+
+	repo, found := FindRepository(bare)
+	suite.True(found)
+	suite.Equal(Repository{GitDir: bare}, repo)
+}
+
+// TestEnvironmentOverrides tests GIT_DIR and GIT_WORK_TREE.
+func (suite *RepositoryTestSuite) TestEnvironmentOverrides() {
+	gitDir := suite.mkdir("store/project.git")
+	workTree := suite.mkdir("checkout")
+	suite.mkdir("repo/.git")
+	inside := suite.mkdir("repo/src")
+
+	suite.T().Setenv("GIT_WORK_TREE", workTree)
+	repo, found := FindRepository(inside)
+	suite.True(found)
+	suite.Equal(Repository{WorkTree: workTree, GitDir: filepath.Join(suite.tempDir, "repo", ".git")}, repo)
+
+	suite.T().Setenv("GIT_DIR", gitDir)
+	repo, found = FindRepository(inside)
+	suite.True(found)
+	suite.Equal(Repository{WorkTree: workTree, GitDir: gitDir}, repo)
+
+	suite.T().Setenv("GIT_WORK_TREE", "")
+	checkout := suite.mkdir("elsewhere")
+	suite.T().Chdir(checkout)
+	repo, _ = FindRepository(inside)
+	suite.Equal(checkout, repo.WorkTree, "Without GIT_WORK_TREE the current directory is the work tree")
+}
+
+// TestRepository runs the repository test suite.
+func TestRepository(t *testing.T) {
+	suite.Run(t, new(RepositoryTestSuite))
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Sample selects which files a scan capped by GetSourceListOptions.MaxFiles
+// keeps, so an exploratory pass over a large repository covers a predictable
+// number of files that are spread over it rather than the first ones walked.
+type Sample string
+
+const (
+	// SampleRandom keeps files picked at random.
+	SampleRandom Sample = "random"
+	// SampleTopBySize keeps the largest files, which tend to hold the core
+	// logic.
+	SampleTopBySize Sample = "top-by-size"
+	// SampleRecent keeps the most recently modified files.
+	SampleRecent Sample = "recent"
+)
+
+// Samples returns the names of the sampling strategies, for flag help.
+func Samples() []string {
+	return []string{string(SampleRandom), string(SampleTopBySize), string(SampleRecent)}
+}
+
+// ParseSample parses a sampling strategy name. The empty name means no
+// sampling.
+func ParseSample(name string) (Sample, error) {
+	switch sample := Sample(name); sample {
+	case "", SampleRandom, SampleTopBySize, SampleRecent:
+		return sample, nil
+	}
+	return "", fmt.Errorf("unknown sampling strategy %q, expected one of %s", name, strings.Join(Samples(), ", "))
+}
+
+// sampleFiles returns n of files picked by sample, in their original order.
+// Files that cannot be stat'ed sort last for the size and recency
+// strategies.
+func sampleFiles(files []string, n int, sample Sample) []string {
+	if n <= 0 || len(files) <= n {
+		return files
+	}
+
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	switch sample {
+	case SampleRandom:
+		rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	case SampleTopBySize, SampleRecent:
+		keys := make([]int64, len(files))
+		for i, file := range files {
+			keys[i] = -1
+			if info, err := os.Stat(file); err == nil {
+				keys[i] = info.Size()
+				if sample == SampleRecent {
+					keys[i] = info.ModTime().UnixNano()
+				}
+			}
+		}
+		sort.SliceStable(order, func(i, j int) bool { return keys[order[i]] > keys[order[j]] })
+	}
+
+	keep := order[:n]
+	sort.Ints(keep)
+	sampled := make([]string, n)
+	for i, index := range keep {
+		sampled[i] = files[index]
+	}
+	return sampled
+}
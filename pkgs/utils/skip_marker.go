@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// SkipFileMarker is the directive that excludes a file from analysis when it
+// appears in the file's leading comment block, e.g. "// aicodereader:skip-file".
+const SkipFileMarker = "aicodereader:skip-file"
+
+// SkipFileTag is the SPDX-style header tag that excludes a file from
+// analysis, written like the SPDX-License-Identifier lines of REUSE headers
+// with the reason as its value, e.g.
+// "# AICodeReader-Skip: vendored from github.com/pkg/errors". Tags are
+// matched case-insensitively, as SPDX tags are.
+const SkipFileTag = "AICodeReader-Skip"
+
+// skipMarkerScanLimit bounds how much of a file is read when looking for the
+// marker. Like SPDX identifiers, the marker is expected near the top of a file.
+const skipMarkerScanLimit = 4096
+
+// commentPrefixes lists the line comment openers recognized in file headers.
+var commentPrefixes = []string{"//", "/*", "<!--", "--", "#", ";", "*"}
+
+// HasSkipMarker reports whether the file at path carries SkipFileMarker or
+// SkipFileTag in its header. Only the leading run of blank and comment lines is inspected, so the
+// marker mentioned later in code or string literals has no effect.
+func HasSkipMarker(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	return hasSkipMarker(io.LimitReader(f, skipMarkerScanLimit)), nil
+}
+
+// hasSkipMarker scans the header comment block of r for SkipFileMarker and
+// SkipFileTag.
+func hasSkipMarker(r io.Reader) bool {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		comment, ok := stripCommentPrefix(line)
+		if !ok {
+			// First non-comment line ends the header
+			return false
+		}
+
+		comment = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(comment, "*/"), "-->"))
+		if strings.HasPrefix(comment, SkipFileMarker) || isSkipFileTag(comment) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripCommentPrefix removes a leading comment opener from line.
+// It returns false if line does not start with a known opener.
+func stripCommentPrefix(line string) (string, bool) {
+	for _, prefix := range commentPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimLeft(line[len(prefix):], "/*#!;- \t"), true
+		}
+	}
+	return line, false
+}
+
+// isSkipFileTag reports whether comment is a SkipFileTag line, "Tag: value".
+func isSkipFileTag(comment string) bool {
+	tag, _, ok := strings.Cut(comment, ":")
+	return ok && strings.EqualFold(strings.TrimSpace(tag), SkipFileTag)
+}
@@ -0,0 +1,94 @@
+// nolint:testpackage
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SkipMarkerTestSuite defines the test suite for skip-file marker detection.
+type SkipMarkerTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+// SetupTest creates a fresh temporary directory for each test.
+func (suite *SkipMarkerTestSuite) SetupTest() {
+	tempDir, err := os.MkdirTemp("", "test_skip_marker")
+	suite.Require().NoError(err, "Failed to create temp dir")
+	suite.tempDir = tempDir
+}
+
+// TearDownTest removes the temporary directory.
+func (suite *SkipMarkerTestSuite) TearDownTest() {
+	if suite.tempDir != "" {
+		os.RemoveAll(suite.tempDir)
+	}
+}
+
+// writeFile creates a file with the given content inside the temp directory.
+func (suite *SkipMarkerTestSuite) writeFile(name, content string) string {
+	path := filepath.Join(suite.tempDir, name)
+	suite.Require().NoError(os.MkdirAll(filepath.Dir(path), 0755))
+	suite.Require().NoError(os.WriteFile(path, []byte(content), 0644), "Failed to create file %s", name)
+	return path
+}
+
+// TestHeaderMarkers tests detection of the marker in common comment syntaxes.
+func (suite *SkipMarkerTestSuite) TestHeaderMarkers() {
+	cases := map[string]bool{
+		"// aicodereader:skip-file\npackage main\n":                             true,
+		"//go:build linux\n\n// aicodereader:skip-file\npackage main\n":         true,
+		"#!/usr/bin/env python\n# aicodereader:skip-file\nprint(1)\n":           true,
+		"/* aicodereader:skip-file */\nint main() {}\n":                         true,
+		"<!-- aicodereader:skip-file -->\n<html></html>\n":                      true,
+		"-- aicodereader:skip-file\nSELECT 1;\n":                                true,
+		"/*\n * SPDX-License-Identifier: MIT\n * aicodereader:skip-file\n */\n": true,
+		"// SPDX-License-Identifier: MIT\n// AICodeReader-Skip: vendored\n":     true,
+		"# aicodereader-skip: third-party\nimport os\n":                         true,
+		"// SPDX-License-Identifier: Apache-2.0\npackage main\n":                false,
+		"// AICodeReader-Skipped files are listed in docs\npackage main\n":      false,
+		"package main\n// aicodereader:skip-file\n":                             false,
+		"const marker = \"aicodereader:skip-file\"\n":                           false,
+		"// regular header\npackage main\n":                                     false,
+		"":                                                                      false,
+	}
+
+	for content, expected := range cases {
+		suite.Equal(expected, hasSkipMarker(strings.NewReader(content)), "content: %q", content)
+	}
+}
+
+// TestHasSkipMarkerMissingFile tests that unreadable files report an error.
+func (suite *SkipMarkerTestSuite) TestHasSkipMarkerMissingFile() {
+	skip, err := HasSkipMarker(filepath.Join(suite.tempDir, "missing.go"))
+	suite.Error(err, "Should return error for missing file")
+	suite.False(skip)
+}
+
+// TestGetSourceListSkipsMarkedFiles tests that marked files are excluded
+// regardless of discovery options.
+func (suite *SkipMarkerTestSuite) TestGetSourceListSkipsMarkedFiles() {
+	suite.writeFile("main.go", "package main\n")
+	suite.writeFile("generated.go", "// aicodereader:skip-file\npackage main\n")
+	suite.writeFile("vendor/lib.py", "# aicodereader:skip-file\nimport os\n")
+
+	options := &GetSourceListOptions{
+		RespectGitignore: false,
+		IncludeHidden:    true,
+	}
+	files, err := GetSourceList(suite.tempDir, options)
+	suite.Require().NoError(err, "GetSourceList failed")
+
+	suite.Equal([]string{filepath.Join(suite.tempDir, "main.go")}, files,
+		"Files carrying the skip marker should be excluded")
+}
+
+// TestSkipMarker runs the skip marker test suite.
+func TestSkipMarker(t *testing.T) {
+	suite.Run(t, new(SkipMarkerTestSuite))
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/JackDrogon/aicodereader/pkgs/lang"
+)
+
+// languageSniffBytes is how much of a file is kept for lang.Detect, which
+// only needs the shebang line.
+const languageSniffBytes = 4096
+
+// SourceEntry describes a file found by GetSourceEntries.
+type SourceEntry struct {
+	// Path is as returned by GetSourceList.
+	Path    string
+	Size    int64
+	ModTime time.Time
+	// Language is detected by lang.Detect, empty if unknown.
+	Language string
+	// Lines counts a final line without a trailing newline.
+	Lines int
+}
+
+// GetSourceEntries is like GetSourceList but describes each file, so callers
+// that need sizes, languages or line counts do not have to stat and read
+// every file again.
+func GetSourceEntries(dir string, options *GetSourceListOptions) ([]SourceEntry, error) {
+	return GetSourceEntriesContext(context.Background(), dir, options)
+}
+
+// GetSourceEntriesContext is like GetSourceEntries but stops when ctx is
+// cancelled, returning ctx's error. A scan stopped at MaxFiles returns the
+// entries of the files found with its *TruncatedError.
+func GetSourceEntriesContext(ctx context.Context, dir string, options *GetSourceListOptions) ([]SourceEntry, error) {
+	paths, err := GetSourceListContext(ctx, dir, options)
+	var truncated *TruncatedError
+	if err != nil && !errors.As(err, &truncated) {
+		return nil, err
+	}
+
+	entries := make([]SourceEntry, 0, len(paths))
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		entry, err := readSourceEntry(path)
+		if err != nil {
+			// The file changed or disappeared since the walk
+			log.Printf("WARNING: Skipping unreadable file %q: %v", path, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, err
+}
+
+// readSourceEntry describes the file at path, reading it once to count its
+// lines.
+func readSourceEntry(path string) (SourceEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SourceEntry{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return SourceEntry{}, err
+	}
+	entry := SourceEntry{Path: path, Size: info.Size(), ModTime: info.ModTime()}
+
+	buf := make([]byte, 32*1024)
+	var head []byte
+	var last byte
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if len(head) < languageSniffBytes {
+				head = append(head, buf[:min(n, languageSniffBytes-len(head))]...)
+			}
+			entry.Lines += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return SourceEntry{}, err
+		}
+	}
+	if len(head) > 0 && last != '\n' {
+		entry.Lines++
+	}
+
+	entry.Language = lang.Detect(path, head)
+	return entry, nil
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// sparseCheckout selects the files of a partially checked-out repository.
+type sparseCheckout struct {
+	// root is the absolute path of the work tree.
+	root     string
+	patterns *ignore.GitIgnore
+}
+
+// loadSparseCheckout reads the sparse-checkout patterns of the repository
+// containing dir. It returns nil if dir is not in a repository or the
+// repository has no $GIT_DIR/info/sparse-checkout file. Git leaves "/*" in
+// that file when sparse checkout is disabled, so an existing file is used
+// without consulting core.sparseCheckout.
+func loadSparseCheckout(dir string) *sparseCheckout {
+	repo, found := FindRepository(dir)
+	if !found || repo.WorkTree == "" {
+		return nil
+	}
+
+	patterns, err := ignore.CompileIgnoreFile(filepath.Join(repo.GitDir, "info", "sparse-checkout"))
+	if err != nil {
+		return nil
+	}
+	return &sparseCheckout{root: repo.WorkTree, patterns: patterns}
+}
+
+// Includes reports whether the file at path, absolute, is inside the
+// checkout. Both cone and non-cone patterns use gitignore syntax, with a
+// match selecting a file instead of excluding it.
+func (s *sparseCheckout) Includes(path string) bool {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return true
+	}
+	return s.patterns.MatchesPath(filepath.ToSlash(rel))
+}